@@ -68,6 +68,8 @@ func (bot *Bot) StartAPIServer(port string) {
 
 	r.GET("/open/link", handleGetOpenAmongUsCapture(bot))
 
+	r.GET("/capture/ws", handleCaptureWebSocket(bot))
+
 	// TODO add endpoints for notable player information, like total games played, num wins, etc
 
 	// TODO properly configure CORS -_-
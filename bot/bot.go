@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"github.com/automuteus/automuteus/v8/bot/command"
 	"github.com/automuteus/automuteus/v8/bot/tokenprovider"
+	"github.com/automuteus/automuteus/v8/internal/logging"
 	"github.com/automuteus/automuteus/v8/internal/server"
 	"github.com/automuteus/automuteus/v8/pkg/amongus"
 	"github.com/automuteus/automuteus/v8/pkg/discord"
@@ -18,13 +19,20 @@ import (
 	"github.com/automuteus/automuteus/v8/storage"
 	"github.com/bwmarrin/discordgo"
 	"github.com/top-gg/go-dbl"
-	"log"
 	"os"
 	"strconv"
 	"sync"
 	"time"
 )
 
+const (
+	// ResubscribeAttempts is how many more times the bot tries to resubscribe to an active game whose state was locked
+	ResubscribeAttempts = 5
+
+	// ResubscribeRetryInterval is how long the bot waits between attempts to resubscribe to an active game
+	ResubscribeRetryInterval = time.Second * 30
+)
+
 type Bot struct {
 	version  string
 	commit   string
@@ -40,10 +48,25 @@ type Bot struct {
 
 	ChannelsMapLock sync.RWMutex
 
+	// mapping of the connect codes of the games this process is subscribed to, to their guild IDs
+	runningGames map[string]string
+	// when each running game's subscription loop last went around, to tell whether it's stuck
+	runningGameBeats map[string]time.Time
+	runningGamesLock sync.Mutex
+	// closed on shutdown, so no game's subscription or voice state update mutes anyone after the players are unmuted
+	stopping     chan struct{}
+	stoppingOnce sync.Once
+	// the running games' subscription loops, so shutdown can wait for them to stop
+	subscriptions sync.WaitGroup
+
 	PrimarySession *discordgo.Session
 
 	TokenProvider *tokenprovider.TokenProvider
 
+	// closed once the TokenProvider is set
+	tokenProviderReady chan struct{}
+	tokenProviderOnce  sync.Once
+
 	TopGGClient *dbl.Client
 
 	RedisInterface *RedisInterface
@@ -55,6 +78,15 @@ type Bot struct {
 	logPath string
 
 	captureTimeout int
+
+	// name this process reads job streams as; unique per process so pending jobs can be attributed and reclaimed
+	jobConsumer string
+
+	// key for the tokens capture clients use to connect directly to the API server
+	captureSecret []byte
+
+	// where the side effects of processing games' jobs go
+	sink gameSink
 }
 
 // MakeAndStartBot does what it sounds like
@@ -62,16 +94,27 @@ type Bot struct {
 func MakeAndStartBot(version, commit, botToken, topGGToken, url, emojiGuildID string, numShards, shardID int, redisInterface *RedisInterface, storageInterface *storage.StorageInterface, psql *storageutils.PsqlInterface, logPath string) *Bot {
 	dg, err := discordgo.New("Bot " + botToken)
 	if err != nil {
-		log.Println("error creating Discord session,", err)
+		logger.Error("Error creating Discord session", err)
 		return nil
 	}
 
 	if numShards > 1 {
-		log.Printf("Identifying to the Discord API with %d total shards, and shard ID=%d\n", numShards, shardID)
+		logger.Info("Identifying to the Discord API as a shard", "shards", numShards, logging.ShardIDKey, shardID)
 		dg.ShardCount = numShards
 		dg.ShardID = shardID
 	}
 
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+
+	secret, err := captureSecret(redisInterface.client)
+	if err != nil {
+		logger.Error("Error loading the capture token secret", err)
+		return nil
+	}
+
 	bot := Bot{
 		version:      version,
 		commit:       commit,
@@ -80,15 +123,22 @@ func MakeAndStartBot(version, commit, botToken, topGGToken, url, emojiGuildID st
 		ConnsToGames: make(map[string]string),
 		StatusEmojis: emptyStatusEmojis(),
 
-		EndGameChannels:   make(map[string]chan EndGameMessage),
-		ChannelsMapLock:   sync.RWMutex{},
-		PrimarySession:    dg,
-		RedisInterface:    redisInterface,
-		StorageInterface:  storageInterface,
-		PostgresInterface: psql,
-		logPath:           logPath,
-		captureTimeout:    GameTimeoutSeconds,
+		EndGameChannels:    make(map[string]chan EndGameMessage),
+		ChannelsMapLock:    sync.RWMutex{},
+		runningGames:       make(map[string]string),
+		runningGameBeats:   make(map[string]time.Time),
+		stopping:           make(chan struct{}),
+		tokenProviderReady: make(chan struct{}),
+		PrimarySession:     dg,
+		RedisInterface:     redisInterface,
+		StorageInterface:   storageInterface,
+		PostgresInterface:  psql,
+		logPath:            logPath,
+		captureTimeout:     GameTimeoutSeconds,
+		jobConsumer:        fmt.Sprintf("%s:%d:%d", hostname, shardID, os.Getpid()),
+		captureSecret:      secret,
 	}
+	bot.sink = liveSink{bot: &bot}
 	dg.LogLevel = discordgo.LogInformational
 
 	dg.AddHandler(bot.handleVoiceStateChange)
@@ -99,7 +149,7 @@ func MakeAndStartBot(version, commit, botToken, topGGToken, url, emojiGuildID st
 	dg.AddHandler(bot.handleInteractionCreate)
 
 	dg.AddHandler(func(s *discordgo.Session, r *discordgo.Ready) {
-		log.Println("Bot is now online according to discord Ready handler")
+		logger.Info("Bot is now online according to Discord's Ready event", logging.ShardIDKey, s.ShardID)
 	})
 
 	dg.Identify.Intents = discordgo.MakeIntent(discordgo.IntentsGuildVoiceStates | discordgo.IntentsGuilds)
@@ -109,11 +159,11 @@ func MakeAndStartBot(version, commit, botToken, topGGToken, url, emojiGuildID st
 	// Open a websocket connection to Discord and begin listening.
 	err = dg.Open()
 	if err != nil {
-		log.Println("Could not connect Bot to the Discord Servers with error:", err)
+		logger.Error("Couldn't connect to the Discord API", err, logging.ShardIDKey, shardID)
 		return nil
 	}
 
-	log.Println("Finished identifying to the Discord API. Now ready for incoming events")
+	logger.Info("Finished identifying to the Discord API; ready for incoming events", logging.ShardIDKey, shardID)
 
 	listeningTo := os.Getenv("AUTOMUTEUS_LISTENING")
 	if listeningTo == "" {
@@ -132,17 +182,17 @@ func MakeAndStartBot(version, commit, botToken, topGGToken, url, emojiGuildID st
 	}
 	err = dg.UpdateStatusComplex(*status)
 	if err != nil {
-		log.Println(err)
+		logger.Error("Error updating the bot's status", err, logging.ShardIDKey, shardID)
 	}
 
 	if topGGToken != "" {
 		dblClient, err := dbl.NewClient(topGGToken)
 		if err != nil {
-			log.Println("Error creating Top.gg client: ", err)
+			logger.Error("Error creating Top.gg client", err)
 		}
 		bot.TopGGClient = dblClient
 	} else {
-		log.Println("No TOP_GG_TOKEN provided")
+		logger.Info("No TOP_GG_TOKEN provided")
 	}
 
 	return &bot
@@ -153,7 +203,7 @@ func (bot *Bot) InitTokenProvider(tp *tokenprovider.TokenProvider) {
 }
 
 func (bot *Bot) StartMetricsServer(nodeID string) error {
-	return server.PrometheusMetricsServer(bot.RedisInterface.client, nodeID, "2112")
+	return server.PrometheusMetricsServer(bot.RedisInterface.client, nodeID, "2112", GameTimeoutSeconds)
 }
 
 func (bot *Bot) Close() {
@@ -166,23 +216,24 @@ var EmojiLock = sync.Mutex{}
 
 func (bot *Bot) newGuild(emojiGuildID string) func(s *discordgo.Session, m *discordgo.GuildCreate) {
 	return func(s *discordgo.Session, m *discordgo.GuildCreate) {
+		l := logger.With(logging.GuildIDKey, m.Guild.ID, logging.ShardIDKey, s.ShardID)
 		gid, err := strconv.ParseUint(m.Guild.ID, 10, 64)
 		if err != nil {
-			log.Println(err)
+			l.Error("Error parsing the guild ID", err)
 		}
 
 		go func() {
-			_, err = bot.PostgresInterface.EnsureGuildExists(gid, m.Guild.Name)
+			_, err := bot.PostgresInterface.EnsureGuildExists(gid, m.Guild.Name)
 			if err != nil {
-				log.Println(err)
+				l.Error("Error adding the guild to Postgres", err)
 			}
 		}()
 
-		log.Printf("Added to new Guild, id %s, name %s", m.Guild.ID, m.Guild.Name)
+		l.Info("Added to guild", "name", m.Guild.Name)
 		bot.RedisInterface.AddUniqueGuildCounter(m.Guild.ID)
 
 		if emojiGuildID == "" {
-			log.Println("[This is not an error] No explicit guildID provided for emojis; using the current guild default")
+			l.Info("No guild provided for emojis; using this guild")
 			emojiGuildID = m.Guild.ID
 		}
 		// only check/add emojis to the server denoted for emojis, OR, this server that we picked as a fallback above ^
@@ -193,7 +244,7 @@ func (bot *Bot) newGuild(emojiGuildID string) func(s *discordgo.Session, m *disc
 		if bot.StatusEmojis.isEmpty() {
 			allEmojis, err := s.GuildEmojis(emojiGuildID)
 			if err != nil {
-				log.Println(err)
+				l.Error("Error fetching the emoji guild's emojis", err, "emoji_guild_id", emojiGuildID)
 			} else {
 				bot.verifyEmojis(s, emojiGuildID, true, allEmojis, uploadMissingEmojis)
 				bot.verifyEmojis(s, emojiGuildID, false, allEmojis, uploadMissingEmojis)
@@ -201,6 +252,12 @@ func (bot *Bot) newGuild(emojiGuildID string) func(s *discordgo.Session, m *disc
 		}
 		EmojiLock.Unlock()
 
+		// fetch these first; loading the active games clears them out
+		orphans := bot.RedisInterface.LoadTimedOutGames(m.Guild.ID)
+		if len(orphans) > 0 {
+			go bot.cleanUpOrphanedGames(m.Guild.ID, orphans)
+		}
+
 		games := bot.RedisInterface.LoadAllActiveGames(m.Guild.ID)
 
 		for _, connCode := range games {
@@ -208,43 +265,83 @@ func (bot *Bot) newGuild(emojiGuildID string) func(s *discordgo.Session, m *disc
 				GuildID:     m.Guild.ID,
 				ConnectCode: connCode,
 			}
-			lock, dgs := bot.RedisInterface.GetDiscordGameStateAndLock(gsr)
-			for lock == nil {
-				lock, dgs = bot.RedisInterface.GetDiscordGameStateAndLock(gsr)
+			err := bot.resubscribeToGame(gsr)
+			if err != nil {
+				bot.gameLogger(gsr.GuildID, gsr.ConnectCode).Warn("Couldn't lock an active game to resubscribe to it; retrying later", "error", err)
+				go bot.retryResubscribe(gsr)
 			}
-			if dgs != nil && dgs.ConnectCode != "" {
-				log.Println("Resubscribing to Redis events for an old game: " + connCode)
-				killChan := make(chan EndGameMessage)
-				go bot.SubscribeToGameByConnectCode(gsr.GuildID, dgs.ConnectCode, killChan)
-				dgs.Subscribed = true
+		}
+	}
+}
 
-				bot.RedisInterface.SetDiscordGameState(dgs, lock)
+// resubscribeToGame subscribes to the jobs of a game that was active before the bot (re)connected to its guild
+func (bot *Bot) resubscribeToGame(gsr GameStateRequest) error {
+	lockCtx, cancel := context.WithTimeout(ctx, GameStateLockTimeout)
+	defer cancel()
+	lock, dgs, err := bot.RedisInterface.GetDiscordGameStateAndLock(lockCtx, gsr)
+	if err != nil {
+		return err
+	}
+	if dgs == nil || dgs.ConnectCode == "" {
+		bot.RedisInterface.SetDiscordGameState(nil, lock)
+		return nil
+	}
+	bot.gameLogger(gsr.GuildID, dgs.ConnectCode).Info("Resubscribing to Redis events for an old game")
+	killChan := make(chan EndGameMessage)
+	go bot.SubscribeToGameByConnectCode(gsr.GuildID, dgs.ConnectCode, killChan)
+	dgs.Subscribed = true
 
-				bot.ChannelsMapLock.Lock()
-				bot.EndGameChannels[dgs.ConnectCode] = killChan
-				bot.ChannelsMapLock.Unlock()
-			}
-			lock.Release(ctx)
+	bot.RedisInterface.SetDiscordGameState(dgs, lock)
+
+	bot.ChannelsMapLock.Lock()
+	bot.EndGameChannels[dgs.ConnectCode] = killChan
+	bot.ChannelsMapLock.Unlock()
+	return nil
+}
+
+// retryResubscribe keeps trying to resubscribe to a game whose state was locked, until it's subscribed to here or the
+// game expires
+func (bot *Bot) retryResubscribe(gsr GameStateRequest) {
+	l := bot.gameLogger(gsr.GuildID, gsr.ConnectCode)
+	for attempt := 1; attempt <= ResubscribeAttempts; attempt++ {
+		time.Sleep(ResubscribeRetryInterval)
+		bot.runningGamesLock.Lock()
+		_, running := bot.runningGames[gsr.ConnectCode]
+		bot.runningGamesLock.Unlock()
+		if running {
+			return
+		}
+		err := bot.resubscribeToGame(gsr)
+		if err == nil {
+			return
 		}
+		l.Warn("Couldn't lock an active game to resubscribe to it", "error", err, "attempt", attempt)
 	}
+	l.Warn("Gave up resubscribing to an active game; it's cleaned up once it times out", "attempts", ResubscribeAttempts)
 }
 
 func (bot *Bot) leaveGuild(_ *discordgo.Session, m *discordgo.GuildDelete) {
-	log.Println("Bot was removed from Guild " + m.ID)
+	l := logger.With(logging.GuildIDKey, m.ID, logging.ShardIDKey, bot.shardID())
+	l.Info("Removed from guild")
 	bot.RedisInterface.LeaveUniqueGuildCounter(m.ID)
 
 	err := bot.StorageInterface.DeleteGuildSettings(m.ID)
 	if err != nil {
-		log.Println(err)
+		l.Error("Error deleting the guild's settings", err)
 	}
 }
 
 func (bot *Bot) forceEndGame(gsr GameStateRequest) {
 	// lock because we don't want anyone else modifying while we delete
-	lock, dgs := bot.RedisInterface.GetDiscordGameStateAndLock(gsr)
-
-	for lock == nil {
-		lock, dgs = bot.RedisInterface.GetDiscordGameStateAndLock(gsr)
+	lockCtx, cancel := context.WithTimeout(ctx, GameStateLockTimeout)
+	lock, dgs, err := bot.RedisInterface.GetDiscordGameStateAndLock(lockCtx, gsr)
+	cancel()
+	if err != nil {
+		// the game is ending regardless, so clean it up without the lock
+		dgs = bot.RedisInterface.GetReadOnlyDiscordGameState(gsr)
+		if dgs == nil {
+			return
+		}
 	}
 
 	deleted := dgs.DeleteGameStateMsg(bot.PrimarySession, true)
@@ -261,41 +358,35 @@ func (bot *Bot) forceEndGame(gsr GameStateRequest) {
 }
 
 func MessageDeleteWorker(s *discordgo.Session, msgChannelID, msgID string, waitDur time.Duration) {
-	log.Printf("Message worker is sleeping for %s before deleting message", waitDur.String())
+	l := logger.With("channel_id", msgChannelID, "message_id", msgID)
+	l.Debug("Waiting to delete message", "wait", waitDur)
 	time.Sleep(waitDur)
 	err := s.ChannelMessageDelete(msgChannelID, msgID)
 	if err != nil {
-		log.Println(err)
+		l.Error("Error deleting message", err)
 	}
 }
 
-func (bot *Bot) RefreshGameStateMessage(gsr GameStateRequest, sett *settings.GuildSettings) bool {
-	lock, dgs := bot.RedisInterface.GetDiscordGameStateAndLock(gsr)
-	for lock == nil {
-		lock, dgs = bot.RedisInterface.GetDiscordGameStateAndLock(gsr)
+// RefreshGameStateMessage replaces the game's message with a new one, and returns whether the game has a message. It
+// waits for the game state lock until ctx is done (or GameStateLockTimeout, without a deadline)
+func (bot *Bot) RefreshGameStateMessage(ctx context.Context, gsr GameStateRequest, sett *settings.GuildSettings) (bool, error) {
+	lock, dgs, err := bot.sink.LockGameState(ctx, gsr)
+	if err != nil {
+		return false, err
 	}
 
-	// don't try to edit this message, because we're about to delete it
-	RemovePendingDGSEdit(dgs.GameStateMsg.MessageID)
-
 	// note, this checks the variables being set, not whether or not the actual Discord message still exists
 	gameExists := dgs.GameStateMsg.Exists()
 	if !gameExists {
-		return false // no-op; no active game to refresh
+		// release the lock
+		bot.sink.SetGameState(nil, lock)
+		return false, nil // no-op; no active game to refresh
 	}
 
-	deleted := dgs.DeleteGameStateMsg(bot.PrimarySession, false) // delete the old message
-	created := dgs.CreateMessage(bot.PrimarySession, bot.gameStateResponse(dgs, sett), dgs.GameStateMsg.MessageChannelID, dgs.GameStateMsg.LeaderID)
-
-	if deleted && created {
-		go server.RecordDiscordRequests(bot.RedisInterface.client, server.MessageCreateDelete, 2)
-	} else if deleted || created {
-		go server.RecordDiscordRequests(bot.RedisInterface.client, server.MessageCreateDelete, 1)
-	}
-
-	bot.RedisInterface.SetDiscordGameState(dgs, lock)
+	bot.sink.ReplaceGameMessage(dgs, bot.gameStateResponse(dgs, sett))
+	bot.sink.SetGameState(dgs, lock)
 	// if for whatever reason the message failed to create, this would catch it
-	return dgs.GameStateMsg.Exists()
+	return dgs.GameStateMsg.Exists(), nil
 }
 
 func (bot *Bot) getInfo() command.BotInfo {
@@ -334,7 +425,7 @@ func linkPlayer(redis *RedisInterface, dgs *GameState, userID, color string) (co
 		if foundID != "" {
 			err := redis.AddUsernameLink(dgs.GuildID, userID, auData.Name)
 			if err != nil {
-				log.Println(err)
+				logger.Error("Error saving username link", err, logging.GuildIDKey, dgs.GuildID, logging.ConnectCodeKey, dgs.ConnectCode, "user_id", userID)
 			}
 			return command.LinkSuccess, nil
 		} else {
@@ -380,7 +471,7 @@ func (bot *Bot) newGame(dgs *GameState) (_ command.NewStatus, activeGames int64)
 		premStatus, days, err := bot.PostgresInterface.GetGuildOrUserPremiumStatus(
 			bot.official, bot.TopGGClient, dgs.GuildID, dgs.GameStateMsg.LeaderID)
 		if err != nil {
-			log.Println("Error in /newgame get premium:", err)
+			bot.matchLogger(dgs).Error("Error fetching premium status for a new game", err)
 		}
 		premTier := premium.FreeTier
 		if !premium.IsExpired(premStatus, days) {
@@ -0,0 +1,280 @@
+package bot
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/automuteus/automuteus/v8/internal/logging"
+	"github.com/automuteus/automuteus/v8/pkg/capture"
+	"github.com/automuteus/automuteus/v8/pkg/rediskey"
+	"github.com/automuteus/automuteus/v8/pkg/task"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/gorilla/websocket"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	captureWriteTimeout = 10 * time.Second
+	capturePongTimeout  = 60 * time.Second
+	capturePingInterval = capturePongTimeout / 2
+	captureMaxFrameSize = 64 * 1024
+	captureMaxSourceLen = 32
+)
+
+var captureUpgrader = websocket.Upgrader{
+	// capture clients aren't browsers; they authenticate with the token instead
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// captureSecret is the key capture tokens are derived with. It has to be the same for every shard, so when
+// CAPTURE_TOKEN_SECRET isn't set, the first shard to start generates one and saves it in Redis for the others
+func captureSecret(client *redis.Client) ([]byte, error) {
+	if secret := os.Getenv("CAPTURE_TOKEN_SECRET"); secret != "" {
+		return []byte(secret), nil
+	}
+
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return nil, err
+	}
+	generated, err := client.SetNX(ctx, rediskey.CaptureTokenSecret, hex.EncodeToString(b), 0).Result()
+	if err != nil {
+		return nil, err
+	}
+	if generated {
+		logger.Warn("CAPTURE_TOKEN_SECRET isn't set; generated a secret and saved it in Redis, so capture tokens will change if Redis is reset")
+	}
+	secret, err := client.Get(ctx, rediskey.CaptureTokenSecret).Result()
+	if err != nil {
+		return nil, err
+	}
+	return []byte(secret), nil
+}
+
+// captureToken is the token a capture client has to present to connect directly to the bot with a connect code
+func (bot *Bot) captureToken(connectCode string) string {
+	mac := hmac.New(sha256.New, bot.captureSecret)
+	mac.Write([]byte(connectCode))
+	return hex.EncodeToString(mac.Sum(nil))[0:16]
+}
+
+func (bot *Bot) validCaptureToken(connectCode, token string) bool {
+	return hmac.Equal([]byte(bot.captureToken(connectCode)), []byte(token))
+}
+
+// captureConn is a capture client connected directly to the bot. Writes are serialized, because gorilla/websocket
+// only supports one concurrent writer
+type captureConn struct {
+	conn *websocket.Conn
+	// source tells the client's jobs apart from those of other clients attached to the same game
+	source    string
+	writeLock sync.Mutex
+}
+
+func randomCaptureSource() string {
+	b := make([]byte, 4)
+	_, err := rand.Read(b)
+	if err != nil {
+		logger.Error("Error generating a capture source", err)
+	}
+	return hex.EncodeToString(b)
+}
+
+func (cc *captureConn) write(messageType int, data []byte) error {
+	cc.writeLock.Lock()
+	defer cc.writeLock.Unlock()
+	err := cc.conn.SetWriteDeadline(time.Now().Add(captureWriteTimeout))
+	if err != nil {
+		return err
+	}
+	return cc.conn.WriteMessage(messageType, data)
+}
+
+// CaptureWebSocket godoc
+// @Summary Connect a capture client
+// @Schemes GET
+// @Description Connect a capture client directly to the bot over a WebSocket, instead of through a broker.
+// @Description The client sends capture.ClientFrame messages, and receives capture.ServerFrame messages
+// @Tags capture
+// @Param connectCode query string true "Connect Code"
+// @Param token query string true "Capture Token"
+// @Param source query string false "Name for the capture client, when several are attached to the same game"
+// @Success 101
+// @Failure 400 {object} HttpError
+// @Failure 401 {object} HttpError
+// @Router /capture/ws [get]
+func handleCaptureWebSocket(bot *Bot) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		connectCode := c.Query("connectCode")
+		if len(connectCode) != 8 {
+			c.JSON(http.StatusBadRequest, HttpError{
+				StatusCode: http.StatusBadRequest,
+				Error:      "invalid connect code",
+			})
+			return
+		}
+		if !bot.validCaptureToken(connectCode, c.Query("token")) {
+			c.JSON(http.StatusUnauthorized, HttpError{
+				StatusCode: http.StatusUnauthorized,
+				Error:      "invalid token",
+			})
+			return
+		}
+		conn, err := captureUpgrader.Upgrade(c.Writer, c.Request, nil)
+		if err != nil {
+			// the upgrader already responded with an error
+			logger.Warn("Error upgrading capture client connection", logging.ConnectCodeKey, connectCode, "error", err)
+			return
+		}
+		source := c.Query("source")
+		if source == "" || len(source) > captureMaxSourceLen {
+			source = randomCaptureSource()
+		}
+		bot.serveCaptureConn(connectCode, &captureConn{conn: conn, source: source})
+	}
+}
+
+// serveCaptureConn translates frames from a capture client into jobs for the game, and forwards mute/deafen tasks
+// for the game to the client, until the client disconnects
+func (bot *Bot) serveCaptureConn(connectCode string, cc *captureConn) {
+	defer cc.conn.Close()
+	l := bot.captureLogger(connectCode, cc.source)
+	l.Info("Capture client connected directly")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	bot.pushCaptureConnection(ctx, connectCode, cc.source, true)
+	defer func() {
+		// the context is cancelled by now
+		bot.pushCaptureConnection(context.Background(), connectCode, cc.source, false)
+		l.Info("Capture client disconnected")
+	}()
+
+	go bot.forwardCaptureTasks(ctx, connectCode, cc)
+
+	cc.conn.SetReadLimit(captureMaxFrameSize)
+	cc.conn.SetReadDeadline(time.Now().Add(capturePongTimeout))
+	cc.conn.SetPongHandler(func(string) error {
+		return cc.conn.SetReadDeadline(time.Now().Add(capturePongTimeout))
+	})
+
+	for {
+		_, msg, err := cc.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				l.Warn("Capture client closed the connection unexpectedly", "error", err)
+			}
+			return
+		}
+		var frame capture.ClientFrame
+		err = json.Unmarshal(msg, &frame)
+		if err != nil {
+			l.Warn("Malformed frame from capture client", "error", err)
+			continue
+		}
+		switch {
+		case frame.Job != nil:
+			bot.pushCaptureJob(ctx, connectCode, cc.source, frame.Job)
+		case frame.TaskAck != nil:
+			err = bot.RedisInterface.client.Publish(ctx, rediskey.CompleteTask(frame.TaskAck.TaskID), strconv.FormatBool(frame.TaskAck.Success)).Err()
+			if err != nil {
+				l.Error("Error publishing task acknowledgement", err)
+			}
+		}
+	}
+}
+
+// pushCaptureConnection pushes the job a broker would when a capture client connects or disconnects
+func (bot *Bot) pushCaptureConnection(ctx context.Context, connectCode, source string, connected bool) {
+	job, err := task.NewJob(task.ConnectionJob, strconv.FormatBool(connected))
+	if err != nil {
+		bot.captureLogger(connectCode, source).Error("Error creating connection job", err)
+		return
+	}
+	job.Source = source
+	err = task.Push(ctx, bot.RedisInterface.client, connectCode, job)
+	if err != nil {
+		bot.captureLogger(connectCode, source).Error("Error pushing connection job", err)
+	}
+}
+
+// pushCaptureJob validates a job from a capture client, and pushes it exactly like a broker would. The client's
+// sequence number is kept; it counts from 1 again each time the client connects, after the connection job pushed for it
+func (bot *Bot) pushCaptureJob(ctx context.Context, connectCode, source string, raw []byte) {
+	job, err := task.DecodeJob(raw)
+	var rejectErr *task.RejectError
+	if errors.As(err, &rejectErr) {
+		recordJobReject(bot.captureLogger(connectCode, source), rejectErr)
+		return
+	}
+	job.Source = source
+	err = task.Push(ctx, bot.RedisInterface.client, connectCode, job)
+	if err != nil {
+		bot.captureLogger(connectCode, source).Error("Error pushing capture job", err)
+	}
+}
+
+// forwardCaptureTasks sends the mute/deafen tasks published for the game to the capture client, as long as it's the
+// game's primary source, and keeps the connection alive with pings
+func (bot *Bot) forwardCaptureTasks(ctx context.Context, connectCode string, cc *captureConn) {
+	l := bot.captureLogger(connectCode, cc.source)
+	pubsub := bot.RedisInterface.client.Subscribe(ctx, rediskey.TasksList(connectCode))
+	defer pubsub.Close()
+	// if we can't write to the client, closing the connection also stops the read loop
+	defer cc.conn.Close()
+	tasks := pubsub.Channel()
+
+	ticker := time.NewTicker(capturePingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := cc.write(websocket.PingMessage, nil)
+			if err != nil {
+				l.Warn("Error pinging capture client", "error", err)
+				return
+			}
+		case msg, ok := <-tasks:
+			if !ok {
+				return
+			}
+			primary, err := task.GetPrimarySource(ctx, bot.RedisInterface.client, connectCode)
+			if err != nil {
+				l.Error("Error fetching the primary capture source", err)
+			} else if primary != "" && primary != cc.source {
+				// only one client should apply (and acknowledge) each task
+				continue
+			}
+			var modifyTask task.ModifyTask
+			err = json.Unmarshal([]byte(msg.Payload), &modifyTask)
+			if err != nil {
+				l.Error("Malformed task for capture client", err)
+				continue
+			}
+			jBytes, err := json.Marshal(capture.ServerFrame{Task: &modifyTask})
+			if err != nil {
+				l.Error("Error encoding task for capture client", err)
+				continue
+			}
+			err = cc.write(websocket.TextMessage, jBytes)
+			if err != nil {
+				l.Warn("Error sending task to capture client", "error", err)
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/automuteus/automuteus/v8/pkg/capture"
+	"github.com/automuteus/automuteus/v8/pkg/rediskey"
+	"github.com/automuteus/automuteus/v8/pkg/task"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/gorilla/websocket"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testCaptureCode = "ABCDEFGH"
+
+func newCaptureTestBot(t *testing.T) *Bot {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		client.Close()
+	})
+	return &Bot{
+		RedisInterface: &RedisInterface{client: client},
+		captureSecret:  []byte("secret"),
+	}
+}
+
+func TestCaptureSecret(t *testing.T) {
+	bot := newCaptureTestBot(t)
+	client := bot.RedisInterface.client
+
+	t.Setenv("CAPTURE_TOKEN_SECRET", "configured")
+	secret, err := captureSecret(client)
+	if err != nil || string(secret) != "configured" {
+		t.Errorf("Expected the configured secret, got %s, %v", secret, err)
+	}
+	if client.Exists(ctx, rediskey.CaptureTokenSecret).Val() != 0 {
+		t.Error("Expected nothing to be saved in Redis when the secret is configured")
+	}
+
+	t.Setenv("CAPTURE_TOKEN_SECRET", "")
+	generated, err := captureSecret(client)
+	if err != nil || len(generated) != 64 {
+		t.Fatalf("Expected a generated 32 byte hex secret, got %s, %v", generated, err)
+	}
+	// every other shard gets the secret the first one generated
+	secret, err = captureSecret(client)
+	if err != nil || string(secret) != string(generated) {
+		t.Errorf("Expected the saved secret %s, got %s, %v", generated, secret, err)
+	}
+}
+
+func TestValidCaptureToken(t *testing.T) {
+	bot := newCaptureTestBot(t)
+	token := bot.captureToken(testCaptureCode)
+	if len(token) != 16 {
+		t.Fatalf("Expected a 16 character token, got %s", token)
+	}
+
+	other := Bot{captureSecret: []byte("other")}
+	tests := []struct {
+		name  string
+		code  string
+		token string
+		valid bool
+	}{
+		{"token for the code", testCaptureCode, token, true},
+		{"empty", testCaptureCode, "", false},
+		{"too short", testCaptureCode, token[:15], false},
+		{"too long", testCaptureCode, token + "0", false},
+		{"wrong", testCaptureCode, strings.Repeat("0", 16), false},
+		{"token for another code", "HGFEDCBA", token, false},
+		{"token from another secret", testCaptureCode, other.captureToken(testCaptureCode), false},
+	}
+	for _, test := range tests {
+		if bot.validCaptureToken(test.code, test.token) != test.valid {
+			t.Errorf("%s: expected valid to be %v", test.name, test.valid)
+		}
+	}
+}
+
+func captureServer(bot *Bot) *httptest.Server {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/capture/ws", handleCaptureWebSocket(bot))
+	return httptest.NewServer(r)
+}
+
+func captureURL(srv *httptest.Server, code, token, source string) string {
+	v := url.Values{}
+	v.Set("connectCode", code)
+	v.Set("token", token)
+	v.Set("source", source)
+	return "ws" + strings.TrimPrefix(srv.URL, "http") + "/capture/ws?" + v.Encode()
+}
+
+func TestHandleCaptureWebSocket_rejected(t *testing.T) {
+	bot := newCaptureTestBot(t)
+	srv := captureServer(bot)
+	defer srv.Close()
+
+	tests := []struct {
+		name   string
+		code   string
+		token  string
+		status int
+	}{
+		{"short connect code", "ABC", bot.captureToken("ABC"), http.StatusBadRequest},
+		{"wrong token", testCaptureCode, strings.Repeat("0", 16), http.StatusUnauthorized},
+		{"token for another code", testCaptureCode, bot.captureToken("HGFEDCBA"), http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		conn, resp, err := websocket.DefaultDialer.Dial(captureURL(srv, test.code, test.token, "a"), nil)
+		if err == nil {
+			conn.Close()
+			t.Errorf("%s: expected the connection to be refused", test.name)
+			continue
+		}
+		if resp == nil || resp.StatusCode != test.status {
+			t.Errorf("%s: expected status %d, got %v", test.name, test.status, resp)
+		}
+	}
+}
+
+// waitForJobs waits until n jobs have been pushed for the connect code, and returns them
+func waitForJobs(t *testing.T, client *redis.Client, n int) []task.Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		msgs, err := client.XRange(ctx, rediskey.JobStream(testCaptureCode), "-", "+").Result()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(msgs) >= n || time.Now().After(deadline) {
+			var jobs []task.Job
+			for _, msg := range msgs {
+				job, err := task.DecodeJob([]byte(msg.Values["job"].(string)))
+				if err != nil {
+					t.Fatal(err)
+				}
+				jobs = append(jobs, job)
+			}
+			return jobs
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestServeCaptureConn(t *testing.T) {
+	bot := newCaptureTestBot(t)
+	client := bot.RedisInterface.client
+	srv := captureServer(bot)
+	defer srv.Close()
+
+	acks := client.Subscribe(context.Background(), rediskey.CompleteTask("task"))
+	defer acks.Close()
+	if _, err := acks.Receive(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(captureURL(srv, testCaptureCode, bot.captureToken(testCaptureCode), "a"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frames := []string{
+		// the client's own source is replaced with the connection's
+		`{"job":{"version":1,"seq":1,"source":"b","type":2,"payload":"1"}}`,
+		// rejected, so never pushed
+		`{"job":{"version":1,"seq":2,"type":2,"payload":1}}`,
+		`not a frame`,
+		`{"job":{"version":1,"seq":2,"type":2,"payload":"2"}}`,
+		`{"taskAck":{"taskID":"task","success":true}}`,
+	}
+	for _, frame := range frames {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(frame)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	select {
+	case msg := <-acks.Channel():
+		if msg.Payload != "true" {
+			t.Errorf("Expected the task to be acknowledged as a success, got %s", msg.Payload)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("Expected the task acknowledgement to be published")
+	}
+	conn.Close()
+
+	jobs := waitForJobs(t, client, 4)
+	if len(jobs) != 4 {
+		t.Fatalf("Expected the connection, 2 valid jobs and the disconnection to be pushed, got %+v", jobs)
+	}
+	if jobs[0].JobType != task.ConnectionJob || !jobs[0].Connected {
+		t.Errorf("Expected a connection job first, got %+v", jobs[0])
+	}
+	for i, payload := range []string{"1", "2"} {
+		job := jobs[i+1]
+		if job.JobType != task.StateJob || job.Payload != payload || job.Seq != uint64(i+1) {
+			t.Errorf("Expected state job %d with payload %s, got %+v", i+1, payload, job)
+		}
+	}
+	if jobs[3].JobType != task.ConnectionJob || jobs[3].Connected {
+		t.Errorf("Expected a disconnection job last, got %+v", jobs[3])
+	}
+	for _, job := range jobs {
+		if job.Source != "a" {
+			t.Errorf("Expected every job to have the connection's source, got %s", job.Source)
+		}
+	}
+}
+
+func TestForwardCaptureTasks(t *testing.T) {
+	bot := newCaptureTestBot(t)
+	client := bot.RedisInterface.client
+	srv := captureServer(bot)
+	defer srv.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial(captureURL(srv, testCaptureCode, bot.captureToken(testCaptureCode), "a"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// wait for the task subscription before publishing
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		subs, _ := client.PubSubNumSub(ctx, rediskey.TasksList(testCaptureCode)).Result()
+		if subs[rediskey.TasksList(testCaptureCode)] > 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	publish := func(userID uint64) task.ModifyTask {
+		modifyTask := task.NewModifyTask(1, userID, task.PatchParams{Mute: true})
+		jBytes, err := json.Marshal(modifyTask)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := client.Publish(ctx, rediskey.TasksList(testCaptureCode), jBytes).Err(); err != nil {
+			t.Fatal(err)
+		}
+		return modifyTask
+	}
+
+	// another client is the game's primary source, so it applies the task instead
+	if err := task.SetPrimarySource(ctx, client, testCaptureCode, "b"); err != nil {
+		t.Fatal(err)
+	}
+	publish(2)
+	// give the client time to skip it before becoming primary
+	time.Sleep(100 * time.Millisecond)
+	if err := task.SetPrimarySource(ctx, client, testCaptureCode, "a"); err != nil {
+		t.Fatal(err)
+	}
+	expected := publish(3)
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var frame capture.ServerFrame
+	if err := conn.ReadJSON(&frame); err != nil {
+		t.Fatal(err)
+	}
+	if frame.Task == nil || frame.Task.TaskID != expected.TaskID {
+		t.Errorf("Expected only the task published while the client was primary to be forwarded, got %+v", frame.Task)
+	}
+}
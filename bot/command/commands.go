@@ -21,10 +21,12 @@ var All = []*discordgo.ApplicationCommand{
 	&Help,
 	&New,
 	&Refresh,
+	&Tasks,
 	&Pause,
 	&End,
 	&Link,
 	&Unlink,
+	&Spectate,
 	&Settings,
 	&Privacy,
 	&Info,
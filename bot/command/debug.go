@@ -2,19 +2,32 @@ package command
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"github.com/automuteus/automuteus/v8/bot/setting"
 	"github.com/automuteus/automuteus/v8/pkg/discord"
 	"github.com/automuteus/automuteus/v8/pkg/settings"
+	"github.com/automuteus/automuteus/v8/pkg/task"
 	"github.com/bwmarrin/discordgo"
 	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"strconv"
+	"strings"
 )
 
 const (
-	User      = "user"
-	GameState = "game-state"
-	UnmuteAll = "unmute-all"
-	Unmute    = "unmute"
+	User        = "user"
+	GameState   = "game-state"
+	UnmuteAll   = "unmute-all"
+	Unmute      = "unmute"
+	Recording   = "recording"
+	ConnectCode = "connect_code"
+	VoiceLog    = "voice-log"
+
+	// MaxVoiceLogLines is how many of the most recent voice operations /debug view voice-log lists
+	MaxVoiceLogLines = 10
+
+	// leaves room in the 2000 character limit for the rest of the message
+	maxVoiceLogLinesLength = 1700
 )
 
 var Debug = discordgo.ApplicationCommand{
@@ -44,6 +57,32 @@ var Debug = discordgo.ApplicationCommand{
 					Description: "Game State",
 					Type:        discordgo.ApplicationCommandOptionSubCommand,
 				},
+				{
+					Name:        VoiceLog,
+					Description: "Recent mute/deafen requests (admin only)",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Name:        User,
+							Description: "Only show requests for this user",
+							Type:        discordgo.ApplicationCommandOptionUser,
+							Required:    false,
+						},
+					},
+				},
+				{
+					Name:        Recording,
+					Description: "Recording of the capture events for a game (admin only)",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Name:        ConnectCode,
+							Description: "Connect code of a game in this server (defaults to the current game)",
+							Type:        discordgo.ApplicationCommandOptionString,
+							Required:    false,
+						},
+					},
+				},
 			},
 		},
 		{
@@ -80,13 +119,30 @@ var Debug = discordgo.ApplicationCommand{
 	},
 }
 
-func GetDebugParams(s *discordgo.Session, userID string, options []*discordgo.ApplicationCommandInteractionDataOption) (action string, opType string, _ string) {
+// GetDebugParams returns the subcommand, and the user it applies to. For recordings, it returns the connect code of the
+// game instead (empty for the current game)
+func GetDebugParams(s *discordgo.Session, userID string, options []*discordgo.ApplicationCommandInteractionDataOption) (action, opType, _, connectCode string) {
 	action = options[0].Name
 	if len(options[0].Options) > 0 {
 		opType = options[0].Options[0].Name
 	}
 	switch action {
 	case setting.View:
+		if opType == VoiceLog {
+			// only filter by user if one was provided
+			if len(options[0].Options[0].Options) > 0 {
+				return action, opType, options[0].Options[0].Options[0].UserValue(s).ID, ""
+			}
+			return action, opType, "", ""
+		}
+		if opType == Recording {
+			for _, v := range options[0].Options[0].Options {
+				if v.Name == ConnectCode {
+					connectCode = v.StringValue()
+				}
+			}
+			return action, opType, "", connectCode
+		}
 		if len(options[0].Options[0].Options) > 0 {
 			userID = options[0].Options[0].Options[0].UserValue(s).ID
 		}
@@ -97,7 +153,7 @@ func GetDebugParams(s *discordgo.Session, userID string, options []*discordgo.Ap
 			userID = options[0].Options[0].UserValue(s).ID
 		}
 	}
-	return action, opType, userID
+	return action, opType, userID, ""
 }
 
 func DebugResponse(operationType string, cached map[string]interface{}, stateBytes []byte, id string, err error, sett *settings.GuildSettings) *discordgo.InteractionResponse {
@@ -189,3 +245,123 @@ func DebugResponse(operationType string, cached map[string]interface{}, stateByt
 		},
 	}
 }
+
+func DebugRecordingResponse(connectCode string, recording []byte, err error, sett *settings.GuildSettings) *discordgo.InteractionResponse {
+	var content string
+	var files []*discordgo.File
+	switch {
+	case err != nil:
+		content = sett.LocalizeMessage(&i18n.Message{
+			ID:    "commands.debug.view.error",
+			Other: "Encountered an error trying to view debug information: {{.Error}}",
+		}, map[string]interface{}{
+			"Error": err.Error(),
+		})
+	case recording == nil:
+		content = sett.LocalizeMessage(&i18n.Message{
+			ID:    "commands.debug.view.recording.empty",
+			Other: "I don't have a recording for the game with connect code `{{.ConnectCode}}`",
+		}, map[string]interface{}{
+			"ConnectCode": connectCode,
+		})
+	default:
+		files = []*discordgo.File{
+			{
+				Name:        "recording-" + connectCode + ".json",
+				ContentType: "application/json",
+				Reader:      bytes.NewReader(recording),
+			},
+		}
+		content = sett.LocalizeMessage(&i18n.Message{
+			ID:    "commands.download.file.success",
+			Other: "Here's that file for you!",
+		})
+	}
+	return &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags:   1 << 6,
+			Content: content,
+			Files:   files,
+		},
+	}
+}
+
+func DebugVoiceLogResponse(ops []task.VoiceOp, err error, sett *settings.GuildSettings) *discordgo.InteractionResponse {
+	var content string
+	var files []*discordgo.File
+	switch {
+	case err != nil:
+		content = sett.LocalizeMessage(&i18n.Message{
+			ID:    "commands.debug.view.error",
+			Other: "Encountered an error trying to view debug information: {{.Error}}",
+		}, map[string]interface{}{
+			"Error": err.Error(),
+		})
+	case len(ops) == 0:
+		content = sett.LocalizeMessage(&i18n.Message{
+			ID:    "commands.debug.view.voiceLog.empty",
+			Other: "I haven't sent any mute/deafen requests here recently",
+		})
+	default:
+		var lines []string
+		length := 0
+		for i, op := range ops {
+			line := formatVoiceOp(op)
+			if i == MaxVoiceLogLines || length+len(line) > maxVoiceLogLinesLength {
+				break
+			}
+			lines = append(lines, line)
+			length += len(line) + 1
+		}
+		content = sett.LocalizeMessage(&i18n.Message{
+			ID:    "commands.debug.view.voiceLog.success",
+			Other: "The most recent mute/deafen requests, newest first (the file has all of them):\n{{.Lines}}",
+		}, map[string]interface{}{
+			"Lines": strings.Join(lines, "\n"),
+		})
+		jBytes, err := json.MarshalIndent(ops, "", "  ")
+		if err == nil {
+			files = []*discordgo.File{
+				{
+					Name:        "voice-log.json",
+					ContentType: "application/json",
+					Reader:      bytes.NewReader(jBytes),
+				},
+			}
+		}
+	}
+	return &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags:   1 << 6,
+			Content: content,
+			Files:   files,
+		},
+	}
+}
+
+// formatVoiceOp is a one-line summary of a voice operation, like
+// "<t:1650000000:T> @user mute ✅ deaf ❌ via worker in 80ms (TASKS)"
+func formatVoiceOp(op task.VoiceOp) string {
+	str := fmt.Sprintf("<t:%d:T> %s mute %s deaf %s", op.Time.Unix(), discord.MentionByUserID(strconv.FormatUint(op.UserID, 10)),
+		checkOrX(op.Mute), checkOrX(op.Deaf))
+	if op.ChannelID != "" {
+		str += " → " + discord.MentionByChannelID(op.ChannelID)
+	}
+	str += fmt.Sprintf(" via %s in %dms", op.Path, op.LatencyMs)
+	if op.Phase != "" {
+		str += " (" + op.Phase + ")"
+	}
+	if op.Error != "" {
+		str += " ⚠️ `" + op.Error + "`"
+	}
+	return str
+}
+
+func checkOrX(b bool) string {
+	if b {
+		return "✅"
+	}
+	return "❌"
+}
@@ -13,6 +13,7 @@ const (
 	UsersGames = "users_games"
 	Games      = "games"
 	GameEvents = "game_events"
+	VoiceOps   = "voice_ops"
 )
 
 var Download = discordgo.ApplicationCommand{
@@ -44,6 +45,10 @@ var Download = discordgo.ApplicationCommand{
 					Name:  GameEvents,
 					Value: GameEvents,
 				},
+				{
+					Name:  VoiceOps,
+					Value: VoiceOps,
+				},
 			},
 			Required: true,
 		},
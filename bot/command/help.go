@@ -25,6 +25,10 @@ var Help = discordgo.ApplicationCommand{
 					Name:  Refresh.Name,
 					Value: Refresh.Name,
 				},
+				{
+					Name:  Tasks.Name,
+					Value: Tasks.Name,
+				},
 				{
 					Name:  Pause.Name,
 					Value: Pause.Name,
@@ -41,6 +45,10 @@ var Help = discordgo.ApplicationCommand{
 					Name:  Unlink.Name,
 					Value: Unlink.Name,
 				},
+				{
+					Name:  Spectate.Name,
+					Value: Spectate.Name,
+				},
 				{
 					Name:  Settings.Name,
 					Value: Settings.Name,
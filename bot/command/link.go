@@ -3,6 +3,7 @@ package command
 import (
 	"github.com/automuteus/automuteus/v8/pkg/discord"
 	"github.com/automuteus/automuteus/v8/pkg/settings"
+	"github.com/automuteus/automuteus/v8/pkg/storage"
 	"github.com/bwmarrin/discordgo"
 	"github.com/nicksnyder/go-i18n/v2/i18n"
 	"strings"
@@ -16,28 +17,88 @@ const (
 	LinkNoGameData
 )
 
+const (
+	LinkColor = "color"
+	LinkAlias = "alias"
+)
+
 var Link = discordgo.ApplicationCommand{
 	Name:        "link",
-	Description: "Link a Discord User to their in-game color",
+	Description: "Link a Discord User to their in-game color, or register an in-game alias",
 	Options: []*discordgo.ApplicationCommandOption{
 		{
-			Type:        discordgo.ApplicationCommandOptionUser,
-			Name:        "user",
-			Description: "User to link",
-			Required:    true,
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        LinkColor,
+			Description: "Link a Discord User to their in-game color",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionUser,
+					Name:        "user",
+					Description: "User to link",
+					Required:    true,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "color",
+					Description: "In-game color",
+					Required:    true,
+					Choices:     colorsToCommandChoices(),
+				},
+			},
 		},
 		{
-			Type:        discordgo.ApplicationCommandOptionString,
-			Name:        "color",
-			Description: "In-game color",
-			Required:    true,
-			Choices:     colorsToCommandChoices(),
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        LinkAlias,
+			Description: "Register an in-game name, so players with that name are linked automatically",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "name",
+					Description: "In-game name",
+					Required:    true,
+					MaxLength:   32,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionUser,
+					Name:        "user",
+					Description: "User the alias is for (defaults to you)",
+					Required:    false,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionBoolean,
+					Name:        "remove",
+					Description: "Remove the alias instead",
+					Required:    false,
+				},
+			},
 		},
 	},
 }
 
-func GetLinkParams(s *discordgo.Session, options []*discordgo.ApplicationCommandInteractionDataOption) (string, string) {
-	return options[0].UserValue(s).ID, strings.ReplaceAll(strings.ToLower(options[1].StringValue()), " ", "")
+// GetLinkParams returns the subcommand, and the user and color it was run with
+func GetLinkParams(s *discordgo.Session, options []*discordgo.ApplicationCommandInteractionDataOption) (string, string, string) {
+	sub := options[0]
+	if sub.Name != LinkColor {
+		return sub.Name, "", ""
+	}
+	return sub.Name, sub.Options[0].UserValue(s).ID, strings.ReplaceAll(strings.ToLower(sub.Options[1].StringValue()), " ", "")
+}
+
+// GetLinkAliasParams returns the alias, who it's for (defaultUserID if nobody was specified), and whether it should be
+// removed
+func GetLinkAliasParams(s *discordgo.Session, options []*discordgo.ApplicationCommandInteractionDataOption, defaultUserID string) (alias, userID string, remove bool) {
+	userID = defaultUserID
+	for _, opt := range options[0].Options {
+		switch opt.Name {
+		case "name":
+			alias = strings.TrimSpace(opt.StringValue())
+		case "user":
+			userID = opt.UserValue(s).ID
+		case "remove":
+			remove = opt.BoolValue()
+		}
+	}
+	return alias, userID, remove
 }
 
 func LinkResponse(status LinkStatus, userID, color string, sett *settings.GuildSettings) *discordgo.InteractionResponse {
@@ -75,3 +136,76 @@ func LinkResponse(status LinkStatus, userID, color string, sett *settings.GuildS
 		},
 	}
 }
+
+type AliasStatus int
+
+const (
+	AliasAdded AliasStatus = iota
+	AliasRemoved
+	AliasNotFound
+	AliasInvalid
+	AliasTaken
+	AliasTooMany
+)
+
+func LinkAliasResponse(status AliasStatus, userID, alias string, aliases []string, sett *settings.GuildSettings) *discordgo.InteractionResponse {
+	var content string
+	switch status {
+	case AliasAdded:
+		content = sett.LocalizeMessage(&i18n.Message{
+			ID:    "commands.link.alias.added",
+			Other: "Players named `{{.Alias}}` will now be linked to {{.UserMention}} automatically",
+		}, map[string]interface{}{
+			"UserMention": discord.MentionByUserID(userID),
+			"Alias":       alias,
+		})
+	case AliasRemoved:
+		content = sett.LocalizeMessage(&i18n.Message{
+			ID:    "commands.link.alias.removed",
+			Other: "Removed the alias `{{.Alias}}` from {{.UserMention}}",
+		}, map[string]interface{}{
+			"UserMention": discord.MentionByUserID(userID),
+			"Alias":       alias,
+		})
+	case AliasNotFound:
+		content = sett.LocalizeMessage(&i18n.Message{
+			ID:    "commands.link.alias.notfound",
+			Other: "{{.UserMention}} doesn't have the alias `{{.Alias}}`",
+		}, map[string]interface{}{
+			"UserMention": discord.MentionByUserID(userID),
+			"Alias":       alias,
+		})
+	case AliasInvalid:
+		content = sett.LocalizeMessage(&i18n.Message{
+			ID:    "commands.link.alias.invalid",
+			Other: "`{{.Alias}}` can't be used as an alias; it needs at least one letter or number",
+		}, map[string]interface{}{
+			"Alias": alias,
+		})
+	case AliasTaken:
+		content = sett.LocalizeMessage(&i18n.Message{
+			ID:    "commands.link.alias.taken",
+			Other: "The alias `{{.Alias}}` is already registered to someone else",
+		}, map[string]interface{}{
+			"Alias": alias,
+		})
+	case AliasTooMany:
+		content = sett.LocalizeMessage(&i18n.Message{
+			ID:    "commands.link.alias.toomany",
+			Other: "{{.UserMention}} already has the maximum of {{.Max}} aliases. Remove one first!",
+		}, map[string]interface{}{
+			"UserMention": discord.MentionByUserID(userID),
+			"Max":         storage.MaxAliasesPerUser,
+		})
+	}
+	if len(aliases) > 0 && (status == AliasAdded || status == AliasRemoved || status == AliasTooMany) {
+		content += "\n" + sett.LocalizeMessage(&i18n.Message{
+			ID:    "commands.link.alias.list",
+			Other: "Aliases: {{.Aliases}}",
+		}, map[string]interface{}{
+			"Aliases": "`" + strings.Join(aliases, "`, `") + "`",
+		})
+	}
+
+	return PrivateResponse(content)
+}
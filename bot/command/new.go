@@ -20,6 +20,7 @@ type NewInfo struct {
 	MinimalURL   string
 	ApiHyperlink string
 	ConnectCode  string
+	CaptureToken string
 	ActiveGames  int64
 }
 
@@ -66,6 +67,14 @@ func NewResponse(status NewStatus, info NewInfo, sett *settings.GuildSettings) *
 						Value:  info.ConnectCode,
 						Inline: true,
 					},
+					{
+						Name: sett.LocalizeMessage(&i18n.Message{
+							ID:    "commands.new.success.token",
+							Other: "Token",
+						}),
+						Value:  info.CaptureToken,
+						Inline: true,
+					},
 				},
 			},
 		}
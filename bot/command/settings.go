@@ -1,9 +1,9 @@
 package command
 
 import (
+	"fmt"
 	"github.com/automuteus/automuteus/v8/bot/setting"
 	"github.com/bwmarrin/discordgo"
-	"log"
 )
 
 var Settings = discordgo.ApplicationCommand{
@@ -56,7 +56,7 @@ func SettingsResponse(m interface{}) *discordgo.InteractionResponse {
 	case nil:
 		// do nothing
 	default:
-		log.Printf("Incapable of processing sendMessage of type: %T", msg)
+		logger.Warn("Can't respond with a settings message of this type", "type", fmt.Sprintf("%T", msg))
 	}
 	return &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
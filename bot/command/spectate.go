@@ -0,0 +1,66 @@
+package command
+
+import (
+	"github.com/automuteus/automuteus/v8/pkg/discord"
+	"github.com/automuteus/automuteus/v8/pkg/settings"
+	"github.com/bwmarrin/discordgo"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+type SpectateStatus int
+
+const (
+	SpectateOn SpectateStatus = iota
+	SpectateOff
+	SpectateNoUser
+)
+
+var Spectate = discordgo.ApplicationCommand{
+	Name:        "spectate",
+	Description: "Start or stop spectating the game (spectators are never linked to a player)",
+	Options: []*discordgo.ApplicationCommandOption{
+		{
+			Type:        discordgo.ApplicationCommandOptionUser,
+			Name:        "user",
+			Description: "User to start or stop spectating (defaults to you)",
+			Required:    false,
+		},
+	},
+}
+
+// GetSpectateParams returns the user to toggle spectating for, which is the user running the command if they didn't
+// pick anyone
+func GetSpectateParams(s *discordgo.Session, userID string, options []*discordgo.ApplicationCommandInteractionDataOption) string {
+	if len(options) > 0 {
+		return options[0].UserValue(s).ID
+	}
+	return userID
+}
+
+func SpectateResponse(status SpectateStatus, userID string, sett *settings.GuildSettings) *discordgo.InteractionResponse {
+	var content string
+	switch status {
+	case SpectateOn:
+		content = sett.LocalizeMessage(&i18n.Message{
+			ID:    "commands.spectate.on",
+			Other: "{{.UserMention}} is now spectating",
+		}, map[string]interface{}{
+			"UserMention": discord.MentionByUserID(userID),
+		})
+	case SpectateOff:
+		content = sett.LocalizeMessage(&i18n.Message{
+			ID:    "commands.spectate.off",
+			Other: "{{.UserMention}} is no longer spectating",
+		}, map[string]interface{}{
+			"UserMention": discord.MentionByUserID(userID),
+		})
+	case SpectateNoUser:
+		content = sett.LocalizeMessage(&i18n.Message{
+			ID:    "commands.spectate.nouser",
+			Other: "I couldn't find {{.UserMention}} in this server",
+		}, map[string]interface{}{
+			"UserMention": discord.MentionByUserID(userID),
+		})
+	}
+	return PrivateResponse(content)
+}
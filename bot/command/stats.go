@@ -2,14 +2,20 @@ package command
 
 import (
 	"github.com/automuteus/automuteus/v8/bot/setting"
+	"github.com/automuteus/automuteus/v8/pkg/game"
+	"github.com/automuteus/automuteus/v8/pkg/storage"
 	"github.com/bwmarrin/discordgo"
 )
 
 const (
-	Match = "match"
-	Guild = "guild"
+	Match         = "match"
+	Guild         = "guild"
+	Impostors     = "impostors"
+	ConfirmEjects = "confirm-ejects"
 )
 
+var minImpostors = float64(1)
+
 var Stats = discordgo.ApplicationCommand{
 	Name:        "stats",
 	Description: "View or clear stats from games played with AutoMuteUs",
@@ -49,6 +55,22 @@ var Stats = discordgo.ApplicationCommand{
 					Name:        Guild,
 					Description: "View this guild's stats",
 					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Name:        Impostors,
+							Description: "Only count games with this many impostors",
+							Type:        discordgo.ApplicationCommandOptionInteger,
+							MinValue:    &minImpostors,
+							MaxValue:    game.MaxLobbySize,
+							Required:    false,
+						},
+						{
+							Name:        ConfirmEjects,
+							Description: "Only count games with (or without) confirm ejects",
+							Type:        discordgo.ApplicationCommandOptionBoolean,
+							Required:    false,
+						},
+					},
 				},
 			},
 		},
@@ -93,3 +115,18 @@ func GetStatsParams(s *discordgo.Session, guildID string, options []*discordgo.A
 	}
 	return action, opType, id
 }
+
+// GetStatsRuleset returns the lobby options guild stats were filtered by
+func GetStatsRuleset(options []*discordgo.ApplicationCommandInteractionDataOption) storage.Ruleset {
+	ruleset := storage.Ruleset{}
+	for _, opt := range options[0].Options[0].Options {
+		switch opt.Name {
+		case Impostors:
+			ruleset.NumImpostors = int16(opt.IntValue())
+		case ConfirmEjects:
+			confirmEjects := opt.BoolValue()
+			ruleset.ConfirmEjects = &confirmEjects
+		}
+	}
+	return ruleset
+}
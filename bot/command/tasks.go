@@ -0,0 +1,38 @@
+package command
+
+import (
+	"github.com/automuteus/automuteus/v8/pkg/game"
+	"github.com/automuteus/automuteus/v8/pkg/settings"
+	"github.com/bwmarrin/discordgo"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+var Tasks = discordgo.ApplicationCommand{
+	Name:        "tasks",
+	Description: "View the crew's task progress (once you're dead)",
+}
+
+// TasksResponse privately shows the crew's task progress, if the user is allowed to see it
+func TasksResponse(progress *game.TaskProgress, canSee bool, sett *settings.GuildSettings) *discordgo.InteractionResponse {
+	if !canSee {
+		return PrivateResponse(sett.LocalizeMessage(&i18n.Message{
+			ID:    "commands.tasks.notDead",
+			Other: "You can only view the crew's task progress once you're dead, or after the game.",
+		}))
+	}
+	if progress == nil {
+		return PrivateResponse(sett.LocalizeMessage(&i18n.Message{
+			ID:    "commands.tasks.noProgress",
+			Other: "The capture hasn't reported any task progress for this game.",
+		}))
+	}
+	return PrivateResponse(sett.LocalizeMessage(&i18n.Message{
+		ID:    "commands.tasks.progress",
+		Other: "The crew's tasks are {{.Percent}}% complete ({{.Completed}}/{{.Total}}).",
+	},
+		map[string]interface{}{
+			"Percent":   progress.Percent(),
+			"Completed": progress.Completed,
+			"Total":     progress.Total,
+		}))
+}
@@ -2,11 +2,11 @@ package bot
 
 import (
 	"fmt"
+	"github.com/automuteus/automuteus/v8/internal/logging"
 	"github.com/automuteus/automuteus/v8/pkg/amongus"
 	"github.com/automuteus/automuteus/v8/pkg/settings"
 	"github.com/bwmarrin/discordgo"
 	"github.com/nicksnyder/go-i18n/v2/i18n"
-	"log"
 )
 
 // GameState represents a full record of the entire current game's state. It is intended to be fully JSON-serializable,
@@ -20,8 +20,13 @@ type GameState struct {
 	Running    bool `json:"running"`
 	Subscribed bool `json:"subscribed"`
 
+	// CaptureSource is the capture client currently elected to report the game, if several are attached
+	CaptureSource string `json:"captureSource,omitempty"`
+
 	MatchID        int64 `json:"matchID"`
 	MatchStartUnix int64 `json:"matchStartUnix"`
+	// DiscussStartUnix is when the current discussion was called, for the discussion and voting countdowns
+	DiscussStartUnix int64 `json:"discussStartUnix,omitempty"`
 
 	UserData     UserDataSet `json:"userData"`
 	VoiceChannel string      `json:"voiceChannel"`
@@ -29,6 +34,11 @@ type GameState struct {
 	GameStateMsg GameStateMessage `json:"gameStateMessage"`
 
 	GameData amongus.GameData `json:"amongUsData"`
+
+	// LinkSuggestions are the links currently offered as buttons on the game message
+	LinkSuggestions []LinkSuggestion `json:"linkSuggestions,omitempty"`
+	// RejectedSuggestions are the keys of the suggestions that were rejected, so they aren't offered again
+	RejectedSuggestions []string `json:"rejectedSuggestions,omitempty"`
 }
 
 func NewDiscordGameState(guildID string) *GameState {
@@ -41,17 +51,21 @@ func (dgs *GameState) Reset() {
 	// Explicitly does not reset the GuildID!
 	dgs.ConnectCode = ""
 	dgs.Linked = false
+	dgs.CaptureSource = ""
 	dgs.Running = false
 	dgs.Subscribed = false
 	dgs.MatchID = -1
 	dgs.MatchStartUnix = -1
+	dgs.DiscussStartUnix = 0
 	dgs.UserData = map[string]UserData{}
 	dgs.VoiceChannel = ""
 	dgs.GameStateMsg = MakeGameStateMessage()
 	dgs.GameData = amongus.NewGameData()
+	dgs.LinkSuggestions = nil
+	dgs.RejectedSuggestions = nil
 }
 
-func (dgs *GameState) checkCacheAndAddUser(g *discordgo.Guild, s *discordgo.Session, userID string) (UserData, bool) {
+func (dgs *GameState) checkCacheAndAddUser(g *discordgo.Guild, s guildMembers, userID string) (UserData, bool) {
 	if g == nil {
 		return UserData{}, false
 	}
@@ -65,7 +79,7 @@ func (dgs *GameState) checkCacheAndAddUser(g *discordgo.Guild, s *discordgo.Sess
 	}
 	mem, err := s.GuildMember(g.ID, userID)
 	if err != nil {
-		log.Println(err)
+		logger.Error("Error fetching guild member", err, logging.GuildIDKey, g.ID, logging.ConnectCodeKey, dgs.ConnectCode, "user_id", userID)
 		return UserData{}, false
 	}
 	user := MakeUserDataFromDiscordUser(mem.User, mem.Nick)
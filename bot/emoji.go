@@ -2,8 +2,8 @@ package bot
 
 import (
 	"encoding/base64"
+	"github.com/automuteus/automuteus/v8/internal/logging"
 	"io"
-	"log"
 	"net/http"
 
 	"github.com/automuteus/automuteus/v8/pkg/game"
@@ -39,12 +39,13 @@ func (e *Emoji) DownloadAndBase64Encode() string {
 	url := e.GetDiscordCDNUrl()
 	response, err := http.Get(url)
 	if err != nil {
-		log.Println(err)
+		logger.Error("Error downloading emoji", err, "emoji", e.Name)
+		return ""
 	}
 	defer response.Body.Close()
 	bytes, err := io.ReadAll(response.Body)
 	if err != nil {
-		log.Println(err)
+		logger.Error("Error reading emoji", err, "emoji", e.Name)
 	}
 	encodedStr := base64.StdEncoding.EncodeToString(bytes)
 	return "data:image/png;base64," + encodedStr
@@ -99,9 +100,9 @@ func (bot *Bot) verifyEmojis(s *discordgo.Session, guildID string, alive bool, s
 			}
 			em, err := s.GuildEmojiCreate(guildID, &p)
 			if err != nil {
-				log.Println(err)
+				logger.Error("Error adding emoji", err, logging.GuildIDKey, guildID, "emoji", emoji.Name)
 			} else {
-				log.Printf("Added emoji %s successfully!\n", emoji.Name)
+				logger.Info("Added emoji", logging.GuildIDKey, guildID, "emoji", emoji.Name)
 				emoji.ID = em.ID
 				bot.StatusEmojis[alive][i] = emoji
 			}
@@ -116,7 +117,7 @@ func EmojisToSelectMenuOptions(emojis []Emoji, unlinkEmoji string) (arr []discor
 	arr = append(arr, discordgo.SelectMenuOption{
 		Label:   "unlink",
 		Value:   UnlinkEmojiName,
-		Emoji:   discordgo.ComponentEmoji{Name: unlinkEmoji},
+		Emoji:   &discordgo.ComponentEmoji{Name: unlinkEmoji},
 		Default: false,
 	})
 	return arr
@@ -126,7 +127,7 @@ func (e Emoji) toSelectMenuOption(displayName string) discordgo.SelectMenuOption
 	return discordgo.SelectMenuOption{
 		Label:   displayName,
 		Value:   displayName, // use the Name for listen events later
-		Emoji:   discordgo.ComponentEmoji{ID: e.ID},
+		Emoji:   &discordgo.ComponentEmoji{ID: e.ID},
 		Default: false,
 	}
 }
@@ -2,19 +2,27 @@ package bot
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/automuteus/automuteus/v8/internal/logging"
 	"github.com/automuteus/automuteus/v8/internal/server"
+	"github.com/automuteus/automuteus/v8/internal/tracing"
 	"github.com/automuteus/automuteus/v8/pkg/amongus"
 	"github.com/automuteus/automuteus/v8/pkg/discord"
 	"github.com/automuteus/automuteus/v8/pkg/game"
+	"github.com/automuteus/automuteus/v8/pkg/rediskey"
 	"github.com/automuteus/automuteus/v8/pkg/settings"
 	"github.com/automuteus/automuteus/v8/pkg/storage"
 	"github.com/automuteus/automuteus/v8/pkg/task"
+	"github.com/bsm/redislock"
 	"github.com/go-redis/redis/v8"
 	"github.com/nicksnyder/go-i18n/v2/i18n"
-	"log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/exp/slog"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -22,22 +30,83 @@ import (
 
 type EndGameMessage bool
 
+const (
+	// JobPollInterval is how often a game's job stream is checked even when no notification has been received
+	JobPollInterval = time.Second * 10
+
+	// JobReadBatchSize is the max number of jobs read from a stream at once
+	JobReadBatchSize = 10
+
+	// JobQueueSampleInterval is how often the number of jobs waiting for a game is measured
+	JobQueueSampleInterval = time.Second * 5
+
+	// JobLockAttempts is how many times a job is tried while the game state stays locked, before it's skipped
+	JobLockAttempts = 3
+
+	// JobLockRetryDelay is how long a job is put off when the game state stayed locked
+	JobLockRetryDelay = time.Second
+
+	// GameStateLockedNoticeInterval is how often a game's channel can be told that updates were skipped because its
+	// state stayed locked
+	GameStateLockedNoticeInterval = time.Minute
+)
+
 func (bot *Bot) SubscribeToGameByConnectCode(guildID, connectCode string, endGameChannel chan EndGameMessage) {
-	log.Println("Started Redis Subscription worker for " + connectCode)
+	l := bot.gameLogger(guildID, connectCode)
+	if !bot.trackRunningGame(guildID, connectCode) {
+		l.Info("Not subscribing to game while shutting down")
+		return
+	}
+	defer bot.untrackRunningGame(connectCode)
+	l.Info("Started Redis Subscription worker")
 
 	notify := task.Subscribe(ctx, bot.RedisInterface.client, connectCode)
 
 	timer := time.NewTimer(time.Second * time.Duration(bot.captureTimeout))
 
+	// notifications are fire-and-forget, so also check for jobs (and reclaim abandoned ones) periodically
+	jobTicker := time.NewTicker(JobPollInterval)
+	defer jobTicker.Stop()
+
 	dgsRequest := GameStateRequest{
 		GuildID:     guildID,
 		ConnectCode: connectCode,
 	}
 
+	queueTicker := time.NewTicker(JobQueueSampleInterval)
+	defer queueTicker.Stop()
+
+	reconciler := newVoiceReconciler(dgsRequest)
+	reconcileTicker := time.NewTicker(VoiceReconcileInterval)
+	defer reconcileTicker.Stop()
+
+	err := task.EnsureJobGroup(ctx, bot.RedisInterface.client, connectCode)
+	if err != nil {
+		l.Error("Error creating the job consumer group", err)
+	}
+
+	// pick up the sequences where the last subscription for this game (possibly on another shard) left off
+	lastSeqs, err := task.GetLastSequences(ctx, bot.RedisInterface.client, connectCode)
+	if err != nil {
+		l.Error("Error fetching the last job sequences", err)
+	}
+	sources := task.NewSources(lastSeqs)
+
+	// fires when a job that arrived out of order has waited long enough for the jobs before it
+	reorderTimer := time.NewTimer(task.JobReorderTimeout)
+	reorderTimer.Stop()
+	scheduleReorder := func() {
+		if next, ok := sources.NextExpiry(); ok {
+			reorderTimer.Reset(time.Until(next))
+		}
+	}
+	defer reorderTimer.Stop()
+
 	// indicate to the broker that we're online and ready to start processing messages
 	task.Ack(ctx, bot.RedisInterface.client, connectCode)
 
 	for {
+		bot.beatRunningGame(connectCode)
 		select {
 		case message := <-notify.Channel():
 			timer.Reset(time.Second * time.Duration(bot.captureTimeout))
@@ -45,194 +114,51 @@ func (bot *Bot) SubscribeToGameByConnectCode(guildID, connectCode string, endGam
 				break
 			}
 
-			// anytime we get a notification message, continue pulling messages off the list until there are no more
-			for {
-				job, err := task.PopJob(ctx, bot.RedisInterface.client, connectCode)
-				if errors.Is(err, redis.Nil) {
-					break
-				} else if err != nil {
-					log.Println(err)
-					break
-				}
-				log.Printf("Popped job of type %d w/ payload %s\n", job.JobType, job.Payload.(string))
-				bot.refreshGameLiveness(connectCode)
-				bot.RedisInterface.RefreshActiveGame(guildID, connectCode)
-
-				gameEvent := storage.PostgresGameEvent{
-					GameID:    -1,
-					UserID:    nil,
-					EventTime: int32(time.Now().Unix()),
-					EventType: int16(job.JobType),
-					Payload:   job.Payload.(string),
-				}
-				correlatedUserID := ""
-				sett := bot.StorageInterface.GetGuildSettings(guildID)
-
-				switch job.JobType {
-				case task.ConnectionJob:
-					lock, dgs := bot.RedisInterface.GetDiscordGameStateAndLock(dgsRequest)
-					for lock == nil {
-						lock, dgs = bot.RedisInterface.GetDiscordGameStateAndLock(dgsRequest)
-					}
-					if job.Payload == "true" {
-						dgs.Linked = true
-					} else {
-						dgs.Linked = false
-					}
-					dgs.ConnectCode = connectCode
-					bot.RedisInterface.SetDiscordGameState(dgs, lock)
-
-					bot.handleTrackedMembers(bot.PrimarySession, sett, 0, NoPriority, dgsRequest)
-					bot.DispatchRefreshOrEdit(dgs, dgsRequest, sett)
+			bot.consumeJobs(guildID, connectCode, sources)
+			scheduleReorder()
 
-				case task.LobbyJob:
-					var lobby game.Lobby
-					err = json.Unmarshal([]byte(job.Payload.(string)), &lobby)
-					if err != nil {
-						log.Println(err)
-						break
-					}
-
-					bot.processLobby(sett, lobby, dgsRequest)
-				case task.StateJob:
-					num, err := strconv.ParseInt(job.Payload.(string), 10, 64)
-					if err != nil {
-						log.Println(err)
-						break
-					}
-
-					bot.processTransition(game.Phase(num), dgsRequest)
-				case task.PlayerJob:
-					var player game.Player
-					err = json.Unmarshal([]byte(job.Payload.(string)), &player)
-					if err != nil {
-						log.Println(err)
-						break
-					}
-					if player.Color > 17 || player.Color < 0 {
-						break
-					}
-
-					shouldHandleTracked, userID, readOnlyDgs, err := bot.processPlayer(sett, player, dgsRequest)
-					if shouldHandleTracked {
-						bot.handleTrackedMembers(bot.PrimarySession, sett, 0, NoPriority, dgsRequest)
-					}
-					if err != nil {
-						bot.PrimarySession.ChannelMessageSend(readOnlyDgs.GameStateMsg.MessageChannelID, sett.LocalizeMessage(&i18n.Message{
-							ID:    "processplayer.error",
-							Other: "Error in muting or deafening {{.User}}. Does the bot have permissions to mute/deafen users in {{.VoiceChannel}}?",
-						},
-							map[string]interface{}{
-								"User":         discord.MentionByUserID(userID),
-								"VoiceChannel": discord.MentionByChannelID(readOnlyDgs.VoiceChannel),
-							},
-						))
-						server.RecordDiscordRequests(bot.RedisInterface.client, server.MessageCreateDelete, 1)
-					}
-					correlatedUserID = userID
-				case task.GameOverJob:
-					var gameOverResult game.Gameover
-					// log.Println("Successfully identified game over event:")
-					// log.Println(job.Payload)
-					err := json.Unmarshal([]byte(job.Payload.(string)), &gameOverResult)
-					if err != nil {
-						log.Println(err)
-						break
-					}
-
-					// we only need a read-only state for making the game summary message
-					dgs := bot.RedisInterface.GetReadOnlyDiscordGameState(dgsRequest)
-					if dgs != nil {
-						delTime := sett.GetDeleteGameSummaryMinutes()
-						if delTime != 0 {
-							winners := getWinners(*dgs, gameOverResult)
-							buf := bytes.NewBuffer([]byte{})
-							for i, v := range winners {
-								roleStr := "Crewmate"
-								if v.role == game.ImposterRole {
-									roleStr = "Imposter"
-								}
-								buf.WriteString(fmt.Sprintf("<@%s>", v.userID))
-								if i < len(winners)-1 {
-									buf.WriteRune(',')
-								} else {
-									buf.WriteString(fmt.Sprintf(" won as %s", roleStr))
-								}
-							}
-							embed := gameOverMessage(dgs, bot.StatusEmojis, sett, buf.String())
-							channelID := dgs.GameStateMsg.MessageChannelID
-							if sett.GetMatchSummaryChannelID() != "" {
-								channelID = sett.GetMatchSummaryChannelID()
-							}
-							msg, err := bot.PrimarySession.ChannelMessageSendEmbed(channelID, embed)
-							if delTime > 0 && err == nil {
-								server.RecordDiscordRequests(bot.RedisInterface.client, server.MessageCreateDelete, 2)
-								go MessageDeleteWorker(bot.PrimarySession, msg.ChannelID, msg.ID, time.Minute*time.Duration(delTime))
-							} else if err == nil {
-								server.RecordDiscordRequests(bot.RedisInterface.client, server.MessageCreateDelete, 1)
-							}
-						}
-						go dumpGameToPostgres(*dgs, bot.PostgresInterface, gameOverResult)
-
-						// refresh the game message if the setting is marked (it is not locked, the previous dgs is
-						// read-only). This means the original msg is refreshed, not the gameover message
-						if sett.AutoRefresh {
-							bot.RefreshGameStateMessage(dgsRequest, sett)
-						}
-
-						// now we need to fetch the state again (AFTER refreshing) to mark the game as complete/
-						lock, dgs := bot.RedisInterface.GetDiscordGameStateAndLock(dgsRequest)
-						for lock == nil {
-							lock, dgs = bot.RedisInterface.GetDiscordGameStateAndLock(dgsRequest)
-						}
-						dgs.MatchID = -1
-						dgs.MatchStartUnix = -1
-						bot.RedisInterface.SetDiscordGameState(dgs, lock)
-					}
-				}
-				if job.JobType != task.ConnectionJob {
-					go func(userID string, ge storage.PostgresGameEvent) {
-						dgs := bot.RedisInterface.GetReadOnlyDiscordGameState(dgsRequest)
-						if dgs != nil && dgs.MatchID > 0 && dgs.MatchStartUnix > 0 {
-							ge.GameID = dgs.MatchID
-							if userID != "" {
-								num, err := strconv.ParseUint(userID, 10, 64)
-								if err != nil {
-									log.Println(err)
-									ge.UserID = nil
-								} else {
-									ge.UserID = &num
-								}
-								log.Printf("Adding postgres event with user id %d\n", ge.UserID)
-							}
-
-							err := bot.PostgresInterface.AddEvent(&ge)
-							if err != nil {
-								log.Println(err)
-							}
-						}
-					}(correlatedUserID, gameEvent)
-				}
+		case <-jobTicker.C:
+			if bot.consumeJobs(guildID, connectCode, sources) > 0 {
+				timer.Reset(time.Second * time.Duration(bot.captureTimeout))
 			}
+			scheduleReorder()
+
+		case <-reorderTimer.C:
+			bot.expireHeldJobs(guildID, connectCode, sources)
+			scheduleReorder()
+
+		case <-reconcileTicker.C:
+			bot.reconcileVoiceStates(reconciler)
+
+		case <-queueTicker.C:
+			bot.sampleJobQueue(l, connectCode)
 
 		case <-timer.C:
 			timer.Stop()
-			log.Printf("Killing game w/ code %s after %d seconds of inactivity!\n", connectCode, bot.captureTimeout)
+			l.Info("Killing game after inactivity", "timeout_secs", bot.captureTimeout)
 			err := notify.Close()
 			if err != nil {
-				log.Println(err)
+				l.Error("Error closing the job notifications", err)
 			}
 			go bot.forceEndGame(dgsRequest)
 			bot.ChannelsMapLock.Lock()
 			delete(bot.EndGameChannels, connectCode)
 			bot.ChannelsMapLock.Unlock()
 
+			return
+		case <-bot.stopping:
+			// the game isn't ended, so it's picked up again once the bot is back
+			l.Info("Stopping the subscription to shut down")
+			err := notify.Close()
+			if err != nil {
+				l.Error("Error closing the job notifications", err)
+			}
 			return
 		case <-endGameChannel:
-			log.Println("Redis subscriber received kill signal, closing all pubsubs")
+			l.Info("Redis subscriber received kill signal, closing all pubsubs")
 			err := notify.Close()
 			if err != nil {
-				log.Println(err)
+				l.Error("Error closing the job notifications", err)
 			}
 			bot.forceEndGame(dgsRequest)
 			return
@@ -240,66 +166,565 @@ func (bot *Bot) SubscribeToGameByConnectCode(guildID, connectCode string, endGam
 	}
 }
 
+// consumeJobs processes every job currently available for a game, and returns how many were processed. Jobs left on
+// the legacy list are drained first, then stream jobs abandoned by a consumer that died mid-job, then new stream jobs.
+// Stream jobs are only acknowledged after they've been processed, so a crash leaves them pending for reclaim.
+// Every job goes through its source's sequencer, so it may be dropped as a duplicate, or held back until the jobs
+// before it arrive
+func (bot *Bot) consumeJobs(guildID, connectCode string, sources *task.Sources) int {
+	l := bot.gameLogger(guildID, connectCode)
+	processed := 0
+	for {
+		job, err := task.PopJob(ctx, bot.RedisInterface.client, connectCode)
+		var rejectErr *task.RejectError
+		if errors.Is(err, redis.Nil) {
+			break
+		} else if errors.As(err, &rejectErr) {
+			// the job was popped, so just move on to the next one
+			recordJobReject(l, rejectErr)
+			continue
+		} else if err != nil {
+			l.Error("Error popping job", err)
+			break
+		}
+		sj := task.StreamJob{Job: job, Popped: time.Now()}
+		bot.recordJob(l, guildID, connectCode, sj)
+		bot.processStreamJob(guildID, connectCode, sources, sj)
+		processed++
+	}
+
+	stale, err := task.ClaimStaleJobs(ctx, bot.RedisInterface.client, connectCode, bot.jobConsumer, task.JobClaimMinIdle)
+	if err != nil {
+		l.Error("Error claiming stale jobs", err)
+	}
+	for _, sj := range stale {
+		l.Warn("Reclaimed unacknowledged job", "job_id", sj.ID)
+		sj.Popped = time.Now()
+		bot.recordJob(l, guildID, connectCode, sj)
+		bot.processStreamJob(guildID, connectCode, sources, sj)
+		processed++
+	}
+
+	for {
+		jobs, err := task.ReadJobs(ctx, bot.RedisInterface.client, connectCode, bot.jobConsumer, JobReadBatchSize)
+		if errors.Is(err, redis.Nil) {
+			break
+		} else if err != nil {
+			l.Error("Error reading jobs", err)
+			break
+		}
+		popped := time.Now()
+		for _, sj := range jobs {
+			sj.Popped = popped
+			bot.recordJob(l, guildID, connectCode, sj)
+			bot.processStreamJob(guildID, connectCode, sources, sj)
+			processed++
+		}
+	}
+	return processed
+}
+
+// sampleJobQueue measures how many jobs are waiting to be processed for the game
+func (bot *Bot) sampleJobQueue(l *slog.Logger, connectCode string) {
+	depth, err := task.QueueDepth(ctx, bot.RedisInterface.client, connectCode)
+	if err != nil {
+		l.Error("Error measuring the job queue depth", err)
+		return
+	}
+	server.JobQueueDepth.WithLabelValues(connectCode).Set(float64(depth))
+}
+
+// recordJob adds a job to the game's recording as it arrived, before it's sequenced, so duplicates and jobs that
+// arrived out of order are replayed as they happened
+func (bot *Bot) recordJob(l *slog.Logger, guildID, connectCode string, sj task.StreamJob) {
+	if sj.Err != nil {
+		return
+	}
+	err := task.RecordJob(ctx, bot.RedisInterface.client, guildID, connectCode, sj.Job, sj.Popped)
+	if err != nil {
+		l.Error("Error recording job", err)
+	}
+}
+
+// processStreamJob passes a job through its source's sequencer, and processes whichever jobs are ready as a result
+func (bot *Bot) processStreamJob(guildID, connectCode string, sources *task.Sources, sj task.StreamJob) {
+	l := bot.gameLogger(guildID, connectCode)
+	var rejectErr *task.RejectError
+	if errors.As(sj.Err, &rejectErr) {
+		recordJobReject(l, rejectErr)
+		bot.ackJob(connectCode, sj)
+		return
+	}
+	sequencer := sources.Sequencer(sj.Job.Source)
+	ready, duplicate := sequencer.Offer(sj, bot.sink.Now())
+	if duplicate {
+		l.Warn("Discarding duplicate job", "seq", sj.Job.Seq, "source", sj.Job.Source)
+		server.JobDuplicates.Inc()
+		bot.ackJob(connectCode, sj)
+		return
+	}
+	if len(ready) == 0 {
+		l.Debug("Holding job until the jobs before it arrive", "seq", sj.Job.Seq, "source", sj.Job.Source, "waiting_for", sequencer.Last()+1)
+	}
+	bot.processReadyJobs(guildID, connectCode, sources, ready)
+}
+
+// expireHeldJobs gives up on jobs that never arrived, and processes the jobs that were waiting on them
+func (bot *Bot) expireHeldJobs(guildID, connectCode string, sources *task.Sources) {
+	ready, gaps := sources.Expire(bot.sink.Now())
+	for _, gap := range gaps {
+		bot.gameLogger(guildID, connectCode).Warn("Skipping missing jobs", "count", gap.Len(), "from", gap.From, "to", gap.To, "source", gap.Source)
+		server.JobsMissing.Add(float64(gap.Len()))
+	}
+	bot.processReadyJobs(guildID, connectCode, sources, ready)
+}
+
+// processReadyJobs processes jobs that are in order, as long as they come from the primary source. Jobs put off because
+// the game state stayed locked go first, and a job that's put off again holds back every job after it
+func (bot *Bot) processReadyJobs(guildID, connectCode string, sources *task.Sources, ready []task.StreamJob) {
+	ready = append(sources.Requeued(), ready...)
+	processedSources := make(map[string]bool)
+	for i, sj := range ready {
+		admission := sources.Admit(sj.Job, bot.sink.Now())
+		if admission.Changed {
+			bot.setCaptureSource(guildID, connectCode, admission.Primary)
+		}
+		if admission.Process {
+			err := bot.processJob(guildID, connectCode, sj)
+			if retryJob(sj, err) {
+				bot.requeueJobs(guildID, connectCode, sources, ready[i:])
+				break
+			} else if errors.Is(err, ErrGameStateLocked) {
+				bot.gameLogger(guildID, connectCode).Warn("Skipping job; the game state stayed locked", "job_type", sj.Job.JobType, "attempts", sj.Attempts+1)
+				server.JobLockTimeouts.WithLabelValues(server.JobSkipped).Inc()
+				bot.sink.GameStateLocked(GameStateRequest{GuildID: guildID, ConnectCode: connectCode}, bot.sink.GuildSettings(guildID))
+			}
+		} else if sj.Job.JobType != task.ConnectionJob {
+			server.JobDuplicates.Inc()
+		}
+		bot.ackJob(connectCode, sj)
+		// a connection restarts the source's count, which has to be saved even though the job isn't sequenced
+		if sj.Job.Seq > 0 || sj.Job.JobType == task.ConnectionJob {
+			processedSources[sj.Job.Source] = true
+		}
+	}
+	for source := range processedSources {
+		err := bot.sink.SaveLastSequence(connectCode, source, sources.Sequencer(source).Last())
+		if err != nil {
+			bot.gameLogger(guildID, connectCode).Error("Error saving the last job sequence", err, "source", source)
+		}
+	}
+}
+
+// retryJob is true if a job's update wasn't applied because the game state stayed locked, and it hasn't been tried
+// JobLockAttempts times yet
+func retryJob(sj task.StreamJob, err error) bool {
+	return errors.Is(err, ErrGameStateLocked) && sj.Attempts+1 < JobLockAttempts
+}
+
+// requeueJobs puts off a job that couldn't be processed because the game state stayed locked, along with the jobs
+// after it, so they're retried in order. They aren't acknowledged until they're processed
+func (bot *Bot) requeueJobs(guildID, connectCode string, sources *task.Sources, jobs []task.StreamJob) {
+	jobs[0].Attempts++
+	bot.gameLogger(guildID, connectCode).Warn("Retrying job once the game state is unlocked", "job_type", jobs[0].Job.JobType,
+		"attempts", jobs[0].Attempts, "held_back", len(jobs)-1)
+	server.JobLockTimeouts.WithLabelValues(server.JobRetried).Inc()
+	sources.Requeue(jobs, bot.sink.Now().Add(JobLockRetryDelay))
+}
+
+// setCaptureSource records which capture client the game's events are coming from, so mute/deafen tasks are only
+// sent to that client, and shows it in the game's embed
+func (bot *Bot) setCaptureSource(guildID, connectCode, source string) {
+	l := bot.gameLogger(guildID, connectCode)
+	l.Info("Capture client is now the primary source", "source", source)
+	server.CaptureSourceChanges.Inc()
+	err := bot.sink.SavePrimarySource(connectCode, source)
+	if err != nil {
+		l.Error("Error saving the primary capture source", err)
+	}
+
+	dgsRequest := GameStateRequest{
+		GuildID:     guildID,
+		ConnectCode: connectCode,
+	}
+	sett := bot.sink.GuildSettings(guildID)
+	lock, dgs, err := bot.lockForUpdate(ctx, dgsRequest)
+	if err != nil {
+		bot.sink.GameStateLocked(dgsRequest, sett)
+		return
+	}
+	if dgs.CaptureSource == source {
+		// release the lock
+		bot.sink.SetGameState(nil, lock)
+		return
+	}
+	dgs.CaptureSource = source
+	bot.sink.SetGameState(dgs, lock)
+	bot.DispatchRefreshOrEdit(dgs, dgsRequest, sett)
+}
+
+// ackJob acknowledges a stream job. Jobs from the legacy list have no ID, and were removed when they were popped
+func (bot *Bot) ackJob(connectCode string, sj task.StreamJob) {
+	if sj.ID == "" {
+		return
+	}
+	err := task.AckJob(ctx, bot.RedisInterface.client, connectCode, sj.ID)
+	if err != nil {
+		logger.Error("Error acknowledging job", err, logging.ConnectCodeKey, connectCode, "job_id", sj.ID)
+	}
+}
+
+func recordJobReject(l *slog.Logger, err *task.RejectError) {
+	l.Warn("Discarding job", "reason", string(err.Reason), "detail", err.Error())
+	server.RecordJobReject(string(err.Reason))
+}
+
+// notifyGameStateLocked tells the game's channel that an update couldn't be applied because the game state stayed
+// locked. A game's channel is told at most once per GameStateLockedNoticeInterval, across every shard
+func (bot *Bot) notifyGameStateLocked(gsr GameStateRequest, sett *settings.GuildSettings) {
+	dgs := bot.RedisInterface.GetReadOnlyDiscordGameState(gsr)
+	if dgs == nil || dgs.GameStateMsg.MessageChannelID == "" {
+		return
+	}
+	first, err := bot.RedisInterface.client.SetNX(ctx, rediskey.GameStateLockedNotice(gsr.ConnectCode), processIdentity, GameStateLockedNoticeInterval).Result()
+	if err != nil {
+		bot.gameLogger(gsr.GuildID, gsr.ConnectCode).Error("Error rate limiting the game state locked notice", err)
+		return
+	} else if !first {
+		return
+	}
+	_, err = bot.PrimarySession.ChannelMessageSend(dgs.GameStateMsg.MessageChannelID, sett.LocalizeMessage(&i18n.Message{
+		ID:    "eventHandler.gameStateLocked",
+		Other: "I couldn't keep up with this game, so some updates from the capture were skipped. If anyone is muted incorrectly, try `/refresh`.",
+	}))
+	if err == nil {
+		server.RecordDiscordRequests(bot.RedisInterface.client, server.MessageCreateDelete, 1)
+	}
+}
+
+// processJob applies a job to the game. If the game state stayed locked, the error is returned; unless the job has been
+// tried JobLockAttempts times, nothing else is done, so it can be tried again
+func (bot *Bot) processJob(guildID, connectCode string, sj task.StreamJob) error {
+	job, popped := sj.Job, sj.Popped
+	dgsRequest := GameStateRequest{
+		GuildID:     guildID,
+		ConnectCode: connectCode,
+	}
+
+	l := bot.gameLogger(guildID, connectCode)
+	l.Debug("Popped job", "job_type", job.JobType, "payload", string(job.Payload))
+	// the span starts when the job was popped, which was earlier if it was held back to be processed in order
+	ctx, span := tracing.Start(ctx, "processJob", trace.WithTimestamp(popped), tracing.GameAttributes(guildID, connectCode),
+		trace.WithAttributes(attribute.Int("automuteus.job_type", int(job.JobType))))
+	defer span.End()
+
+	bot.sink.RefreshGameLiveness(guildID, connectCode)
+
+	gameEvent := storage.PostgresGameEvent{
+		GameID:    -1,
+		UserID:    nil,
+		EventTime: int32(bot.sink.Now().Unix()),
+		EventType: int16(job.JobType),
+		Payload:   job.Payload,
+	}
+	correlatedUserID := ""
+	// most jobs are persisted as a single event (gameEvent), but some are split up so each part is correlated with
+	// its own user
+	var splitEvents []correlatedEvent
+	sett := bot.sink.GuildSettings(guildID)
+
+	var err error
+	switch job.JobType {
+	case task.ConnectionJob:
+		lock, dgs, lockErr := bot.lockForUpdate(ctx, dgsRequest)
+		if lockErr != nil {
+			err = lockErr
+			break
+		}
+		dgs.Linked = job.Connected
+		dgs.ConnectCode = connectCode
+		bot.sink.SetGameState(dgs, lock)
+
+		bot.handleTrackedMembers(ctx, sett, 0, NoPriority, dgsRequest)
+		bot.DispatchRefreshOrEdit(dgs, dgsRequest, sett)
+
+	case task.LobbyJob:
+		err = bot.processLobby(ctx, sett, job.Lobby, dgsRequest)
+	case task.StateJob:
+		err = bot.processTransition(ctx, job.Phase, popped, dgsRequest)
+	case task.PlayerJob:
+		shouldHandleTracked, userID, readOnlyDgs, playerErr := bot.processPlayer(ctx, sett, job.Player, dgsRequest)
+		if readOnlyDgs == nil && playerErr != nil {
+			// the game state couldn't be locked, so the player wasn't updated
+			err = playerErr
+			break
+		}
+		if shouldHandleTracked {
+			bot.handleTrackedMembers(ctx, sett, 0, NoPriority, dgsRequest)
+		}
+		if playerErr != nil {
+			bot.sink.SendMessage(readOnlyDgs.GameStateMsg.MessageChannelID, sett.LocalizeMessage(&i18n.Message{
+				ID:    "processplayer.error",
+				Other: "Error in muting or deafening {{.User}}. Does the bot have permissions to mute/deafen users in {{.VoiceChannel}}?",
+			},
+				map[string]interface{}{
+					"User":         discord.MentionByUserID(userID),
+					"VoiceChannel": discord.MentionByChannelID(readOnlyDgs.VoiceChannel),
+				},
+			))
+		}
+		correlatedUserID = userID
+		if job.Player.Action == game.JOINED || job.Player.Action == game.LEFT || job.Player.Action == game.CHANGECOLOR || job.Player.Disconnected {
+			// suggestions are for a player's color, so they go stale when the player changes it
+			bot.sink.Go(func() {
+				bot.updateLinkSuggestions(dgsRequest)
+			})
+		}
+		if job.Player.Action == game.DIED && job.Player.Killer != "" {
+			// capture clients that report the killer with the death don't send a separate kill job
+			splitEvents = []correlatedEvent{
+				{userID: userID, event: gameEvent},
+				killEvent(bot.gameLogger(guildID, connectCode), readOnlyDgs, game.Kill{Killer: job.Player.Killer, Victim: job.Player.Name}, gameEvent),
+			}
+		}
+	case task.GameOverJob:
+		gameOverResult := job.GameOver
+
+		// we only need a read-only state for making the game summary message
+		dgs := bot.sink.ReadGameState(dgsRequest)
+		if dgs != nil {
+			delTime := sett.GetDeleteGameSummaryMinutes()
+			if delTime != 0 {
+				winners := getWinners(*dgs, gameOverResult)
+				roles := formatRoles(getPlayerRoles(*dgs, gameOverResult))
+				embed := gameOverMessage(dgs, bot.StatusEmojis, sett, formatWinners(winners), roles)
+				channelID := dgs.GameStateMsg.MessageChannelID
+				if sett.GetMatchSummaryChannelID() != "" {
+					channelID = sett.GetMatchSummaryChannelID()
+				}
+				bot.sink.SendGameOver(channelID, embed, time.Minute*time.Duration(delTime))
+			}
+			bot.sink.EndMatch(*dgs, gameOverResult)
+
+			// refresh the game message if the setting is marked (it is not locked, the previous dgs is
+			// read-only). This means the original msg is refreshed, not the gameover message
+			if sett.AutoRefresh {
+				bot.RefreshGameStateMessage(ctx, dgsRequest, sett)
+			}
+
+			// now we need to fetch the state again (AFTER refreshing) to mark the game as complete/
+			lock, dgs, lockErr := bot.lockForUpdate(ctx, dgsRequest)
+			if lockErr == nil {
+				dgs.MatchID = -1
+				dgs.MatchStartUnix = -1
+				bot.sink.SetGameState(dgs, lock)
+			} else {
+				// the game over was already announced, so it's too late to try it again
+				bot.sink.GameStateLocked(dgsRequest, sett)
+			}
+		}
+	case task.MeetingJob:
+		dgs := bot.sink.ReadGameState(dgsRequest)
+		if dgs != nil {
+			correlatedUserID = dgs.GetUserIDByPlayerName(job.Meeting.Caller)
+		}
+	case task.VoteJob:
+		splitEvents = voteEvents(bot.gameLogger(guildID, connectCode), bot.sink.ReadGameState(dgsRequest), job.Votes, gameEvent)
+	case task.KillJob:
+		splitEvents = []correlatedEvent{killEvent(bot.gameLogger(guildID, connectCode), bot.sink.ReadGameState(dgsRequest), job.Kill, gameEvent)}
+	case task.TaskProgressJob:
+		lock, dgs, lockErr := bot.lockForUpdate(ctx, dgsRequest)
+		if lockErr != nil {
+			err = lockErr
+			break
+		}
+		dgs.GameData.SetTaskProgress(job.Tasks)
+		bot.sink.SetGameState(dgs, lock)
+
+		// otherwise, the progress isn't shown until the game is over, so there's nothing to edit
+		if sett.GetShowTaskProgress() {
+			bot.DispatchRefreshOrEdit(dgs, dgsRequest, sett)
+		}
+	}
+	if retryJob(sj, err) {
+		// the events are added when the job is tried again
+		return err
+	}
+	if job.JobType != task.ConnectionJob {
+		events := splitEvents
+		if events == nil {
+			events = []correlatedEvent{{userID: correlatedUserID, event: gameEvent}}
+		}
+		bot.sink.AddEvents(dgsRequest, events)
+	}
+	return err
+}
+
+// lockForUpdate locks the game state to apply an update from the capture, waiting at most GameStateLockTimeout
+func (bot *Bot) lockForUpdate(ctx context.Context, gsr GameStateRequest) (*redislock.Lock, *GameState, error) {
+	ctx, cancel := context.WithTimeout(ctx, GameStateLockTimeout)
+	defer cancel()
+	return bot.sink.LockGameState(ctx, gsr)
+}
+
+// correlatedEvent is a game event to persist, along with the user it should be correlated with (if any)
+type correlatedEvent struct {
+	userID string
+	event  storage.PostgresGameEvent
+}
+
+// killEvent makes the event attributing a death to its killer, correlated with the killer. The victim is matched by
+// name, since their own death is correlated with them already
+func killEvent(l *slog.Logger, dgs *GameState, kill game.Kill, ge storage.PostgresGameEvent) correlatedEvent {
+	ge.EventType = int16(task.KillJob)
+	payload, err := json.Marshal(kill)
+	if err != nil {
+		l.Error("Error encoding kill event", err)
+	}
+	ge.Payload = string(payload)
+	userID := ""
+	if dgs != nil {
+		userID = dgs.GetUserIDByPlayerName(kill.Killer)
+	}
+	return correlatedEvent{userID: userID, event: ge}
+}
+
+// voteEvents splits a meeting's votes into an event per vote, correlated with the voter, and an event for the player
+// who was voted out (if anybody was), correlated with them
+func voteEvents(l *slog.Logger, dgs *GameState, result game.VoteResult, ge storage.PostgresGameEvent) []correlatedEvent {
+	userIDFor := func(playerName string) string {
+		if dgs == nil {
+			return ""
+		}
+		return dgs.GetUserIDByPlayerName(playerName)
+	}
+
+	events := make([]correlatedEvent, 0, len(result.Votes)+1)
+	for _, vote := range result.Votes {
+		payload, err := json.Marshal(vote)
+		if err != nil {
+			l.Error("Error encoding vote event", err, "voter", vote.Voter)
+			continue
+		}
+		voteEvent := ge
+		voteEvent.Payload = string(payload)
+		events = append(events, correlatedEvent{userID: userIDFor(vote.Voter), event: voteEvent})
+	}
+	if result.Exiled != "" {
+		payload, err := json.Marshal(game.Exile{Exiled: result.Exiled})
+		if err != nil {
+			l.Error("Error encoding exile event", err, "exiled", result.Exiled)
+		} else {
+			exileEvent := ge
+			exileEvent.Payload = string(payload)
+			events = append(events, correlatedEvent{userID: userIDFor(result.Exiled), event: exileEvent})
+		}
+	}
+	return events
+}
+
 type winnerRecord struct {
 	userID string
 	role   game.GameRole
 }
 
-func getWinners(dgs GameState, gameOver game.Gameover) []winnerRecord {
-	var winners []winnerRecord
+func formatWinners(winners []winnerRecord) string {
+	buf := bytes.NewBuffer([]byte{})
+	for i, v := range winners {
+		buf.WriteString(fmt.Sprintf("<@%s>", v.userID))
+		if !isBaseRole(v.role) {
+			buf.WriteString(fmt.Sprintf(" (%s)", v.role))
+		}
+		if i < len(winners)-1 {
+			buf.WriteRune(',')
+		} else {
+			teamStr := "Crewmate"
+			if v.role.Team() == game.ImpostorTeam {
+				teamStr = "Imposter"
+			}
+			buf.WriteString(fmt.Sprintf(" won as %s", teamStr))
+		}
+	}
+	return buf.String()
+}
 
-	imposterWin := gameOver.GameOverReason == game.ImpostorByKill ||
-		gameOver.GameOverReason == game.ImpostorByVote ||
-		gameOver.GameOverReason == game.ImpostorBySabotage ||
-		gameOver.GameOverReason == game.ImpostorDisconnect
+// isBaseRole is true for the roles that don't need mentioning, because the team says it all
+func isBaseRole(role game.GameRole) bool {
+	return role == game.CrewmateRole || role == game.ImposterRole
+}
 
+// getPlayerRoles returns the role of every linked player, according to the game over results
+func getPlayerRoles(dgs GameState, gameOver game.Gameover) []winnerRecord {
+	var roles []winnerRecord
 	for _, player := range dgs.UserData {
-		if player.GetPlayerName() != amongus.UnlinkedPlayerName {
-			for _, v := range gameOver.PlayerInfos {
-				// only override for the imposters
-				if player.GetPlayerName() == v.Name {
-					if (v.IsImpostor && imposterWin) || (!v.IsImpostor && !imposterWin) {
-						role := game.CrewmateRole
-						if v.IsImpostor {
-							role = game.ImposterRole
-						}
-						winners = append(winners, winnerRecord{
-							userID: player.User.UserID,
-							role:   role,
-						})
-					}
-				}
+		if player.GetPlayerName() == amongus.UnlinkedPlayerName {
+			continue
+		}
+		for _, v := range gameOver.PlayerInfos {
+			if strings.EqualFold(player.GetPlayerName(), v.Name) {
+				roles = append(roles, winnerRecord{
+					userID: player.User.UserID,
+					role:   v.GameRole(),
+				})
+				break
 			}
 		}
 	}
+	sort.Slice(roles, func(i, j int) bool {
+		return roles[i].userID < roles[j].userID
+	})
+	return roles
+}
+
+func getWinners(dgs GameState, gameOver game.Gameover) []winnerRecord {
+	var winners []winnerRecord
+	for _, v := range getPlayerRoles(dgs, gameOver) {
+		if gameOver.GameOverReason.Won(v.role) {
+			winners = append(winners, v)
+		}
+	}
 	return winners
 }
 
-func (bot *Bot) processPlayer(sett *settings.GuildSettings, player game.Player, dgsRequest GameStateRequest) (bool, string, *GameState, error) {
+// formatRoles lists the players that had a role other than plain Crewmate or Impostor
+func formatRoles(roles []winnerRecord) string {
+	buf := bytes.NewBuffer([]byte{})
+	for _, v := range roles {
+		if isBaseRole(v.role) {
+			continue
+		}
+		if buf.Len() > 0 {
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(fmt.Sprintf("<@%s> %s", v.userID, v.role))
+	}
+	return buf.String()
+}
+
+// processPlayer updates a player, and returns whether the tracked members should be muted/deafened again, the user
+// linked to the player, and the game state it was updated in. If the game state couldn't be locked, the lock error is
+// all that's returned
+func (bot *Bot) processPlayer(ctx context.Context, sett *settings.GuildSettings, player game.Player, dgsRequest GameStateRequest) (bool, string, *GameState, error) {
 	var err error
 	if player.Name != "" {
-		lock, dgs := bot.RedisInterface.GetDiscordGameStateAndLock(dgsRequest)
-		for lock == nil {
-			lock, dgs = bot.RedisInterface.GetDiscordGameStateAndLock(dgsRequest)
+		lock, dgs, lockErr := bot.lockForUpdate(ctx, dgsRequest)
+		if lockErr != nil {
+			return false, "", nil, lockErr
 		}
 		dgs.Linked = true
 
-		defer bot.RedisInterface.SetDiscordGameState(dgs, lock)
+		defer bot.sink.SetGameState(dgs, lock)
 
 		if player.Disconnected || player.Action == game.LEFT {
 			if player.Disconnected {
-				log.Println("I detected that " + player.Name + " disconnected, I'm purging their player data!")
+				bot.matchLogger(dgs).Info("Player disconnected, clearing their player data", "player", player.Name)
 				dgs.ClearPlayerDataByPlayerName(player.Name)
 			}
 			_, _, data := dgs.GameData.UpdatePlayer(player)
 
-			userID := dgs.AttemptPairingByMatchingNames(data)
-			// try pairing via the cached usernames
-			if userID == "" {
-				var uids map[string]interface{}
-				uids, err = bot.RedisInterface.GetUsernameOrUserIDMappings(dgs.GuildID, player.Name)
-				userID = dgs.AttemptPairingByUserIDs(data, uids)
-			} else {
+			var userID string
+			userID, err = bot.pairPlayer(dgs, data)
+			if userID != "" {
 				err = bot.applyToSingle(dgs, userID, false, false)
 			}
 
@@ -315,28 +740,20 @@ func (bot *Bot) processPlayer(sett *settings.GuildSettings, player game.Player,
 		updated, isAliveUpdated, data := dgs.GameData.UpdatePlayer(player)
 		switch {
 		case player.Action == game.JOINED:
-			log.Println("Detected a player joined, refreshing User data mappings")
-			userID := dgs.AttemptPairingByMatchingNames(data)
-			if userID == "" {
-				var uids map[string]interface{}
-				uids, err = bot.RedisInterface.GetUsernameOrUserIDMappings(dgs.GuildID, player.Name)
-				userID = dgs.AttemptPairingByUserIDs(data, uids)
-			}
+			bot.matchLogger(dgs).Debug("Player joined, refreshing user data mappings", "player", player.Name)
+			var userID string
+			userID, err = bot.pairPlayer(dgs, data)
 			bot.DispatchRefreshOrEdit(dgs, dgsRequest, sett)
 			return true, userID, dgs, err
 		case updated:
-			userID := dgs.AttemptPairingByMatchingNames(data)
-			if userID == "" {
-				var uids map[string]interface{}
-				uids, err = bot.RedisInterface.GetUsernameOrUserIDMappings(dgs.GuildID, player.Name)
-				userID = dgs.AttemptPairingByUserIDs(data, uids)
-			}
+			var userID string
+			userID, err = bot.pairPlayer(dgs, data)
 			if isAliveUpdated && dgs.GameData.GetPhase() == game.TASKS {
 				if sett.GetUnmuteDeadDuringTasks() || player.Action == game.EXILED {
 					bot.DispatchRefreshOrEdit(dgs, dgsRequest, sett)
 					return true, userID, dgs, err
 				}
-				log.Println("NOT updating the discord status message; would leak info")
+				bot.matchLogger(dgs).Debug("Not updating the game message during tasks; it would leak who died", "player", player.Name)
 				return false, userID, dgs, err
 			}
 			bot.DispatchRefreshOrEdit(dgs, dgsRequest, sett)
@@ -351,88 +768,138 @@ func (bot *Bot) processPlayer(sett *settings.GuildSettings, player game.Player,
 	return false, "", nil, nil
 }
 
-func (bot *Bot) processTransition(phase game.Phase, dgsRequest GameStateRequest) {
-	sett := bot.StorageInterface.GetGuildSettings(dgsRequest.GuildID)
-	lock, dgs := bot.RedisInterface.GetDiscordGameStateAndLock(dgsRequest)
-	for lock == nil {
-		lock, dgs = bot.RedisInterface.GetDiscordGameStateAndLock(dgsRequest)
+// pairPlayer returns the user linked to the player, linking one first if nobody is. Users are matched by the aliases
+// they registered, and then by their names (and the in-game names they were linked to before). Spectators are skipped
+func (bot *Bot) pairPlayer(dgs *GameState, data amongus.PlayerData) (string, error) {
+	if userID := dgs.GetUserIDByPlayerName(data.Name); userID != "" {
+		return userID, nil
+	}
+	aliasUserID, err := bot.sink.UserIDByAlias(dgs.GuildID, data.Name)
+	if err != nil {
+		bot.matchLogger(dgs).Error("Error fetching the user with the player's alias", err, "player", data.Name)
+	} else if v, ok := dgs.UserData[aliasUserID]; ok && v.GetPlayerName() == amongus.UnlinkedPlayerName && !v.IsSpectator() {
+		v.Link(data)
+		dgs.UserData[aliasUserID] = v
+		return aliasUserID, nil
+	}
+	pastNames, err := bot.sink.PlayerNamesForUserIDs(dgs.GuildID, dgs.UnlinkedUserIDs())
+	userID, score := dgs.AttemptPairingByMatchingNames(data, pastNames)
+	if userID != "" {
+		bot.matchLogger(dgs).Info("Linked player to user by name", "player", data.Name, "user_id", userID, "confidence", score)
+	}
+	return userID, err
+}
+
+// processTransition updates the game for a new phase, and records how long it took from popping the job until every
+// player's voice state was updated (apart from the configured delay). The error is only set if the game state couldn't
+// be locked, in which case nothing was updated
+func (bot *Bot) processTransition(ctx context.Context, phase game.Phase, popped time.Time, dgsRequest GameStateRequest) error {
+	ctx, span := tracing.Start(ctx, "processTransition", tracing.GameAttributes(dgsRequest.GuildID, dgsRequest.ConnectCode),
+		trace.WithAttributes(tracing.PhaseKey.String(string(phase.ToString()))))
+	defer span.End()
+
+	sett := bot.sink.GuildSettings(dgsRequest.GuildID)
+	lock, dgs, err := bot.lockForUpdate(ctx, dgsRequest)
+	if err != nil {
+		return err
 	}
 
 	oldPhase := dgs.GameData.UpdatePhase(phase)
 	if oldPhase == phase {
-		lock.Release(ctx)
-		return
+		// release the lock
+		bot.sink.SetGameState(nil, lock)
+		return nil
 	}
 	dgs.Linked = true
 	// if we started a new game
 	if oldPhase == game.LOBBY && phase == game.TASKS {
-		matchStart := time.Now().Unix()
+		matchStart := bot.sink.Now().Unix()
 		dgs.MatchStartUnix = matchStart
-		gameID := startGameInPostgres(*dgs, bot.PostgresInterface)
+		gameID := bot.sink.StartMatch(*dgs)
 		dgs.MatchID = int64(gameID)
-		log.Printf("New match has begun. ID %d and starttime %d\n", gameID, matchStart)
+		bot.matchLogger(dgs).Info("New match has begun", "start_time", matchStart)
+	}
+	if phase == game.DISCUSS {
+		dgs.DiscussStartUnix = bot.sink.Now().Unix()
 	}
 
-	bot.RedisInterface.SetDiscordGameState(dgs, lock)
+	bot.sink.SetGameState(dgs, lock)
+	from := string(oldPhase.ToString())
+	to := string(phase.ToString())
+	// the latency only covers applying the transition to the players' voice states, not the delay the guild
+	// configured before applying it, or updating the game's message after
+	recordLatency := func(delay int) {
+		server.RecordTransitionLatency(from, to, time.Since(popped)-time.Second*time.Duration(delay))
+	}
 	switch phase {
 	case game.MENU:
-		bot.DispatchRefreshOrEdit(dgs, dgsRequest, sett)
-		err := bot.applyToAll(dgs, false, false)
+		err := bot.applyToAll(ctx, dgs, false, false)
 		if err != nil {
-			log.Println("Error in unmuting all users when returning to menu ", err)
+			bot.matchLogger(dgs).Error("Error in unmuting all users when returning to menu", err)
 		}
+		recordLatency(0)
+		bot.DispatchRefreshOrEdit(dgs, dgsRequest, sett)
 		// on a gameover event from the capture, it's like going to the lobby; use that delay
 	case game.GAMEOVER:
 		phase = game.LOBBY
 		fallthrough
 	case game.LOBBY:
-		delay := sett.Delays.GetDelay(oldPhase, phase)
-		bot.handleTrackedMembers(bot.PrimarySession, sett, delay, NoPriority, dgsRequest)
+		delay := sett.GetDelay(dgs.GameData.GetMode(), oldPhase, phase)
+		bot.handleTrackedMembers(ctx, sett, delay, NoPriority, dgsRequest)
+		recordLatency(delay)
 
 		bot.DispatchRefreshOrEdit(dgs, dgsRequest, sett)
 
 	case game.TASKS:
-		delay := sett.Delays.GetDelay(oldPhase, phase)
+		delay := sett.GetDelay(dgs.GameData.GetMode(), oldPhase, phase)
 		// when going from discussion to tasks, we should mute alive players FIRST
 		priority := AlivePriority
 		if oldPhase == game.LOBBY {
 			priority = NoPriority
 		}
 
-		bot.handleTrackedMembers(bot.PrimarySession, sett, delay, priority, dgsRequest)
+		bot.handleTrackedMembers(ctx, sett, delay, priority, dgsRequest)
+		recordLatency(delay)
 		bot.DispatchRefreshOrEdit(dgs, dgsRequest, sett)
 
 	case game.DISCUSS:
-		delay := sett.Delays.GetDelay(oldPhase, phase)
-		bot.handleTrackedMembers(bot.PrimarySession, sett, delay, DeadPriority, dgsRequest)
+		delay := sett.GetDelay(dgs.GameData.GetMode(), oldPhase, phase)
+		bot.handleTrackedMembers(ctx, sett, delay, DeadPriority, dgsRequest)
+		recordLatency(delay)
 
 		if sett.AutoRefresh {
-			bot.RefreshGameStateMessage(dgsRequest, sett)
+			bot.RefreshGameStateMessage(ctx, dgsRequest, sett)
 		} else {
 			bot.DispatchRefreshOrEdit(dgs, dgsRequest, sett)
 		}
 	}
+	return nil
 }
 
-func (bot *Bot) processLobby(sett *settings.GuildSettings, lobby game.Lobby, dgsRequest GameStateRequest) {
-	lock, dgs := bot.RedisInterface.GetDiscordGameStateAndLock(dgsRequest)
-	for lock == nil {
-		lock, dgs = bot.RedisInterface.GetDiscordGameStateAndLock(dgsRequest)
+func (bot *Bot) processLobby(ctx context.Context, sett *settings.GuildSettings, lobby game.Lobby, dgsRequest GameStateRequest) error {
+	lock, dgs, err := bot.lockForUpdate(ctx, dgsRequest)
+	if err != nil {
+		return err
 	}
 
 	dgs.GameData.SetRoomRegionMap(lobby.LobbyCode, lobby.Region.ToString(), lobby.PlayMap)
-	bot.RedisInterface.SetDiscordGameState(dgs, lock)
+	dgs.GameData.SetMode(lobby.GameMode)
+	if lobby.Options != nil {
+		dgs.GameData.SetOptions(lobby.Options)
+	}
+	bot.sink.SetGameState(dgs, lock)
 
 	bot.DispatchRefreshOrEdit(dgs, dgsRequest, sett)
+	return nil
 }
 
-func startGameInPostgres(dgs GameState, psql *storage.PsqlInterface) uint64 {
+func startGameInPostgres(l *slog.Logger, dgs GameState, psql *storage.PsqlInterface) uint64 {
 	if dgs.MatchStartUnix < 0 {
 		return 0
 	}
 	gid, err := strconv.ParseUint(dgs.GuildID, 10, 64)
 	if err != nil {
-		log.Println(err)
+		l.Error("Error parsing the guild ID", err)
 		return 0
 	}
 	pgame := &storage.PostgresGame{
@@ -442,66 +909,59 @@ func startGameInPostgres(dgs GameState, psql *storage.PsqlInterface) uint64 {
 		StartTime:   int32(dgs.MatchStartUnix),
 		WinType:     -1,
 		EndTime:     -1,
+		GameMode:    int16(dgs.GameData.GetMode()),
 	}
+	pgame.SetLobbyOptions(dgs.GameData.GetOptions())
 	i, err := psql.AddInitialGame(pgame)
 	if err != nil {
-		log.Println(err)
+		l.Error("Error adding the game to Postgres", err)
 	}
 	return i
 }
 
-func dumpGameToPostgres(dgs GameState, psql *storage.PsqlInterface, gameOver game.Gameover) {
+func dumpGameToPostgres(l *slog.Logger, dgs GameState, psql *storage.PsqlInterface, gameOver game.Gameover) {
 	if dgs.MatchID < 0 || dgs.MatchStartUnix < 0 {
-		log.Println("dgs match id or start time is <0; not dumping game to Postgres")
+		l.Warn("Match hasn't started; not saving the game to Postgres", "start_time", dgs.MatchStartUnix)
 		return
 	}
 	end := time.Now().Unix()
 
 	userGames := make([]*storage.PostgresUserGame, 0)
 
-	imposterWin := gameOver.GameOverReason == game.ImpostorByKill ||
-		gameOver.GameOverReason == game.ImpostorBySabotage ||
-		gameOver.GameOverReason == game.ImpostorByVote ||
-		gameOver.GameOverReason == game.ImpostorDisconnect
-
 	for _, v := range dgs.UserData {
 		if v.GetPlayerName() != amongus.UnlinkedPlayerName {
 			inGameData, found := dgs.GameData.GetByName(v.GetPlayerName())
 			if !found {
-				log.Println("No game data found for that player")
+				l.Warn("No game data found for linked player", "player", v.GetPlayerName())
 				continue
 			}
 
 			uid, err := strconv.ParseUint(v.User.UserID, 10, 64)
 			if err != nil {
-				log.Println(err)
+				l.Error("Error parsing the user ID", err, "user_id", v.User.UserID)
 				continue
 			}
 			gid, err := strconv.ParseUint(dgs.GuildID, 10, 64)
 			if err != nil {
-				log.Println(err)
+				l.Error("Error parsing the guild ID", err)
 				continue
 			}
 
 			puser, err := psql.EnsureUserExists(uid)
 			if err != nil || puser == nil {
-				log.Println(err)
+				l.Error("Error adding the user to Postgres", err, "user_id", v.User.UserID)
 				continue
 			}
 
 			// assume crewmate by default
-			won := !imposterWin
 			role := game.CrewmateRole
 			for _, pi := range gameOver.PlayerInfos {
-				// only override for the imposters
-				if pi.IsImpostor {
-					if strings.EqualFold(pi.Name, inGameData.Name) {
-						role = game.ImposterRole
-						won = imposterWin
-						break
-					}
+				if strings.EqualFold(pi.Name, inGameData.Name) {
+					role = pi.GameRole()
+					break
 				}
 			}
+			won := gameOver.GameOverReason.Won(role)
 
 			userGames = append(userGames, &storage.PostgresUserGame{
 				UserID:      puser.UserID,
@@ -514,10 +974,10 @@ func dumpGameToPostgres(dgs GameState, psql *storage.PsqlInterface, gameOver gam
 			})
 		}
 	}
-	log.Printf("Game %d has been completed and recorded in postgres\n", dgs.MatchID)
-
 	err := psql.UpdateGameAndPlayers(dgs.MatchID, int16(gameOver.GameOverReason), end, userGames)
 	if err != nil {
-		log.Println(err)
+		l.Error("Error saving the completed game to Postgres", err)
+		return
 	}
+	l.Info("Game has been completed and saved to Postgres", "players", len(userGames))
 }
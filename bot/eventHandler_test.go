@@ -0,0 +1,95 @@
+package bot
+
+import (
+	"context"
+	"github.com/automuteus/automuteus/v8/pkg/settings"
+	"github.com/automuteus/automuteus/v8/pkg/task"
+	"github.com/bsm/redislock"
+	"testing"
+	"time"
+)
+
+// lockedSink is a memorySink whose game state stays locked for the next few attempts to lock it
+type lockedSink struct {
+	*memorySink
+	failures int
+	notices  int
+}
+
+func (sink *lockedSink) LockGameState(ctx context.Context, gsr GameStateRequest) (*redislock.Lock, *GameState, error) {
+	if sink.failures > 0 {
+		sink.failures--
+		return nil, nil, &GameStateLockError{Attempts: 1, Err: context.DeadlineExceeded}
+	}
+	return sink.memorySink.LockGameState(ctx, gsr)
+}
+
+func (sink *lockedSink) GameStateLocked(_ GameStateRequest, _ *settings.GuildSettings) {
+	sink.notices++
+}
+
+func TestProcessReadyJobs_locked(t *testing.T) {
+	recording := &recordingSink{}
+	sink := &lockedSink{memorySink: newMemorySink(settings.MakeGuildSettings(), recording)}
+	sink.now = time.Now()
+	bot := &Bot{
+		StatusEmojis: GlobalAlivenessEmojis,
+		sink:         sink,
+	}
+	sources := task.NewSources(nil)
+	process := func(jobType task.JobType, payload string) {
+		rj := recorded(t, jobType, payload)
+		bot.processStreamJob(replayGuildID, replayConnectCode, sources, task.StreamJob{Job: rj.Job, Popped: rj.Time})
+	}
+	retry := func() {
+		sink.now = sink.now.Add(JobLockRetryDelay)
+		bot.expireHeldJobs(replayGuildID, replayConnectCode, sources)
+	}
+
+	process(task.ConnectionJob, "true")
+	process(task.StateJob, "0")
+	sink.joinVoice("Alice")
+	process(task.PlayerJob, `{"Action":0,"Name":"Alice","Color":0,"IsDead":false,"Disconnected":false}`)
+	sink.joinVoice("Bob")
+	process(task.PlayerJob, `{"Action":0,"Name":"Bob","Color":1,"IsDead":false,"Disconnected":false}`)
+
+	// the transition to tasks is put off (twice), and Alice's death waits behind it
+	sink.failures = 2
+	process(task.StateJob, "1")
+	process(task.PlayerJob, `{"Action":2,"Name":"Alice","Color":0,"IsDead":true,"Disconnected":false}`)
+	if len(recording.voice) != 0 {
+		t.Fatalf("Expected nobody to be muted while the transition is put off, got %v", recording.voice)
+	}
+	next, ok := sources.NextExpiry()
+	if !ok || !next.Equal(sink.now.Add(JobLockRetryDelay)) {
+		t.Fatalf("Expected the transition to be retried after %s, got %v", JobLockRetryDelay, next)
+	}
+	retry()
+	expected := []voiceChangeRecord{
+		{name: "Alice", mute: true, deaf: true},
+		{name: "Bob", mute: true, deaf: true},
+	}
+	if len(recording.voice) != len(expected) || recording.voice[0] != expected[0] || recording.voice[1] != expected[1] {
+		t.Fatalf("Expected everyone to be deafened once the transition was retried, got %v", recording.voice)
+	}
+	if sink.notices != 0 {
+		t.Errorf("Expected the game's channel not to be told about a transition that was applied, got %d notices", sink.notices)
+	}
+	dgs := sink.ReadGameState(GameStateRequest{})
+	if data, _ := dgs.GameData.GetByName("Alice"); data.IsAlive {
+		t.Error("Expected Alice's death to be applied after the transition")
+	}
+
+	// a job that stays locked is skipped once it's been tried JobLockAttempts times
+	sink.failures = JobLockAttempts
+	process(task.StateJob, "2")
+	for i := 1; i < JobLockAttempts; i++ {
+		retry()
+	}
+	if _, ok := sources.NextExpiry(); ok {
+		t.Error("Expected nothing left to retry")
+	}
+	if sink.notices != 1 || len(recording.voice) != len(expected) {
+		t.Errorf("Expected the transition to be skipped and the game's channel told, got %d notices and %v", sink.notices, recording.voice)
+	}
+}
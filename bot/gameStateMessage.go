@@ -1,7 +1,6 @@
 package bot
 
 import (
-	"github.com/automuteus/automuteus/v8/internal/server"
 	"github.com/automuteus/automuteus/v8/pkg/settings"
 	"sync"
 	"time"
@@ -72,9 +71,9 @@ func (dgs GameState) dispatchEdit(s *discordgo.Session, me *discordgo.MessageEmb
 	return newEdit
 }
 
-func (dgs GameState) shouldRefresh() bool {
+func (dgs GameState) shouldRefresh(now time.Time) bool {
 	// discord dictates that we can't edit messages that are older than 1 hour
-	return (time.Now().Sub(time.Unix(dgs.GameStateMsg.CreationTimeUnix, 0))) > time.Hour
+	return (now.Sub(time.Unix(dgs.GameStateMsg.CreationTimeUnix, 0))) > time.Hour
 }
 
 func ValidFields(me *discordgo.MessageEmbed) bool {
@@ -109,6 +108,19 @@ func deferredEditWorker(s *discordgo.Session, channelID, messageID string) {
 }
 
 func (dgs *GameState) CreateMessage(s *discordgo.Session, me *discordgo.MessageEmbed, channelID string, authorID string) bool {
+	msg := sendEmbedWithComponents(s, channelID, me, dgs.gameStateComponents())
+	if msg != nil {
+		dgs.GameStateMsg.LeaderID = authorID
+		dgs.GameStateMsg.MessageChannelID = msg.ChannelID
+		dgs.GameStateMsg.MessageID = msg.ID
+		dgs.GameStateMsg.CreationTimeUnix = time.Now().Unix()
+		return true
+	}
+	return false
+}
+
+// gameStateComponents are the color select, followed by the buttons for any link suggestions
+func (dgs *GameState) gameStateComponents() []discordgo.MessageComponent {
 	components := []discordgo.MessageComponent{
 		discordgo.ActionsRow{
 			Components: []discordgo.MessageComponent{
@@ -120,24 +132,13 @@ func (dgs *GameState) CreateMessage(s *discordgo.Session, me *discordgo.MessageE
 			},
 		},
 	}
-	msg := sendEmbedWithComponents(s, channelID, me, components)
-	if msg != nil {
-		dgs.GameStateMsg.LeaderID = authorID
-		dgs.GameStateMsg.MessageChannelID = msg.ChannelID
-		dgs.GameStateMsg.MessageID = msg.ID
-		dgs.GameStateMsg.CreationTimeUnix = time.Now().Unix()
-		return true
-	}
-	return false
+	return append(components, dgs.linkSuggestionComponents()...)
 }
 
 func (bot *Bot) DispatchRefreshOrEdit(readOnlyDgs *GameState, dgsRequest GameStateRequest, sett *settings.GuildSettings) {
-	if readOnlyDgs.shouldRefresh() {
-		bot.RefreshGameStateMessage(dgsRequest, sett)
+	if readOnlyDgs.shouldRefresh(bot.sink.Now()) {
+		bot.RefreshGameStateMessage(ctx, dgsRequest, sett)
 	} else {
-		edited := readOnlyDgs.dispatchEdit(bot.PrimarySession, bot.gameStateResponse(readOnlyDgs, sett))
-		if edited {
-			server.RecordDiscordRequests(bot.RedisInterface.client, server.MessageEdit, 1)
-		}
+		bot.sink.EditGameMessage(readOnlyDgs, bot.gameStateResponse(readOnlyDgs, sett))
 	}
 }
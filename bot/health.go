@@ -0,0 +1,125 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"github.com/automuteus/automuteus/v8/internal/server"
+	"github.com/automuteus/automuteus/v8/pkg/storage"
+	"sort"
+	"strings"
+	"time"
+)
+
+// GameLoopStaleAfter is how long a game's subscription loop can go without going around before it's considered stuck.
+// The loop polls for jobs every JobPollInterval, so a healthy loop is never idle for long
+const GameLoopStaleAfter = JobPollInterval * 6
+
+// beatRunningGame records that the game's subscription loop is still going around
+func (bot *Bot) beatRunningGame(connectCode string) {
+	bot.runningGamesLock.Lock()
+	bot.runningGameBeats[connectCode] = time.Now()
+	bot.runningGamesLock.Unlock()
+}
+
+// staleRunningGames returns how many games are running, and the connect codes of the ones whose subscription loops
+// haven't gone around in maxAge
+func (bot *Bot) staleRunningGames(now time.Time, maxAge time.Duration) (running int, stale []string) {
+	bot.runningGamesLock.Lock()
+	defer bot.runningGamesLock.Unlock()
+	for connectCode, beat := range bot.runningGameBeats {
+		if now.Sub(beat) > maxAge {
+			stale = append(stale, connectCode)
+		}
+	}
+	sort.Strings(stale)
+	return len(bot.runningGameBeats), stale
+}
+
+type sessionHealth struct {
+	Connected          bool  `json:"connected"`
+	HeartbeatLatencyMs int64 `json:"heartbeat_latency_ms"`
+}
+
+// primarySessionHealth reports whether the primary session's websocket is connected and ready
+func (bot *Bot) primarySessionHealth() sessionHealth {
+	sess := bot.PrimarySession
+	sess.RLock()
+	defer sess.RUnlock()
+	return sessionHealth{
+		Connected:          sess.DataReady,
+		HeartbeatLatencyMs: sess.HeartbeatLatency().Milliseconds(),
+	}
+}
+
+// HealthChecks are the checks /ready reports on for the bots (one per shard) of this process. None of them call out
+// to anything but Redis and Postgres
+func HealthChecks(bots []*Bot, psql *storage.PsqlInterface) map[string]server.Check {
+	return map[string]server.Check{
+		server.RedisCheck: func(ctx context.Context) (interface{}, error) {
+			client := bots[0].RedisInterface.client
+			stats := client.PoolStats()
+			return map[string]uint32{
+				"total_conns": stats.TotalConns,
+				"idle_conns":  stats.IdleConns,
+			}, client.Ping(ctx).Err()
+		},
+		server.PostgresCheck: func(ctx context.Context) (interface{}, error) {
+			stats := psql.Pool.Stat()
+			return map[string]int32{
+				"total_conns":    stats.TotalConns(),
+				"idle_conns":     stats.IdleConns(),
+				"acquired_conns": stats.AcquiredConns(),
+			}, psql.Pool.Ping(ctx)
+		},
+		server.DiscordCheck: func(ctx context.Context) (interface{}, error) {
+			shards := make(map[int]sessionHealth)
+			var disconnected []string
+			for _, b := range bots {
+				health := b.primarySessionHealth()
+				shards[b.shardID()] = health
+				if !health.Connected {
+					disconnected = append(disconnected, fmt.Sprint(b.shardID()))
+				}
+			}
+			if len(disconnected) > 0 {
+				return shards, fmt.Errorf("shard(s) %s disconnected", strings.Join(disconnected, ", "))
+			}
+			return shards, nil
+		},
+		server.WorkersCheck: func(ctx context.Context) (interface{}, error) {
+			tp := bots[0].TokenProvider
+			if tp == nil {
+				return nil, fmt.Errorf("no token provider")
+			}
+			sessions := tp.SessionStates()
+			disconnected := 0
+			for _, connected := range sessions {
+				if !connected {
+					disconnected++
+				}
+			}
+			if disconnected > 0 {
+				return sessions, fmt.Errorf("%d of %d worker session(s) disconnected", disconnected, len(sessions))
+			}
+			return sessions, nil
+		},
+		server.JobsCheck: func(ctx context.Context) (interface{}, error) {
+			now := time.Now()
+			running := 0
+			var stale []string
+			for _, b := range bots {
+				r, s := b.staleRunningGames(now, GameLoopStaleAfter)
+				running += r
+				stale = append(stale, s...)
+			}
+			details := map[string]interface{}{
+				"running_games": running,
+			}
+			if len(stale) > 0 {
+				details["stale_games"] = stale
+				return details, fmt.Errorf("%d game subscription loop(s) haven't run in %s", len(stale), GameLoopStaleAfter)
+			}
+			return details, nil
+		},
+	}
+}
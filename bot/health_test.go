@@ -0,0 +1,34 @@
+package bot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStaleRunningGames(t *testing.T) {
+	bot := Bot{
+		runningGames:     make(map[string]string),
+		runningGameBeats: make(map[string]time.Time),
+	}
+	bot.trackRunningGame("1", "ABCDEFGH")
+	bot.trackRunningGame("1", "HGFEDCBA")
+
+	now := time.Now()
+	running, stale := bot.staleRunningGames(now, GameLoopStaleAfter)
+	if running != 2 || len(stale) != 0 {
+		t.Errorf("expected 2 running games and none stale, got %d and %v", running, stale)
+	}
+
+	bot.beatRunningGame("ABCDEFGH")
+	running, stale = bot.staleRunningGames(now.Add(GameLoopStaleAfter).Add(time.Second), GameLoopStaleAfter)
+	if running != 2 || len(stale) != 2 {
+		t.Errorf("expected both games to be stale once their loops stop, got %d and %v", running, stale)
+	}
+
+	bot.untrackRunningGame("HGFEDCBA")
+	bot.beatRunningGame("ABCDEFGH")
+	running, stale = bot.staleRunningGames(time.Now(), GameLoopStaleAfter)
+	if running != 1 || len(stale) != 0 {
+		t.Errorf("expected 1 running game and none stale, got %d and %v", running, stale)
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
-	"log"
 	"math/rand"
 	"os"
 	"regexp"
@@ -75,7 +74,7 @@ func sendEmbedWithComponents(s *discordgo.Session, channelID string, message *di
 	}
 	msg, err := s.ChannelMessageSendComplex(channelID, &complexMsg)
 	if err != nil {
-		log.Println(err)
+		logger.Error("Error sending message", err, "channel_id", channelID)
 	}
 	return msg
 }
@@ -84,7 +83,7 @@ func editMessageEmbed(s *discordgo.Session, channelID string, messageID string,
 	me := discordgo.NewMessageEdit(channelID, messageID).SetEmbed(message)
 	msg, err := s.ChannelMessageEditComplex(me)
 	if err != nil {
-		log.Println("Error when attempting to edit complex message", err)
+		logger.Error("Error editing message", err, "channel_id", channelID, "message_id", messageID)
 	}
 	return msg
 }
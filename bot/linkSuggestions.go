@@ -0,0 +1,303 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"github.com/automuteus/automuteus/v8/bot/command"
+	"github.com/automuteus/automuteus/v8/pkg/amongus"
+	"github.com/automuteus/automuteus/v8/pkg/discord"
+	"github.com/automuteus/automuteus/v8/pkg/game"
+	"github.com/automuteus/automuteus/v8/pkg/namematch"
+	"github.com/automuteus/automuteus/v8/pkg/settings"
+	"github.com/automuteus/automuteus/v8/pkg/storage"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+const (
+	suggestionConfirmPrefix = "suggest-confirm:"
+	suggestionRejectPrefix  = "suggest-reject:"
+
+	// the game message can have 5 rows of components, and the color select takes one of them
+	maxLinkSuggestions = 4
+
+	// a game played under the player's name says a lot more than one played in the same color
+	suggestionNameWeight  = 3
+	suggestionColorWeight = 1
+	minSuggestionScore    = 3
+)
+
+// LinkSuggestion proposes linking an unlinked user in the voice channel to an unlinked player, based on who they've
+// played as in this guild before
+type LinkSuggestion struct {
+	UserID     string `json:"userID"`
+	PlayerName string `json:"playerName"`
+	Color      int    `json:"color"`
+	// Games is how many of the user's past games back up the suggestion
+	Games int64 `json:"games"`
+}
+
+func (s LinkSuggestion) key() string {
+	return s.UserID + ":" + strconv.Itoa(s.Color)
+}
+
+// suggestLinks pairs unlinked players with unlinked users, best match first, so each player and user is only suggested
+// once. Pairs that were already rejected aren't suggested again
+func suggestLinks(players []amongus.PlayerData, userIDs []string, history []*storage.PostgresUserPlayerHistory, rejected []string) []LinkSuggestion {
+	isRejected := make(map[string]bool, len(rejected))
+	for _, v := range rejected {
+		isRejected[v] = true
+	}
+	isUser := make(map[string]bool, len(userIDs))
+	for _, v := range userIDs {
+		isUser[v] = true
+	}
+	historyByUser := make(map[string][]*storage.PostgresUserPlayerHistory)
+	for _, v := range history {
+		userID := strconv.FormatUint(v.UserID, 10)
+		if isUser[userID] {
+			historyByUser[userID] = append(historyByUser[userID], v)
+		}
+	}
+
+	type scored struct {
+		LinkSuggestion
+		score int64
+	}
+	var candidates []scored
+	for _, player := range players {
+		key := namematch.Normalize(player.Name)
+		for userID, rows := range historyByUser {
+			s := scored{LinkSuggestion: LinkSuggestion{UserID: userID, PlayerName: player.Name, Color: player.Color}}
+			for _, row := range rows {
+				switch {
+				case key != "" && namematch.Normalize(row.PlayerName) == key:
+					s.score += suggestionNameWeight * row.Count
+				case int(row.PlayerColor) == player.Color:
+					s.score += suggestionColorWeight * row.Count
+				default:
+					continue
+				}
+				s.Games += row.Count
+			}
+			if s.score >= minSuggestionScore && !isRejected[s.key()] {
+				candidates = append(candidates, s)
+			}
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].score != candidates[j].score {
+			return candidates[i].score > candidates[j].score
+		}
+		if candidates[i].UserID != candidates[j].UserID {
+			return candidates[i].UserID < candidates[j].UserID
+		}
+		return candidates[i].Color < candidates[j].Color
+	})
+
+	var suggestions []LinkSuggestion
+	usedUsers, usedPlayers := make(map[string]bool), make(map[string]bool)
+	for _, c := range candidates {
+		if len(suggestions) == maxLinkSuggestions {
+			break
+		}
+		if usedUsers[c.UserID] || usedPlayers[c.PlayerName] {
+			continue
+		}
+		usedUsers[c.UserID] = true
+		usedPlayers[c.PlayerName] = true
+		suggestions = append(suggestions, c.LinkSuggestion)
+	}
+	return suggestions
+}
+
+// linkSuggestions works out the suggestions for the game's unlinked players, from the unlinked users currently in the
+// tracked voice channel (other than spectators)
+func (bot *Bot) linkSuggestions(dgs *GameState) []LinkSuggestion {
+	if !dgs.GameStateMsg.Exists() || dgs.VoiceChannel == "" || dgs.GameData.GetPhase() == game.MENU {
+		return nil
+	}
+	var players []amongus.PlayerData
+	for _, player := range dgs.GameData.PlayerData {
+		if dgs.GetUserIDByPlayerName(player.Name) == "" {
+			players = append(players, player)
+		}
+	}
+	if len(players) == 0 {
+		return nil
+	}
+
+	g, err := bot.sink.Guild(dgs.GuildID)
+	if err != nil {
+		bot.matchLogger(dgs).Error("Error fetching the guild for link suggestions", err)
+		return nil
+	}
+	var userIDs []string
+	var uids []uint64
+	for _, v := range g.VoiceStates {
+		if v.ChannelID != dgs.VoiceChannel {
+			continue
+		}
+		if user, ok := dgs.UserData[v.UserID]; ok && user.GetPlayerName() == amongus.UnlinkedPlayerName && !user.IsSpectator() {
+			uid, err := strconv.ParseUint(v.UserID, 10, 64)
+			if err != nil {
+				continue
+			}
+			userIDs = append(userIDs, v.UserID)
+			uids = append(uids, uid)
+		}
+	}
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	history, err := bot.sink.UserPlayerHistory(dgs.GuildID, uids)
+	if err != nil {
+		bot.matchLogger(dgs).Error("Error fetching the users' player history for link suggestions", err)
+		return nil
+	}
+	return suggestLinks(players, userIDs, history, dgs.RejectedSuggestions)
+}
+
+// updateLinkSuggestions recomputes the game's link suggestions, and only edits the game message's buttons if they
+// changed
+func (bot *Bot) updateLinkSuggestions(gsr GameStateRequest) {
+	lockCtx, cancel := context.WithTimeout(ctx, GameStateLockTimeout)
+	defer cancel()
+	// the suggestions are recomputed the next time a player or user changes
+	lock, dgs, err := bot.sink.LockGameState(lockCtx, gsr)
+	if err != nil {
+		return
+	}
+	suggestions := bot.linkSuggestions(dgs)
+	if sameSuggestions(suggestions, dgs.LinkSuggestions) {
+		// release the lock
+		bot.sink.SetGameState(nil, lock)
+		return
+	}
+	dgs.LinkSuggestions = suggestions
+	bot.sink.SetGameState(dgs, lock)
+	bot.sink.EditGameMessageComponents(dgs)
+}
+
+func sameSuggestions(a, b []LinkSuggestion) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// linkSuggestionComponents has a row for each suggestion, with a button to confirm it and one to reject it
+func (dgs *GameState) linkSuggestionComponents() []discordgo.MessageComponent {
+	var rows []discordgo.MessageComponent
+	for _, s := range dgs.LinkSuggestions {
+		user, ok := dgs.UserData[s.UserID]
+		if !ok {
+			continue
+		}
+		var emoji *discordgo.ComponentEmoji
+		if s.Color >= 0 && s.Color < len(GlobalAlivenessEmojis[true]) {
+			emoji = &discordgo.ComponentEmoji{ID: GlobalAlivenessEmojis[true][s.Color].ID}
+		}
+		rows = append(rows, discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					Label:    fmt.Sprintf("%s → %s (%d)", user.GetUserName(), s.PlayerName, s.Games),
+					Style:    discordgo.SuccessButton,
+					Emoji:    emoji,
+					CustomID: suggestionConfirmPrefix + s.key(),
+				},
+				discordgo.Button{
+					Style:    discordgo.SecondaryButton,
+					Emoji:    &discordgo.ComponentEmoji{Name: X},
+					CustomID: suggestionRejectPrefix + s.key(),
+				},
+			},
+		})
+	}
+	return rows
+}
+
+// handleLinkSuggestion confirms or rejects one of the game's link suggestions. Anyone who can link players can
+// confirm any suggestion, and users can also confirm the ones about themselves
+func (bot *Bot) handleLinkSuggestion(gsr GameStateRequest, memberID, customID string, isPermissioned bool, sett *settings.GuildSettings) *discordgo.InteractionResponse {
+	confirm := strings.HasPrefix(customID, suggestionConfirmPrefix)
+	key := strings.TrimPrefix(strings.TrimPrefix(customID, suggestionConfirmPrefix), suggestionRejectPrefix)
+
+	lock, dgs, err := bot.lockForInteraction(gsr)
+	if err != nil {
+		return command.DeadlockGameStateResponse(command.Link.Name, sett)
+	}
+	suggestion := dgs.linkSuggestion(key)
+	if suggestion == nil {
+		bot.RedisInterface.SetDiscordGameState(nil, lock)
+		go bot.updateLinkSuggestions(gsr)
+		return linkSuggestionResponse(sett.LocalizeMessage(&i18n.Message{
+			ID:    "linkSuggestions.outdated",
+			Other: "That suggestion is out of date",
+		}))
+	}
+	if suggestion.UserID != memberID && !isPermissioned {
+		bot.RedisInterface.SetDiscordGameState(nil, lock)
+		return command.InsufficientPermissionsResponse(sett)
+	}
+
+	var resp *discordgo.InteractionResponse
+	if confirm {
+		var success bool
+		resp, success = bot.linkOrUnlinkAndRespond(dgs, suggestion.UserID, game.GetColorStringForInt(suggestion.Color), sett)
+		if !success {
+			bot.RedisInterface.SetDiscordGameState(nil, lock)
+			return resp
+		}
+	} else {
+		dgs.RejectedSuggestions = append(dgs.RejectedSuggestions, key)
+		resp = linkSuggestionResponse(sett.LocalizeMessage(&i18n.Message{
+			ID:    "linkSuggestions.rejected",
+			Other: "Got it, I won't suggest linking {{.User}} to {{.PlayerName}} again this game",
+		}, map[string]interface{}{
+			"User":       discord.MentionByUserID(suggestion.UserID),
+			"PlayerName": suggestion.PlayerName,
+		}))
+	}
+	bot.RedisInterface.SetDiscordGameState(dgs, lock)
+	if confirm {
+		bot.DispatchRefreshOrEdit(dgs, gsr, sett)
+	}
+	go bot.updateLinkSuggestions(gsr)
+	return resp
+}
+
+// linkSuggestion returns the game's suggestion with the key, unless its player has changed color since (the key is the
+// color, so the button would link the user to whoever has the color now)
+func (dgs *GameState) linkSuggestion(key string) *LinkSuggestion {
+	for i, v := range dgs.LinkSuggestions {
+		if v.key() != key {
+			continue
+		}
+		if player, ok := dgs.GameData.PlayerData[v.PlayerName]; ok && player.Color == v.Color {
+			return &dgs.LinkSuggestions[i]
+		}
+		return nil
+	}
+	return nil
+}
+
+func linkSuggestionResponse(content string) *discordgo.InteractionResponse {
+	return &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags:   1 << 6, //private message
+			Content: content,
+		},
+	}
+}
@@ -0,0 +1,77 @@
+package bot
+
+import (
+	"github.com/automuteus/automuteus/v8/pkg/amongus"
+	"github.com/automuteus/automuteus/v8/pkg/storage"
+	"testing"
+)
+
+func TestSuggestLinks(t *testing.T) {
+	players := []amongus.PlayerData{
+		{Name: "Kuro", Color: 0},
+		{Name: "Soup", Color: 1},
+		{Name: "Denver", Color: 2},
+	}
+	history := []*storage.PostgresUserPlayerHistory{
+		// user 1 always plays as Kuro, sometimes in Soup's color
+		{UserID: 1, PlayerName: "kuro", PlayerColor: 5, Count: 4},
+		{UserID: 1, PlayerName: "Kuro", PlayerColor: 1, Count: 2},
+		// user 2 has only ever played red, like Kuro is now
+		{UserID: 2, PlayerName: "Someone", PlayerColor: 0, Count: 10},
+		// user 3 played as Denver once, which is enough
+		{UserID: 3, PlayerName: "Denver", PlayerColor: 7, Count: 1},
+		// user 4 played blue twice, which isn't
+		{UserID: 4, PlayerName: "Bob", PlayerColor: 1, Count: 2},
+		// user 5 isn't in the voice channel
+		{UserID: 5, PlayerName: "Soup", PlayerColor: 1, Count: 50},
+	}
+	userIDs := []string{"1", "2", "3", "4"}
+
+	suggestions := suggestLinks(players, userIDs, history, nil)
+	expected := []LinkSuggestion{
+		{UserID: "1", PlayerName: "Kuro", Color: 0, Games: 6},
+		{UserID: "3", PlayerName: "Denver", Color: 2, Games: 1},
+	}
+	if !sameSuggestions(suggestions, expected) {
+		t.Errorf("Expected suggestions %v, got %v", expected, suggestions)
+	}
+
+	// once user 1 is rejected for Kuro, user 2 is the next best guess; user 1's two games in Soup's color aren't enough
+	suggestions = suggestLinks(players, userIDs, history, []string{"1:0"})
+	expected = []LinkSuggestion{
+		{UserID: "2", PlayerName: "Kuro", Color: 0, Games: 10},
+		{UserID: "3", PlayerName: "Denver", Color: 2, Games: 1},
+	}
+	if !sameSuggestions(suggestions, expected) {
+		t.Errorf("Expected suggestions %v, got %v", expected, suggestions)
+	}
+
+	if suggestions := suggestLinks(players, nil, history, nil); len(suggestions) != 0 {
+		t.Errorf("Expected no suggestions without any users, got %v", suggestions)
+	}
+}
+
+func TestGameState_linkSuggestion(t *testing.T) {
+	dgs := GameState{
+		GameData: amongus.NewGameData(),
+		LinkSuggestions: []LinkSuggestion{
+			{UserID: "1", PlayerName: "Kuro", Color: 0},
+		},
+	}
+	dgs.GameData.PlayerData["Kuro"] = amongus.PlayerData{Name: "Kuro", Color: 0}
+	key := dgs.LinkSuggestions[0].key()
+
+	if s := dgs.linkSuggestion(key); s == nil || s.PlayerName != "Kuro" {
+		t.Errorf("Expected the suggestion for Kuro, got %v", s)
+	}
+	if s := dgs.linkSuggestion("2:0"); s != nil {
+		t.Errorf("Expected no suggestion for an unknown key, got %v", s)
+	}
+
+	// Kuro changed color, and Soup took theirs; the button mustn't link the user to Soup
+	dgs.GameData.PlayerData["Kuro"] = amongus.PlayerData{Name: "Kuro", Color: 1}
+	dgs.GameData.PlayerData["Soup"] = amongus.PlayerData{Name: "Soup", Color: 0}
+	if s := dgs.linkSuggestion(key); s != nil {
+		t.Errorf("Expected the suggestion to be out of date once the player changed color, got %v", s)
+	}
+}
@@ -0,0 +1,57 @@
+package bot
+
+import (
+	"github.com/automuteus/automuteus/v8/internal/logging"
+	"github.com/bwmarrin/discordgo"
+	"golang.org/x/exp/slog"
+)
+
+var logger = logging.For("bot")
+
+// shardID is the shard of the bot's primary session
+func (bot *Bot) shardID() int {
+	if bot.PrimarySession == nil {
+		return 0
+	}
+	return bot.PrimarySession.ShardID
+}
+
+// gameLogger logs lines about a game, with the game's guild, connect code and shard
+func (bot *Bot) gameLogger(guildID, connectCode string) *slog.Logger {
+	return logger.With(
+		logging.GuildIDKey, guildID,
+		logging.ConnectCodeKey, connectCode,
+		logging.ShardIDKey, bot.shardID(),
+	)
+}
+
+// matchLogger logs lines about a game like gameLogger, and with the match ID too once the match has started
+func (bot *Bot) matchLogger(dgs *GameState) *slog.Logger {
+	l := bot.gameLogger(dgs.GuildID, dgs.ConnectCode)
+	if dgs.MatchID > 0 {
+		l = l.With(logging.MatchIDKey, dgs.MatchID)
+	}
+	return l
+}
+
+// captureLogger logs lines about a capture client connected directly to the bot
+func (bot *Bot) captureLogger(connectCode, source string) *slog.Logger {
+	return logger.With(
+		logging.ConnectCodeKey, connectCode,
+		logging.ShardIDKey, bot.shardID(),
+		"source", source,
+	)
+}
+
+// interactionLogger logs lines about an interaction, with its guild, user and shard
+func (bot *Bot) interactionLogger(i *discordgo.InteractionCreate) *slog.Logger {
+	l := logger.With(
+		logging.GuildIDKey, i.GuildID,
+		logging.ShardIDKey, bot.shardID(),
+		"interaction_id", i.ID,
+	)
+	if i.Member != nil && i.Member.User != nil {
+		l = l.With("user_id", i.Member.User.ID)
+	}
+	return l
+}
@@ -1,8 +1,9 @@
 package bot
 
 import (
+	"context"
+	"github.com/automuteus/automuteus/v8/pkg/amongus"
 	"github.com/automuteus/automuteus/v8/pkg/settings"
-	"log"
 	"strconv"
 	"time"
 
@@ -13,10 +14,18 @@ import (
 	"github.com/bwmarrin/discordgo"
 )
 
+// VoiceStateLockTimeout bounds how long a voice state update waits for the game state lock. Voice state updates are
+// frequent, so they don't wait long
+const VoiceStateLockTimeout = time.Second
+
 // voiceStateChange handles more edge-case behavior for users moving between voice channels, and catches when
 // relevant discord api requests are fully applied successfully. Otherwise, we can issue multiple requests for
 // the same mute/unmute, erroneously
 func (bot *Bot) handleVoiceStateChange(s *discordgo.Session, m *discordgo.VoiceStateUpdate) {
+	// players are being unmuted to shut down, so they mustn't be muted again
+	if bot.isStopping() {
+		return
+	}
 	snowFlakeLock := bot.RedisInterface.LockSnowflake(m.ChannelID + m.UserID + m.SessionID)
 	// couldn't obtain lock; bail bail bail!
 	if snowFlakeLock == nil {
@@ -36,8 +45,11 @@ func (bot *Bot) handleVoiceStateChange(s *discordgo.Session, m *discordgo.VoiceS
 		VoiceChannel: m.ChannelID,
 	}
 
-	stateLock, dgs := bot.RedisInterface.GetDiscordGameStateAndLock(gsr)
-	if stateLock == nil {
+	// if the game state stays locked, the reconciler corrects the voice states of anyone left out of sync
+	lockCtx, cancel := context.WithTimeout(ctx, VoiceStateLockTimeout)
+	defer cancel()
+	stateLock, dgs, err := bot.RedisInterface.GetDiscordGameStateAndLock(lockCtx, gsr)
+	if err != nil {
 		return
 	}
 	defer stateLock.Release(ctx)
@@ -65,25 +77,41 @@ func (bot *Bot) handleVoiceStateChange(s *discordgo.Session, m *discordgo.VoiceS
 
 	tracked := m.ChannelID != "" && dgs.VoiceChannel == m.ChannelID
 
-	auData, found := dgs.GameData.GetByName(userData.InGameName)
-
-	var isAlive bool
-
-	// only actually tracked if we're in a tracked channel AND linked to a player
-	if !sett.GetMuteSpectator() {
-		tracked = tracked && found
-		isAlive = auData.IsAlive
-	} else {
-		if !found {
-			// we just assume the spectator is dead
-			isAlive = false
-		} else {
-			isAlive = auData.IsAlive
+	channelChanged := m.BeforeUpdate == nil || m.BeforeUpdate.ChannelID != m.ChannelID
+	// the member in every update has their current roles, so gaining or losing the spectator role applies right away
+	spectatorChanged := false
+	if dgs.isGameChannel(sett, m.ChannelID) && userData.GetID() != "" {
+		spectatorChanged = applySpectatorRole(sett, &userData, m.Member)
+		if spectatorChanged {
+			dgs.UpdateUserData(m.UserID, userData)
 		}
 	}
-	mute, deaf := sett.GetVoiceState(isAlive, tracked, dgs.GameData.GetPhase())
-	// check the userdata is linked here to not accidentally undeafen music bots, for example
-	if found && (userData.ShouldBeDeaf != deaf || userData.ShouldBeMute != mute) && (mute != m.Mute || deaf != m.Deaf) {
+
+	_, found := dgs.GameData.GetByName(userData.InGameName)
+	mute, deaf, _, _ := dgs.voiceState(sett, userData, tracked)
+	switch {
+	case sett.GetVoiceStrategy() == settings.MoveStrategy:
+		// players that wander off into the wrong channel get moved back where they belong
+		userModify, _, changed := dgs.moveChange(sett, userData, m.ChannelID)
+		if changed {
+			userData.SetShouldBeMuteDeaf(false, false)
+			dgs.UpdateUserData(m.UserID, userData)
+
+			if dgs.Running {
+				req := task.UserModifyRequest{
+					Premium: premTier,
+					Phase:   dgs.phaseName(),
+					Users:   []task.UserModify{userModify},
+				}
+				err = bot.TokenProvider.ModifyUsers(ctx, m.GuildID, dgs.ConnectCode, req, voiceLock)
+				if err != nil {
+					bot.matchLogger(dgs).Error("Error moving user", err, "user_id", m.UserID)
+				}
+			}
+		}
+	// check the userdata is linked (or spectating) here to not accidentally undeafen music bots, for example. Users
+	// who just stopped spectating get unmuted, if they aren't managed anymore
+	case (found || userData.IsSpectator() || spectatorChanged) && (userData.ShouldBeDeaf != deaf || userData.ShouldBeMute != mute) && (mute != m.Mute || deaf != m.Deaf):
 		userData.SetShouldBeMuteDeaf(mute, deaf)
 
 		dgs.UpdateUserData(m.UserID, userData)
@@ -92,6 +120,7 @@ func (bot *Bot) handleVoiceStateChange(s *discordgo.Session, m *discordgo.VoiceS
 			uid, _ := strconv.ParseUint(m.UserID, 10, 64)
 			req := task.UserModifyRequest{
 				Premium: premTier,
+				Phase:   dgs.phaseName(),
 				Users: []task.UserModify{
 					{
 						UserID: uid,
@@ -100,23 +129,28 @@ func (bot *Bot) handleVoiceStateChange(s *discordgo.Session, m *discordgo.VoiceS
 					},
 				},
 			}
-			err = bot.TokenProvider.ModifyUsers(m.GuildID, dgs.ConnectCode, req, voiceLock)
+			err = bot.TokenProvider.ModifyUsers(ctx, m.GuildID, dgs.ConnectCode, req, voiceLock)
 			if err != nil {
-				log.Println("error received from galactus for modifyUsers: ", err.Error())
+				bot.matchLogger(dgs).Error("Error muting or deafening user", err, "user_id", m.UserID)
 			}
 		}
 	}
 	bot.RedisInterface.SetDiscordGameState(dgs, stateLock)
+
+	// an unlinked user joining or leaving the game's channel changes who we can suggest linking
+	if channelChanged && dgs.GameStateMsg.Exists() && userData.GetPlayerName() == amongus.UnlinkedPlayerName {
+		go bot.updateLinkSuggestions(GameStateRequest{GuildID: dgs.GuildID, ConnectCode: dgs.ConnectCode})
+	}
 }
 
 func (bot *Bot) handleGameStartMessage(guildID, textChannelID, voiceChannelID, userID string, sett *settings.GuildSettings, g *discordgo.Guild, connCode string) {
-	lock, dgs := bot.RedisInterface.GetDiscordGameStateAndLock(GameStateRequest{
+	// without the lock, the game's message isn't created; it can be created with /refresh
+	lock, dgs, err := bot.lockForInteraction(GameStateRequest{
 		GuildID:     guildID,
 		TextChannel: textChannelID,
 		ConnectCode: connCode,
 	})
-	if lock == nil {
-		log.Println("Couldn't obtain lock for DGS on game start...")
+	if err != nil {
 		return
 	}
 	dgs.GameData.Reset()
@@ -0,0 +1,191 @@
+package bot
+
+import (
+	"context"
+	"github.com/automuteus/automuteus/v8/internal/server"
+	"github.com/automuteus/automuteus/v8/pkg/premium"
+	"github.com/automuteus/automuteus/v8/pkg/settings"
+	"github.com/automuteus/automuteus/v8/pkg/task"
+	"github.com/bwmarrin/discordgo"
+	"strconv"
+	"time"
+)
+
+const (
+	// VoiceReconcileInterval is how often a game's voice states are checked against the state they should be in
+	VoiceReconcileInterval = time.Second * 15
+
+	// VoiceReconcileLockTimeout bounds how long a reconciliation waits for the game state lock
+	VoiceReconcileLockTimeout = time.Second * 2
+
+	// MaxVoiceCorrections is the most users a single reconciliation of a game sends corrective requests for
+	MaxVoiceCorrections = 5
+
+	DriftMute    = "mute"
+	DriftDeaf    = "deaf"
+	DriftChannel = "channel"
+)
+
+// voiceDrift is a user whose actual voice state doesn't match the state they should be in
+type voiceDrift struct {
+	task.UserModify
+	// kinds is what was out of sync (DriftMute, DriftDeaf and/or DriftChannel)
+	kinds []string
+}
+
+// voiceReconciler periodically corrects the voice states of a game's players, for when mute requests failed silently
+// (a blacklisted worker bot, for example). A user is only corrected when they're out of sync on two checks in a row,
+// so requests that are still being applied aren't sent twice
+type voiceReconciler struct {
+	gsr      GameStateRequest
+	drifting map[uint64]bool
+}
+
+func newVoiceReconciler(gsr GameStateRequest) *voiceReconciler {
+	return &voiceReconciler{
+		gsr:      gsr,
+		drifting: make(map[uint64]bool),
+	}
+}
+
+// confirm remembers who's drifting now, and returns the drifts that were also seen on the previous check
+func (r *voiceReconciler) confirm(drifts []voiceDrift) []voiceDrift {
+	var confirmed []voiceDrift
+	drifting := make(map[uint64]bool, len(drifts))
+	for _, d := range drifts {
+		drifting[d.UserID] = true
+		if r.drifting[d.UserID] {
+			confirmed = append(confirmed, d)
+		}
+	}
+	r.drifting = drifting
+	return confirmed
+}
+
+func (r *voiceReconciler) reset() {
+	r.drifting = make(map[uint64]bool)
+}
+
+// reconcileVoiceStates compares the voice states in the game's channels with the state each player should be in, and
+// sends corrective requests for players that stayed out of sync
+func (bot *Bot) reconcileVoiceStates(r *voiceReconciler) {
+	// the states are checked again on the next pass
+	lockCtx, cancel := context.WithTimeout(ctx, VoiceReconcileLockTimeout)
+	defer cancel()
+	lock, dgs, err := bot.RedisInterface.GetDiscordGameStateAndLock(lockCtx, r.gsr)
+	if err != nil {
+		return
+	}
+	if !dgs.Running || !dgs.GameStateMsg.Exists() || dgs.VoiceChannel == "" {
+		r.reset()
+		bot.RedisInterface.SetDiscordGameState(nil, lock)
+		return
+	}
+	// voice changes are already being applied (or waiting on a delay); the states will change under us
+	voiceLock := bot.RedisInterface.LockVoiceChanges(dgs.ConnectCode, time.Second*5)
+	if voiceLock == nil {
+		r.reset()
+		bot.RedisInterface.SetDiscordGameState(nil, lock)
+		return
+	}
+
+	g, err := bot.PrimarySession.State.Guild(dgs.GuildID)
+	if err != nil || g == nil {
+		voiceLock.Release(ctx)
+		bot.RedisInterface.SetDiscordGameState(nil, lock)
+		return
+	}
+	sett := bot.StorageInterface.GetGuildSettings(dgs.GuildID)
+
+	dgs.applySpectatorRoles(sett, g)
+	drifts := r.confirm(dgs.voiceDrifts(sett, g.VoiceStates))
+	if len(drifts) > MaxVoiceCorrections {
+		// the rest get picked up on the next checks
+		drifts = drifts[:MaxVoiceCorrections]
+	}
+	users := make([]task.UserModify, len(drifts))
+	for i, d := range drifts {
+		for _, kind := range d.kinds {
+			server.RecordVoiceStateDrift(kind)
+		}
+		users[i] = d.UserModify
+		userID := strconv.FormatUint(d.UserID, 10)
+		if userData, err := dgs.GetUser(userID); err == nil {
+			userData.SetShouldBeMuteDeaf(d.Mute, d.Deaf)
+			dgs.UpdateUserData(userID, userData)
+		}
+		// we're sending the correction, so don't count them again until it's had a chance to apply
+		delete(r.drifting, d.UserID)
+	}
+	bot.RedisInterface.SetDiscordGameState(dgs, lock)
+
+	if len(users) == 0 {
+		voiceLock.Release(ctx)
+		return
+	}
+	l := bot.matchLogger(dgs)
+	l.Info("Correcting the voice state of drifting users", "users", len(users))
+
+	prem, days, _ := bot.PostgresInterface.GetGuildOrUserPremiumStatus(bot.official, nil, dgs.GuildID, "")
+	premTier := premium.FreeTier
+	if !premium.IsExpired(prem, days) {
+		premTier = prem
+	}
+	req := task.UserModifyRequest{
+		Premium: premTier,
+		Phase:   dgs.phaseName(),
+		Users:   users,
+	}
+	go func() {
+		err := bot.issueMutesAndRecord(ctx, dgs.GuildID, dgs.ConnectCode, req, voiceLock)
+		if err != nil {
+			l.Error("Error correcting voice states", err)
+			server.RecordVoiceStateCorrections("error", len(users))
+		} else {
+			server.RecordVoiceStateCorrections("success", len(users))
+		}
+	}()
+}
+
+// voiceDrifts returns the users in the game's voice channels whose server mute/deafen (or channel, with the move
+// strategy) doesn't match the state they should be in, along with the change that would fix it. Users the bot doesn't
+// manage are never included
+func (dgs *GameState) voiceDrifts(sett *settings.GuildSettings, voiceStates []*discordgo.VoiceState) []voiceDrift {
+	var drifts []voiceDrift
+	move := sett.GetVoiceStrategy() == settings.MoveStrategy
+	for _, voiceState := range voiceStates {
+		if !dgs.isGameChannel(sett, voiceState.ChannelID) {
+			continue
+		}
+		userData, err := dgs.GetUser(voiceState.UserID)
+		if err != nil {
+			continue
+		}
+		mute, deaf, isAlive, managed := dgs.voiceState(sett, userData, voiceState.ChannelID == dgs.VoiceChannel)
+		if !managed {
+			continue
+		}
+		uid, err := strconv.ParseUint(voiceState.UserID, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		d := voiceDrift{UserModify: task.UserModify{UserID: uid, Mute: mute, Deaf: deaf}}
+		if voiceState.Mute != mute {
+			d.kinds = append(d.kinds, DriftMute)
+		}
+		if voiceState.Deaf != deaf {
+			d.kinds = append(d.kinds, DriftDeaf)
+		}
+		if move {
+			if target := dgs.moveTarget(sett, isAlive); target != voiceState.ChannelID {
+				d.ChannelID = target
+				d.kinds = append(d.kinds, DriftChannel)
+			}
+		}
+		if len(d.kinds) > 0 {
+			drifts = append(drifts, d)
+		}
+	}
+	return drifts
+}
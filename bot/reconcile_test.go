@@ -0,0 +1,66 @@
+package bot
+
+import (
+	"github.com/automuteus/automuteus/v8/pkg/amongus"
+	"github.com/automuteus/automuteus/v8/pkg/game"
+	"github.com/automuteus/automuteus/v8/pkg/settings"
+	"github.com/bwmarrin/discordgo"
+	"testing"
+)
+
+func TestVoiceDrifts(t *testing.T) {
+	sett := settings.MakeGuildSettings()
+	dgs := NewDiscordGameState("1")
+	dgs.VoiceChannel = "game"
+	dgs.GameData.PlayerData = map[string]amongus.PlayerData{
+		"Kuro": {Name: "Kuro", IsAlive: true},
+		"Soup": {Name: "Soup", IsAlive: false},
+	}
+	dgs.UpdateUserData("2", UserData{User: User{UserID: "2"}, InGameName: "Kuro"})
+	dgs.UpdateUserData("3", UserData{User: User{UserID: "3"}, InGameName: "Soup"})
+	dgs.UpdateUserData("4", UserData{User: User{UserID: "4"}, InGameName: amongus.UnlinkedPlayerName})
+	dgs.GameData.UpdatePhase(game.LOBBY)
+
+	voiceStates := []*discordgo.VoiceState{
+		// stuck muted in the lobby
+		{UserID: "2", ChannelID: "game", Mute: true},
+		{UserID: "3", ChannelID: "game"},
+		// unlinked users aren't managed, so they're left alone
+		{UserID: "4", ChannelID: "game", Mute: true, Deaf: true},
+	}
+	drifts := dgs.voiceDrifts(sett, voiceStates)
+	if len(drifts) != 1 || drifts[0].UserID != 2 || drifts[0].Mute || len(drifts[0].kinds) != 1 || drifts[0].kinds[0] != DriftMute {
+		t.Errorf("Expected only user 2 to need unmuting, got %+v", drifts)
+	}
+
+	// players outside the game's channel aren't checked either
+	voiceStates[0].ChannelID = "afk"
+	if drifts := dgs.voiceDrifts(sett, voiceStates); len(drifts) != 0 {
+		t.Errorf("Expected no drift outside the game's channel, got %+v", drifts)
+	}
+
+	sett.SetVoiceStrategy(settings.MoveStrategy)
+	sett.SetGhostChannelID("ghost")
+	dgs.GameData.UpdatePhase(game.TASKS)
+	dgs.GameData.PlayerData["Soup"] = amongus.PlayerData{Name: "Soup", IsAlive: false}
+	drifts = dgs.voiceDrifts(sett, voiceStates)
+	if len(drifts) != 1 || drifts[0].UserID != 3 || drifts[0].ChannelID != "ghost" {
+		t.Errorf("Expected dead user 3 to need moving to the ghost channel, got %+v", drifts)
+	}
+}
+
+func TestVoiceReconciler_confirm(t *testing.T) {
+	r := newVoiceReconciler(GameStateRequest{})
+	first := []voiceDrift{{}, {}}
+	first[0].UserID, first[1].UserID = 1, 2
+	if confirmed := r.confirm(first); len(confirmed) != 0 {
+		t.Errorf("Expected nothing to be confirmed on the first check, got %+v", confirmed)
+	}
+
+	second := []voiceDrift{{}, {}}
+	second[0].UserID, second[1].UserID = 2, 3
+	confirmed := r.confirm(second)
+	if len(confirmed) != 1 || confirmed[0].UserID != 2 {
+		t.Errorf("Expected only the user drifting on both checks to be confirmed, got %+v", confirmed)
+	}
+}
@@ -5,13 +5,19 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/automuteus/automuteus/v8/internal/logging"
 	"github.com/automuteus/automuteus/v8/internal/server"
+	"github.com/automuteus/automuteus/v8/internal/tracing"
 	"github.com/automuteus/automuteus/v8/pkg/rediskey"
 	"github.com/automuteus/automuteus/v8/storage"
 	"github.com/bsm/redislock"
 	"github.com/bwmarrin/discordgo"
 	"github.com/go-redis/redis/v8"
-	"log"
+	"go.opentelemetry.io/otel/attribute"
+	"math/rand"
+	"os"
+	"runtime"
+	"strings"
 	"time"
 )
 
@@ -20,6 +26,17 @@ var ctx = context.Background()
 const LockTimeoutMs = 250
 const LinearBackoffMs = 100
 const MaxRetries = 10
+
+// backoff between attempts to obtain a game state lock
+const LockMinBackoffMs = 10
+const LockMaxBackoffMs = 200
+
+// GameStateLockTimeout bounds how long background processing (capture jobs, game loops) waits for a game state lock
+const GameStateLockTimeout = time.Second * 5
+
+// InteractionLockTimeout bounds how long interactions wait for a game state lock, leaving time to respond within
+// Discord's 3 second limit
+const InteractionLockTimeout = time.Second * 2
 const SnowflakeLockMs = 3000
 
 // 15 minute timeout
@@ -52,21 +69,21 @@ func (bot *Bot) refreshGameLiveness(code string) {
 }
 
 func (bot *Bot) rateLimitEventCallback(_ *discordgo.Session, rl *discordgo.RateLimit) {
-	log.Println(rl.Message)
+	logger.Warn("Rate limited by Discord", "message", rl.Message, "bucket", rl.Bucket, logging.ShardIDKey, bot.shardID())
 	server.RecordDiscordRequests(bot.RedisInterface.client, server.InvalidRequest, 1)
 }
 
 func (redisInterface *RedisInterface) AddUniqueGuildCounter(guildID string) {
 	_, err := redisInterface.client.SAdd(ctx, rediskey.TotalGuildsSet, string(rediskey.HashGuildID(guildID))).Result()
 	if err != nil {
-		log.Println(err)
+		logger.Error("Error adding guild to the guild counter", err, logging.GuildIDKey, guildID)
 	}
 }
 
 func (redisInterface *RedisInterface) LeaveUniqueGuildCounter(guildID string) {
 	_, err := redisInterface.client.SRem(ctx, rediskey.TotalGuildsSet, string(rediskey.HashGuildID(guildID))).Result()
 	if err != nil {
-		log.Println(err)
+		logger.Error("Error removing guild from the guild counter", err, logging.GuildIDKey, guildID)
 	}
 }
 
@@ -98,7 +115,7 @@ func (redisInterface *RedisInterface) LockVoiceChanges(connectCode string, dur t
 	if errors.Is(err, redislock.ErrNotObtained) {
 		return nil
 	} else if err != nil {
-		log.Println(err)
+		logger.Error("Error locking voice changes", err, logging.ConnectCodeKey, connectCode)
 		return nil
 	}
 
@@ -112,7 +129,7 @@ func (redisInterface *RedisInterface) GetReadOnlyDiscordGameState(gsr GameStateR
 	for dgs == nil {
 		i++
 		if i > 10 {
-			log.Println("RETURNING NIL GAMESTATE FOR READONLY FETCH")
+			logger.Warn("Game state not found for read-only fetch", logging.GuildIDKey, gsr.GuildID, logging.ConnectCodeKey, gsr.ConnectCode)
 			return nil
 		}
 		dgs = redisInterface.getDiscordGameState(gsr, false)
@@ -120,31 +137,160 @@ func (redisInterface *RedisInterface) GetReadOnlyDiscordGameState(gsr GameStateR
 	return dgs
 }
 
-func (redisInterface RedisInterface) GetDiscordGameStateAndLockRetries(gsr GameStateRequest, retries int) (*redislock.Lock, *GameState) {
-	lock, state := redisInterface.GetDiscordGameStateAndLock(gsr)
-	var i int
-	for lock == nil && i < retries {
-		lock, state = redisInterface.GetDiscordGameStateAndLock(gsr)
-		i++
+// ErrGameStateLocked is returned when someone else still holds the game state lock at the deadline
+var ErrGameStateLocked = errors.New("game state is locked")
+
+// GameStateLockError is returned when the game state lock couldn't be obtained before the deadline
+type GameStateLockError struct {
+	// Holder is whoever held the lock at the deadline (their host, process and function), if it's known
+	Holder   string
+	Attempts int
+	Err      error
+}
+
+func (e *GameStateLockError) Error() string {
+	holder := e.Holder
+	if holder == "" {
+		holder = "unknown"
 	}
-	return lock, state
+	return fmt.Sprintf("%s by %s after %d attempts: %s", ErrGameStateLocked, holder, e.Attempts, e.Err)
+}
+
+func (e *GameStateLockError) Unwrap() error {
+	return e.Err
+}
+
+func (e *GameStateLockError) Is(target error) bool {
+	return target == ErrGameStateLocked
 }
 
-func (redisInterface *RedisInterface) GetDiscordGameStateAndLock(gsr GameStateRequest) (*redislock.Lock, *GameState) {
+// processIdentity identifies this process in the metadata of the game state locks it holds
+var processIdentity = func() string {
+	hostname, _ := os.Hostname()
+	return fmt.Sprintf("%s:%d", hostname, os.Getpid())
+}()
+
+// lockBackoff is how long to wait before the next attempt to obtain a game state lock. It doubles with each attempt up
+// to LockMaxBackoffMs, and is jittered so that everyone waiting on the same game doesn't retry in lockstep
+func lockBackoff(attempt int) time.Duration {
+	ceiling := time.Millisecond * LockMaxBackoffMs
+	if attempt < 16 && time.Millisecond*LockMinBackoffMs<<attempt < ceiling {
+		ceiling = time.Millisecond * LockMinBackoffMs << attempt
+	}
+	return ceiling/2 + time.Duration(rand.Int63n(int64(ceiling/2)))
+}
+
+// lockCaller names the function taking a game state lock (the caller of the function calling lockCaller), like
+// bot.(*Bot).forceEndGame. Helpers named lockFor... take the lock on behalf of their callers, so they're skipped
+func lockCaller() string {
+	pcs := make([]uintptr, 8)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	for {
+		frame, more := frames.Next()
+		if frame.Function == "" {
+			return "unknown"
+		}
+		name := frame.Function[strings.LastIndex(frame.Function, "/")+1:]
+		if !more || !strings.HasPrefix(name[strings.LastIndex(name, ".")+1:], "lockFor") {
+			return name
+		}
+	}
+}
+
+// GetDiscordGameStateAndLock locks the game state and fetches it, waiting for anyone else holding the lock until ctx
+// is done. Without a deadline on ctx, GameStateLockTimeout applies. If the lock is still held at the deadline, the
+// error is a *GameStateLockError
+func (redisInterface *RedisInterface) GetDiscordGameStateAndLock(ctx context.Context, gsr GameStateRequest) (lock *redislock.Lock, dgs *GameState, err error) {
+	ctx, span := tracing.Start(ctx, "GetDiscordGameStateAndLock", tracing.GameAttributes(gsr.GuildID, gsr.ConnectCode))
+	defer func() {
+		tracing.End(span, err)
+	}()
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, GameStateLockTimeout)
+		defer cancel()
+	}
+
+	caller := lockCaller()
 	key := redisInterface.getDiscordGameStateKey(gsr)
-	locker := redislock.New(redisInterface.client)
-	lock, err := locker.Obtain(ctx, key+":lock", time.Millisecond*LockTimeoutMs, &redislock.Options{
-		RetryStrategy: redislock.LimitRetry(redislock.LinearBackoff(time.Millisecond*LinearBackoffMs), MaxRetries),
-		Metadata:      "",
-	})
-	if errors.Is(err, redislock.ErrNotObtained) {
-		return nil, nil
-	} else if err != nil {
-		log.Println(err)
-		return nil, nil
+	lock, err = redisInterface.obtainGameStateLock(ctx, key+":lock", caller)
+	span.SetAttributes(attribute.String("automuteus.lock_caller", caller), attribute.Bool("automuteus.lock_obtained", err == nil))
+	if err != nil {
+		l := logger.With(logging.GuildIDKey, gsr.GuildID, logging.ConnectCodeKey, gsr.ConnectCode, "caller", caller)
+		var lockErr *GameStateLockError
+		if errors.As(err, &lockErr) {
+			l.Warn("game state lock is held by someone else", "holder", lockErr.Holder, "attempts", lockErr.Attempts)
+		} else {
+			l.Error("couldn't obtain the game state lock", err)
+		}
+		return nil, nil, err
+	}
+
+	return lock, redisInterface.getDiscordGameState(gsr, true), nil
+}
+
+// gameStateLockClient is the part of the Redis client that game state locks use
+type gameStateLockClient interface {
+	redislock.RedisClient
+	Get(ctx context.Context, key string) *redis.StringCmd
+}
+
+func (redisInterface *RedisInterface) obtainGameStateLock(ctx context.Context, key, caller string) (*redislock.Lock, error) {
+	return obtainGameStateLock(ctx, redisInterface.client, key, caller)
+}
+
+// obtainGameStateLock tries to obtain the lock until ctx is done, backing off between attempts
+func obtainGameStateLock(ctx context.Context, client gameStateLockClient, key, caller string) (*redislock.Lock, error) {
+	locker := redislock.New(client)
+	opts := &redislock.Options{
+		RetryStrategy: redislock.NoRetry(),
+		Metadata:      processIdentity + "/" + caller,
+	}
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		lock, err := locker.Obtain(ctx, key, time.Millisecond*LockTimeoutMs, opts)
+		switch {
+		case err == nil:
+			result := server.LockObtained
+			if attempt > 1 {
+				result = server.LockContended
+			}
+			server.RecordGameStateLock(caller, result, time.Since(start), attempt-1)
+			return lock, nil
+		case !errors.Is(err, redislock.ErrNotObtained) && ctx.Err() == nil:
+			server.RecordGameStateLock(caller, server.LockFailed, time.Since(start), attempt-1)
+			return nil, err
+		}
+
+		timer := time.NewTimer(lockBackoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			server.RecordGameStateLock(caller, server.LockTimedOut, time.Since(start), attempt-1)
+			return nil, &GameStateLockError{
+				Holder:   gameStateLockHolder(client, key),
+				Attempts: attempt,
+				Err:      ctx.Err(),
+			}
+		case <-timer.C:
+		}
 	}
+}
 
-	return lock, redisInterface.getDiscordGameState(gsr, true)
+// gameStateLockHolder reads who holds the lock from its metadata
+func gameStateLockHolder(client gameStateLockClient, key string) string {
+	value, err := client.Get(ctx, key).Result()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			logger.Error("Error reading the game state lock's holder", err, "key", key)
+		}
+		return ""
+	}
+	// the value is a 22-character token, followed by the metadata
+	if len(value) <= 22 {
+		return ""
+	}
+	return value[22:]
 }
 
 func (redisInterface *RedisInterface) getDiscordGameState(gsr GameStateRequest, createOnNil bool) *GameState {
@@ -164,13 +310,13 @@ func (redisInterface *RedisInterface) getDiscordGameState(gsr GameStateRequest,
 			return nil
 		}
 	case err != nil:
-		log.Println(err)
+		logger.Error("Error fetching game state", err, logging.GuildIDKey, gsr.GuildID, logging.ConnectCodeKey, gsr.ConnectCode)
 		return nil
 	default:
 		dgs := GameState{}
 		err := json.Unmarshal([]byte(jsonStr), &dgs)
 		if err != nil {
-			log.Println(err)
+			logger.Error("Error decoding game state", err, logging.GuildIDKey, gsr.GuildID, logging.ConnectCodeKey, gsr.ConnectCode)
 			return nil
 		}
 		return &dgs
@@ -209,10 +355,11 @@ func (redisInterface *RedisInterface) SetDiscordGameState(data *GameState, lock
 		return
 	}
 	key = rediskey.ConnectCodeData(data.GuildID, data.ConnectCode)
+	l := logger.With(logging.GuildIDKey, data.GuildID, logging.ConnectCodeKey, data.ConnectCode)
 
 	jBytes, err := json.Marshal(data)
 	if err != nil {
-		log.Println(err)
+		l.Error("Error encoding game state", err)
 		if lock != nil {
 			lock.Release(ctx)
 		}
@@ -221,7 +368,7 @@ func (redisInterface *RedisInterface) SetDiscordGameState(data *GameState, lock
 
 	err = redisInterface.client.Set(ctx, key, jBytes, GameTimeoutSeconds*time.Second).Err()
 	if err != nil {
-		log.Println(err)
+		l.Error("Error saving game state", err)
 	}
 
 	if lock != nil {
@@ -231,21 +378,21 @@ func (redisInterface *RedisInterface) SetDiscordGameState(data *GameState, lock
 	if data.ConnectCode != "" {
 		err = redisInterface.client.Set(ctx, rediskey.ConnectCodePtr(data.GuildID, data.ConnectCode), key, GameTimeoutSeconds*time.Second).Err()
 		if err != nil {
-			log.Println(err)
+			l.Error("Error saving the game state's connect code pointer", err)
 		}
 	}
 
 	if data.VoiceChannel != "" {
 		err = redisInterface.client.Set(ctx, rediskey.VoiceChannelPtr(data.GuildID, data.VoiceChannel), key, GameTimeoutSeconds*time.Second).Err()
 		if err != nil {
-			log.Println(err)
+			l.Error("Error saving the game state's voice channel pointer", err)
 		}
 	}
 
 	if data.GameStateMsg.MessageChannelID != "" {
 		err = redisInterface.client.Set(ctx, rediskey.TextChannelPtr(data.GuildID, data.GameStateMsg.MessageChannelID), key, GameTimeoutSeconds*time.Second).Err()
 		if err != nil {
-			log.Println(err)
+			l.Error("Error saving the game state's text channel pointer", err)
 		}
 	}
 }
@@ -259,7 +406,7 @@ func (redisInterface *RedisInterface) RefreshActiveGame(guildID, connectCode str
 	}).Result()
 
 	if err != nil {
-		log.Println(err)
+		logger.Error("Error refreshing active game", err, logging.GuildIDKey, guildID, logging.ConnectCodeKey, connectCode)
 	}
 	before := t.Add(-time.Second * GameTimeoutSeconds)
 	go redisInterface.client.ZRemRangeByScore(context.Background(), rediskey.ActiveGamesZSet, "-inf", fmt.Sprintf("%d", before.Unix()))
@@ -270,8 +417,29 @@ func (redisInterface *RedisInterface) RemoveOldGame(guildID, connectCode string)
 
 	err := redisInterface.client.ZRem(ctx, key, connectCode).Err()
 	if err != nil {
-		log.Println(err)
+		logger.Error("Error removing active game", err, logging.GuildIDKey, guildID, logging.ConnectCodeKey, connectCode)
+	}
+}
+
+// LoadTimedOutGames returns the guild's games that haven't seen any activity from their capture within the game timeout,
+// but were never ended
+func (redisInterface *RedisInterface) LoadTimedOutGames(guildID string) []string {
+	hash := rediskey.ActiveGamesForGuild(guildID)
+
+	before := time.Now().Add(-time.Second * GameTimeoutSeconds).Unix()
+
+	games, err := redisInterface.client.ZRangeByScore(ctx, hash, &redis.ZRangeBy{
+		Min:    "-inf",
+		Max:    fmt.Sprintf("(%d", before),
+		Offset: 0,
+		Count:  0,
+	}).Result()
+
+	if err != nil {
+		logger.Error("Error loading timed out games", err, logging.GuildIDKey, guildID)
+		return []string{}
 	}
+	return games
 }
 
 // only deletes from the guild's responsibility, NOT the entire guild counter!
@@ -288,7 +456,7 @@ func (redisInterface *RedisInterface) LoadAllActiveGames(guildID string) []strin
 	}).Result()
 
 	if err != nil {
-		log.Println(err)
+		logger.Error("Error loading active games", err, logging.GuildIDKey, guildID)
 		return []string{}
 	}
 	go redisInterface.client.ZRemRangeByScore(context.Background(), hash, "-inf", fmt.Sprintf("%d", before))
@@ -300,7 +468,7 @@ func (redisInterface *RedisInterface) DeleteDiscordGameState(dgs *GameState) {
 	guildID := dgs.GuildID
 	connCode := dgs.ConnectCode
 	if guildID == "" || connCode == "" {
-		log.Println("Can't delete DGS with null guildID or null ConnCode")
+		logger.Warn("Can't delete a game state without its guild ID and connect code", logging.GuildIDKey, guildID, logging.ConnectCodeKey, connCode)
 	}
 	data := redisInterface.getDiscordGameState(GameStateRequest{
 		GuildID:     guildID,
@@ -313,37 +481,34 @@ func (redisInterface *RedisInterface) DeleteDiscordGameState(dgs *GameState) {
 	}
 	key := rediskey.ConnectCodeData(guildID, connCode)
 
-	locker := redislock.New(redisInterface.client)
-	lock, err := locker.Obtain(ctx, key+":lock", time.Millisecond*LockTimeoutMs, &redislock.Options{
-		RetryStrategy: redislock.LimitRetry(redislock.LinearBackoff(time.Millisecond*LinearBackoffMs), MaxRetries),
-		Metadata:      "",
-	})
-	switch {
-	case errors.Is(err, redislock.ErrNotObtained):
-		fmt.Println("Could not obtain lock!")
-	case err != nil:
-		log.Fatalln(err)
-	default:
+	// the game is going away regardless, so delete it even if someone else is still holding the lock
+	lockCtx, cancel := context.WithTimeout(ctx, GameStateLockTimeout)
+	lock, err := redisInterface.obtainGameStateLock(lockCtx, key+":lock", lockCaller())
+	cancel()
+	l := logger.With(logging.GuildIDKey, guildID, logging.ConnectCodeKey, connCode)
+	if err != nil {
+		l.Warn("deleting the game state without its lock", "error", err)
+	} else {
 		defer lock.Release(ctx)
 	}
 
 	// delete all the pointers to the underlying -actual- discord data
 	err = redisInterface.client.Del(ctx, rediskey.TextChannelPtr(guildID, data.GameStateMsg.MessageChannelID)).Err()
 	if err != nil {
-		log.Println(err)
+		l.Error("Error deleting the game state's text channel pointer", err)
 	}
 	err = redisInterface.client.Del(ctx, rediskey.VoiceChannelPtr(guildID, data.VoiceChannel)).Err()
 	if err != nil {
-		log.Println(err)
+		l.Error("Error deleting the game state's voice channel pointer", err)
 	}
 	err = redisInterface.client.Del(ctx, rediskey.ConnectCodePtr(guildID, data.ConnectCode)).Err()
 	if err != nil {
-		log.Println(err)
+		l.Error("Error deleting the game state's connect code pointer", err)
 	}
 
 	err = redisInterface.client.Del(ctx, key).Err()
 	if err != nil {
-		log.Println(err)
+		l.Error("Error deleting game state", err)
 	}
 }
 
@@ -367,6 +532,35 @@ func (redisInterface *RedisInterface) GetUsernameOrUserIDMappings(guildID, key s
 	return ret, nil
 }
 
+// GetPlayerNamesForUserIDs returns the in-game names each user has been linked to before, from the same cache as
+// GetUsernameOrUserIDMappings. Users without any cached names are left out
+func (redisInterface *RedisInterface) GetPlayerNamesForUserIDs(guildID string, userIDs []string) (map[string][]string, error) {
+	names := make(map[string][]string)
+	if len(userIDs) == 0 {
+		return names, nil
+	}
+	values, err := redisInterface.client.HMGet(ctx, rediskey.GuildCacheHash(guildID), userIDs...).Result()
+	if err != nil {
+		return names, err
+	}
+	for i, v := range values {
+		str, ok := v.(string)
+		if !ok {
+			continue
+		}
+		var mappings map[string]interface{}
+		err = json.Unmarshal([]byte(str), &mappings)
+		if err != nil {
+			logger.Error("Error decoding the user's player names", err, logging.GuildIDKey, guildID, "user_id", userIDs[i])
+			continue
+		}
+		for name := range mappings {
+			names[userIDs[i]] = append(names[userIDs[i]], name)
+		}
+	}
+	return names, nil
+}
+
 func (redisInterface *RedisInterface) AddUsernameLink(guildID, userID, userName string) error {
 	err := redisInterface.appendToHashedEntry(guildID, userID, userName)
 	if err != nil {
@@ -379,12 +573,12 @@ func (redisInterface *RedisInterface) DeleteLinksByUserID(guildID, userID string
 	// over all the usernames associated with just this userID, delete the underlying mapping of username->userID
 	usernames, err := redisInterface.GetUsernameOrUserIDMappings(guildID, userID)
 	if err != nil {
-		log.Println(err)
+		logger.Error("Error fetching the user's player names", err, logging.GuildIDKey, guildID, "user_id", userID)
 	} else {
 		for username := range usernames {
 			err := redisInterface.deleteHashSubEntry(guildID, username, userID)
 			if err != nil {
-				log.Println(err)
+				logger.Error("Error deleting the player name's user", err, logging.GuildIDKey, guildID, "user_id", userID, "player", username)
 			}
 		}
 	}
@@ -397,7 +591,7 @@ func (redisInterface *RedisInterface) DeleteLinksByUserID(guildID, userID string
 func (redisInterface *RedisInterface) appendToHashedEntry(guildID, key, value string) error {
 	resp, err := redisInterface.GetUsernameOrUserIDMappings(guildID, key)
 	if err != nil {
-		log.Println(err)
+		logger.Error("Error fetching username mappings", err, logging.GuildIDKey, guildID, "key", key)
 	}
 
 	resp[value] = struct{}{}
@@ -408,7 +602,7 @@ func (redisInterface *RedisInterface) appendToHashedEntry(guildID, key, value st
 func (redisInterface *RedisInterface) deleteHashSubEntry(guildID, key, entry string) error {
 	entries, err := redisInterface.GetUsernameOrUserIDMappings(guildID, key)
 	if err != nil {
-		log.Println(err)
+		logger.Error("Error fetching username mappings", err, logging.GuildIDKey, guildID, "key", key)
 	} else {
 		delete(entries, entry)
 	}
@@ -439,7 +633,7 @@ func (redisInterface *RedisInterface) LockSnowflake(snowflake string) *redislock
 	if errors.Is(err, redislock.ErrNotObtained) {
 		return nil
 	} else if err != nil {
-		log.Println(err)
+		logger.Error("Error locking snowflake", err, "snowflake", snowflake)
 		return nil
 	}
 	return lock
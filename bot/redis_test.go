@@ -0,0 +1,120 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"github.com/bsm/redislock"
+	"github.com/go-redis/redis/v8"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLockBackoff(t *testing.T) {
+	ms := time.Millisecond
+	bounds := []struct {
+		attempt  int
+		min, max time.Duration
+	}{
+		{1, 10 * ms, 20 * ms},
+		{2, 20 * ms, 40 * ms},
+		{3, 40 * ms, 80 * ms},
+		{4, 80 * ms, 160 * ms},
+		{5, 100 * ms, 200 * ms},
+		{6, 100 * ms, 200 * ms},
+		{20, 100 * ms, 200 * ms},
+	}
+	for _, b := range bounds {
+		for i := 0; i < 100; i++ {
+			backoff := lockBackoff(b.attempt)
+			if backoff < b.min || backoff >= b.max {
+				t.Fatalf("expected the backoff for attempt %d to be in [%s, %s), got %s", b.attempt, b.min, b.max, backoff)
+			}
+		}
+	}
+}
+
+// heldLockClient is a Redis client where the lock is always held by someone else
+type heldLockClient struct {
+	redislock.RedisClient
+	value    string
+	attempts int
+}
+
+func (c *heldLockClient) SetNX(_ context.Context, _ string, _ interface{}, _ time.Duration) *redis.BoolCmd {
+	c.attempts++
+	return redis.NewBoolResult(false, nil)
+}
+
+func (c *heldLockClient) Get(_ context.Context, _ string) *redis.StringCmd {
+	return redis.NewStringResult(c.value, nil)
+}
+
+func TestObtainGameStateLock_held(t *testing.T) {
+	client := &heldLockClient{value: "abcdefghijklmnopqrstuvhost:123/bot.(*Bot).processTransition"}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+
+	start := time.Now()
+	lock, err := obtainGameStateLock(ctx, client, "lock", "bot.TestObtainGameStateLock_held")
+	if lock != nil {
+		t.Fatal("expected the lock not to be obtained")
+	}
+	if waited := time.Since(start); waited < time.Millisecond*100 || waited > time.Second {
+		t.Errorf("expected to give up at the deadline, gave up after %s", waited)
+	}
+
+	var lockErr *GameStateLockError
+	if !errors.As(err, &lockErr) {
+		t.Fatalf("expected a GameStateLockError, got %v", err)
+	}
+	if lockErr.Holder != "host:123/bot.(*Bot).processTransition" {
+		t.Errorf("expected the holder to be read from the lock, got \"%s\"", lockErr.Holder)
+	}
+	if lockErr.Attempts < 2 || lockErr.Attempts != client.attempts {
+		t.Errorf("expected the attempts to be counted, got %d (made %d)", lockErr.Attempts, client.attempts)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the context's error, got %v", lockErr.Err)
+	}
+}
+
+func TestGameStateLockError(t *testing.T) {
+	var err error = &GameStateLockError{
+		Holder:   "host:123/bot.(*Bot).processTransition",
+		Attempts: 12,
+		Err:      context.DeadlineExceeded,
+	}
+	if !errors.Is(err, ErrGameStateLocked) {
+		t.Error("expected the error to be ErrGameStateLocked")
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("expected the error to wrap the context's error")
+	}
+	if !strings.Contains(err.Error(), "host:123/bot.(*Bot).processTransition") {
+		t.Errorf("expected the error to name the holder, got \"%s\"", err)
+	}
+
+	err = &GameStateLockError{Err: context.Canceled}
+	if !strings.Contains(err.Error(), "unknown") {
+		t.Errorf("expected an unknown holder, got \"%s\"", err)
+	}
+}
+
+// takeTestLock stands in for a function taking the lock, like GetDiscordGameStateAndLock
+func takeTestLock() string {
+	return lockCaller()
+}
+
+func lockForTest() string {
+	return takeTestLock()
+}
+
+func TestLockCaller(t *testing.T) {
+	if caller := takeTestLock(); caller != "bot.TestLockCaller" {
+		t.Errorf("expected bot.TestLockCaller, got %s", caller)
+	}
+	if caller := lockForTest(); caller != "bot.TestLockCaller" {
+		t.Errorf("expected the lockFor helper to be skipped, got %s", caller)
+	}
+}
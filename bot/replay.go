@@ -0,0 +1,265 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/automuteus/automuteus/v8/pkg/game"
+	"github.com/automuteus/automuteus/v8/pkg/premium"
+	"github.com/automuteus/automuteus/v8/pkg/settings"
+	"github.com/automuteus/automuteus/v8/pkg/storage"
+	"github.com/automuteus/automuteus/v8/pkg/task"
+	"github.com/bsm/redislock"
+	"github.com/bwmarrin/discordgo"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	replayGuildID        = "1"
+	replayConnectCode    = "REPLAY"
+	replayTextChannelID  = "text"
+	replayVoiceChannelID = "voice"
+)
+
+// ReplaySink receives the decisions made while replaying a recording, in place of Discord
+type ReplaySink interface {
+	VoiceChange(at time.Time, user UserData, change task.UserModify, delay time.Duration)
+	EmbedEdit(at time.Time, embed *discordgo.MessageEmbed)
+}
+
+// TextReplaySink writes every decision made during a replay as a line of text
+type TextReplaySink struct {
+	Writer io.Writer
+}
+
+func (sink TextReplaySink) VoiceChange(at time.Time, user UserData, change task.UserModify, delay time.Duration) {
+	move := ""
+	if change.ChannelID != "" {
+		move = " move=" + change.ChannelID
+	}
+	fmt.Fprintf(sink.Writer, "%s voice: %s mute=%t deaf=%t%s (after %s)\n", at.Format(time.RFC3339Nano), user.GetUserName(), change.Mute, change.Deaf, move, delay)
+}
+
+func (sink TextReplaySink) EmbedEdit(at time.Time, embed *discordgo.MessageEmbed) {
+	fmt.Fprintf(sink.Writer, "%s embed: %s | %s\n", at.Format(time.RFC3339Nano), embed.Title, strings.ReplaceAll(embed.Description, "\n", " "))
+	for _, field := range embed.Fields {
+		fmt.Fprintf(sink.Writer, "\t%s: %s\n", field.Name, strings.ReplaceAll(field.Value, "\n", " "))
+	}
+}
+
+// Replay feeds a recorded sequence of jobs through the same sequencing and job handlers live games use, but against an
+// in-memory game instead of Redis, Postgres and Discord. Every player that joins has a user with the same name join
+// the tracked voice channel, so the sink sees the mutes/deafens/moves the bot would have issued for each of them
+func Replay(jobs []task.RecordedJob, sett *settings.GuildSettings, sink ReplaySink) {
+	mem := newMemorySink(sett, sink)
+	bot := &Bot{
+		StatusEmojis: GlobalAlivenessEmojis,
+		sink:         mem,
+	}
+	sources := task.NewSources(nil)
+
+	for _, rj := range jobs {
+		mem.now = rj.Time
+		bot.expireHeldJobs(replayGuildID, replayConnectCode, sources)
+		if rj.Job.JobType == task.PlayerJob && rj.Job.Player.Action == game.JOINED {
+			mem.joinVoice(rj.Job.Player.Name)
+		}
+		bot.processStreamJob(replayGuildID, replayConnectCode, sources, task.StreamJob{Job: rj.Job, Popped: rj.Time})
+	}
+	// give up on any jobs still waiting for ones that were never recorded
+	mem.now = mem.now.Add(task.JobReorderTimeout)
+	bot.expireHeldJobs(replayGuildID, replayConnectCode, sources)
+}
+
+// memorySink keeps a replayed game in memory, and passes the decisions made for it to a ReplaySink. The game state is
+// kept as JSON, like it is in Redis, so handlers can't share it by accident
+type memorySink struct {
+	sett  *settings.GuildSettings
+	sink  ReplaySink
+	state []byte
+	guild *discordgo.Guild
+	now   time.Time
+	// delay is the delay the next voice changes were issued after
+	delay   time.Duration
+	matches uint64
+}
+
+func newMemorySink(sett *settings.GuildSettings, sink ReplaySink) *memorySink {
+	dgs := NewDiscordGameState(replayGuildID)
+	dgs.ConnectCode = replayConnectCode
+	dgs.Running = true
+	dgs.Subscribed = true
+	dgs.VoiceChannel = replayVoiceChannelID
+	dgs.GameStateMsg = GameStateMessage{
+		MessageID:        "1",
+		MessageChannelID: replayTextChannelID,
+	}
+	mem := &memorySink{
+		sett:  sett,
+		sink:  sink,
+		guild: &discordgo.Guild{ID: replayGuildID},
+	}
+	mem.SetGameState(dgs, nil)
+	return mem
+}
+
+// joinVoice has a user with the player's name join the tracked voice channel, and adds them to the game like the
+// voice state handler would, unless they already joined
+func (mem *memorySink) joinVoice(playerName string) {
+	for _, v := range mem.guild.Members {
+		if v.User.Username == playerName {
+			return
+		}
+	}
+	member := &discordgo.Member{
+		GuildID: replayGuildID,
+		User: &discordgo.User{
+			ID:       strconv.Itoa(len(mem.guild.Members) + 1),
+			Username: playerName,
+		},
+	}
+	mem.guild.Members = append(mem.guild.Members, member)
+	mem.guild.VoiceStates = append(mem.guild.VoiceStates, &discordgo.VoiceState{
+		GuildID:   replayGuildID,
+		ChannelID: replayVoiceChannelID,
+		UserID:    member.User.ID,
+	})
+
+	dgs := mem.ReadGameState(GameStateRequest{})
+	dgs.UserData[member.User.ID] = MakeUserDataFromDiscordUser(member.User, "")
+	mem.SetGameState(dgs, nil)
+}
+
+func (mem *memorySink) GuildMember(_, userID string, _ ...discordgo.RequestOption) (*discordgo.Member, error) {
+	for _, v := range mem.guild.Members {
+		if v.User.ID == userID {
+			return v, nil
+		}
+	}
+	return nil, fmt.Errorf("no member with id %s", userID)
+}
+
+func (mem *memorySink) Now() time.Time {
+	return mem.now
+}
+
+func (mem *memorySink) SaveLastSequence(_, _ string, _ uint64) error {
+	return nil
+}
+
+func (mem *memorySink) SavePrimarySource(_, _ string) error {
+	return nil
+}
+
+func (mem *memorySink) GuildSettings(_ string) *settings.GuildSettings {
+	return mem.sett
+}
+
+func (mem *memorySink) PremiumTier(_ string) premium.Tier {
+	return premium.FreeTier
+}
+
+func (mem *memorySink) LockGameState(_ context.Context, gsr GameStateRequest) (*redislock.Lock, *GameState, error) {
+	return nil, mem.ReadGameState(gsr), nil
+}
+
+func (mem *memorySink) ReadGameState(_ GameStateRequest) *GameState {
+	var dgs GameState
+	err := json.Unmarshal(mem.state, &dgs)
+	if err != nil {
+		panic(err)
+	}
+	return &dgs
+}
+
+func (mem *memorySink) SetGameState(dgs *GameState, _ *redislock.Lock) {
+	if dgs == nil {
+		return
+	}
+	state, err := json.Marshal(dgs)
+	if err != nil {
+		panic(err)
+	}
+	mem.state = state
+}
+
+func (mem *memorySink) GameStateLocked(_ GameStateRequest, _ *settings.GuildSettings) {}
+
+func (mem *memorySink) RefreshGameLiveness(_, _ string) {}
+
+func (mem *memorySink) Guild(_ string) (*discordgo.Guild, error) {
+	return mem.guild, nil
+}
+
+func (mem *memorySink) LockVoiceChanges(_ string, _ time.Duration) *redislock.Lock {
+	return nil
+}
+
+func (mem *memorySink) Sleep(dur time.Duration) {
+	mem.delay = dur
+}
+
+// ModifyUsers passes each change to the ReplaySink, and moves users to the channel they were moved to
+func (mem *memorySink) ModifyUsers(_ context.Context, _, _ string, req task.UserModifyRequest, _ *redislock.Lock) error {
+	dgs := mem.ReadGameState(GameStateRequest{})
+	for _, change := range req.Users {
+		userID := strconv.FormatUint(change.UserID, 10)
+		mem.sink.VoiceChange(mem.now, dgs.UserData[userID], change, mem.delay)
+		if change.ChannelID == "" {
+			continue
+		}
+		for _, v := range mem.guild.VoiceStates {
+			if v.UserID == userID {
+				v.ChannelID = change.ChannelID
+			}
+		}
+	}
+	mem.delay = 0
+	return nil
+}
+
+func (mem *memorySink) EditGameMessage(_ *GameState, embed *discordgo.MessageEmbed) {
+	mem.sink.EmbedEdit(mem.now, embed)
+}
+
+func (mem *memorySink) ReplaceGameMessage(dgs *GameState, embed *discordgo.MessageEmbed) {
+	dgs.GameStateMsg.CreationTimeUnix = mem.now.Unix()
+	mem.sink.EmbedEdit(mem.now, embed)
+}
+
+// Go runs the work straight away, so replays are deterministic
+func (mem *memorySink) Go(f func()) {
+	f()
+}
+
+func (mem *memorySink) EditGameMessageComponents(_ *GameState) {}
+
+func (mem *memorySink) SendMessage(_, _ string) {}
+
+func (mem *memorySink) SendGameOver(_ string, embed *discordgo.MessageEmbed, _ time.Duration) {
+	mem.sink.EmbedEdit(mem.now, embed)
+}
+
+func (mem *memorySink) StartMatch(_ GameState) uint64 {
+	mem.matches++
+	return mem.matches
+}
+
+func (mem *memorySink) EndMatch(_ GameState, _ game.Gameover) {}
+
+func (mem *memorySink) AddEvents(_ GameStateRequest, _ []correlatedEvent) {}
+
+func (mem *memorySink) UserIDByAlias(_, _ string) (string, error) {
+	return "", nil
+}
+
+func (mem *memorySink) PlayerNamesForUserIDs(_ string, _ []string) (map[string][]string, error) {
+	return nil, nil
+}
+
+func (mem *memorySink) UserPlayerHistory(_ string, _ []uint64) ([]*storage.PostgresUserPlayerHistory, error) {
+	return nil, nil
+}
@@ -0,0 +1,140 @@
+package bot
+
+import (
+	"github.com/automuteus/automuteus/v8/pkg/settings"
+	"github.com/automuteus/automuteus/v8/pkg/task"
+	"github.com/bwmarrin/discordgo"
+	"testing"
+	"time"
+)
+
+type voiceChangeRecord struct {
+	name       string
+	mute, deaf bool
+	channelID  string
+}
+
+type recordingSink struct {
+	voice  []voiceChangeRecord
+	embeds int
+}
+
+func (sink *recordingSink) VoiceChange(_ time.Time, user UserData, change task.UserModify, _ time.Duration) {
+	sink.voice = append(sink.voice, voiceChangeRecord{name: user.GetUserName(), mute: change.Mute, deaf: change.Deaf, channelID: change.ChannelID})
+}
+
+func (sink *recordingSink) EmbedEdit(_ time.Time, _ *discordgo.MessageEmbed) {
+	sink.embeds++
+}
+
+func recorded(t *testing.T, jobType task.JobType, payload string) task.RecordedJob {
+	job, err := task.NewJob(jobType, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return task.RecordedJob{
+		Time: time.Now(),
+		Job:  job,
+	}
+}
+
+func TestReplay(t *testing.T) {
+	jobs := []task.RecordedJob{
+		recorded(t, task.ConnectionJob, "true"),
+		recorded(t, task.StateJob, "0"),
+		recorded(t, task.PlayerJob, `{"Action":0,"Name":"Alice","Color":0,"IsDead":false,"Disconnected":false}`),
+		recorded(t, task.PlayerJob, `{"Action":0,"Name":"Bob","Color":1,"IsDead":false,"Disconnected":false}`),
+		recorded(t, task.StateJob, "1"),
+		recorded(t, task.PlayerJob, `{"Action":2,"Name":"Alice","Color":0,"IsDead":true,"Disconnected":false}`),
+		recorded(t, task.StateJob, "2"),
+	}
+	sink := &recordingSink{}
+	Replay(jobs, settings.MakeGuildSettings(), sink)
+
+	expected := []voiceChangeRecord{
+		// LOBBY->TASKS deafens everyone
+		{name: "Alice", mute: true, deaf: true},
+		{name: "Bob", mute: true, deaf: true},
+		// TASKS->DISCUSS unmutes the living, and undeafens the dead (but keeps them muted)
+		{name: "Alice", mute: true, deaf: false},
+		{name: "Bob", mute: false, deaf: false},
+	}
+	if len(sink.voice) != len(expected) {
+		t.Fatalf("Expected %d voice changes, got %d: %v", len(expected), len(sink.voice), sink.voice)
+	}
+	for i, v := range expected {
+		if sink.voice[i] != v {
+			t.Errorf("Expected voice change %d to be %v, got %v", i, v, sink.voice[i])
+		}
+	}
+	// the death during tasks shouldn't edit the message, because it'd leak info
+	if sink.embeds != 6 {
+		t.Errorf("Expected 6 embed edits, got %d", sink.embeds)
+	}
+}
+
+func TestReplay_MoveStrategy(t *testing.T) {
+	jobs := []task.RecordedJob{
+		recorded(t, task.ConnectionJob, "true"),
+		recorded(t, task.StateJob, "0"),
+		recorded(t, task.PlayerJob, `{"Action":0,"Name":"Alice","Color":0,"IsDead":false,"Disconnected":false}`),
+		recorded(t, task.PlayerJob, `{"Action":0,"Name":"Bob","Color":1,"IsDead":false,"Disconnected":false}`),
+		recorded(t, task.StateJob, "1"),
+		recorded(t, task.PlayerJob, `{"Action":2,"Name":"Alice","Color":0,"IsDead":true,"Disconnected":false}`),
+		recorded(t, task.StateJob, "2"),
+	}
+	sett := settings.MakeGuildSettings()
+	sett.SetVoiceStrategy(settings.MoveStrategy)
+	sett.SetTasksChannelID("tasks")
+	sink := &recordingSink{}
+	Replay(jobs, sett, sink)
+
+	expected := []voiceChangeRecord{
+		// LOBBY->TASKS moves the living to the tasks channel, instead of deafening them
+		{name: "Alice", channelID: "tasks"},
+		{name: "Bob", channelID: "tasks"},
+		// TASKS->DISCUSS brings everyone back, the dead first
+		{name: "Alice", channelID: replayVoiceChannelID},
+		{name: "Bob", channelID: replayVoiceChannelID},
+	}
+	if len(sink.voice) != len(expected) {
+		t.Fatalf("Expected %d voice changes, got %d: %v", len(expected), len(sink.voice), sink.voice)
+	}
+	for i, v := range expected {
+		if sink.voice[i] != v {
+			t.Errorf("Expected voice change %d to be %v, got %v", i, v, sink.voice[i])
+		}
+	}
+}
+
+func TestReplay_OutOfOrder(t *testing.T) {
+	jobs := []task.RecordedJob{
+		recorded(t, task.ConnectionJob, "true"),
+		recorded(t, task.StateJob, "0"),
+		recorded(t, task.PlayerJob, `{"Action":0,"Name":"Alice","Color":0,"IsDead":false,"Disconnected":false}`),
+		recorded(t, task.PlayerJob, `{"Action":0,"Name":"Bob","Color":1,"IsDead":false,"Disconnected":false}`),
+		recorded(t, task.StateJob, "1"),
+	}
+	for i := range jobs {
+		jobs[i].Job.Seq = uint64(i + 1)
+	}
+	// the transition to tasks arrived before Bob joined, and Alice's join was delivered twice
+	jobs[3], jobs[4] = jobs[4], jobs[3]
+	jobs = append(jobs[:3], append([]task.RecordedJob{jobs[2]}, jobs[3:]...)...)
+
+	sink := &recordingSink{}
+	Replay(jobs, settings.MakeGuildSettings(), sink)
+
+	expected := []voiceChangeRecord{
+		{name: "Alice", mute: true, deaf: true},
+		{name: "Bob", mute: true, deaf: true},
+	}
+	if len(sink.voice) != len(expected) {
+		t.Fatalf("Expected %d voice changes, got %d: %v", len(expected), len(sink.voice), sink.voice)
+	}
+	for i, v := range expected {
+		if sink.voice[i] != v {
+			t.Errorf("Expected voice change %d to be %v, got %v", i, v, sink.voice[i])
+		}
+	}
+}
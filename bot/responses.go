@@ -6,6 +6,7 @@ import (
 	"github.com/automuteus/automuteus/v8/pkg/discord"
 	"github.com/automuteus/automuteus/v8/pkg/settings"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -87,6 +88,10 @@ func settingResponse(settings []setting.Setting, sett *settings.GuildSettings, p
 }
 
 func (bot *Bot) gameStateResponse(dgs *GameState, sett *settings.GuildSettings) *discordgo.MessageEmbed {
+	return gameStateEmbed(dgs, bot.StatusEmojis, sett)
+}
+
+func gameStateEmbed(dgs *GameState, emojis AlivenessEmojis, sett *settings.GuildSettings) *discordgo.MessageEmbed {
 	// we need to generate the messages based on the state of the game
 	messages := map[game.Phase]func(dgs *GameState, emojis AlivenessEmojis, sett *settings.GuildSettings) *discordgo.MessageEmbed{
 		game.MENU:     menuMessage,
@@ -95,10 +100,10 @@ func (bot *Bot) gameStateResponse(dgs *GameState, sett *settings.GuildSettings)
 		game.DISCUSS:  gamePlayMessage,
 		game.GAMEOVER: gamePlayMessage,
 	}
-	return messages[dgs.GameData.Phase](dgs, bot.StatusEmojis, sett)
+	return messages[dgs.GameData.Phase](dgs, emojis, sett)
 }
 
-func lobbyMetaEmbedFields(room, region string, author, voiceChannelID string, playerCount int, linkedPlayers int, sett *settings.GuildSettings) []*discordgo.MessageEmbedField {
+func lobbyMetaEmbedFields(room, region string, author, voiceChannelID, captureSource string, playerCount int, linkedPlayers int, sett *settings.GuildSettings) []*discordgo.MessageEmbedField {
 	gameInfoFields := make([]*discordgo.MessageEmbedField, 0)
 	if author != "" {
 		gameInfoFields = append(gameInfoFields, &discordgo.MessageEmbedField{
@@ -120,6 +125,16 @@ func lobbyMetaEmbedFields(room, region string, author, voiceChannelID string, pl
 			Inline: true,
 		})
 	}
+	if captureSource != "" {
+		gameInfoFields = append(gameInfoFields, &discordgo.MessageEmbedField{
+			Name: sett.LocalizeMessage(&i18n.Message{
+				ID:    "responses.lobbyMetaEmbedFields.CaptureSource",
+				Other: "Capture",
+			}),
+			Value:  captureSource,
+			Inline: true,
+		})
+	}
 	if linkedPlayers > playerCount {
 		linkedPlayers = playerCount
 	}
@@ -227,12 +242,151 @@ func menuMessage(dgs *GameState, _ AlivenessEmojis, sett *settings.GuildSettings
 	return &msg
 }
 
+func lobbyOptionsEmbedField(opts *game.LobbyOptions, sett *settings.GuildSettings) *discordgo.MessageEmbedField {
+	confirmEjects := sett.LocalizeMessage(&i18n.Message{
+		ID:    "responses.lobbyOptionsEmbedField.Off",
+		Other: "Off",
+	})
+	if opts.ConfirmEjects {
+		confirmEjects = sett.LocalizeMessage(&i18n.Message{
+			ID:    "responses.lobbyOptionsEmbedField.On",
+			Other: "On",
+		})
+	}
+	return &discordgo.MessageEmbedField{
+		Name: sett.LocalizeMessage(&i18n.Message{
+			ID:    "responses.lobbyOptionsEmbedField.Name",
+			Other: "Game Options",
+		}),
+		Value: sett.LocalizeMessage(&i18n.Message{
+			ID: "responses.lobbyOptionsEmbedField.Value",
+			Other: "Impostors: {{.Impostors}} • Discussion: {{.Discussion}}s • Voting: {{.Voting}}s\n" +
+				"Kill Cooldown: {{.KillCooldown}}s • Confirm Ejects: {{.ConfirmEjects}} • Speed: {{.Speed}}x",
+		},
+			map[string]interface{}{
+				"Impostors":     opts.NumImpostors,
+				"Discussion":    opts.DiscussionTime,
+				"Voting":        opts.VotingTime,
+				"KillCooldown":  strconv.FormatFloat(opts.KillCooldown, 'f', -1, 64),
+				"ConfirmEjects": confirmEjects,
+				"Speed":         strconv.FormatFloat(opts.PlayerSpeed, 'f', -1, 64),
+			}),
+		Inline: false,
+	}
+}
+
+// discussCountdownEmbedFields counts down to the end of the discussion and of the voting, using Discord's relative
+// timestamps so the countdown ticks without the message having to be edited
+func discussCountdownEmbedFields(opts *game.LobbyOptions, discussStartUnix int64, sett *settings.GuildSettings) []*discordgo.MessageEmbedField {
+	fields := make([]*discordgo.MessageEmbedField, 0)
+	if opts == nil || discussStartUnix <= 0 {
+		return fields
+	}
+	discussEnd := discussStartUnix + int64(opts.DiscussionTime)
+	if opts.DiscussionTime > 0 {
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name: sett.LocalizeMessage(&i18n.Message{
+				ID:    "responses.discussCountdownEmbedFields.DiscussionEnds",
+				Other: "Discussion Ends",
+			}),
+			Value:  fmt.Sprintf("<t:%d:R>", discussEnd),
+			Inline: true,
+		})
+	}
+	if opts.VotingTime > 0 {
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name: sett.LocalizeMessage(&i18n.Message{
+				ID:    "responses.discussCountdownEmbedFields.VotingEnds",
+				Other: "Voting Ends",
+			}),
+			Value:  fmt.Sprintf("<t:%d:R>", discussEnd+int64(opts.VotingTime)),
+			Inline: true,
+		})
+	}
+	return fields
+}
+
+func taskProgressEmbedField(progress game.TaskProgress, sett *settings.GuildSettings) *discordgo.MessageEmbedField {
+	return &discordgo.MessageEmbedField{
+		Name: sett.LocalizeMessage(&i18n.Message{
+			ID:    "responses.taskProgressEmbedField.Name",
+			Other: "Crew Tasks",
+		}),
+		Value: sett.LocalizeMessage(&i18n.Message{
+			ID:    "responses.taskProgressEmbedField.Value",
+			Other: "{{.Percent}}% ({{.Completed}}/{{.Total}})",
+		},
+			map[string]interface{}{
+				"Percent":   progress.Percent(),
+				"Completed": progress.Completed,
+				"Total":     progress.Total,
+			}),
+		Inline: true,
+	}
+}
+
+// taskProgressIsPublic is whether the crew's task progress can be shown to everyone. During a game it would tell the
+// impostors how close the crew is to winning, so it's only shown if the guild opted in
+func taskProgressIsPublic(dgs *GameState, sett *settings.GuildSettings) bool {
+	phase := dgs.GameData.GetPhase()
+	return sett.GetShowTaskProgress() || phase == game.GAMEOVER || phase == game.LOBBY
+}
+
+func showTaskProgress(dgs *GameState, sett *settings.GuildSettings) bool {
+	return dgs.GameData.GetTaskProgress() != nil && taskProgressIsPublic(dgs, sett)
+}
+
+// canSeeTaskProgress is whether the user can privately view the crew's task progress; dead players can't give
+// anything away, so they always can
+func (dgs *GameState) canSeeTaskProgress(userID string, sett *settings.GuildSettings) bool {
+	if taskProgressIsPublic(dgs, sett) {
+		return true
+	}
+	userData, err := dgs.GetUser(userID)
+	if err != nil {
+		return false
+	}
+	player, ok := dgs.GameData.GetByName(userData.GetPlayerName())
+	return ok && !player.IsAlive
+}
+
+// spectatorsEmbedField lists the users spectating the game, or is nil if nobody is
+func spectatorsEmbedField(dgs *GameState, sett *settings.GuildSettings) *discordgo.MessageEmbedField {
+	spectators := dgs.Spectators()
+	if len(spectators) == 0 {
+		return nil
+	}
+	mentions := make([]string, len(spectators))
+	for i, userID := range spectators {
+		mentions[i] = discord.MentionByUserID(userID)
+	}
+	return &discordgo.MessageEmbedField{
+		Name: sett.LocalizeMessage(&i18n.Message{
+			ID:    "responses.spectatorsEmbedField.Name",
+			Other: "👀 Spectators",
+		}),
+		Value:  strings.Join(mentions, " "),
+		Inline: false,
+	}
+}
+
 func lobbyMessage(dgs *GameState, emojis AlivenessEmojis, sett *settings.GuildSettings) *discordgo.MessageEmbed {
 	room, region, playMap := dgs.GameData.GetRoomRegionMap()
-	gameInfoFields := lobbyMetaEmbedFields(room, region, dgs.GameStateMsg.LeaderID, dgs.VoiceChannel, dgs.GameData.GetNumDetectedPlayers(), dgs.GetCountLinked(), sett)
+	gameInfoFields := lobbyMetaEmbedFields(room, region, dgs.GameStateMsg.LeaderID, dgs.VoiceChannel, dgs.CaptureSource, dgs.GameData.GetNumDetectedPlayers(), dgs.GetCountLinked(), sett)
+
+	if opts := dgs.GameData.GetOptions(); opts != nil {
+		gameInfoFields = append(gameInfoFields, lobbyOptionsEmbedField(opts, sett))
+	}
+	// the progress the last game ended with
+	if showTaskProgress(dgs, sett) {
+		gameInfoFields = append(gameInfoFields, taskProgressEmbedField(*dgs.GameData.GetTaskProgress(), sett))
+	}
 
 	listResp := dgs.ToEmojiEmbedFields(emojis, sett)
 	listResp = append(gameInfoFields, listResp...)
+	if spectators := spectatorsEmbedField(dgs, sett); spectators != nil {
+		listResp = append(listResp, spectators)
+	}
 
 	desc, color := dgs.descriptionAndColor(sett)
 	if color == discord.DEFAULT {
@@ -270,10 +424,23 @@ func lobbyMessage(dgs *GameState, emojis AlivenessEmojis, sett *settings.GuildSe
 	return &msg
 }
 
-func gameOverMessage(dgs *GameState, emojis AlivenessEmojis, sett *settings.GuildSettings, winners string) *discordgo.MessageEmbed {
+func gameOverMessage(dgs *GameState, emojis AlivenessEmojis, sett *settings.GuildSettings, winners, roles string) *discordgo.MessageEmbed {
 	_, _, playMap := dgs.GameData.GetRoomRegionMap()
 
 	listResp := dgs.ToEmojiEmbedFields(emojis, sett)
+	if progress := dgs.GameData.GetTaskProgress(); progress != nil {
+		listResp = append(listResp, taskProgressEmbedField(*progress, sett))
+	}
+	if roles != "" {
+		listResp = append(listResp, &discordgo.MessageEmbedField{
+			Name: sett.LocalizeMessage(&i18n.Message{
+				ID:    "eventHandler.gameOver.roles",
+				Other: "Roles",
+			}),
+			Value:  roles,
+			Inline: false,
+		})
+	}
 
 	desc := sett.LocalizeMessage(&i18n.Message{
 		ID:    "eventHandler.gameOver.matchID",
@@ -284,6 +451,17 @@ func gameOverMessage(dgs *GameState, emojis AlivenessEmojis, sett *settings.Guil
 			"Winners": winners,
 		})
 
+	title := sett.LocalizeMessage(amongus.ToLocale(game.GAMEOVER))
+	if dgs.GameData.GetMode() == game.HideAndSeekMode {
+		title = sett.LocalizeMessage(&i18n.Message{
+			ID:    "eventHandler.gameOver.hideAndSeekTitle",
+			Other: "{{.Title}} (Hide and Seek)",
+		},
+			map[string]interface{}{
+				"Title": title,
+			})
+	}
+
 	var footer *discordgo.MessageEmbedFooter
 
 	if sett.DeleteGameSummaryMinutes > 0 {
@@ -303,7 +481,7 @@ func gameOverMessage(dgs *GameState, emojis AlivenessEmojis, sett *settings.Guil
 	msg := discordgo.MessageEmbed{
 		URL:         "",
 		Type:        "",
-		Title:       sett.LocalizeMessage(amongus.ToLocale(game.GAMEOVER)),
+		Title:       title,
 		Description: desc,
 		Timestamp:   time.Now().Format(ISO8601),
 		Footer:      footer,
@@ -334,8 +512,17 @@ func gamePlayMessage(dgs *GameState, emojis AlivenessEmojis, sett *settings.Guil
 	// send empty fields because we don't need to display those fields during the game...
 	listResp := dgs.ToEmojiEmbedFields(emojis, sett)
 
-	gameInfoFields := lobbyMetaEmbedFields("", "", dgs.GameStateMsg.LeaderID, dgs.VoiceChannel, dgs.GameData.GetNumDetectedPlayers(), dgs.GetCountLinked(), sett)
+	gameInfoFields := lobbyMetaEmbedFields("", "", dgs.GameStateMsg.LeaderID, dgs.VoiceChannel, dgs.CaptureSource, dgs.GameData.GetNumDetectedPlayers(), dgs.GetCountLinked(), sett)
+	if phase == game.DISCUSS {
+		gameInfoFields = append(gameInfoFields, discussCountdownEmbedFields(dgs.GameData.GetOptions(), dgs.DiscussStartUnix, sett)...)
+	}
+	if showTaskProgress(dgs, sett) {
+		gameInfoFields = append(gameInfoFields, taskProgressEmbedField(*dgs.GameData.GetTaskProgress(), sett))
+	}
 	listResp = append(gameInfoFields, listResp...)
+	if spectators := spectatorsEmbedField(dgs, sett); spectators != nil {
+		listResp = append(listResp, spectators)
+	}
 	desc, color := dgs.descriptionAndColor(sett)
 	if color == discord.DEFAULT {
 		switch phase {
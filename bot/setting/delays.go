@@ -11,6 +11,7 @@ func FnDelays(sett *settings.GuildSettings, args []string) (interface{}, bool) {
 	if sett == nil {
 		return nil, false
 	}
+	args, mode := splitGameMode(args)
 	// User passes phase name, phase name and new delay value
 	if len(args) < 2 {
 		// User didn't pass 2 phases, tell them the list of game phases
@@ -41,7 +42,7 @@ func FnDelays(sett *settings.GuildSettings, args []string) (interface{}, bool) {
 			}), false
 	}
 
-	oldDelay := sett.GetDelay(gamePhase1, gamePhase2)
+	oldDelay := sett.GetDelay(mode, gamePhase1, gamePhase2)
 	if len(args) == 2 {
 		// no number was passed, User was querying the delay
 		return sett.LocalizeMessage(&i18n.Message{
@@ -66,7 +67,7 @@ func FnDelays(sett *settings.GuildSettings, args []string) (interface{}, bool) {
 			}), false
 	}
 
-	sett.SetDelay(gamePhase1, gamePhase2, newDelay)
+	sett.SetDelay(mode, gamePhase1, gamePhase2, newDelay)
 	return sett.LocalizeMessage(&i18n.Message{
 		ID:    "settings.SettingDelays.setDelayBetweenPhases",
 		Other: "The delay when passing from `{{.PhaseA}}` to `{{.PhaseB}}` changed from {{.OldDelay}} to {{.NewDelay}}.",
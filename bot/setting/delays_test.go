@@ -30,7 +30,18 @@ func TestFnDelays(t *testing.T) {
 	if !valid {
 		t.Error("Sending valid args should result in valid settings change")
 	}
-	if sett.GetDelay(game.LOBBY, game.TASKS) != 8 {
+	if sett.GetDelay(game.ClassicMode, game.LOBBY, game.TASKS) != 8 {
 		t.Error("Delay was not set properly")
 	}
+
+	_, valid = FnDelays(sett, []string{"lobby", "tasks", "3", "HIDENSEEK"})
+	if !valid {
+		t.Error("Sending valid args with a game mode should result in valid settings change")
+	}
+	if sett.GetDelay(game.HideAndSeekMode, game.LOBBY, game.TASKS) != 3 {
+		t.Error("Hide and Seek delay was not set properly")
+	}
+	if sett.GetDelay(game.ClassicMode, game.LOBBY, game.TASKS) != 8 {
+		t.Error("Setting a Hide and Seek delay should not change the classic delay")
+	}
 }
@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"github.com/automuteus/automuteus/v8/pkg/settings"
 	"github.com/nicksnyder/go-i18n/v2/i18n"
-	"log"
 	"strconv"
 )
 
@@ -19,7 +18,7 @@ func FnLeaderboardMin(sett *settings.GuildSettings, args []string) (interface{},
 
 	num, err := strconv.ParseInt(args[0], 10, 64)
 	if err != nil {
-		log.Println("error for parseint in LeaderboardMin: ", err)
+		logger.Debug("Invalid number for setting", "setting", LeaderboardMin, "value", args[0])
 		return sett.LocalizeMessage(&i18n.Message{
 			ID:    "settings.SettingLeaderboardMin.Unrecognized",
 			Other: "{{.Number}} is not a valid number. See `/settings leaderboard-min` for usage",
@@ -83,7 +82,7 @@ func FnLeaderboardSize(sett *settings.GuildSettings, args []string) (interface{}
 
 	num, err := strconv.ParseInt(args[0], 10, 64)
 	if err != nil {
-		log.Println("error for parseint in LeaderboardSize: ", err)
+		logger.Debug("Invalid number for setting", "setting", LeaderboardSize, "value", args[0])
 		return sett.LocalizeMessage(&i18n.Message{
 			ID:    "settings.SettingLeaderboardSize.Unrecognized",
 			Other: "{{.Number}} is not a valid number. See `/settings leaderboard-size` for usage",
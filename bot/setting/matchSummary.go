@@ -5,7 +5,6 @@ import (
 	"github.com/automuteus/automuteus/v8/pkg/discord"
 	"github.com/automuteus/automuteus/v8/pkg/settings"
 	"github.com/nicksnyder/go-i18n/v2/i18n"
-	"log"
 	"strconv"
 )
 
@@ -20,7 +19,7 @@ func FnMatchSummary(sett *settings.GuildSettings, args []string) (interface{}, b
 
 	num, err := strconv.ParseInt(args[0], 10, 64)
 	if err != nil {
-		log.Println("error for parseint in MatchSummary: ", err)
+		logger.Debug("Invalid number for setting", "setting", MatchSummary, "value", args[0])
 		return sett.LocalizeMessage(&i18n.Message{
 			ID:    "settings.SettingMatchSummary.Unrecognized",
 			Other: "{{.Minutes}} is not a valid number. See `/settings match-summary` for usage",
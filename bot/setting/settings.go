@@ -18,10 +18,11 @@ const (
 
 	MaxMatchSummaryDelete float64 = 60
 
-	View  = "view"
-	Clear = "clear"
-	User  = "user"
-	Role  = "role"
+	View    = "view"
+	Clear   = "clear"
+	User    = "user"
+	Role    = "role"
+	Channel = "channel"
 )
 
 var (
@@ -50,6 +51,11 @@ const (
 	LeaderboardMin      = "leaderboard-min"
 	MuteSpectators      = "mute-spectators"
 	DisplayRoomCode     = "display-room-code"
+	ShowTaskProgress    = "show-task-progress"
+	SpectatorRole       = "spectator-role"
+	VoiceStrategy       = "voice-strategy"
+	GhostChannel        = "ghost-channel"
+	TasksChannel        = "tasks-channel"
 	Show                = "show"
 	List                = "list"
 	Reset               = "reset"
@@ -107,6 +113,37 @@ var phaseChoices = []*discordgo.ApplicationCommandOptionChoice{
 	},
 }
 
+var gameModeOption = &discordgo.ApplicationCommandOption{
+	Type:        discordgo.ApplicationCommandOptionString,
+	Name:        "game-mode",
+	Description: "game-mode",
+	Choices: []*discordgo.ApplicationCommandOptionChoice{
+		{
+			Name:  "Classic",
+			Value: string(game.GameModeNames[game.ClassicMode]),
+		},
+		{
+			Name:  "Hide and Seek",
+			Value: string(game.GameModeNames[game.HideAndSeekMode]),
+		},
+	},
+}
+
+// splitGameMode removes the (optional) game mode from a setting's args, and returns the mode they're for. Game mode
+// names never collide with the other args, so the mode is found wherever it was passed
+func splitGameMode(args []string) ([]string, game.GameMode) {
+	rest := make([]string, 0, len(args))
+	mode := game.ClassicMode
+	for _, arg := range args {
+		if m, ok := game.GetGameModeFromString(arg); ok {
+			mode = m
+		} else {
+			rest = append(rest, arg)
+		}
+	}
+	return rest, mode
+}
+
 var AllSettings = []Setting{
 	{
 		Name:      List,
@@ -166,6 +203,10 @@ var AllSettings = []Setting{
 						Name:  "dead",
 						Value: "dead",
 					},
+					{
+						Name:  game.SpectatorRules,
+						Value: game.SpectatorRules,
+					},
 				},
 				Required: true,
 			},
@@ -174,6 +215,7 @@ var AllSettings = []Setting{
 				Name:        "value",
 				Description: "value",
 			},
+			gameModeOption,
 		},
 		Premium: false,
 	},
@@ -249,6 +291,18 @@ var AllSettings = []Setting{
 		},
 		Premium: false,
 	},
+	{
+		Name:      ShowTaskProgress,
+		ShortDesc: "Show task progress during the game",
+		Arguments: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionBoolean,
+				Name:        "show",
+				Description: "show",
+			},
+		},
+		Premium: false,
+	},
 	{
 		Name:      MapVersion,
 		ShortDesc: "Map version",
@@ -286,6 +340,7 @@ var AllSettings = []Setting{
 				MinValue:    &MinDelay,
 				MaxValue:    MaxDelay,
 			},
+			gameModeOption,
 		},
 		Premium: false,
 	},
@@ -380,6 +435,120 @@ var AllSettings = []Setting{
 		},
 		Premium: true,
 	},
+	{
+		Name:      SpectatorRole,
+		ShortDesc: "Spectator Role",
+		Arguments: []*discordgo.ApplicationCommandOption{
+			{
+				Name:        View,
+				Description: "View the Spectator Role",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+			},
+			{
+				Name:        Clear,
+				Description: "Clear the Spectator Role",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        Role,
+				Description: "Discord role whose members spectate",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Name:        Role,
+						Description: "Discord role whose members spectate",
+						Type:        discordgo.ApplicationCommandOptionRole,
+						Required:    true,
+					},
+				},
+			},
+		},
+		Premium: false,
+	},
+	{
+		Name:      VoiceStrategy,
+		ShortDesc: "Mute or Move Players",
+		Arguments: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "strategy",
+				Description: "strategy",
+				Choices: []*discordgo.ApplicationCommandOptionChoice{
+					{
+						Name:  settings.MuteStrategy,
+						Value: settings.MuteStrategy,
+					},
+					{
+						Name:  settings.MoveStrategy,
+						Value: settings.MoveStrategy,
+					},
+				},
+			},
+		},
+		Premium: false,
+	},
+	{
+		Name:      GhostChannel,
+		ShortDesc: "Ghost Channel",
+		Arguments: []*discordgo.ApplicationCommandOption{
+			{
+				Name:        View,
+				Description: "View the Ghost Channel",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+			},
+			{
+				Name:        Clear,
+				Description: "Clear the Ghost Channel",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        Channel,
+				Description: "Voice channel dead players are moved to during tasks",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Name:         Channel,
+						Description:  "Voice channel dead players are moved to during tasks",
+						Type:         discordgo.ApplicationCommandOptionChannel,
+						ChannelTypes: []discordgo.ChannelType{discordgo.ChannelTypeGuildVoice},
+						Required:     true,
+					},
+				},
+			},
+		},
+		Premium: false,
+	},
+	{
+		Name:      TasksChannel,
+		ShortDesc: "Tasks Channel",
+		Arguments: []*discordgo.ApplicationCommandOption{
+			{
+				Name:        View,
+				Description: "View the Tasks Channel",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+			},
+			{
+				Name:        Clear,
+				Description: "Clear the Tasks Channel",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        Channel,
+				Description: "Voice channel alive players are moved to during tasks",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Name:         Channel,
+						Description:  "Voice channel alive players are moved to during tasks",
+						Type:         discordgo.ApplicationCommandOptionChannel,
+						ChannelTypes: []discordgo.ChannelType{discordgo.ChannelTypeGuildVoice},
+						Required:     true,
+					},
+				},
+			},
+		},
+		Premium: false,
+	},
 	{
 		Name:      DisplayRoomCode,
 		ShortDesc: "Visibility for the ROOM CODE",
@@ -0,0 +1,56 @@
+package setting
+
+import (
+	"github.com/automuteus/automuteus/v8/pkg/settings"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+func FnShowTaskProgress(sett *settings.GuildSettings, args []string) (interface{}, bool) {
+	s := GetSettingByName(ShowTaskProgress)
+	if sett == nil {
+		return nil, false
+	}
+	showProgress := sett.GetShowTaskProgress()
+	if len(args) == 0 {
+		current := "false"
+		if showProgress {
+			current = "true"
+		}
+		return ConstructEmbedForSetting(current, s, sett), false
+	}
+	switch {
+	case args[0] == "true":
+		if showProgress {
+			return sett.LocalizeMessage(&i18n.Message{
+				ID:    "settings.already_true",
+				Other: "It's already true!",
+			}), false
+		} else {
+			sett.SetShowTaskProgress(true)
+			return sett.LocalizeMessage(&i18n.Message{
+				ID:    "settings.SettingShowTaskProgress.true_noShowTaskProgress",
+				Other: "I will now show the crew's task progress on the game message while the game is running.",
+			}), true
+		}
+	case args[0] == "false":
+		if showProgress {
+			sett.SetShowTaskProgress(false)
+			return sett.LocalizeMessage(&i18n.Message{
+				ID:    "settings.SettingShowTaskProgress.false_showTaskProgress",
+				Other: "I will now only show the crew's task progress to dead players (with `/tasks`), and after the game.",
+			}), true
+		}
+		return sett.LocalizeMessage(&i18n.Message{
+			ID:    "settings.already_false",
+			Other: "It's already false!",
+		}), false
+	default:
+		return sett.LocalizeMessage(&i18n.Message{
+			ID:    "settings.SettingUnmuteDeadDuringTasks.wrongArg",
+			Other: "Sorry, `{{.Arg}}` is neither `true` nor `false`.",
+		},
+			map[string]interface{}{
+				"Arg": args[0],
+			}), false
+	}
+}
@@ -0,0 +1,36 @@
+package setting
+
+import "testing"
+
+func TestFnShowTaskProgress(t *testing.T) {
+	sett, err := testSettingsFn(FnShowTaskProgress)
+	if err != nil {
+		t.Error(err)
+	}
+
+	_, valid := FnShowTaskProgress(sett, []string{"nottrueorfalse"})
+	if valid {
+		t.Error("Invalid show task progress arg should never result in a valid settings change")
+	}
+
+	_, valid = FnShowTaskProgress(sett, []string{"false"})
+	if valid {
+		t.Error("Identical show task progress arg to default should never result in a valid settings change")
+	}
+
+	_, valid = FnShowTaskProgress(sett, []string{"true"})
+	if !valid {
+		t.Error("Valid show task progress arg should result in a valid settings change")
+	}
+	if !sett.GetShowTaskProgress() {
+		t.Error("Valid show task progress (\"true\") was not set correctly")
+	}
+
+	_, valid = FnShowTaskProgress(sett, []string{"false"})
+	if !valid {
+		t.Error("Valid show task progress arg should result in a valid settings change")
+	}
+	if sett.GetShowTaskProgress() {
+		t.Error("Valid show task progress (\"false\") was not set correctly")
+	}
+}
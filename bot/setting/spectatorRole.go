@@ -0,0 +1,53 @@
+package setting
+
+import (
+	"github.com/automuteus/automuteus/v8/pkg/discord"
+	"github.com/automuteus/automuteus/v8/pkg/settings"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+func FnSpectatorRole(sett *settings.GuildSettings, args []string) (interface{}, bool) {
+	s := GetSettingByName(SpectatorRole)
+	if sett == nil {
+		return nil, false
+	}
+	if len(args) == 0 || args[0] == View {
+		if sett.GetSpectatorRoleID() == "" {
+			return ConstructEmbedForSetting(sett.LocalizeMessage(&i18n.Message{
+				ID:    "settings.SettingSpectatorRole.noRole",
+				Other: "No Spectator Role",
+			}), s, sett), false
+		}
+		return ConstructEmbedForSetting("<@&"+sett.GetSpectatorRoleID()+">", s, sett), false
+	}
+
+	if args[0] == Clear {
+		sett.SetSpectatorRoleID("")
+		return sett.LocalizeMessage(&i18n.Message{
+			ID:    "settings.SettingSpectatorRole.clear",
+			Other: "Cleared the spectator role",
+		}), true
+	}
+
+	ID, err := discord.ExtractRoleIDFromText(args[0])
+	if err != nil || ID == "" {
+		return sett.LocalizeMessage(&i18n.Message{
+			ID:    "settings.SettingSpectatorRole.notFound",
+			Other: "Sorry, I didn't recognize the role you provided",
+		}), false
+	}
+	if ID == sett.GetSpectatorRoleID() {
+		return sett.LocalizeMessage(&i18n.Message{
+			ID:    "settings.SettingSpectatorRole.alreadySet",
+			Other: "That role is already the spectator role!",
+		}), false
+	}
+	sett.SetSpectatorRoleID(ID)
+	return sett.LocalizeMessage(&i18n.Message{
+		ID:    "settings.SettingSpectatorRole.set",
+		Other: "From now on, unlinked users with <@&{{.RoleID}}> will spectate when they join the game's voice channel",
+	},
+		map[string]interface{}{
+			"RoleID": ID,
+		}), true
+}
@@ -0,0 +1,41 @@
+package setting
+
+import "testing"
+
+func TestFnSpectatorRole(t *testing.T) {
+	sett, err := testSettingsFn(FnSpectatorRole)
+	if err != nil {
+		t.Error(err)
+	}
+
+	_, valid := FnSpectatorRole(sett, []string{View})
+	if valid {
+		t.Error("Viewing should never result in a valid settings change")
+	}
+
+	_, valid = FnSpectatorRole(sett, []string{"notaroleorclear"})
+	if valid {
+		t.Error("Invalid role should never result in a valid settings change")
+	}
+
+	_, valid = FnSpectatorRole(sett, []string{"141100845902200999"})
+	if !valid {
+		t.Error("Valid role arg should result in a valid settings change")
+	}
+	if sett.GetSpectatorRoleID() != "141100845902200999" {
+		t.Error("Valid role arg didn't set the spectator role correctly")
+	}
+
+	_, valid = FnSpectatorRole(sett, []string{"141100845902200999"})
+	if valid {
+		t.Error("Setting the same spectator role should never result in a valid settings change")
+	}
+
+	_, valid = FnSpectatorRole(sett, []string{Clear})
+	if !valid {
+		t.Error("Clearing the spectator role should result in a valid settings change")
+	}
+	if sett.GetSpectatorRoleID() != "" {
+		t.Error("Clearing the spectator role didn't clear it")
+	}
+}
@@ -10,13 +10,14 @@ func FnVoiceRules(sett *settings.GuildSettings, args []string) (interface{}, boo
 	if sett == nil {
 		return nil, false
 	}
+	args, mode := splitGameMode(args)
 
 	// now for a bunch of input checking
 	if len(args) < 3 {
 		// User didn't pass enough args
 		return sett.LocalizeMessage(&i18n.Message{
 			ID:    "settings.SettingVoiceRules.enoughArgs",
-			Other: "You didn't pass enough arguments! Correct syntax is: `voiceRules [muted/deafened] [game phase] [alive/dead] [true/false]`",
+			Other: "You didn't pass enough arguments! Correct syntax is: `voiceRules [muted/deafened] [game phase] [alive/dead/spectator] [true/false]`",
 		}), false
 	}
 
@@ -31,17 +32,17 @@ func FnVoiceRules(sett *settings.GuildSettings, args []string) (interface{}, boo
 			}), false
 	}
 
-	if args[2] != "alive" && args[2] != "dead" {
+	if args[2] != "alive" && args[2] != "dead" && args[2] != game.SpectatorRules {
 		return sett.LocalizeMessage(&i18n.Message{
 			ID:    "settings.SettingVoiceRules.neitherAliveDead",
-			Other: "`{{.Arg}}` is neither `alive` or `dead`!",
+			Other: "`{{.Arg}}` is neither `alive`, `dead` or `spectator`!",
 		},
 			map[string]interface{}{
 				"Arg": args[2],
 			}), false
 	}
 
-	oldValue := sett.GetVoiceRule(args[0] == "muted", gamePhase, args[2])
+	oldValue := sett.GetVoiceRule(mode, args[0] == "muted", gamePhase, args[2])
 
 	if len(args) == 3 {
 		// User was only querying
@@ -94,9 +95,9 @@ func FnVoiceRules(sett *settings.GuildSettings, args []string) (interface{}, boo
 	}
 
 	if args[0] == "muted" {
-		sett.SetVoiceRule(true, gamePhase, args[2], newValue)
+		sett.SetVoiceRule(mode, true, gamePhase, args[2], newValue)
 	} else {
-		sett.SetVoiceRule(false, gamePhase, args[2], newValue)
+		sett.SetVoiceRule(mode, false, gamePhase, args[2], newValue)
 	}
 
 	if newValue {
@@ -55,4 +55,18 @@ func TestFnVoiceRules(t *testing.T) {
 	if sett.VoiceRules.DeafRules[game.PhaseNames[game.LOBBY]]["alive"] == false {
 		t.Error("Valid VR rule change was not changed successfully!")
 	}
+
+	// rules saved before spectators had their own column follow the rules for dead players
+	delete(sett.VoiceRules.MuteRules[game.PhaseNames[game.DISCUSS]], game.SpectatorRules)
+	if !sett.GetVoiceRule(game.ClassicMode, true, game.DISCUSS, game.SpectatorRules) {
+		t.Error("Spectators without their own rules should be muted during discussion like dead players")
+	}
+
+	_, valid = FnVoiceRules(sett, []string{"muted", "discuss", game.SpectatorRules, "false"})
+	if !valid {
+		t.Error("Valid spectator VR rules should result in a valid settings change")
+	}
+	if sett.VoiceRules.MuteRules[game.PhaseNames[game.DISCUSS]][game.SpectatorRules] {
+		t.Error("Valid spectator VR rule change was not changed successfully!")
+	}
 }
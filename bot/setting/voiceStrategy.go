@@ -0,0 +1,120 @@
+package setting
+
+import (
+	"github.com/automuteus/automuteus/v8/pkg/discord"
+	"github.com/automuteus/automuteus/v8/pkg/settings"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+func FnVoiceStrategy(sett *settings.GuildSettings, args []string) (interface{}, bool) {
+	s := GetSettingByName(VoiceStrategy)
+	if sett == nil {
+		return nil, false
+	}
+	if len(args) == 0 {
+		return ConstructEmbedForSetting(sett.GetVoiceStrategy(), s, sett), false
+	}
+	strategy := args[0]
+	if strategy != settings.MuteStrategy && strategy != settings.MoveStrategy {
+		return sett.LocalizeMessage(&i18n.Message{
+			ID:    "settings.SettingVoiceStrategy.wrongArg",
+			Other: "Sorry, `{{.Arg}}` is neither `mute` nor `move`.",
+		},
+			map[string]interface{}{
+				"Arg": strategy,
+			}), false
+	}
+	if strategy == sett.GetVoiceStrategy() {
+		return sett.LocalizeMessage(&i18n.Message{
+			ID:    "settings.SettingVoiceStrategy.alreadySet",
+			Other: "I'm already using the `{{.Strategy}}` strategy!",
+		},
+			map[string]interface{}{
+				"Strategy": strategy,
+			}), false
+	}
+	sett.SetVoiceStrategy(strategy)
+	if strategy == settings.MuteStrategy {
+		return sett.LocalizeMessage(&i18n.Message{
+			ID:    "settings.SettingVoiceStrategy.mute",
+			Other: "From now on, I'll mute and deafen players according to the voice rules",
+		}), true
+	}
+	if sett.GetGhostChannelID() == "" && sett.GetTasksChannelID() == "" {
+		return sett.LocalizeMessage(&i18n.Message{
+			ID:    "settings.SettingVoiceStrategy.moveNoChannels",
+			Other: "From now on, I'll move players between voice channels instead of muting them. Set the channels with `/settings ghost-channel` and `/settings tasks-channel`, or nobody will be moved!",
+		}), true
+	}
+	return sett.LocalizeMessage(&i18n.Message{
+		ID:    "settings.SettingVoiceStrategy.move",
+		Other: "From now on, I'll move players between voice channels instead of muting them",
+	}), true
+}
+
+func FnGhostChannel(sett *settings.GuildSettings, args []string) (interface{}, bool) {
+	if sett == nil {
+		return nil, false
+	}
+	return fnMoveChannel(sett, args, GetSettingByName(GhostChannel), sett.GetGhostChannelID(), sett.SetGhostChannelID)
+}
+
+func FnTasksChannel(sett *settings.GuildSettings, args []string) (interface{}, bool) {
+	if sett == nil {
+		return nil, false
+	}
+	return fnMoveChannel(sett, args, GetSettingByName(TasksChannel), sett.GetTasksChannelID(), sett.SetTasksChannelID)
+}
+
+// fnMoveChannel views, clears or sets one of the voice channels players are moved to with the move strategy
+func fnMoveChannel(sett *settings.GuildSettings, args []string, s *Setting, current string, set func(string)) (interface{}, bool) {
+	if len(args) == 0 || args[0] == View {
+		if current == "" {
+			return ConstructEmbedForSetting(sett.LocalizeMessage(&i18n.Message{
+				ID:    "settings.SettingMoveChannel.noChannel",
+				Other: "No Channel",
+			}), s, sett), false
+		}
+		return ConstructEmbedForSetting(discord.MentionByChannelID(current), s, sett), false
+	}
+
+	if args[0] == Clear {
+		set("")
+		return sett.LocalizeMessage(&i18n.Message{
+			ID:    "settings.SettingMoveChannel.clear",
+			Other: "Cleared the `{{.Setting}}` channel",
+		},
+			map[string]interface{}{
+				"Setting": s.Name,
+			}), true
+	}
+
+	channelID, err := discord.ExtractChannelIDFromText(args[0])
+	if err != nil {
+		return sett.LocalizeMessage(&i18n.Message{
+			ID:    "settings.SettingMoveChannel.invalidChannelID",
+			Other: "{{.channelID}} is not a valid voice channel ID or mention!",
+		},
+			map[string]interface{}{
+				"channelID": args[0],
+			}), false
+	}
+	if channelID == current {
+		return sett.LocalizeMessage(&i18n.Message{
+			ID:    "settings.SettingMoveChannel.alreadySet",
+			Other: "That's already the `{{.Setting}}` channel!",
+		},
+			map[string]interface{}{
+				"Setting": s.Name,
+			}), false
+	}
+	set(channelID)
+	return sett.LocalizeMessage(&i18n.Message{
+		ID:    "settings.SettingMoveChannel.withChannelID",
+		Other: "The `{{.Setting}}` channel is now {{.channelID}}!",
+	},
+		map[string]interface{}{
+			"Setting":   s.Name,
+			"channelID": discord.MentionByChannelID(channelID),
+		}), true
+}
@@ -0,0 +1,72 @@
+package setting
+
+import (
+	"github.com/automuteus/automuteus/v8/pkg/settings"
+	"testing"
+)
+
+func TestFnVoiceStrategy(t *testing.T) {
+	sett, err := testSettingsFn(FnVoiceStrategy)
+	if err != nil {
+		t.Error(err)
+	}
+
+	_, valid := FnVoiceStrategy(sett, []string{"teleport"})
+	if valid {
+		t.Error("Invalid strategy should never result in a valid settings change")
+	}
+
+	_, valid = FnVoiceStrategy(sett, []string{settings.MuteStrategy})
+	if valid {
+		t.Error("Setting the current strategy should never result in a valid settings change")
+	}
+
+	_, valid = FnVoiceStrategy(sett, []string{settings.MoveStrategy})
+	if !valid {
+		t.Error("Valid strategy should result in a valid settings change")
+	}
+	if sett.GetVoiceStrategy() != settings.MoveStrategy {
+		t.Error("Valid strategy didn't set the voice strategy correctly")
+	}
+}
+
+func TestFnGhostChannel(t *testing.T) {
+	sett, err := testSettingsFn(FnGhostChannel)
+	if err != nil {
+		t.Error(err)
+	}
+
+	_, valid := FnGhostChannel(sett, []string{View})
+	if valid {
+		t.Error("Viewing should never result in a valid settings change")
+	}
+
+	_, valid = FnGhostChannel(sett, []string{"notachannel"})
+	if valid {
+		t.Error("Invalid channel should never result in a valid settings change")
+	}
+
+	_, valid = FnGhostChannel(sett, []string{"<#141101495071408128>"})
+	if !valid {
+		t.Error("Valid channel arg should result in a valid settings change")
+	}
+	if sett.GetGhostChannelID() != "141101495071408128" {
+		t.Error("Valid channel arg didn't set the ghost channel correctly")
+	}
+	if sett.GetTasksChannelID() != "" {
+		t.Error("Setting the ghost channel shouldn't set the tasks channel")
+	}
+
+	_, valid = FnGhostChannel(sett, []string{"141101495071408128"})
+	if valid {
+		t.Error("Setting the same ghost channel should never result in a valid settings change")
+	}
+
+	_, valid = FnGhostChannel(sett, []string{Clear})
+	if !valid {
+		t.Error("Clearing the ghost channel should result in a valid settings change")
+	}
+	if sett.GetGhostChannelID() != "" {
+		t.Error("Clearing the ghost channel didn't clear it")
+	}
+}
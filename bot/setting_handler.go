@@ -4,8 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/automuteus/automuteus/v8/bot/setting"
+	"github.com/automuteus/automuteus/v8/internal/logging"
 	"github.com/automuteus/automuteus/v8/pkg/settings"
-	"log"
 )
 
 func (bot *Bot) HandleSettingsCommand(guildID string, sett *settings.GuildSettings, settType string, args []string, prem bool) interface{} {
@@ -22,6 +22,8 @@ func (bot *Bot) HandleSettingsCommand(guildID string, sett *settings.GuildSettin
 		sendMsg, isValid = setting.FnPermissionRoleIDs(sett, args)
 	case setting.UnmuteDead:
 		sendMsg, isValid = setting.FnUnmuteDeadDuringTasks(sett, args)
+	case setting.ShowTaskProgress:
+		sendMsg, isValid = setting.FnShowTaskProgress(sett, args)
 	case setting.MapVersion:
 		sendMsg, isValid = setting.FnMapVersion(sett, args)
 	case setting.Delays:
@@ -63,6 +65,14 @@ func (bot *Bot) HandleSettingsCommand(guildID string, sett *settings.GuildSettin
 			return nonPremiumSettingResponse(sett)
 		}
 		sendMsg, isValid = setting.FnMuteSpectators(sett, args)
+	case setting.SpectatorRole:
+		sendMsg, isValid = setting.FnSpectatorRole(sett, args)
+	case setting.VoiceStrategy:
+		sendMsg, isValid = setting.FnVoiceStrategy(sett, args)
+	case setting.GhostChannel:
+		sendMsg, isValid = setting.FnGhostChannel(sett, args)
+	case setting.TasksChannel:
+		sendMsg, isValid = setting.FnTasksChannel(sett, args)
 	case setting.DisplayRoomCode:
 		if !prem {
 			return nonPremiumSettingResponse(sett)
@@ -71,7 +81,7 @@ func (bot *Bot) HandleSettingsCommand(guildID string, sett *settings.GuildSettin
 	case setting.Show:
 		jBytes, err := json.MarshalIndent(sett, "", "  ")
 		if err != nil {
-			log.Println(err)
+			logger.Error("Error encoding guild settings", err, logging.GuildIDKey, guildID)
 			return err
 		}
 		// TODO need to consider if the settings are too long? Is that possible?
@@ -89,7 +99,7 @@ func (bot *Bot) HandleSettingsCommand(guildID string, sett *settings.GuildSettin
 	if isValid {
 		err := bot.StorageInterface.SetGuildSettings(guildID, sett)
 		if err != nil {
-			log.Println(err)
+			logger.Error("Error saving guild settings", err, logging.GuildIDKey, guildID)
 		}
 	}
 	return sendMsg
@@ -0,0 +1,152 @@
+package bot
+
+import (
+	"context"
+	"github.com/automuteus/automuteus/v8/bot/tokenprovider"
+	"github.com/automuteus/automuteus/v8/internal/logging"
+	"github.com/automuteus/automuteus/v8/internal/server"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// trackRunningGame records that this process is subscribed to the game, so its players can be unmuted on shutdown.
+// It returns false if the bot is shutting down, in which case the game mustn't be subscribed to
+func (bot *Bot) trackRunningGame(guildID, connectCode string) bool {
+	bot.runningGamesLock.Lock()
+	defer bot.runningGamesLock.Unlock()
+	if bot.isStopping() {
+		return false
+	}
+	bot.subscriptions.Add(1)
+	bot.runningGames[connectCode] = guildID
+	bot.runningGameBeats[connectCode] = time.Now()
+	server.SubscribedGames.WithLabelValues(bot.shardLabel()).Set(float64(len(bot.runningGames)))
+	return true
+}
+
+func (bot *Bot) untrackRunningGame(connectCode string) {
+	bot.runningGamesLock.Lock()
+	delete(bot.runningGames, connectCode)
+	delete(bot.runningGameBeats, connectCode)
+	server.JobQueueDepth.DeleteLabelValues(connectCode)
+	server.SubscribedGames.WithLabelValues(bot.shardLabel()).Set(float64(len(bot.runningGames)))
+	bot.runningGamesLock.Unlock()
+	bot.subscriptions.Done()
+}
+
+// isStopping is true once the bot has started shutting down
+func (bot *Bot) isStopping() bool {
+	select {
+	case <-bot.stopping:
+		return true
+	default:
+		return false
+	}
+}
+
+// shardLabel identifies the bot's shard in metrics
+func (bot *Bot) shardLabel() string {
+	return strconv.Itoa(bot.shardID())
+}
+
+// UnmuteRunningGames stops every running game's subscription (and stops handling voice state updates), then unmutes
+// and undeafens the tracked players of the games, so nobody is left muted when it exits. It returns once every game
+// is done, or the context expires
+func (bot *Bot) UnmuteRunningGames(ctx context.Context) {
+	bot.runningGamesLock.Lock()
+	requests := make([]GameStateRequest, 0, len(bot.runningGames))
+	for connectCode, guildID := range bot.runningGames {
+		requests = append(requests, GameStateRequest{
+			GuildID:     guildID,
+			ConnectCode: connectCode,
+		})
+	}
+	bot.stoppingOnce.Do(func() {
+		close(bot.stopping)
+	})
+	bot.runningGamesLock.Unlock()
+	if len(requests) == 0 {
+		return
+	}
+
+	// a job still being processed could mute players again after they're unmuted
+	stopped := make(chan struct{})
+	go func() {
+		bot.subscriptions.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		logger.Warn("Ran out of time waiting for running games to stop; players may still be muted", logging.ShardIDKey, bot.shardID())
+		return
+	}
+	logger.Info("Unmuting the players of running games before shutting down", "games", len(requests), logging.ShardIDKey, bot.shardID())
+
+	wg := sync.WaitGroup{}
+	for _, gsr := range requests {
+		wg.Add(1)
+		go func(gsr GameStateRequest) {
+			defer wg.Done()
+			dgs := bot.sink.ReadGameState(gsr)
+			if dgs == nil || !dgs.Running {
+				return
+			}
+			err := bot.applyToAll(ctx, dgs, false, false)
+			if err != nil {
+				bot.matchLogger(dgs).Error("Error unmuting the players of game on shutdown", err)
+			}
+		}(gsr)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		logger.Info("Finished unmuting all running games", logging.ShardIDKey, bot.shardID())
+	case <-ctx.Done():
+		logger.Warn("Ran out of time unmuting running games; some players may still be muted", logging.ShardIDKey, bot.shardID())
+	}
+}
+
+// SetTokenProvider sets the provider used for mutes/deafens, and lets work that was waiting on it (like cleaning up
+// orphaned games) proceed
+func (bot *Bot) SetTokenProvider(tp *tokenprovider.TokenProvider) {
+	bot.TokenProvider = tp
+	bot.tokenProviderOnce.Do(func() {
+		close(bot.tokenProviderReady)
+	})
+}
+
+// cleanUpOrphanedGames ends games whose capture timed out while no process was subscribed to them (after a crash, for
+// example), unmuting their players first
+func (bot *Bot) cleanUpOrphanedGames(guildID string, connectCodes []string) {
+	// mutes can't be applied until the token provider is set
+	<-bot.tokenProviderReady
+
+	for _, connectCode := range connectCodes {
+		gsr := GameStateRequest{
+			GuildID:     guildID,
+			ConnectCode: connectCode,
+		}
+		dgs := bot.RedisInterface.GetReadOnlyDiscordGameState(gsr)
+		if dgs == nil || dgs.ConnectCode == "" {
+			// the state already expired, so there's nothing left to unmute
+			bot.RedisInterface.RemoveOldGame(guildID, connectCode)
+			continue
+		}
+		l := bot.matchLogger(dgs)
+		l.Info("Cleaning up orphaned game")
+		if dgs.Running {
+			err := bot.applyToAll(ctx, dgs, false, false)
+			if err != nil {
+				l.Error("Error in unmuting all users of an orphaned game", err)
+			}
+		}
+		bot.forceEndGame(gsr)
+	}
+}
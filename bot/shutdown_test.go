@@ -0,0 +1,79 @@
+package bot
+
+import (
+	"context"
+	"github.com/automuteus/automuteus/v8/pkg/amongus"
+	"github.com/automuteus/automuteus/v8/pkg/settings"
+	"testing"
+	"time"
+)
+
+func TestRunningGames(t *testing.T) {
+	bot := Bot{
+		runningGames:       make(map[string]string),
+		runningGameBeats:   make(map[string]time.Time),
+		stopping:           make(chan struct{}),
+		tokenProviderReady: make(chan struct{}),
+	}
+	if !bot.trackRunningGame("1", "ABCDEFGH") || bot.runningGames["ABCDEFGH"] != "1" {
+		t.Error("Expected the game to be tracked with its guild")
+	}
+	bot.untrackRunningGame("ABCDEFGH")
+	if len(bot.runningGames) != 0 {
+		t.Error("Expected the game to no longer be tracked")
+	}
+
+	// with nothing running, there's nothing to wait on
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	bot.UnmuteRunningGames(ctx)
+	if bot.trackRunningGame("1", "ABCDEFGH") {
+		t.Error("Expected no new games to be tracked once the bot is shutting down")
+	}
+
+	bot.SetTokenProvider(nil)
+	bot.SetTokenProvider(nil)
+	select {
+	case <-bot.tokenProviderReady:
+	default:
+		t.Error("Expected setting the token provider to unblock work waiting on it")
+	}
+}
+
+func TestUnmuteRunningGames(t *testing.T) {
+	sink := &recordingSink{}
+	mem := newMemorySink(settings.MakeGuildSettings(), sink)
+	mem.joinVoice("Alice")
+	mem.joinVoice("Bob")
+	dgs := mem.ReadGameState(GameStateRequest{})
+	alice := amongus.PlayerData{Name: "Alice"}
+	dgs.GameData.PlayerData[alice.Name] = alice
+	user := dgs.UserData["1"]
+	user.Link(alice)
+	dgs.UserData["1"] = user
+	mem.SetGameState(dgs, nil)
+
+	bot := Bot{
+		runningGames:       make(map[string]string),
+		runningGameBeats:   make(map[string]time.Time),
+		stopping:           make(chan struct{}),
+		tokenProviderReady: make(chan struct{}),
+		sink:               mem,
+	}
+	bot.trackRunningGame(replayGuildID, replayConnectCode)
+	// the subscription loop stops once the bot starts shutting down
+	go func() {
+		<-bot.stopping
+		bot.untrackRunningGame(replayConnectCode)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	bot.UnmuteRunningGames(ctx)
+
+	// Bob isn't linked to a player, so the game never muted them
+	expected := []voiceChangeRecord{{name: "Alice"}}
+	if len(sink.voice) != len(expected) || sink.voice[0] != expected[0] {
+		t.Errorf("Expected only Alice to be unmuted, got %v", sink.voice)
+	}
+}
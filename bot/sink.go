@@ -0,0 +1,239 @@
+package bot
+
+import (
+	"context"
+	"github.com/automuteus/automuteus/v8/internal/server"
+	"github.com/automuteus/automuteus/v8/pkg/game"
+	"github.com/automuteus/automuteus/v8/pkg/premium"
+	"github.com/automuteus/automuteus/v8/pkg/settings"
+	"github.com/automuteus/automuteus/v8/pkg/storage"
+	"github.com/automuteus/automuteus/v8/pkg/task"
+	"github.com/bsm/redislock"
+	"github.com/bwmarrin/discordgo"
+	"strconv"
+	"time"
+)
+
+// guildMembers fetches guild members that aren't cached
+type guildMembers interface {
+	GuildMember(guildID, userID string, options ...discordgo.RequestOption) (*discordgo.Member, error)
+}
+
+// gameSink is where the side effects of processing a game's jobs go. A live game's sink is Redis, Postgres and
+// Discord (liveSink); a replayed game's is memory (memorySink), so replays run the same handlers as live games
+type gameSink interface {
+	guildMembers
+
+	// Now is the time jobs are sequenced at
+	Now() time.Time
+	SaveLastSequence(connectCode, source string, seq uint64) error
+	SavePrimarySource(connectCode, source string) error
+
+	GuildSettings(guildID string) *settings.GuildSettings
+	PremiumTier(guildID string) premium.Tier
+
+	// LockGameState locks the game state and fetches it, waiting for the lock until ctx is done
+	LockGameState(ctx context.Context, gsr GameStateRequest) (*redislock.Lock, *GameState, error)
+	ReadGameState(gsr GameStateRequest) *GameState
+	// SetGameState saves the game state (unless it's nil), and releases the lock
+	SetGameState(dgs *GameState, lock *redislock.Lock)
+	// GameStateLocked is called when a game state update is skipped, because the lock couldn't be obtained
+	GameStateLocked(gsr GameStateRequest, sett *settings.GuildSettings)
+	RefreshGameLiveness(guildID, connectCode string)
+
+	Guild(guildID string) (*discordgo.Guild, error)
+	LockVoiceChanges(connectCode string, dur time.Duration) *redislock.Lock
+	// Sleep waits out the delay configured before applying voice changes
+	Sleep(dur time.Duration)
+	// Go runs work the next job doesn't have to wait for
+	Go(f func())
+	ModifyUsers(ctx context.Context, guildID, connectCode string, req task.UserModifyRequest, lock *redislock.Lock) error
+
+	EditGameMessage(dgs *GameState, embed *discordgo.MessageEmbed)
+	// ReplaceGameMessage deletes the game's message, and sends it again with the embed
+	ReplaceGameMessage(dgs *GameState, embed *discordgo.MessageEmbed)
+	EditGameMessageComponents(dgs *GameState)
+	SendMessage(channelID, content string)
+	// SendGameOver sends the game's summary, deleting it after deleteAfter (if it's positive)
+	SendGameOver(channelID string, embed *discordgo.MessageEmbed, deleteAfter time.Duration)
+
+	StartMatch(dgs GameState) uint64
+	EndMatch(dgs GameState, gameOver game.Gameover)
+	AddEvents(gsr GameStateRequest, events []correlatedEvent)
+	UserIDByAlias(guildID, playerName string) (string, error)
+	PlayerNamesForUserIDs(guildID string, userIDs []string) (map[string][]string, error)
+	UserPlayerHistory(guildID string, userIDs []uint64) ([]*storage.PostgresUserPlayerHistory, error)
+}
+
+// liveSink applies a live game's side effects to Redis, Postgres and Discord
+type liveSink struct {
+	bot *Bot
+}
+
+func (sink liveSink) GuildMember(guildID, userID string, options ...discordgo.RequestOption) (*discordgo.Member, error) {
+	return sink.bot.PrimarySession.GuildMember(guildID, userID, options...)
+}
+
+func (sink liveSink) Now() time.Time {
+	return time.Now()
+}
+
+func (sink liveSink) SaveLastSequence(connectCode, source string, seq uint64) error {
+	return task.SetLastSequence(ctx, sink.bot.RedisInterface.client, connectCode, source, seq)
+}
+
+func (sink liveSink) SavePrimarySource(connectCode, source string) error {
+	return task.SetPrimarySource(ctx, sink.bot.RedisInterface.client, connectCode, source)
+}
+
+func (sink liveSink) GuildSettings(guildID string) *settings.GuildSettings {
+	return sink.bot.StorageInterface.GetGuildSettings(guildID)
+}
+
+func (sink liveSink) PremiumTier(guildID string) premium.Tier {
+	prem, days, _ := sink.bot.PostgresInterface.GetGuildOrUserPremiumStatus(sink.bot.official, nil, guildID, "")
+	if premium.IsExpired(prem, days) {
+		return premium.FreeTier
+	}
+	return prem
+}
+
+func (sink liveSink) LockGameState(ctx context.Context, gsr GameStateRequest) (*redislock.Lock, *GameState, error) {
+	return sink.bot.RedisInterface.GetDiscordGameStateAndLock(ctx, gsr)
+}
+
+func (sink liveSink) ReadGameState(gsr GameStateRequest) *GameState {
+	return sink.bot.RedisInterface.GetReadOnlyDiscordGameState(gsr)
+}
+
+func (sink liveSink) SetGameState(dgs *GameState, lock *redislock.Lock) {
+	sink.bot.RedisInterface.SetDiscordGameState(dgs, lock)
+}
+
+func (sink liveSink) GameStateLocked(gsr GameStateRequest, sett *settings.GuildSettings) {
+	sink.bot.notifyGameStateLocked(gsr, sett)
+}
+
+func (sink liveSink) RefreshGameLiveness(guildID, connectCode string) {
+	sink.bot.refreshGameLiveness(connectCode)
+	sink.bot.RedisInterface.RefreshActiveGame(guildID, connectCode)
+}
+
+func (sink liveSink) Guild(guildID string) (*discordgo.Guild, error) {
+	return sink.bot.PrimarySession.State.Guild(guildID)
+}
+
+func (sink liveSink) LockVoiceChanges(connectCode string, dur time.Duration) *redislock.Lock {
+	return sink.bot.RedisInterface.LockVoiceChanges(connectCode, dur)
+}
+
+func (sink liveSink) Sleep(dur time.Duration) {
+	time.Sleep(dur)
+}
+
+func (sink liveSink) ModifyUsers(ctx context.Context, guildID, connectCode string, req task.UserModifyRequest, lock *redislock.Lock) error {
+	return sink.bot.TokenProvider.ModifyUsers(ctx, guildID, connectCode, req, lock)
+}
+
+func (sink liveSink) EditGameMessage(dgs *GameState, embed *discordgo.MessageEmbed) {
+	if dgs.dispatchEdit(sink.bot.PrimarySession, embed) {
+		server.RecordDiscordRequests(sink.bot.RedisInterface.client, server.MessageEdit, 1)
+	}
+}
+
+func (sink liveSink) ReplaceGameMessage(dgs *GameState, embed *discordgo.MessageEmbed) {
+	// don't try to edit this message, because we're about to delete it
+	RemovePendingDGSEdit(dgs.GameStateMsg.MessageID)
+
+	deleted := dgs.DeleteGameStateMsg(sink.bot.PrimarySession, false) // delete the old message
+	created := dgs.CreateMessage(sink.bot.PrimarySession, embed, dgs.GameStateMsg.MessageChannelID, dgs.GameStateMsg.LeaderID)
+
+	if deleted && created {
+		go server.RecordDiscordRequests(sink.bot.RedisInterface.client, server.MessageCreateDelete, 2)
+	} else if deleted || created {
+		go server.RecordDiscordRequests(sink.bot.RedisInterface.client, server.MessageCreateDelete, 1)
+	}
+}
+
+func (sink liveSink) Go(f func()) {
+	go f()
+}
+
+func (sink liveSink) EditGameMessageComponents(dgs *GameState) {
+	me := discordgo.NewMessageEdit(dgs.GameStateMsg.MessageChannelID, dgs.GameStateMsg.MessageID)
+	components := dgs.gameStateComponents()
+	me.Components = &components
+	_, err := sink.bot.PrimarySession.ChannelMessageEditComplex(me)
+	if err != nil {
+		sink.bot.matchLogger(dgs).Error("Error editing the game message's components", err)
+	}
+	server.RecordDiscordRequests(sink.bot.RedisInterface.client, server.MessageEdit, 1)
+}
+
+func (sink liveSink) SendMessage(channelID, content string) {
+	_, err := sink.bot.PrimarySession.ChannelMessageSend(channelID, content)
+	if err == nil {
+		server.RecordDiscordRequests(sink.bot.RedisInterface.client, server.MessageCreateDelete, 1)
+	}
+}
+
+func (sink liveSink) SendGameOver(channelID string, embed *discordgo.MessageEmbed, deleteAfter time.Duration) {
+	msg, err := sink.bot.PrimarySession.ChannelMessageSendEmbed(channelID, embed)
+	if deleteAfter > 0 && err == nil {
+		server.RecordDiscordRequests(sink.bot.RedisInterface.client, server.MessageCreateDelete, 2)
+		go MessageDeleteWorker(sink.bot.PrimarySession, msg.ChannelID, msg.ID, deleteAfter)
+	} else if err == nil {
+		server.RecordDiscordRequests(sink.bot.RedisInterface.client, server.MessageCreateDelete, 1)
+	}
+}
+
+func (sink liveSink) StartMatch(dgs GameState) uint64 {
+	return startGameInPostgres(sink.bot.matchLogger(&dgs), dgs, sink.bot.PostgresInterface)
+}
+
+func (sink liveSink) EndMatch(dgs GameState, gameOver game.Gameover) {
+	go dumpGameToPostgres(sink.bot.matchLogger(&dgs), dgs, sink.bot.PostgresInterface, gameOver)
+}
+
+// AddEvents persists the events in the background, once the game state shows they're part of a match
+func (sink liveSink) AddEvents(gsr GameStateRequest, events []correlatedEvent) {
+	go func() {
+		dgs := sink.bot.RedisInterface.GetReadOnlyDiscordGameState(gsr)
+		if dgs == nil || dgs.MatchID <= 0 || dgs.MatchStartUnix <= 0 {
+			return
+		}
+		l := sink.bot.matchLogger(dgs)
+		for _, v := range events {
+			ge := v.event
+			ge.GameID = dgs.MatchID
+			if v.userID != "" {
+				num, err := strconv.ParseUint(v.userID, 10, 64)
+				if err != nil {
+					l.Error("Error parsing the event's user ID", err, "user_id", v.userID)
+					ge.UserID = nil
+				} else {
+					ge.UserID = &num
+				}
+			}
+
+			err := sink.bot.PostgresInterface.AddEvent(&ge)
+			if err != nil {
+				l.Error("Error adding event to Postgres", err, "event_type", ge.EventType, "user_id", v.userID)
+			} else {
+				l.Debug("Added event to Postgres", "event_type", ge.EventType, "user_id", v.userID)
+			}
+		}
+	}()
+}
+
+func (sink liveSink) UserIDByAlias(guildID, playerName string) (string, error) {
+	return sink.bot.PostgresInterface.GetUserIDByAlias(guildID, playerName)
+}
+
+func (sink liveSink) PlayerNamesForUserIDs(guildID string, userIDs []string) (map[string][]string, error) {
+	return sink.bot.RedisInterface.GetPlayerNamesForUserIDs(guildID, userIDs)
+}
+
+func (sink liveSink) UserPlayerHistory(guildID string, userIDs []uint64) ([]*storage.PostgresUserPlayerHistory, error) {
+	return sink.bot.PostgresInterface.UserPlayerHistoryOnServer(guildID, userIDs)
+}
@@ -1,12 +1,14 @@
 package bot
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/automuteus/automuteus/v8/internal/logging"
 	"github.com/automuteus/automuteus/v8/internal/server"
 	"github.com/automuteus/automuteus/v8/pkg/storage"
-	"log"
+	"github.com/bsm/redislock"
 	"regexp"
 	"strconv"
 	"strings"
@@ -15,9 +17,12 @@ import (
 	"github.com/automuteus/automuteus/v8/bot/command"
 	"github.com/automuteus/automuteus/v8/bot/setting"
 	redis_common "github.com/automuteus/automuteus/v8/common"
+	"github.com/automuteus/automuteus/v8/pkg/amongus"
 	"github.com/automuteus/automuteus/v8/pkg/discord"
+	"github.com/automuteus/automuteus/v8/pkg/namematch"
 	"github.com/automuteus/automuteus/v8/pkg/premium"
 	"github.com/automuteus/automuteus/v8/pkg/settings"
+	"github.com/automuteus/automuteus/v8/pkg/task"
 	"github.com/bwmarrin/discordgo"
 	"github.com/nicksnyder/go-i18n/v2/i18n"
 )
@@ -48,10 +53,12 @@ const (
 	downloadUsersGamesConfirmedID = "download-users-games-confirmed"
 	downloadGamesConfirmedID      = "download-games-confirmed"
 	downloadGameEventsConfirmedID = "download-game-events-confirmed"
+	downloadVoiceOpsConfirmedID   = "download-voice-ops-confirmed"
 	downloadCanceledID            = "download-canceled"
 )
 
 func (bot *Bot) handleInteractionCreate(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	l := bot.interactionLogger(i)
 	respondChan := make(chan *discordgo.InteractionResponse)
 	ticker := time.NewTicker(time.Second * 2)
 	var followUpMsg *discordgo.Message
@@ -75,13 +82,13 @@ func (bot *Bot) handleInteractionCreate(s *discordgo.Session, i *discordgo.Inter
 					},
 				})
 				if err != nil {
-					log.Println("err issuing wait response ", err)
+					l.Error("Error responding to interaction with a wait message", err)
 				}
 				followUpMsg, err = s.FollowupMessageCreate(i.Interaction, true, &discordgo.WebhookParams{
 					Content: Hourglass,
 				})
 				if err != nil {
-					log.Println("Error creating followup message: ", err)
+					l.Error("Error creating followup message", err)
 				}
 			}
 			// don't return here
@@ -99,19 +106,16 @@ func (bot *Bot) handleInteractionCreate(s *discordgo.Session, i *discordgo.Inter
 						Embeds:     &resp.Data.Embeds,
 					})
 				} else {
-					//TODO if this shows up in logs regularly, print more context
-					log.Println("received a nil response, or resp.data was nil")
+					l.Warn("No response to edit the followup message with", "nil_response", resp == nil)
 				}
 			} else if resp != nil {
 				err = s.InteractionRespond(i.Interaction, resp)
 				if err != nil {
-					log.Println("error issuing interaction response: ", err)
-					iBytes, err := json.Marshal(i.Interaction)
-					if err != nil {
-						log.Println(err)
-					} else {
-						log.Println(string(iBytes))
+					iBytes, jsonErr := json.Marshal(i.Interaction)
+					if jsonErr != nil {
+						l.Error("Error encoding interaction", jsonErr)
 					}
+					l.Error("Error responding to interaction", err, "interaction", string(iBytes))
 				}
 			}
 			ticker.Stop()
@@ -138,6 +142,7 @@ func (bot *Bot) slashCommandHandler(s *discordgo.Session, i *discordgo.Interacti
 	defer interactionLock.Release(ctx)
 
 	sett := bot.StorageInterface.GetGuildSettings(i.GuildID)
+	l := bot.interactionLogger(i)
 
 	// TODO respond properly for commands that *can* be performed in DMs. Such as minimal stats queries, help, info, etc
 	// NOTE: difference between i.Member.User (Server/Guild chat) vs i.User (DMs)
@@ -152,12 +157,12 @@ func (bot *Bot) slashCommandHandler(s *discordgo.Session, i *discordgo.Interacti
 
 	g, err := s.State.Guild(i.GuildID)
 	if err != nil {
-		log.Println(err)
+		l.Error("Error fetching guild for interaction", err)
 		return command.PrivateErrorResponse("get-guild", err, sett)
 	}
 	perm, err := bot.PrimarySession.State.UserChannelPermissions(s.State.User.ID, i.ChannelID)
 	if err != nil {
-		log.Println(err)
+		l.Error("Error fetching the bot's channel permissions", err, "channel_id", i.ChannelID)
 		return command.PrivateErrorResponse("get-permissions", err, sett)
 	}
 	missingPerms := checkPermissions(perm, RequiredPermissions)
@@ -209,20 +214,28 @@ func (bot *Bot) slashCommandHandler(s *discordgo.Session, i *discordgo.Interacti
 			return command.InfoResponse(botInfo, i.GuildID, sett)
 
 		case command.Link.Name:
+			sub, userID, color := command.GetLinkParams(s, i.ApplicationCommandData().Options)
+			if sub == command.LinkAlias {
+				alias, aliasUserID, remove := command.GetLinkAliasParams(s, i.ApplicationCommandData().Options, i.Member.User.ID)
+				// anyone can manage their own aliases
+				if aliasUserID != i.Member.User.ID && !isPermissioned {
+					return command.InsufficientPermissionsResponse(sett)
+				}
+				return bot.linkAliasAndRespond(gsr, aliasUserID, alias, remove, sett)
+			}
 			if !isPermissioned {
 				return command.InsufficientPermissionsResponse(sett)
 			}
-			userID, color := command.GetLinkParams(s, i.ApplicationCommandData().Options)
 
-			lock, dgs := bot.RedisInterface.GetDiscordGameStateAndLockRetries(gsr, 5)
-			if lock == nil {
-				log.Printf("No lock could be obtained when linking for guild %s, channel %s\n", i.GuildID, i.ChannelID)
+			lock, dgs, err := bot.lockForInteraction(gsr)
+			if err != nil {
 				return command.DeadlockGameStateResponse(command.Link.Name, sett)
 			}
 			resp, success := bot.linkOrUnlinkAndRespond(dgs, userID, color, sett)
 			if success {
 				bot.RedisInterface.SetDiscordGameState(dgs, lock)
 				bot.DispatchRefreshOrEdit(dgs, gsr, sett)
+				go bot.updateLinkSuggestions(gsr)
 			} else {
 				// release the lock
 				bot.RedisInterface.SetDiscordGameState(nil, lock)
@@ -235,28 +248,56 @@ func (bot *Bot) slashCommandHandler(s *discordgo.Session, i *discordgo.Interacti
 			}
 			userID := command.GetUnlinkParams(s, i.ApplicationCommandData().Options)
 
-			lock, dgs := bot.RedisInterface.GetDiscordGameStateAndLock(gsr)
-			if lock == nil {
-				log.Printf("No lock could be obtained when unlinking for guild %s, channel %s\n", i.GuildID, i.ChannelID)
+			lock, dgs, err := bot.lockForInteraction(gsr)
+			if err != nil {
 				return command.DeadlockGameStateResponse(command.Unlink.Name, sett)
 			}
 			resp, success := bot.linkOrUnlinkAndRespond(dgs, userID, "", sett)
 			if success {
 				bot.RedisInterface.SetDiscordGameState(dgs, lock)
 				bot.DispatchRefreshOrEdit(dgs, gsr, sett)
+				go bot.updateLinkSuggestions(gsr)
 			} else {
 				// release the lock
 				bot.RedisInterface.SetDiscordGameState(nil, lock)
 			}
 			return resp
 
+		case command.Spectate.Name:
+			userID := command.GetSpectateParams(s, i.Member.User.ID, i.ApplicationCommandData().Options)
+			// anyone can start or stop spectating themselves
+			if userID != i.Member.User.ID && !isPermissioned {
+				return command.InsufficientPermissionsResponse(sett)
+			}
+
+			lock, dgs, err := bot.lockForInteraction(gsr)
+			if err != nil {
+				return command.DeadlockGameStateResponse(command.Spectate.Name, sett)
+			}
+			if !dgs.GameStateMsg.Exists() {
+				// release the lock
+				bot.RedisInterface.SetDiscordGameState(nil, lock)
+				return command.NoGameResponse(sett)
+			}
+			status := bot.toggleSpectator(dgs, g, userID, sett)
+			if status == command.SpectateNoUser {
+				// release the lock
+				bot.RedisInterface.SetDiscordGameState(nil, lock)
+			} else {
+				bot.RedisInterface.SetDiscordGameState(dgs, lock)
+				bot.DispatchRefreshOrEdit(dgs, gsr, sett)
+				go bot.handleTrackedMembers(ctx, sett, 0, NoPriority, gsr)
+				go bot.updateLinkSuggestions(gsr)
+			}
+			return command.SpectateResponse(status, userID, sett)
+
 		case command.Settings.Name:
 			if !isAdmin {
 				return command.InsufficientPermissionsResponse(sett)
 			}
 			premStatus, days, err := bot.PostgresInterface.GetGuildOrUserPremiumStatus(bot.official, bot.TopGGClient, i.GuildID, i.Member.User.ID)
 			if err != nil {
-				log.Println("Err in /settings get premium:", err)
+				l.Error("Error fetching premium status", err, "command", command.Settings.Name)
 			}
 			setting, args := command.GetSettingsParams(i.ApplicationCommandData().Options)
 			msg := bot.HandleSettingsCommand(i.GuildID, sett, setting, args, !premium.IsExpired(premStatus, days))
@@ -278,9 +319,8 @@ func (bot *Bot) slashCommandHandler(s *discordgo.Session, i *discordgo.Interacti
 				return command.ReinviteMeResponse(missingPerms, voiceChannelID, sett)
 			}
 
-			lock, dgs := bot.RedisInterface.GetDiscordGameStateAndLockRetries(gsr, 5)
-			if lock == nil {
-				log.Printf("No lock could be obtained when making a new game for guild %s, channel %s\n", i.GuildID, i.ChannelID)
+			lock, dgs, err := bot.lockForInteraction(gsr)
+			if err != nil {
 				return command.DeadlockGameStateResponse(command.New.Name, sett)
 			}
 
@@ -308,6 +348,7 @@ func (bot *Bot) slashCommandHandler(s *discordgo.Session, i *discordgo.Interacti
 					ApiHyperlink: apiHyperlink,
 					MinimalURL:   minimalURL,
 					ConnectCode:  dgs.ConnectCode,
+					CaptureToken: bot.captureToken(dgs.ConnectCode),
 					ActiveGames:  activeGames, // not actually needed for Success messages
 				}, sett)
 			} else {
@@ -318,19 +359,31 @@ func (bot *Bot) slashCommandHandler(s *discordgo.Session, i *discordgo.Interacti
 				}, sett)
 			}
 		case command.Refresh.Name:
-			if bot.RefreshGameStateMessage(gsr, sett) {
+			lockCtx, cancel := context.WithTimeout(ctx, InteractionLockTimeout)
+			defer cancel()
+			refreshed, err := bot.RefreshGameStateMessage(lockCtx, gsr, sett)
+			switch {
+			case err != nil:
+				return command.DeadlockGameStateResponse(command.Refresh.Name, sett)
+			case refreshed:
 				return command.PrivateResponse(ThumbsUp)
-			} else {
+			default:
+				return command.NoGameResponse(sett)
+			}
+
+		case command.Tasks.Name:
+			dgs := bot.RedisInterface.GetReadOnlyDiscordGameState(gsr)
+			if dgs == nil || !dgs.GameStateMsg.Exists() {
 				return command.NoGameResponse(sett)
 			}
+			return command.TasksResponse(dgs.GameData.GetTaskProgress(), dgs.canSeeTaskProgress(i.Member.User.ID, sett), sett)
 
 		case command.Pause.Name:
 			if !isPermissioned {
 				return command.InsufficientPermissionsResponse(sett)
 			}
-			lock, dgs := bot.RedisInterface.GetDiscordGameStateAndLockRetries(gsr, 5)
-			if lock == nil {
-				log.Printf("No lock could be obtained when pausing game for guild %s, channel %s\n", i.GuildID, i.ChannelID)
+			lock, dgs, err := bot.lockForInteraction(gsr)
+			if err != nil {
 				return command.DeadlockGameStateResponse(command.Pause.Name, sett)
 			}
 			if !dgs.GameStateMsg.Exists() {
@@ -343,7 +396,7 @@ func (bot *Bot) slashCommandHandler(s *discordgo.Session, i *discordgo.Interacti
 			bot.RedisInterface.SetDiscordGameState(dgs, lock)
 			// if we paused the game, unmute/undeafen all players
 			if !dgs.Running {
-				err = bot.applyToAll(dgs, false, false)
+				err = bot.applyToAll(ctx, dgs, false, false)
 			}
 			bot.DispatchRefreshOrEdit(dgs, gsr, sett)
 			if err != nil {
@@ -366,7 +419,7 @@ func (bot *Bot) slashCommandHandler(s *discordgo.Session, i *discordgo.Interacti
 				}
 				delete(bot.EndGameChannels, dgs.ConnectCode)
 
-				err = bot.applyToAll(dgs, false, false)
+				err = bot.applyToAll(ctx, dgs, false, false)
 				if err != nil {
 					return command.PrivateErrorResponse(command.End.Name, err, sett)
 				}
@@ -405,7 +458,7 @@ func (bot *Bot) slashCommandHandler(s *discordgo.Session, i *discordgo.Interacti
 			prem := true
 			tier, days, err := bot.PostgresInterface.GetGuildOrUserPremiumStatus(bot.official, bot.TopGGClient, i.GuildID, i.Member.User.ID)
 			if err != nil {
-				log.Println("Error in /stats getPremium:", err)
+				l.Error("Error fetching premium status", err, "command", command.Stats.Name)
 			}
 			if premium.IsExpired(tier, days) {
 				prem = false
@@ -416,7 +469,8 @@ func (bot *Bot) slashCommandHandler(s *discordgo.Session, i *discordgo.Interacti
 				case command.User:
 					embed = bot.UserStatsEmbed(id, i.GuildID, sett, prem)
 				case command.Guild:
-					embed = bot.GuildStatsEmbed(i.GuildID, sett, prem)
+					ruleset := command.GetStatsRuleset(i.ApplicationCommandData().Options)
+					embed = bot.GuildStatsEmbed(i.GuildID, sett, prem, ruleset)
 				case command.Match:
 					if MatchIDRegex.Match([]byte(id)) {
 						tokens := strings.Split(id, ":")
@@ -477,7 +531,7 @@ func (bot *Bot) slashCommandHandler(s *discordgo.Session, i *discordgo.Interacti
 			premArg := command.GetPremiumParams(i.ApplicationCommandData().Options)
 			premStatus, days, err := bot.PostgresInterface.GetGuildOrUserPremiumStatus(bot.official, bot.TopGGClient, i.GuildID, i.Member.User.ID)
 			if err != nil {
-				log.Println("Err in /premium get guild prem:", err)
+				l.Error("Error fetching premium status", err, "command", command.Premium.Name)
 			}
 			if premium.IsExpired(premStatus, days) {
 				premStatus = premium.FreeTier
@@ -485,11 +539,11 @@ func (bot *Bot) slashCommandHandler(s *discordgo.Session, i *discordgo.Interacti
 			return command.PremiumResponse(i.GuildID, premStatus, days, premArg, isAdmin, sett)
 
 		case command.Debug.Name:
-			action, opType, id := command.GetDebugParams(bot.PrimarySession, i.Member.User.ID, i.ApplicationCommandData().Options)
+			action, opType, id, connectCode := command.GetDebugParams(bot.PrimarySession, i.Member.User.ID, i.ApplicationCommandData().Options)
 			if action == setting.View {
 				if opType == command.User {
 					cached, err := bot.RedisInterface.GetUsernameOrUserIDMappings(i.GuildID, id)
-					log.Println("View user cache")
+					l.Debug("Viewing user cache", "target_user_id", id)
 					return command.DebugResponse(setting.View, cached, nil, id, err, sett)
 				} else if opType == command.GameState {
 					state := bot.RedisInterface.GetReadOnlyDiscordGameState(gsr)
@@ -499,6 +553,33 @@ func (bot *Bot) slashCommandHandler(s *discordgo.Session, i *discordgo.Interacti
 					} else {
 						return command.DeadlockGameStateResponse(command.Debug.Name, sett)
 					}
+				} else if opType == command.VoiceLog {
+					if !isAdmin {
+						return command.InsufficientPermissionsResponse(sett)
+					}
+					ops, err := task.GetVoiceLog(ctx, bot.RedisInterface.client, i.GuildID, 0)
+					if uid, err := strconv.ParseUint(id, 10, 64); err == nil {
+						ops = task.FilterVoiceOpsByUser(ops, uid)
+					}
+					return command.DebugVoiceLogResponse(ops, err, sett)
+				} else if opType == command.Recording {
+					if !isAdmin {
+						return command.InsufficientPermissionsResponse(sett)
+					}
+					if connectCode == "" {
+						// no code provided; use the game in this channel
+						state := bot.RedisInterface.GetReadOnlyDiscordGameState(gsr)
+						if state == nil {
+							return command.DeadlockGameStateResponse(command.Debug.Name, sett)
+						}
+						connectCode = state.ConnectCode
+					}
+					recording, err := task.GetRecording(ctx, bot.RedisInterface.client, i.GuildID, connectCode)
+					if err != nil || len(recording) == 0 {
+						return command.DebugRecordingResponse(connectCode, nil, err, sett)
+					}
+					jBytes, err := json.MarshalIndent(recording, "", "  ")
+					return command.DebugRecordingResponse(connectCode, jBytes, err, sett)
 				}
 			} else if action == setting.Clear {
 				if opType == command.User {
@@ -530,7 +611,7 @@ func (bot *Bot) slashCommandHandler(s *discordgo.Session, i *discordgo.Interacti
 						// fetch the game state purely by the voice channel ID
 						gsr.TextChannel = ""
 						gsr.VoiceChannel = v.ChannelID
-						log.Println("fetching game by id ", v.ChannelID)
+						l.Debug("Fetching game by voice channel", "channel_id", v.ChannelID)
 
 						// no game is happening in this voice channel, so we're safe to unmute
 						if bot.RedisInterface.getDiscordGameStateKey(gsr) == "" {
@@ -549,7 +630,7 @@ func (bot *Bot) slashCommandHandler(s *discordgo.Session, i *discordgo.Interacti
 			} else if action == command.UnmuteAll {
 				dgs := bot.RedisInterface.GetReadOnlyDiscordGameState(gsr)
 				if dgs != nil {
-					err = bot.applyToAll(dgs, false, false)
+					err = bot.applyToAll(ctx, dgs, false, false)
 					if err != nil {
 						return command.PrivateErrorResponse(command.UnmuteAll, err, sett)
 					}
@@ -564,7 +645,7 @@ func (bot *Bot) slashCommandHandler(s *discordgo.Session, i *discordgo.Interacti
 			// don't send the userid because downloading is restricted to Gold members
 			premStatus, days, err := bot.PostgresInterface.GetGuildOrUserPremiumStatus(bot.official, bot.TopGGClient, i.GuildID, "")
 			if err != nil {
-				log.Println("Err in /download get guild prem:", err)
+				l.Error("Error fetching premium status", err, "command", command.Download.Name)
 			}
 			if premium.IsExpired(premStatus, days) {
 				premStatus = premium.FreeTier
@@ -606,6 +687,8 @@ func (bot *Bot) slashCommandHandler(s *discordgo.Session, i *discordgo.Interacti
 				downloadConfirmedID = downloadGamesConfirmedID
 			case command.GameEvents:
 				downloadConfirmedID = downloadGameEventsConfirmedID
+			case command.VoiceOps:
+				downloadConfirmedID = downloadVoiceOpsConfirmedID
 			}
 			components = confirmationComponents(downloadConfirmedID, downloadCanceledID, sett)
 			return &discordgo.InteractionResponse{
@@ -627,16 +710,15 @@ func (bot *Bot) slashCommandHandler(s *discordgo.Session, i *discordgo.Interacti
 
 		gid, err := strconv.ParseUint(i.GuildID, 10, 64)
 		if err != nil {
-			log.Println(err)
+			l.Error("Error parsing the guild ID", err)
 			// TODO report this properly
 		}
 		switch i.MessageComponentData().CustomID {
 		case colorSelectID:
 			if len(i.MessageComponentData().Values) > 0 {
 				value := i.MessageComponentData().Values[0]
-				lock, dgs := bot.RedisInterface.GetDiscordGameStateAndLockRetries(gsr, 5)
-				if lock == nil {
-					log.Printf("No lock could be obtained when linking for guild %s, channel %s\n", i.GuildID, i.ChannelID)
+				lock, dgs, err := bot.lockForInteraction(gsr)
+				if err != nil {
 					return command.DeadlockGameStateResponse(command.Link.Name, sett)
 				}
 				if value == UnlinkEmojiName {
@@ -646,6 +728,7 @@ func (bot *Bot) slashCommandHandler(s *discordgo.Session, i *discordgo.Interacti
 				if success {
 					bot.RedisInterface.SetDiscordGameState(dgs, lock)
 					bot.DispatchRefreshOrEdit(dgs, gsr, sett)
+					go bot.updateLinkSuggestions(gsr)
 				} else {
 					// only release the lock; no changes
 					bot.RedisInterface.SetDiscordGameState(nil, lock)
@@ -732,7 +815,7 @@ func (bot *Bot) slashCommandHandler(s *discordgo.Session, i *discordgo.Interacti
 		case downloadGuildConfirmedID:
 			guild, err := bot.PostgresInterface.GetGuildForDownload(gid)
 			if err != nil {
-				log.Println("Error downloading guild data:", err)
+				l.Error("Error fetching data for download", err, "data", "guild")
 				return downloadErrorResponse(sett, err)
 			} else {
 				redis_common.MarkDownloadCategoryCooldown(bot.RedisInterface.client, i.GuildID, command.Guild)
@@ -759,7 +842,7 @@ func (bot *Bot) slashCommandHandler(s *discordgo.Session, i *discordgo.Interacti
 		case downloadUsersConfirmedID:
 			users, err := bot.PostgresInterface.GetUsersForGuild(gid)
 			if err != nil {
-				log.Println("Error downloading users data:", err)
+				l.Error("Error fetching data for download", err, "data", "users")
 				return downloadErrorResponse(sett, err)
 			} else {
 				redis_common.MarkDownloadCategoryCooldown(bot.RedisInterface.client, i.GuildID, command.Users)
@@ -785,7 +868,7 @@ func (bot *Bot) slashCommandHandler(s *discordgo.Session, i *discordgo.Interacti
 		case downloadUsersGamesConfirmedID:
 			usersGames, err := bot.PostgresInterface.GetUsersGamesForGuild(gid)
 			if err != nil {
-				log.Println("Error downloading users_games data:", err)
+				l.Error("Error fetching data for download", err, "data", "users_games")
 				return downloadErrorResponse(sett, err)
 			} else {
 				redis_common.MarkDownloadCategoryCooldown(bot.RedisInterface.client, i.GuildID, command.UsersGames)
@@ -811,7 +894,7 @@ func (bot *Bot) slashCommandHandler(s *discordgo.Session, i *discordgo.Interacti
 		case downloadGamesConfirmedID:
 			games, err := bot.PostgresInterface.GetGamesForGuild(gid)
 			if err != nil {
-				log.Println("Error downloading game data:", err)
+				l.Error("Error fetching data for download", err, "data", "games")
 				return downloadErrorResponse(sett, err)
 			} else {
 				redis_common.MarkDownloadCategoryCooldown(bot.RedisInterface.client, i.GuildID, command.Games)
@@ -837,7 +920,7 @@ func (bot *Bot) slashCommandHandler(s *discordgo.Session, i *discordgo.Interacti
 		case downloadGameEventsConfirmedID:
 			events, err := bot.PostgresInterface.GetGamesEventsForGuild(gid)
 			if err != nil {
-				log.Println("Error downloading game events data:", err)
+				l.Error("Error fetching data for download", err, "data", "game_events")
 				return downloadErrorResponse(sett, err)
 			} else {
 				redis_common.MarkDownloadCategoryCooldown(bot.RedisInterface.client, i.GuildID, command.GameEvents)
@@ -860,6 +943,32 @@ func (bot *Bot) slashCommandHandler(s *discordgo.Session, i *discordgo.Interacti
 					},
 				}
 			}
+		case downloadVoiceOpsConfirmedID:
+			ops, err := task.GetVoiceLog(ctx, bot.RedisInterface.client, i.GuildID, 0)
+			if err != nil {
+				l.Error("Error fetching data for download", err, "data", "voice_ops")
+				return downloadErrorResponse(sett, err)
+			} else {
+				redis_common.MarkDownloadCategoryCooldown(bot.RedisInterface.client, i.GuildID, command.VoiceOps)
+				return &discordgo.InteractionResponse{
+					Type: discordgo.InteractionResponseUpdateMessage,
+					Data: &discordgo.InteractionResponseData{
+						Flags: 1 << 6, //private message
+						Content: sett.LocalizeMessage(&i18n.Message{
+							ID:    "commands.download.file.success",
+							Other: "Here's that file for you!",
+						}),
+						Components: []discordgo.MessageComponent{},
+						Files: []*discordgo.File{
+							{
+								Name:        "voice_ops.csv",
+								ContentType: "text/csv",
+								Reader:      strings.NewReader(task.VoiceOpsToCSV(ops)),
+							},
+						},
+					},
+				}
+			}
 		case downloadCanceledID:
 			fallthrough
 		case resetUserCanceledID:
@@ -869,6 +978,12 @@ func (bot *Bot) slashCommandHandler(s *discordgo.Session, i *discordgo.Interacti
 				bot.deleteComponentInParentMessage(s, i)
 			}
 			return resetCancelResponse(sett)
+
+		default:
+			customID := i.MessageComponentData().CustomID
+			if strings.HasPrefix(customID, suggestionConfirmPrefix) || strings.HasPrefix(customID, suggestionRejectPrefix) {
+				return bot.handleLinkSuggestion(gsr, i.Member.User.ID, customID, isPermissioned, sett)
+			}
 		}
 	}
 
@@ -897,7 +1012,7 @@ func (bot *Bot) linkOrUnlinkAndRespond(dgs *GameState, userID, testValue string,
 		unlinkPlayer(dgs, userID)
 		status, err := linkPlayer(bot.RedisInterface, dgs, userID, testValue)
 		if err != nil {
-			log.Println(err)
+			bot.matchLogger(dgs).Info("Couldn't link user", "user_id", userID, "color", testValue, "reason", err.Error())
 		}
 		return command.LinkResponse(status, userID, testValue, sett), status == command.LinkSuccess
 	} else {
@@ -906,15 +1021,117 @@ func (bot *Bot) linkOrUnlinkAndRespond(dgs *GameState, userID, testValue string,
 	}
 }
 
+// toggleSpectator starts or stops the user spectating. Users who stop spectating without being linked aren't managed
+// by the bot anymore (unless spectators are muted), so they're unmuted on the way out
+func (bot *Bot) toggleSpectator(dgs *GameState, g *discordgo.Guild, userID string, sett *settings.GuildSettings) command.SpectateStatus {
+	userData, err := dgs.GetUser(userID)
+	if err != nil {
+		var added bool
+		userData, added = dgs.checkCacheAndAddUser(g, bot.PrimarySession, userID)
+		if !added {
+			return command.SpectateNoUser
+		}
+	}
+	if !userData.IsSpectator() {
+		userData.SetSpectator(true)
+		dgs.UpdateUserData(userID, userData)
+		return command.SpectateOn
+	}
+
+	userData.SetSpectator(false)
+	if _, _, _, managed := dgs.voiceState(sett, userData, true); !managed && (userData.ShouldBeMute || userData.ShouldBeDeaf) {
+		if dgs.Running {
+			err = bot.applyToSingle(dgs, userID, false, false)
+			if err != nil {
+				bot.matchLogger(dgs).Error("Error unmuting user who stopped spectating", err, "user_id", userID)
+			}
+		}
+		userData.SetShouldBeMuteDeaf(false, false)
+	}
+	dgs.UpdateUserData(userID, userData)
+	return command.SpectateOff
+}
+
+func (bot *Bot) linkAliasAndRespond(gsr GameStateRequest, userID, alias string, remove bool, sett *settings.GuildSettings) *discordgo.InteractionResponse {
+	l := logger.With(logging.GuildIDKey, gsr.GuildID, "user_id", userID, "alias", alias)
+	if remove {
+		found, err := bot.PostgresInterface.DeleteUserAlias(gsr.GuildID, userID, alias)
+		if err != nil {
+			l.Error("Error deleting alias", err)
+			return command.PrivateErrorResponse(command.Link.Name, err, sett)
+		}
+		if !found {
+			return command.LinkAliasResponse(command.AliasNotFound, userID, alias, nil, sett)
+		}
+		aliases, err := bot.PostgresInterface.GetUserAliases(gsr.GuildID, userID)
+		if err != nil {
+			l.Error("Error fetching the user's aliases", err)
+		}
+		return command.LinkAliasResponse(command.AliasRemoved, userID, alias, aliases, sett)
+	}
+
+	status := command.AliasAdded
+	err := bot.PostgresInterface.AddUserAlias(gsr.GuildID, userID, alias)
+	switch {
+	case errors.Is(err, storage.ErrInvalidAlias):
+		return command.LinkAliasResponse(command.AliasInvalid, userID, alias, nil, sett)
+	case errors.Is(err, storage.ErrAliasTaken):
+		return command.LinkAliasResponse(command.AliasTaken, userID, alias, nil, sett)
+	case errors.Is(err, storage.ErrTooManyAliases):
+		status = command.AliasTooMany
+	case err != nil:
+		l.Error("Error adding alias", err)
+		return command.PrivateErrorResponse(command.Link.Name, err, sett)
+	default:
+		bot.linkByAlias(gsr, userID, alias, sett)
+	}
+	aliases, err := bot.PostgresInterface.GetUserAliases(gsr.GuildID, userID)
+	if err != nil {
+		l.Error("Error fetching the user's aliases", err)
+	}
+	return command.LinkAliasResponse(status, userID, alias, aliases, sett)
+}
+
+// lockForInteraction locks the game state for an interaction, giving up in time to respond within Discord's limit
+func (bot *Bot) lockForInteraction(gsr GameStateRequest) (*redislock.Lock, *GameState, error) {
+	ctx, cancel := context.WithTimeout(ctx, InteractionLockTimeout)
+	defer cancel()
+	return bot.RedisInterface.GetDiscordGameStateAndLock(ctx, gsr)
+}
+
+// linkByAlias links the user to a player in the current game with the alias they just registered, so they don't have
+// to wait for the player to be updated to be linked
+func (bot *Bot) linkByAlias(gsr GameStateRequest, userID, alias string, sett *settings.GuildSettings) {
+	lock, dgs, err := bot.lockForInteraction(gsr)
+	if err != nil {
+		return
+	}
+	if v, ok := dgs.UserData[userID]; ok && v.GetPlayerName() == amongus.UnlinkedPlayerName && !v.IsSpectator() {
+		key := namematch.Normalize(alias)
+		for _, player := range dgs.GameData.PlayerData {
+			if namematch.Normalize(player.Name) == key && dgs.GetUserIDByPlayerName(player.Name) == "" {
+				v.Link(player)
+				dgs.UserData[userID] = v
+				bot.RedisInterface.SetDiscordGameState(dgs, lock)
+				bot.DispatchRefreshOrEdit(dgs, gsr, sett)
+				go bot.updateLinkSuggestions(gsr)
+				return
+			}
+		}
+	}
+	// release the lock
+	bot.RedisInterface.SetDiscordGameState(nil, lock)
+}
+
 // deleteComponentInParentMessage deletes any components from parent messages.
 // this is required for safety. if the resetting process takes over 2 seconds,
 // since RESET/Cancel buttons remain forever once the button has been clicked.
 func (bot *Bot) deleteComponentInParentMessage(s *discordgo.Session, i *discordgo.InteractionCreate) {
 	me := discordgo.NewMessageEdit(i.ChannelID, i.Message.ID)
-	me.Components = []discordgo.MessageComponent{}
+	me.Components = &[]discordgo.MessageComponent{}
 	_, err := s.ChannelMessageEditComplex(me)
 	if err != nil {
-		log.Println("Error when attempting to edit complex message", err)
+		bot.interactionLogger(i).Error("Error removing the components from the interaction's message", err, "message_id", i.Message.ID)
 	}
 }
 
@@ -929,7 +1146,7 @@ func confirmationComponents(confirmedID string, canceledID string, sett *setting
 						ID:    "commands.stats.reset.button.proceed",
 						Other: "Confirm",
 					}),
-					Emoji: discordgo.ComponentEmoji{Name: ThumbsUp},
+					Emoji: &discordgo.ComponentEmoji{Name: ThumbsUp},
 				},
 				discordgo.Button{
 					CustomID: canceledID,
@@ -938,7 +1155,7 @@ func confirmationComponents(confirmedID string, canceledID string, sett *setting
 						ID:    "commands.stats.reset.button.cancel",
 						Other: "Cancel",
 					}),
-					Emoji: discordgo.ComponentEmoji{Name: X},
+					Emoji: &discordgo.ComponentEmoji{Name: X},
 				},
 			},
 		},
@@ -4,9 +4,9 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"github.com/automuteus/automuteus/v8/internal/logging"
 	"github.com/automuteus/automuteus/v8/pkg/settings"
 	"github.com/automuteus/automuteus/v8/pkg/storage"
-	"log"
 	"strconv"
 	"strings"
 
@@ -23,7 +23,7 @@ func (bot *Bot) UserStatsEmbed(userID, guildID string, sett *settings.GuildSetti
 	avatarURL := ""
 	mem, err := bot.PrimarySession.GuildMember(guildID, userID)
 	if err != nil {
-		log.Println(err)
+		logger.Error("Error fetching guild member for their stats", err, logging.GuildIDKey, guildID, "user_id", userID)
 	} else if mem.User != nil {
 		avatarURL = mem.User.AvatarURL("")
 	}
@@ -90,6 +90,25 @@ func (bot *Bot) UserStatsEmbed(userID, guildID string, sett *settings.GuildSetti
 				Inline: true,
 			})
 		}
+		roleRankings := bot.PostgresInterface.RoleRankingForPlayerOnServer(userID, guildID)
+		if len(roleRankings) > 0 {
+			buf := bytes.NewBuffer([]byte{})
+			for i := 0; i < len(roleRankings) && i < leaderBoardSize; i++ {
+				elem := roleRankings[i]
+				buf.WriteString(fmt.Sprintf("%s | %.0f%%", game.GameRole(elem.Mode), 100.0*float64(elem.Count)/float64(gamesPlayed)))
+				if i < len(roleRankings)-1 && i < leaderBoardSize-1 {
+					buf.WriteByte('\n')
+				}
+			}
+			fields = append(fields, &discordgo.MessageEmbedField{
+				Name: sett.LocalizeMessage(&i18n.Message{
+					ID:    "responses.userStatsEmbed.FavoriteRoles",
+					Other: "Favorite Roles",
+				}),
+				Value:  buf.String(),
+				Inline: true,
+			})
+		}
 		nameRankings := bot.PostgresInterface.NamesRankingForPlayerOnServer(userID, guildID)
 		if len(nameRankings) > 0 {
 			buf := bytes.NewBuffer([]byte{})
@@ -142,9 +161,9 @@ func (bot *Bot) UserStatsEmbed(userID, guildID string, sett *settings.GuildSetti
 			})
 		}
 
-		totalCrewmateGames := bot.PostgresInterface.NumGamesAsRoleOnServer(userID, guildID, int16(game.CrewmateRole))
+		totalCrewmateGames := bot.PostgresInterface.NumGamesAsRoleOnServer(userID, guildID, game.CrewTeam)
 		if totalCrewmateGames > 0 {
-			crewmateWins := bot.PostgresInterface.NumWinsAsRoleOnServer(userID, guildID, int16(game.CrewmateRole))
+			crewmateWins := bot.PostgresInterface.NumWinsAsRoleOnServer(userID, guildID, game.CrewTeam)
 			fields = append(fields, &discordgo.MessageEmbedField{
 				Name: sett.LocalizeMessage(&i18n.Message{
 					ID:    "responses.userStatsEmbed.CrewmateWins",
@@ -165,9 +184,9 @@ func (bot *Bot) UserStatsEmbed(userID, guildID string, sett *settings.GuildSetti
 				Inline: true,
 			})
 		}
-		totalImposterGames := bot.PostgresInterface.NumGamesAsRoleOnServer(userID, guildID, int16(game.ImposterRole))
+		totalImposterGames := bot.PostgresInterface.NumGamesAsRoleOnServer(userID, guildID, game.ImpostorTeam)
 		if totalImposterGames > 0 {
-			imposterWins := bot.PostgresInterface.NumWinsAsRoleOnServer(userID, guildID, int16(game.ImposterRole))
+			imposterWins := bot.PostgresInterface.NumWinsAsRoleOnServer(userID, guildID, game.ImpostorTeam)
 			fields = append(fields, &discordgo.MessageEmbedField{
 				Name: sett.LocalizeMessage(&i18n.Message{
 					ID:    "responses.userStatsEmbed.ImposterWins",
@@ -217,7 +236,7 @@ func (bot *Bot) UserStatsEmbed(userID, guildID string, sett *settings.GuildSetti
 			}
 		}
 
-		bestImpostorTeammateRankings := bot.PostgresInterface.BestTeammateByRole(userID, guildID, int16(game.ImposterRole), 2)
+		bestImpostorTeammateRankings := bot.PostgresInterface.BestTeammateByRole(userID, guildID, game.ImpostorTeam, 2)
 		if len(bestImpostorTeammateRankings) > 0 {
 			buf := bytes.NewBuffer([]byte{})
 			for i, v := range bestImpostorTeammateRankings {
@@ -243,7 +262,7 @@ func (bot *Bot) UserStatsEmbed(userID, guildID string, sett *settings.GuildSetti
 			})
 		}
 
-		worstImpostorTeammateRankings := bot.PostgresInterface.WorstTeammateByRole(userID, guildID, int16(game.ImposterRole), 2)
+		worstImpostorTeammateRankings := bot.PostgresInterface.WorstTeammateByRole(userID, guildID, game.ImpostorTeam, 2)
 		if len(worstImpostorTeammateRankings) > 0 {
 			buf := bytes.NewBuffer([]byte{})
 			for i, v := range worstImpostorTeammateRankings {
@@ -274,7 +293,7 @@ func (bot *Bot) UserStatsEmbed(userID, guildID string, sett *settings.GuildSetti
 			})
 		}
 
-		bestCrewmateTeammateRankings := bot.PostgresInterface.BestTeammateByRole(userID, guildID, int16(game.CrewmateRole), sett.GetLeaderboardMin())
+		bestCrewmateTeammateRankings := bot.PostgresInterface.BestTeammateByRole(userID, guildID, game.CrewTeam, sett.GetLeaderboardMin())
 		if len(bestCrewmateTeammateRankings) > 0 {
 			buf := bytes.NewBuffer([]byte{})
 			for i, v := range bestCrewmateTeammateRankings {
@@ -300,7 +319,7 @@ func (bot *Bot) UserStatsEmbed(userID, guildID string, sett *settings.GuildSetti
 			})
 		}
 
-		worstCrewmateTeammateRankings := bot.PostgresInterface.WorstTeammateByRole(userID, guildID, int16(game.CrewmateRole), sett.GetLeaderboardMin())
+		worstCrewmateTeammateRankings := bot.PostgresInterface.WorstTeammateByRole(userID, guildID, game.CrewTeam, sett.GetLeaderboardMin())
 		if len(bestCrewmateTeammateRankings) > 0 {
 			buf := bytes.NewBuffer([]byte{})
 			for i, v := range worstCrewmateTeammateRankings {
@@ -326,7 +345,7 @@ func (bot *Bot) UserStatsEmbed(userID, guildID string, sett *settings.GuildSetti
 			})
 		}
 
-		userExiledAsImpostor := bot.PostgresInterface.UserWinByActionAndRole(userID, guildID, strconv.Itoa(int(game.EXILED)), int16(game.ImposterRole))
+		userExiledAsImpostor := bot.PostgresInterface.UserWinByActionAndRole(userID, guildID, strconv.Itoa(int(game.EXILED)), game.ImpostorTeam)
 		if len(userExiledAsImpostor) > 0 {
 			fields = append(fields, &discordgo.MessageEmbedField{
 				Name:   "\u200b",
@@ -360,7 +379,7 @@ func (bot *Bot) UserStatsEmbed(userID, guildID string, sett *settings.GuildSetti
 			})
 		}
 
-		userExiledAsCrewmate := bot.PostgresInterface.UserWinByActionAndRole(userID, guildID, strconv.Itoa(int(game.EXILED)), int16(game.CrewmateRole))
+		userExiledAsCrewmate := bot.PostgresInterface.UserWinByActionAndRole(userID, guildID, strconv.Itoa(int(game.EXILED)), game.CrewTeam)
 		if len(userExiledAsImpostor) > 0 {
 			buf := bytes.NewBuffer([]byte{})
 			for i, v := range userExiledAsCrewmate {
@@ -389,7 +408,7 @@ func (bot *Bot) UserStatsEmbed(userID, guildID string, sett *settings.GuildSetti
 			})
 		}
 
-		userKilledAsCrewmate := bot.PostgresInterface.UserWinByActionAndRole(userID, guildID, strconv.Itoa(int(game.DIED)), int16(game.CrewmateRole))
+		userKilledAsCrewmate := bot.PostgresInterface.UserWinByActionAndRole(userID, guildID, strconv.Itoa(int(game.DIED)), game.CrewTeam)
 		if len(userKilledAsCrewmate) > 0 {
 			buf := bytes.NewBuffer([]byte{})
 			for i, v := range userKilledAsCrewmate {
@@ -418,6 +437,43 @@ func (bot *Bot) UserStatsEmbed(userID, guildID string, sett *settings.GuildSetti
 			})
 		}
 
+		meetingStats, err := bot.PostgresInterface.UserMeetingStatsOnServer(userID, guildID)
+		if err != nil {
+			logger.Error("Error fetching user's meeting stats", err, logging.GuildIDKey, guildID, "user_id", userID)
+		} else if meetingStats.MeetingsCalled > 0 || meetingStats.Votes > 0 {
+			fields = append(fields, &discordgo.MessageEmbedField{
+				Name: sett.LocalizeMessage(&i18n.Message{
+					ID:    "responses.userStatsEmbed.Meetings",
+					Other: "Meetings",
+				}),
+				Value: sett.LocalizeMessage(&i18n.Message{
+					ID:    "responses.userStatsEmbed.MeetingsValue",
+					Other: "{{.Called}} Called ({{.Reported}} Reports)\n{{.VotedOut}} Voted Out as Crewmate",
+				}, map[string]interface{}{
+					"Called":   meetingStats.MeetingsCalled,
+					"Reported": meetingStats.BodiesReported,
+					"VotedOut": meetingStats.VotedOutAsCrew,
+				}),
+				Inline: true,
+			})
+			fields = append(fields, &discordgo.MessageEmbedField{
+				Name: sett.LocalizeMessage(&i18n.Message{
+					ID:    "responses.userStatsEmbed.Votes",
+					Other: "Votes",
+				}),
+				Value: sett.LocalizeMessage(&i18n.Message{
+					ID:    "responses.userStatsEmbed.VotesValue",
+					Other: "{{.Correct}}/{{.CrewVotes}} Correct as Crewmate ({{.CorrectRate}})\n{{.SkipRate}} Skipped",
+				}, map[string]interface{}{
+					"Correct":     meetingStats.CorrectVotes,
+					"CrewVotes":   meetingStats.CrewVotes,
+					"CorrectRate": fmt.Sprintf("%.0f%%", meetingStats.CorrectVoteRate()),
+					"SkipRate":    fmt.Sprintf("%.0f%%", meetingStats.SkipRate()),
+				}),
+				Inline: true,
+			})
+		}
+
 		userFirstTimeKilled := bot.PostgresInterface.UserFrequentFirstTarget(userID, guildID, strconv.Itoa(int(game.DIED)), sett.GetLeaderboardSize())
 		if len(userFirstTimeKilled) > 0 {
 			fields = append(fields, &discordgo.MessageEmbedField{
@@ -502,14 +558,14 @@ func (bot *Bot) CheckOrFetchCachedUserData(userID, guildID string) (string, stri
 	if info == "" {
 		mem, err := bot.PrimarySession.GuildMember(guildID, userID)
 		if err != nil {
-			log.Println(err)
+			logger.Error("Error fetching guild member", err, logging.GuildIDKey, guildID, "user_id", userID)
 			return "", "", ""
 		}
 		if mem.User != nil {
 			err := rediskey.SetCachedUserInfo(context.Background(), bot.RedisInterface.client, userID, guildID,
 				fmt.Sprintf("%s:%s:%s", mem.User.Username, mem.Nick, mem.User.Discriminator))
 			if err != nil {
-				log.Println(err)
+				logger.Error("Error caching user info", err, logging.GuildIDKey, guildID, "user_id", userID)
 			}
 			return mem.User.Username, mem.Nick, mem.User.Discriminator
 		}
@@ -535,20 +591,22 @@ func (bot *Bot) MentionWithCacheData(userID, guildID string, sett *settings.Guil
 	return "<@" + userID + ">"
 }
 
-func (bot *Bot) GuildStatsEmbed(guildID string, sett *settings.GuildSettings, isPrem bool) *discordgo.MessageEmbed {
+// GuildStatsEmbed shows the guild's stats. The games played and winrates only count games played with the ruleset, but
+// the leaderboards count every game
+func (bot *Bot) GuildStatsEmbed(guildID string, sett *settings.GuildSettings, isPrem bool, ruleset storage.Ruleset) *discordgo.MessageEmbed {
 	gname := ""
 	avatarURL := ""
 	g, err := bot.PrimarySession.Guild(guildID)
 
 	if err != nil {
-		log.Println(err)
+		logger.Error("Error fetching guild for its stats", err, logging.GuildIDKey, guildID)
 		gname = guildID
 	} else {
 		gname = g.Name
 		avatarURL = g.IconURL("256")
 	}
 
-	gamesPlayed := bot.PostgresInterface.NumGamesPlayedOnGuild(guildID)
+	gamesPlayed := bot.PostgresInterface.NumGamesPlayedOnGuild(guildID, ruleset)
 
 	fields := make([]*discordgo.MessageEmbedField, 1)
 	fields[0] = &discordgo.MessageEmbedField{
@@ -561,8 +619,8 @@ func (bot *Bot) GuildStatsEmbed(guildID string, sett *settings.GuildSettings, is
 	}
 
 	if gamesPlayed > 0 {
-		crewmateWins := bot.PostgresInterface.NumGamesWonAsRoleOnServer(guildID, game.CrewmateRole)
-		imposterWins := bot.PostgresInterface.NumGamesWonAsRoleOnServer(guildID, game.ImposterRole)
+		crewmateWins := bot.PostgresInterface.NumGamesWonAsRoleOnServer(guildID, game.CrewTeam, ruleset)
+		imposterWins := bot.PostgresInterface.NumGamesWonAsRoleOnServer(guildID, game.ImpostorTeam, ruleset)
 
 		fields = append(fields, &discordgo.MessageEmbedField{
 			Name: sett.LocalizeMessage(&i18n.Message{
@@ -580,6 +638,18 @@ func (bot *Bot) GuildStatsEmbed(guildID string, sett *settings.GuildSettings, is
 			Value:  fmt.Sprintf("%.0f%%", 100.0*(float64(imposterWins)/float64(gamesPlayed))),
 			Inline: true,
 		})
+
+		hideAndSeekGames := bot.PostgresInterface.NumGamesPlayedOnGuildInMode(guildID, game.HideAndSeekMode, ruleset)
+		if hideAndSeekGames > 0 {
+			fields = append(fields, &discordgo.MessageEmbedField{
+				Name: sett.LocalizeMessage(&i18n.Message{
+					ID:    "responses.guildStatsEmbed.HideAndSeekGames",
+					Other: "Hide and Seek Games",
+				}),
+				Value:  fmt.Sprintf("%d", hideAndSeekGames),
+				Inline: true,
+			})
+		}
 	}
 
 	extraDesc := sett.LocalizeMessage(&i18n.Message{
@@ -651,7 +721,7 @@ func (bot *Bot) GuildStatsEmbed(guildID string, sett *settings.GuildSettings, is
 				Inline: false,
 			})
 
-			crewmateGameRankings := bot.PostgresInterface.TotalWinRankingForServerByRole(gid, 0)
+			crewmateGameRankings := bot.PostgresInterface.TotalWinRankingForServerByRole(gid, game.CrewTeam)
 			buf = bytes.NewBuffer([]byte{})
 			count = 0
 			for i := 0; i < len(crewmateGameRankings) && count < leaderboardSize; i++ {
@@ -678,7 +748,7 @@ func (bot *Bot) GuildStatsEmbed(guildID string, sett *settings.GuildSettings, is
 				})
 			}
 
-			imposterGameRankings := bot.PostgresInterface.TotalWinRankingForServerByRole(gid, 1)
+			imposterGameRankings := bot.PostgresInterface.TotalWinRankingForServerByRole(gid, game.ImpostorTeam)
 			buf = bytes.NewBuffer([]byte{})
 			count = 0
 			for i := 0; i < len(imposterGameRankings) && count < leaderboardSize; i++ {
@@ -711,7 +781,7 @@ func (bot *Bot) GuildStatsEmbed(guildID string, sett *settings.GuildSettings, is
 				Inline: false,
 			})
 
-			bestImpostorTeammateForServerRankings := bot.PostgresInterface.BestTeammateForServerByRole(guildID, int16(game.ImposterRole), 2)
+			bestImpostorTeammateForServerRankings := bot.PostgresInterface.BestTeammateForServerByRole(guildID, game.ImpostorTeam, 2)
 			if len(bestImpostorTeammateForServerRankings) > 0 {
 				buf := bytes.NewBuffer([]byte{})
 				for i, v := range bestImpostorTeammateForServerRankings {
@@ -733,7 +803,7 @@ func (bot *Bot) GuildStatsEmbed(guildID string, sett *settings.GuildSettings, is
 				})
 			}
 
-			worstImpostorTeammateServerRankings := bot.PostgresInterface.WorstTeammateForServerByRole(guildID, int16(game.ImposterRole), 2)
+			worstImpostorTeammateServerRankings := bot.PostgresInterface.WorstTeammateForServerByRole(guildID, game.ImpostorTeam, 2)
 			if len(worstImpostorTeammateServerRankings) > 0 {
 				buf := bytes.NewBuffer([]byte{})
 				for i, v := range worstImpostorTeammateServerRankings {
@@ -760,7 +830,7 @@ func (bot *Bot) GuildStatsEmbed(guildID string, sett *settings.GuildSettings, is
 				})
 			}
 
-			bestCrewmateTeammateServerRankings := bot.PostgresInterface.BestTeammateForServerByRole(guildID, int16(game.CrewmateRole), sett.GetLeaderboardMin())
+			bestCrewmateTeammateServerRankings := bot.PostgresInterface.BestTeammateForServerByRole(guildID, game.CrewTeam, sett.GetLeaderboardMin())
 			if len(bestCrewmateTeammateServerRankings) > 0 {
 				buf := bytes.NewBuffer([]byte{})
 				for i, v := range bestCrewmateTeammateServerRankings {
@@ -782,7 +852,7 @@ func (bot *Bot) GuildStatsEmbed(guildID string, sett *settings.GuildSettings, is
 				})
 			}
 
-			worstCrewmateTeammateRankings := bot.PostgresInterface.WorstTeammateForServerByRole(guildID, int16(game.CrewmateRole), sett.GetLeaderboardMin())
+			worstCrewmateTeammateRankings := bot.PostgresInterface.WorstTeammateForServerByRole(guildID, game.CrewTeam, sett.GetLeaderboardMin())
 			if len(worstCrewmateTeammateRankings) > 0 {
 				buf := bytes.NewBuffer([]byte{})
 				for i, v := range worstCrewmateTeammateRankings {
@@ -855,6 +925,23 @@ func (bot *Bot) GuildStatsEmbed(guildID string, sett *settings.GuildSettings, is
 					Inline: true,
 				})
 			}
+
+			deadliestImpostors := bot.PostgresInterface.DeadliestImpostorsForServer(guildID, leaderboardSize)
+			if len(deadliestImpostors) > 0 {
+				buf := bytes.NewBuffer([]byte{})
+				for _, v := range deadliestImpostors {
+					buf.WriteString(fmt.Sprintf("%.1f | %d/%d | %s\n", v.KillRate, v.TotalKills, v.Count,
+						bot.MentionWithCacheData(strconv.FormatUint(v.UserID, 10), guildID, sett)))
+				}
+				fields = append(fields, &discordgo.MessageEmbedField{
+					Name: sett.LocalizeMessage(&i18n.Message{
+						ID:    "responses.guildStatsEmbed.DeadliestImpostors",
+						Other: "Deadliest Impostors (Kills/Game)",
+					}),
+					Value:  buf.String(),
+					Inline: true,
+				})
+			}
 		}
 	}
 
@@ -872,7 +959,7 @@ func (bot *Bot) GuildStatsEmbed(guildID string, sett *settings.GuildSettings, is
 			Other: "Guild stats for {{.GuildName}}",
 		}, map[string]interface{}{
 			"GuildName": gname,
-		}) + "\n\n" + extraDesc,
+		}) + rulesetDescription(ruleset, sett) + "\n\n" + extraDesc,
 		Timestamp: "",
 		Color:     3066993, // GREEN
 		Image:     nil,
@@ -912,16 +999,17 @@ func (bot *Bot) GameStatsEmbed(guildID, matchID, connectCode string, isPrem bool
 		}
 	}
 
+	l := logger.With(logging.GuildIDKey, guildID, logging.ConnectCodeKey, connectCode, logging.MatchIDKey, matchID)
 	gameData, err := bot.PostgresInterface.GetGame(guildID, connectCode, matchID)
 	if err != nil {
-		log.Fatal(err)
+		l.Error("Error fetching game for its stats", err)
 	}
 
 	var events []*storage.PostgresGameEvent
 	if gameData != nil {
 		events, err = bot.PostgresInterface.GetGameEvents(matchID)
 		if err != nil {
-			log.Fatal(err)
+			l.Error("Error fetching game events for its stats", err)
 		}
 	}
 
@@ -947,3 +1035,34 @@ func TrimEmbedFields(fields []*discordgo.MessageEmbedField) []*discordgo.Message
 
 	return fields[:i]
 }
+
+func rulesetDescription(ruleset storage.Ruleset, sett *settings.GuildSettings) string {
+	desc := ""
+	if ruleset.NumImpostors > 0 {
+		desc += "\n" + sett.LocalizeMessage(&i18n.Message{
+			ID:    "responses.guildStatsEmbed.RulesetImpostors",
+			Other: "Only counting games with {{.Impostors}} impostor(s)",
+		}, map[string]interface{}{
+			"Impostors": ruleset.NumImpostors,
+		})
+	}
+	if ruleset.ConfirmEjects != nil {
+		confirmEjects := sett.LocalizeMessage(&i18n.Message{
+			ID:    "responses.lobbyOptionsEmbedField.Off",
+			Other: "Off",
+		})
+		if *ruleset.ConfirmEjects {
+			confirmEjects = sett.LocalizeMessage(&i18n.Message{
+				ID:    "responses.lobbyOptionsEmbedField.On",
+				Other: "On",
+			})
+		}
+		desc += "\n" + sett.LocalizeMessage(&i18n.Message{
+			ID:    "responses.guildStatsEmbed.RulesetConfirmEjects",
+			Other: "Only counting games with Confirm Ejects {{.ConfirmEjects}}",
+		}, map[string]interface{}{
+			"ConfirmEjects": confirmEjects,
+		})
+	}
+	return desc
+}
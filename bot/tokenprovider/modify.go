@@ -3,11 +3,15 @@ package tokenprovider
 import (
 	"context"
 	"encoding/json"
+	"github.com/automuteus/automuteus/v8/internal/logging"
 	"github.com/automuteus/automuteus/v8/internal/server"
+	"github.com/automuteus/automuteus/v8/internal/tracing"
 	"github.com/automuteus/automuteus/v8/pkg/rediskey"
 	"github.com/automuteus/automuteus/v8/pkg/task"
 	"github.com/go-redis/redis/v8"
-	"log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"time"
 )
 
 func RecordDiscordRequestsByCounts(client *redis.Client, counts task.MuteDeafenSuccessCounts) {
@@ -17,36 +21,80 @@ func RecordDiscordRequestsByCounts(client *redis.Client, counts task.MuteDeafenS
 	server.RecordDiscordRequests(client, server.InvalidRequest, counts.RateLimit)
 }
 
-func (tokenProvider *TokenProvider) attemptOnSecondaryTokens(guildID, userID string, tokenSubset map[string]struct{}, request task.UserModify) string {
+// VoiceOpQueueSize is how many voice operations can be waiting to be recorded. Any more are dropped, instead of
+// making mutes wait on Redis or Postgres
+const VoiceOpQueueSize = 1000
+
+type guildVoiceOp struct {
+	guildID string
+	op      task.VoiceOp
+}
+
+// recordVoiceOp queues the operation to be added to the guild's voice log, and to the voice op sink if there is one
+func (tokenProvider *TokenProvider) recordVoiceOp(guildID string, op task.VoiceOp) {
+	server.RecordModifyUsersLatency(op.Path, time.Duration(op.LatencyMs)*time.Millisecond)
+	select {
+	case tokenProvider.voiceOps <- guildVoiceOp{guildID: guildID, op: op}:
+	default:
+		logger.Warn("Too many voice ops waiting to be recorded; dropping one", logging.GuildIDKey, guildID, logging.ConnectCodeKey, op.ConnectCode)
+	}
+}
+
+// saveVoiceOps records the queued voice operations, one at a time
+func (tokenProvider *TokenProvider) saveVoiceOps() {
+	for v := range tokenProvider.voiceOps {
+		tokenProvider.saveVoiceOp(v.guildID, v.op)
+	}
+}
+
+func (tokenProvider *TokenProvider) saveVoiceOp(guildID string, op task.VoiceOp) {
+	err := task.RecordVoiceOp(context.Background(), tokenProvider.client, guildID, op)
+	if err != nil {
+		logger.Error("Error recording voice op", err, logging.GuildIDKey, guildID, logging.ConnectCodeKey, op.ConnectCode)
+	}
+	if tokenProvider.voiceOpSink != nil {
+		err = tokenProvider.voiceOpSink(guildID, op)
+		if err != nil {
+			logger.Error("Error recording voice op with the sink", err, logging.GuildIDKey, guildID, logging.ConnectCodeKey, op.ConnectCode)
+		}
+	}
+}
+
+func (tokenProvider *TokenProvider) attemptOnSecondaryTokens(ctx context.Context, guildID, userID string, tokenSubset map[string]struct{}, request task.UserModify) string {
+	l := logger.With(logging.GuildIDKey, guildID, "user_id", userID)
 	if len(tokenProvider.activeSessions) > 0 {
 		sess, hToken := tokenProvider.getSession(guildID, tokenSubset)
 		if sess != nil {
-			err := task.ApplyMuteDeaf(sess, guildID, userID, request.Mute, request.Deaf)
+			_, span := tracing.Start(ctx, "ApplyUserModify", trace.WithAttributes(tracing.PathKey.String(task.VoicePathWorker)))
+			err := task.ApplyUserModify(sess, guildID, userID, request)
+			tracing.End(span, err)
 			if err != nil {
-				log.Println("Failed to apply mute to player with error:")
-				log.Println(err)
+				l.Error("Failed to apply mute to player with secondary bot", err, "token", hToken)
 
 				// don't attempt this token for this guild for another 5 minutes
 				err = tokenProvider.BlacklistTokenForDuration(guildID, hToken, UnresponsiveCaptureBlacklistDuration)
 				if err != nil {
-					log.Println(err)
+					l.Error("Error blacklisting secondary bot", err, "token", hToken)
 				}
 			} else {
-				log.Printf("Successfully applied mute=%v, deaf=%v to User %d using secondary bot: %s\n", request.Mute, request.Deaf, request.UserID, hToken)
+				l.Debug("Successfully applied voice state using secondary bot", "mute", request.Mute, "deaf", request.Deaf, "channel_id", request.ChannelID, "token", hToken)
 				return hToken
 			}
 		} else {
-			log.Println("No secondary bot tokens found. Trying other methods")
+			l.Debug("No secondary bot tokens found. Trying other methods")
 		}
 	} else {
-		log.Println("Guild has no access to secondary bot tokens; skipping")
+		l.Debug("Guild has no access to secondary bot tokens; skipping")
 	}
 	return ""
 }
 
-func (tokenProvider *TokenProvider) attemptOnCaptureBot(guildID, connectCode string, gid uint64, request task.UserModify) bool {
+func (tokenProvider *TokenProvider) attemptOnCaptureBot(ctx context.Context, guildID, connectCode string, gid uint64, request task.UserModify) bool {
+	l := logger.With(logging.GuildIDKey, guildID, logging.ConnectCodeKey, connectCode, "user_id", request.UserID)
 	// this is cheeky, but use the connect code as part of the lock; don't issue too many requests on the capture client w/ this code
 	if tokenProvider.IncrAndTestGuildTokenComboLock(guildID, connectCode) {
+		_, span := tracing.Start(ctx, "captureTask", trace.WithAttributes(tracing.PathKey.String(task.VoicePathCapture)))
+		defer span.End()
 		// if the secondary token didn't work, then next we try the client-side capture request
 		taskObj := task.NewModifyTask(gid, request.UserID, task.PatchParams{
 			Deaf: request.Deaf,
@@ -54,7 +102,7 @@ func (tokenProvider *TokenProvider) attemptOnCaptureBot(guildID, connectCode str
 		})
 		jBytes, err := json.Marshal(taskObj)
 		if err != nil {
-			log.Println(err)
+			l.Error("Error encoding capture task", err)
 			return false
 		}
 		acked := make(chan bool)
@@ -62,24 +110,24 @@ func (tokenProvider *TokenProvider) attemptOnCaptureBot(guildID, connectCode str
 		pubsub := tokenProvider.client.Subscribe(context.Background(), rediskey.CompleteTask(taskObj.TaskID))
 		err = tokenProvider.client.Publish(context.Background(), rediskey.TasksList(connectCode), jBytes).Err()
 		if err != nil {
-			log.Println("Error in publishing task to " + rediskey.TasksList(connectCode))
-			log.Println(err)
+			l.Error("Error in publishing capture task", err)
 		} else {
 			go tokenProvider.waitForAck(pubsub, acked)
 			res := <-acked
+			span.SetAttributes(attribute.Bool("automuteus.acked", res))
 			if res {
-				log.Println("Successful mute/deafen using client capture bot!")
+				l.Debug("Successful mute/deafen using client capture bot")
 
 				// hooray! we did the mute with a client token!
 				return true
 			}
 			err = tokenProvider.BlacklistTokenForDuration(guildID, connectCode, UnresponsiveCaptureBlacklistDuration)
 			if err == nil {
-				log.Printf("No ack from capture clients; blacklisting capture client for gamecode \"%s\" for %s\n", connectCode, UnresponsiveCaptureBlacklistDuration.String())
+				l.Warn("No ack from capture clients; blacklisting capture client", "duration", UnresponsiveCaptureBlacklistDuration.String())
 			}
 		}
 	} else {
-		log.Println("Capture client is probably rate-limited. Deferring to main bot instead")
+		l.Debug("Capture client is probably rate-limited. Deferring to main bot instead")
 	}
 	return false
 }
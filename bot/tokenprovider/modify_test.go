@@ -0,0 +1,53 @@
+package tokenprovider
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/automuteus/automuteus/v8/pkg/task"
+	"github.com/go-redis/redis/v8"
+	"testing"
+	"time"
+)
+
+func TestRecordVoiceOp(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	tp := NewTokenProvider(client, nil, 0, 0)
+
+	// a sink stuck on a slow database
+	release := make(chan struct{})
+	sunk := make(chan task.VoiceOp, VoiceOpQueueSize+10)
+	tp.SetVoiceOpSink(func(_ string, op task.VoiceOp) error {
+		<-release
+		sunk <- op
+		return nil
+	})
+
+	recorded := make(chan struct{})
+	go func() {
+		for i := 0; i < VoiceOpQueueSize+10; i++ {
+			tp.recordVoiceOp("1", task.VoiceOp{UserID: uint64(i)})
+		}
+		close(recorded)
+	}()
+	select {
+	case <-recorded:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected recording voice ops not to wait for them to be saved")
+	}
+
+	close(release)
+	select {
+	case op := <-sunk:
+		if op.UserID != 0 {
+			t.Errorf("Expected the ops to be saved in order, got user %d first", op.UserID)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the queued ops to be saved")
+	}
+	ops, err := task.GetVoiceLog(context.Background(), client, "1", 0)
+	if err != nil || len(ops) == 0 {
+		t.Errorf("Expected the ops to be in the guild's voice log, got %v, %v", ops, err)
+	}
+}
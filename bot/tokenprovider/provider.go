@@ -4,6 +4,9 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"github.com/automuteus/automuteus/v8/internal/logging"
+	"github.com/automuteus/automuteus/v8/internal/server"
+	"github.com/automuteus/automuteus/v8/internal/tracing"
 	"github.com/automuteus/automuteus/v8/pkg/premium"
 	"github.com/automuteus/automuteus/v8/pkg/rediskey"
 	"github.com/automuteus/automuteus/v8/pkg/task"
@@ -11,8 +14,9 @@ import (
 	"github.com/bsm/redislock"
 	"github.com/bwmarrin/discordgo"
 	"github.com/go-redis/redis/v8"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/exp/constraints"
-	"log"
 	"strconv"
 	"sync"
 	"time"
@@ -27,6 +31,8 @@ var PremiumBotConstraints = map[premium.Tier]int{
 	5: 100, // Selfhost; 100 bots(!)
 }
 
+var logger = logging.For("tokenprovider")
+
 type TokenProvider struct {
 	client         *redis.Client
 	primarySession *discordgo.Session
@@ -36,17 +42,25 @@ type TokenProvider struct {
 	maxRequests5Seconds int64
 	sessionLock         sync.RWMutex
 	taskTimeoutMs       time.Duration
+
+	// optionally also records voice operations somewhere longer-lived than the voice log (like Postgres)
+	voiceOpSink func(guildID string, op task.VoiceOp) error
+	// voice operations waiting to be recorded, so recording them never holds up a mute
+	voiceOps chan guildVoiceOp
 }
 
 func NewTokenProvider(client *redis.Client, sess *discordgo.Session, taskTimeout time.Duration, maxReq int64) *TokenProvider {
-	return &TokenProvider{
+	tp := &TokenProvider{
 		client:              client,
 		primarySession:      sess,
 		activeSessions:      make(map[string]*discordgo.Session),
 		maxRequests5Seconds: maxReq,
 		sessionLock:         sync.RWMutex{},
 		taskTimeoutMs:       taskTimeout,
+		voiceOps:            make(chan guildVoiceOp, VoiceOpQueueSize),
 	}
+	go tp.saveVoiceOps()
+	return tp
 }
 
 func (tp *TokenProvider) Init(client *redis.Client, sess *discordgo.Session) {
@@ -54,8 +68,13 @@ func (tp *TokenProvider) Init(client *redis.Client, sess *discordgo.Session) {
 	tp.primarySession = sess
 }
 
+// SetVoiceOpSink records every voice operation with the sink too, on top of the guild's voice log in Redis
+func (tp *TokenProvider) SetVoiceOpSink(sink func(guildID string, op task.VoiceOp) error) {
+	tp.voiceOpSink = sink
+}
+
 //func rateLimitEventCallback(sess *discordgo.Session, rl *discordgo.RateLimit) {
-//	log.Println(rl.Message)
+//	logger.Warn("Rate limited by Discord", "message", rl.Message)
 //}
 
 func (tokenProvider *TokenProvider) PopulateAndStartSessions(tokens []string) {
@@ -74,24 +93,40 @@ func (tokenProvider *TokenProvider) openAndStartSessionWithToken(botToken string
 		token.LockForToken(tokenProvider.client, botToken)
 		sess, err := discordgo.New("Bot " + botToken)
 		if err != nil {
-			log.Println(err)
+			logger.Error("Error creating worker bot session", err, "token", k)
 			return false
 		}
 		sess.Identify.Intents = discordgo.MakeIntent(discordgo.IntentsGuilds)
 		err = sess.Open()
 		if err != nil {
-			log.Println(err)
+			logger.Error("Error opening worker bot session", err, "token", k)
 			return false
 		}
 		// associates the guilds with this token to be used for requests
 		sess.AddHandler(tokenProvider.newGuild)
-		log.Println("Opened session on startup for " + k)
+		logger.Info("Opened worker bot session", "token", k)
 		tokenProvider.activeSessions[k] = sess
+		server.WorkerSessions.Set(float64(len(tokenProvider.activeSessions)))
 		return true
 	}
 	return false
 }
 
+// SessionStates reports whether each worker session's websocket is connected, by the first characters of its hashed
+// token
+func (tokenProvider *TokenProvider) SessionStates() map[string]bool {
+	tokenProvider.sessionLock.RLock()
+	defer tokenProvider.sessionLock.RUnlock()
+
+	states := make(map[string]bool, len(tokenProvider.activeSessions))
+	for hToken, sess := range tokenProvider.activeSessions {
+		sess.RLock()
+		states[hToken[:8]] = sess.DataReady
+		sess.RUnlock()
+	}
+	return states
+}
+
 func (tokenProvider *TokenProvider) getSession(guildID string, hTokenSubset map[string]struct{}) (*discordgo.Session, string) {
 	tokenProvider.sessionLock.RLock()
 	defer tokenProvider.sessionLock.RUnlock()
@@ -103,7 +138,7 @@ func (tokenProvider *TokenProvider) getSession(guildID string, hTokenSubset map[
 			if tokenProvider.IncrAndTestGuildTokenComboLock(guildID, hToken) {
 				return sess, hToken
 			} else {
-				log.Println("Secondary token is potentially rate-limited. Skipping")
+				logger.Debug("Secondary token is potentially rate-limited. Skipping", logging.GuildIDKey, guildID, "token", hToken)
 			}
 		}
 	}
@@ -122,10 +157,10 @@ func mapHasEntry[T constraints.Ordered, K any](dict map[T]K, key T) bool {
 func (tokenProvider *TokenProvider) IncrAndTestGuildTokenComboLock(guildID, hashToken string) bool {
 	i, err := tokenProvider.client.Incr(context.Background(), rediskey.GuildTokenLock(guildID, hashToken)).Result()
 	if err != nil {
-		log.Println(err)
+		logger.Error("Error counting the token's requests", err, logging.GuildIDKey, guildID, "token", hashToken)
 	}
 	usable := i < tokenProvider.maxRequests5Seconds
-	log.Printf("Token/capture %s on guild %s is at count %d. Using?: %v", hashToken, guildID, i, usable)
+	logger.Debug("Token/capture request count", logging.GuildIDKey, guildID, "token", hashToken, "count", i, "usable", usable)
 	if !usable {
 		return false
 	}
@@ -133,7 +168,7 @@ func (tokenProvider *TokenProvider) IncrAndTestGuildTokenComboLock(guildID, hash
 	// set the expiry only if the mute/deafen was successful, because we want to preserve any existing blacklist expiries
 	err = tokenProvider.client.Expire(context.Background(), rediskey.GuildTokenLock(guildID, hashToken), time.Second*5).Err()
 	if err != nil {
-		log.Println(err)
+		logger.Error("Error expiring the token's request count", err, logging.GuildIDKey, guildID, "token", hashToken)
 	}
 
 	return true
@@ -152,10 +187,15 @@ const DefaultMaxWorkers = 8
 
 var UnresponsiveCaptureBlacklistDuration = time.Minute * time.Duration(5)
 
-func (tokenProvider *TokenProvider) ModifyUsers(guildID, connectCode string, request task.UserModifyRequest, voicelock *redislock.Lock) error {
+func (tokenProvider *TokenProvider) ModifyUsers(ctx context.Context, guildID, connectCode string, request task.UserModifyRequest, voicelock *redislock.Lock) (err error) {
 	if voicelock != nil {
 		defer voicelock.Release(context.Background())
 	}
+	ctx, span := tracing.Start(ctx, "ModifyUsers", tracing.GameAttributes(guildID, connectCode),
+		trace.WithAttributes(tracing.PhaseKey.String(request.Phase), attribute.Int("automuteus.users", len(request.Users))))
+	defer func() {
+		tracing.End(span, err)
+	}()
 
 	gid, gerr := strconv.ParseUint(guildID, 10, 64)
 	if gerr != nil {
@@ -181,19 +221,31 @@ func (tokenProvider *TokenProvider) ModifyUsers(guildID, connectCode string, req
 	for i := 0; i < DefaultMaxWorkers; i++ {
 		go func() {
 			for req := range tasksChannel {
+				start := time.Now()
+				userCtx, userSpan := tracing.Start(ctx, "modifyUser", trace.WithAttributes(tracing.UserIDKey.Int64(int64(req.UserID))))
+				op := task.VoiceOp{
+					ConnectCode: connectCode,
+					UserID:      req.UserID,
+					Mute:        req.Mute,
+					Deaf:        req.Deaf,
+					ChannelID:   req.ChannelID,
+					Phase:       request.Phase,
+				}
 				userIDStr := strconv.FormatUint(req.UserID, 10)
 				hToken := ""
 				if limit > 0 {
 					tokenLock.RLock()
 					if len(uniqueTokensUsed) >= limit {
-						hToken = tokenProvider.attemptOnSecondaryTokens(guildID, userIDStr, uniqueTokensUsed, req)
+						hToken = tokenProvider.attemptOnSecondaryTokens(userCtx, guildID, userIDStr, uniqueTokensUsed, req)
 						tokenLock.RUnlock()
 					} else {
 						tokenLock.RUnlock()
-						hToken = tokenProvider.attemptOnSecondaryTokens(guildID, userIDStr, nil, req)
+						hToken = tokenProvider.attemptOnSecondaryTokens(userCtx, guildID, userIDStr, nil, req)
 					}
 				}
 				if hToken != "" {
+					op.Path = task.VoicePathWorker
+					op.TokenHash = hToken
 					lock.Lock()
 					mdsc.Worker++
 					lock.Unlock()
@@ -202,20 +254,26 @@ func (tokenProvider *TokenProvider) ModifyUsers(guildID, connectCode string, req
 					uniqueTokensUsed[hToken] = struct{}{}
 					tokenLock.Unlock()
 				} else {
-					success := tokenProvider.attemptOnCaptureBot(guildID, connectCode, gid, req)
+					// capture clients only know how to mute and deafen, so moves skip straight to the primary bot
+					success := req.ChannelID == "" && tokenProvider.attemptOnCaptureBot(userCtx, guildID, connectCode, gid, req)
 					if success {
+						op.Path = task.VoicePathCapture
 						lock.Lock()
 						mdsc.Capture++
 						lock.Unlock()
 					} else {
-						log.Printf("Applying mute=%v, deaf=%v using primary bot\n", req.Mute, req.Deaf)
-						err := task.ApplyMuteDeaf(tokenProvider.primarySession, guildID, userIDStr, req.Mute, req.Deaf)
+						op.Path = task.VoicePathOfficial
+						logger.Debug("Applying voice state using primary bot", logging.GuildIDKey, guildID, logging.ConnectCodeKey, connectCode,
+							"user_id", req.UserID, "mute", req.Mute, "deaf", req.Deaf, "channel_id", req.ChannelID)
+						_, applySpan := tracing.Start(userCtx, "ApplyUserModify", trace.WithAttributes(tracing.PathKey.String(task.VoicePathOfficial)))
+						err := task.ApplyUserModify(tokenProvider.primarySession, guildID, userIDStr, req)
+						tracing.End(applySpan, err)
 						if err != nil {
+							op.Error = err.Error()
 							lock.Lock()
 							latestErr = err
 							lock.Unlock()
-							log.Println("Error on primary bot:")
-							log.Println(err)
+							logger.Error("Error on primary bot", err, logging.GuildIDKey, guildID, logging.ConnectCodeKey, connectCode, "user_id", req.UserID)
 						} else {
 							lock.Lock()
 							mdsc.Official++
@@ -223,6 +281,11 @@ func (tokenProvider *TokenProvider) ModifyUsers(guildID, connectCode string, req
 						}
 					}
 				}
+				op.Time = start
+				op.LatencyMs = time.Since(start).Milliseconds()
+				tokenProvider.recordVoiceOp(guildID, op)
+				userSpan.SetAttributes(tracing.PathKey.String(op.Path))
+				userSpan.End()
 				wg.Done()
 			}
 		}()
@@ -245,7 +308,7 @@ func (tokenProvider *TokenProvider) ModifyUsers(guildID, connectCode string, req
 }
 
 func (tokenProvider *TokenProvider) rateLimitEventCallback(sess *discordgo.Session, rl *discordgo.RateLimit) {
-	log.Println(rl.Message)
+	logger.Warn("Rate limited by Discord", "message", rl.Message, "bucket", rl.Bucket)
 }
 
 func (tokenProvider *TokenProvider) waitForAck(pubsub *redis.PubSub, result chan<- bool) {
@@ -280,10 +343,11 @@ func (tokenProvider *TokenProvider) Close() {
 	}
 
 	tokenProvider.activeSessions = map[string]*discordgo.Session{}
+	server.WorkerSessions.Set(0)
 	tokenProvider.sessionLock.Unlock()
 	tokenProvider.primarySession.Close()
 }
 
 func (tokenProvider *TokenProvider) newGuild(s *discordgo.Session, m *discordgo.GuildCreate) {
-	log.Println("added to " + m.ID)
+	logger.Debug("Worker bot added to guild", logging.GuildIDKey, m.ID)
 }
@@ -1,7 +1,7 @@
 package tokenprovider
 
 import (
-	"log"
+	"github.com/automuteus/automuteus/v8/internal/logging"
 )
 
 func (tokenProvider *TokenProvider) verifyBotMembership(guildID string, limit int, uniqueTokensUsed map[string]struct{}) {
@@ -14,18 +14,18 @@ func (tokenProvider *TokenProvider) verifyBotMembership(guildID string, limit in
 		if !mapHasEntry(uniqueTokensUsed, hToken) {
 			_, err := sess.GuildMember(guildID, sess.State.User.ID)
 			if err != nil {
-				//log.Println(err)
+				logger.Debug("Worker bot isn't a member of the guild", logging.GuildIDKey, guildID, "token", hToken, "error", err)
 			} else {
 				i++ // successfully checked self's membership; we are a member of this server
 			}
 
 			// if the bot is verified as a member of too many servers for the premium status, then we should leave them
 			if i > limit {
-				log.Println("Token/Bot " + hToken + " leaving server " + guildID + " due to lack of premium membership")
+				logger.Info("Worker bot leaving guild without the premium tier for it", logging.GuildIDKey, guildID, "token", hToken, "limit", limit)
 
 				err = sess.GuildLeave(guildID)
 				if err != nil {
-					log.Println(err)
+					logger.Error("Error leaving guild", err, logging.GuildIDKey, guildID, "token", hToken)
 				}
 			}
 		}
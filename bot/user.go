@@ -10,6 +10,8 @@ type User struct {
 	Nick     string `json:"Nick"`
 	UserID   string `json:"UserID"`
 	UserName string `json:"UserName"`
+	// GlobalName is the user's display name across servers, if they've set one
+	GlobalName string `json:"GlobalName,omitempty"`
 }
 
 // UserData struct
@@ -18,14 +20,20 @@ type UserData struct {
 	ShouldBeMute bool   `json:"ShouldBeMute"`
 	ShouldBeDeaf bool   `json:"ShouldBeDeaf"`
 	InGameName   string `json:"PlayerName"`
+	// Spectator users aren't playing, so they follow the spectator voice rules and are never linked automatically
+	Spectator bool `json:"Spectator,omitempty"`
+	// SpectatorChosen is set when the user started or stopped spectating themselves, so the guild's spectator role
+	// doesn't override their choice
+	SpectatorChosen bool `json:"SpectatorChosen,omitempty"`
 }
 
 func MakeUserDataFromDiscordUser(dUser *discordgo.User, nick string) UserData {
 	return UserData{
 		User: User{
-			Nick:     nick,
-			UserID:   dUser.ID,
-			UserName: dUser.Username,
+			Nick:       nick,
+			UserID:     dUser.ID,
+			UserName:   dUser.Username,
+			GlobalName: dUser.GlobalName,
 		},
 		ShouldBeDeaf: false,
 		ShouldBeMute: false,
@@ -37,6 +45,10 @@ func (user *UserData) GetNickName() string {
 	return user.User.Nick
 }
 
+func (user *UserData) GetGlobalName() string {
+	return user.User.GlobalName
+}
+
 func (user *UserData) SetShouldBeMuteDeaf(mute, deaf bool) {
 	user.ShouldBeMute = mute
 	user.ShouldBeDeaf = deaf
@@ -56,4 +68,21 @@ func (user *UserData) GetPlayerName() string {
 
 func (user *UserData) Link(player amongus.PlayerData) {
 	user.InGameName = player.Name
+	// whoever is linked to a player is playing, not spectating
+	user.Spectator = false
+	user.SpectatorChosen = false
+}
+
+func (user *UserData) IsSpectator() bool {
+	return user.Spectator
+}
+
+// SetSpectator marks the user as spectating (or not) by their own choice, which the spectator role doesn't override.
+// Spectators can't be linked to a player, so they're unlinked
+func (user *UserData) SetSpectator(spectator bool) {
+	user.Spectator = spectator
+	user.SpectatorChosen = true
+	if spectator {
+		user.InGameName = amongus.UnlinkedPlayerName
+	}
 }
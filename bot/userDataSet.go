@@ -3,6 +3,8 @@ package bot
 import (
 	"fmt"
 	"github.com/automuteus/automuteus/v8/pkg/amongus"
+	"github.com/automuteus/automuteus/v8/pkg/namematch"
+	"sort"
 	"strings"
 )
 
@@ -19,16 +21,37 @@ func (dgs *GameState) GetCountLinked() int {
 	return LinkedPlayerCount
 }
 
-func (dgs *GameState) AttemptPairingByMatchingNames(data amongus.PlayerData) string {
-	name := strings.ReplaceAll(strings.ToLower(data.Name), " ", "")
+// AttemptPairingByMatchingNames links the player to the unlinked user whose names best match the player's name. A
+// user's names are their username, display name and nickname, plus any in-game names in pastNames (indexed by user
+// ID). Spectators are never matched. It returns the linked user's ID (if any), and how confident the match was
+func (dgs *GameState) AttemptPairingByMatchingNames(data amongus.PlayerData, pastNames map[string][]string) (string, float64) {
+	candidates := make([]namematch.Candidate, 0, len(dgs.UserData))
 	for userID, v := range dgs.UserData {
-		if strings.ReplaceAll(strings.ToLower(v.GetUserName()), " ", "") == name || strings.ReplaceAll(strings.ToLower(v.GetNickName()), " ", "") == name {
-			v.Link(data)
-			dgs.UserData[userID] = v
-			return userID
+		if v.GetPlayerName() != amongus.UnlinkedPlayerName || v.IsSpectator() {
+			continue
 		}
+		names := append([]string{v.GetUserName(), v.GetGlobalName(), v.GetNickName()}, pastNames[userID]...)
+		candidates = append(candidates, namematch.Candidate{UserID: userID, Names: names})
 	}
-	return ""
+	userID, score := namematch.Best(data.Name, candidates, namematch.DefaultThreshold)
+	if userID == "" {
+		return "", score
+	}
+	v := dgs.UserData[userID]
+	v.Link(data)
+	dgs.UserData[userID] = v
+	return userID, score
+}
+
+// UnlinkedUserIDs returns the IDs of the tracked users that aren't linked to a player, and could be (so not spectators)
+func (dgs *GameState) UnlinkedUserIDs() []string {
+	userIDs := make([]string, 0)
+	for userID, v := range dgs.UserData {
+		if v.GetPlayerName() == amongus.UnlinkedPlayerName && !v.IsSpectator() {
+			userIDs = append(userIDs, userID)
+		}
+	}
+	return userIDs
 }
 
 func (dgs *GameState) UpdateUserData(userID string, data UserData) {
@@ -70,6 +93,16 @@ func (dgs *GameState) ClearPlayerDataByPlayerName(playerName string) {
 	}
 }
 
+// GetUserIDByPlayerName returns the ID of the user linked to the in-game name, or "" if nobody is
+func (dgs *GameState) GetUserIDByPlayerName(playerName string) string {
+	for userID, v := range dgs.UserData {
+		if v.GetPlayerName() != amongus.UnlinkedPlayerName && strings.EqualFold(v.GetPlayerName(), playerName) {
+			return userID
+		}
+	}
+	return ""
+}
+
 func (dgs *GameState) UnlinkAllUsers() {
 	for i, v := range dgs.UserData {
 		v.InGameName = amongus.UnlinkedPlayerName
@@ -77,6 +110,18 @@ func (dgs *GameState) UnlinkAllUsers() {
 	}
 }
 
+// Spectators returns the IDs of the users that are spectating, sorted so the game message doesn't shuffle them around
+func (dgs *GameState) Spectators() []string {
+	userIDs := make([]string, 0)
+	for userID, v := range dgs.UserData {
+		if v.IsSpectator() {
+			userIDs = append(userIDs, userID)
+		}
+	}
+	sort.Strings(userIDs)
+	return userIDs
+}
+
 func (dgs *GameState) GetUser(userID string) (UserData, error) {
 	if v, ok := dgs.UserData[userID]; ok {
 		return v, nil
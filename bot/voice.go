@@ -2,12 +2,13 @@ package bot
 
 import (
 	"context"
-	"github.com/automuteus/automuteus/v8/pkg/premium"
+	"github.com/automuteus/automuteus/v8/internal/tracing"
+	"github.com/automuteus/automuteus/v8/pkg/amongus"
+	"github.com/automuteus/automuteus/v8/pkg/game"
 	"github.com/automuteus/automuteus/v8/pkg/settings"
 	"github.com/automuteus/automuteus/v8/pkg/task"
 	"github.com/bsm/redislock"
 	"github.com/bwmarrin/discordgo"
-	"log"
 	"strconv"
 	"time"
 )
@@ -21,14 +22,10 @@ const (
 )
 
 func (bot *Bot) applyToSingle(dgs *GameState, userID string, mute, deaf bool) error {
-	prem, days, _ := bot.PostgresInterface.GetGuildOrUserPremiumStatus(bot.official, nil, dgs.GuildID, "")
-	premTier := premium.FreeTier
-	if !premium.IsExpired(prem, days) {
-		premTier = prem
-	}
 	uid, _ := strconv.ParseUint(userID, 10, 64)
 	req := task.UserModifyRequest{
-		Premium: premTier,
+		Premium: bot.sink.PremiumTier(dgs.GuildID),
+		Phase:   dgs.phaseName(),
 		Users: []task.UserModify{
 			{
 				UserID: uid,
@@ -38,15 +35,18 @@ func (bot *Bot) applyToSingle(dgs *GameState, userID string, mute, deaf bool) er
 		},
 	}
 	// nil lock because this is an override; we don't care about legitimately obtaining the lock
-	return bot.TokenProvider.ModifyUsers(dgs.GuildID, dgs.ConnectCode, req, nil)
+	return bot.sink.ModifyUsers(ctx, dgs.GuildID, dgs.ConnectCode, req, nil)
 }
 
-func (bot *Bot) applyToAll(dgs *GameState, mute, deaf bool) error {
-	g, err := bot.PrimarySession.State.Guild(dgs.GuildID)
+// applyToAll mutes/deafens every tracked player in the game's channels, bringing them back to the game's voice channel
+func (bot *Bot) applyToAll(ctx context.Context, dgs *GameState, mute, deaf bool) error {
+	g, err := bot.sink.Guild(dgs.GuildID)
 	if err != nil {
 		return err
 	}
 
+	sett := bot.sink.GuildSettings(dgs.GuildID)
+
 	var users []task.UserModify
 
 	for _, voiceState := range g.VoiceStates {
@@ -54,13 +54,13 @@ func (bot *Bot) applyToAll(dgs *GameState, mute, deaf bool) error {
 		if err != nil {
 			// the User doesn't exist in our userdata cache; add them
 			added := false
-			userData, added = dgs.checkCacheAndAddUser(g, bot.PrimarySession, voiceState.UserID)
+			userData, added = dgs.checkCacheAndAddUser(g, bot.sink, voiceState.UserID)
 			if !added {
 				continue
 			}
 		}
 
-		tracked := voiceState.ChannelID != "" && dgs.VoiceChannel == voiceState.ChannelID
+		tracked := dgs.isGameChannel(sett, voiceState.ChannelID)
 
 		_, linked := dgs.GameData.GetByName(userData.InGameName)
 		// only actually tracked if we're in a tracked channel AND linked to a player
@@ -68,42 +68,51 @@ func (bot *Bot) applyToAll(dgs *GameState, mute, deaf bool) error {
 
 		if tracked {
 			uid, _ := strconv.ParseUint(userData.User.UserID, 10, 64)
-			users = append(users, task.UserModify{
+			userModify := task.UserModify{
 				UserID: uid,
 				Mute:   mute,
 				Deaf:   deaf,
-			})
-			log.Println("Forcibly applying mute/deaf to " + userData.User.UserID)
+			}
+			// bring players back from the ghost and tasks channels too
+			if voiceState.ChannelID != dgs.VoiceChannel {
+				userModify.ChannelID = dgs.VoiceChannel
+			}
+			users = append(users, userModify)
+			bot.matchLogger(dgs).Debug("Forcibly applying mute/deaf", "user_id", userData.User.UserID)
 		}
 	}
 	if len(users) > 0 {
-		prem, days, _ := bot.PostgresInterface.GetGuildOrUserPremiumStatus(bot.official, nil, dgs.GuildID, "")
-		premTier := premium.FreeTier
-		if !premium.IsExpired(prem, days) {
-			premTier = prem
-		}
 		req := task.UserModifyRequest{
-			Premium: premTier,
+			Premium: bot.sink.PremiumTier(dgs.GuildID),
+			Phase:   dgs.phaseName(),
 			Users:   users,
 		}
 		// nil lock because this is an override; we don't care about legitimately obtaining the lock
-		return bot.TokenProvider.ModifyUsers(dgs.GuildID, dgs.ConnectCode, req, nil)
+		return bot.sink.ModifyUsers(ctx, dgs.GuildID, dgs.ConnectCode, req, nil)
 	}
 	return nil
 }
 
 // handleTrackedMembers moves/mutes players according to the current game state
-func (bot *Bot) handleTrackedMembers(sess *discordgo.Session, sett *settings.GuildSettings, delay int, handlePriority HandlePriority, gsr GameStateRequest) {
+func (bot *Bot) handleTrackedMembers(ctx context.Context, sett *settings.GuildSettings, delay int, handlePriority HandlePriority, gsr GameStateRequest) {
+	ctx, span := tracing.Start(ctx, "handleTrackedMembers", tracing.GameAttributes(gsr.GuildID, gsr.ConnectCode))
+	defer span.End()
 
-	lock, dgs := bot.RedisInterface.GetDiscordGameStateAndLock(gsr)
-	for lock == nil {
-		lock, dgs = bot.RedisInterface.GetDiscordGameStateAndLock(gsr)
+	// players left in the wrong voice state are corrected by the reconciler, so this doesn't wait long for the lock,
+	// or tell the channel when it gives up
+	lockCtx, cancel := context.WithTimeout(ctx, VoiceStateLockTimeout)
+	lock, dgs, err := bot.sink.LockGameState(lockCtx, gsr)
+	cancel()
+	if err != nil {
+		return
 	}
+	span.SetAttributes(tracing.PhaseKey.String(dgs.phaseName()))
 
-	g, err := sess.State.Guild(dgs.GuildID)
+	g, err := bot.sink.Guild(dgs.GuildID)
 
 	if err != nil || g == nil {
-		lock.Release(ctx)
+		// release the lock
+		bot.sink.SetGameState(nil, lock)
 		return
 	}
 
@@ -115,113 +124,242 @@ func (bot *Bot) handleTrackedMembers(sess *discordgo.Session, sett *settings.Gui
 		if err != nil {
 			// the User doesn't exist in our userdata cache; add them
 			added := false
-			userData, added = dgs.checkCacheAndAddUser(g, sess, voiceState.UserID)
+			userData, added = dgs.checkCacheAndAddUser(g, bot.sink, voiceState.UserID)
 			if !added {
 				continue
 			}
 		}
-
-		tracked := voiceState.ChannelID != "" && dgs.VoiceChannel == voiceState.ChannelID
-
-		auData, found := dgs.GameData.GetByName(userData.InGameName)
-		// only actually tracked if we're in a tracked channel AND linked to a player
-		var isAlive bool
-
-		// only actually tracked if we're in a tracked channel AND linked to a player
-		if !sett.GetMuteSpectator() {
-			tracked = tracked && found
-			isAlive = auData.IsAlive
-		} else {
-			if !found {
-				// we just assume the spectator is dead
-				isAlive = false
-			} else {
-				isAlive = auData.IsAlive
-			}
+		if dgs.isGameChannel(sett, voiceState.ChannelID) && applySpectatorRole(sett, &userData, guildMember(g, voiceState)) {
+			dgs.UpdateUserData(voiceState.UserID, userData)
 		}
-		shouldMute, shouldDeaf := sett.GetVoiceState(isAlive, tracked, dgs.GameData.GetPhase())
-
-		incorrectMuteDeafenState := shouldMute != userData.ShouldBeMute || shouldDeaf != userData.ShouldBeDeaf
 
-		// only issue a change if the User isn't in the right state already
-		// nicksmatch can only be false if the in-game data is != nil, so the reference to .audata below is safe
-		// check the userdata is linked here to not accidentally undeafen music bots, for example
-		if incorrectMuteDeafenState && (found || sett.GetMuteSpectator()) {
-			uid, _ := strconv.ParseUint(userData.User.UserID, 10, 64)
-			userModify := task.UserModify{
-				UserID: uid,
-				Mute:   shouldMute,
-				Deaf:   shouldDeaf,
-			}
-
-			if handlePriority != NoPriority && ((handlePriority == AlivePriority && isAlive) || (handlePriority == DeadPriority && !isAlive)) {
+		userModify, isAlive, changed := dgs.userChange(sett, userData, voiceState.ChannelID)
+		if changed {
+			if handlePriority.appliesTo(isAlive) {
 				users = append([]task.UserModify{userModify}, users...)
 				priorityRequests++ // counter of how many elements on the front of the arr should be sent first
 			} else {
 				users = append(users, userModify)
 			}
-			userData.SetShouldBeMuteDeaf(shouldMute, shouldDeaf)
+			userData.SetShouldBeMuteDeaf(userModify.Mute, userModify.Deaf)
 			dgs.UpdateUserData(userData.User.UserID, userData)
 		}
 	}
 
 	// we relinquish the lock while we wait
-	bot.RedisInterface.SetDiscordGameState(dgs, lock)
+	bot.sink.SetGameState(dgs, lock)
 
-	voiceLock := bot.RedisInterface.LockVoiceChanges(dgs.ConnectCode, time.Second*time.Duration(delay+1))
+	voiceLock := bot.sink.LockVoiceChanges(dgs.ConnectCode, time.Second*time.Duration(delay+1))
 
+	l := bot.matchLogger(dgs).With("phase", dgs.phaseName())
 	if delay > 0 {
-		log.Printf("Sleeping for %d seconds before applying changes to users\n", delay)
-		time.Sleep(time.Second * time.Duration(delay))
+		l.Debug("Sleeping before applying changes to users", "delay_secs", delay)
+		bot.sink.Sleep(time.Second * time.Duration(delay))
 	}
 
 	if dgs.Running && len(users) > 0 {
-		prem, days, _ := bot.PostgresInterface.GetGuildOrUserPremiumStatus(bot.official, nil, dgs.GuildID, "")
-		premTier := premium.FreeTier
-		if !premium.IsExpired(prem, days) {
-			premTier = prem
-		}
+		premTier := bot.sink.PremiumTier(dgs.GuildID)
 
 		if priorityRequests > 0 {
 			req := task.UserModifyRequest{
 				Premium: premTier,
+				Phase:   dgs.phaseName(),
 				Users:   users[:priorityRequests],
 			}
 			// no lock; we're not done yet
-			err := bot.issueMutesAndRecord(dgs.GuildID, dgs.ConnectCode, req, nil)
+			err := bot.issueMutesAndRecord(ctx, dgs.GuildID, dgs.ConnectCode, req, nil)
 			if err != nil {
-				log.Println(err)
+				l.Error("Error issuing high priority mutes", err)
 			} else {
-				log.Println("Successfully finished issuing high priority mutes")
+				l.Debug("Successfully finished issuing high priority mutes", "users", priorityRequests)
 			}
 			rem := users[priorityRequests:]
 			if len(rem) > 0 {
 				req = task.UserModifyRequest{
 					Premium: premTier,
+					Phase:   dgs.phaseName(),
 					Users:   rem,
 				}
-				err := bot.issueMutesAndRecord(dgs.GuildID, dgs.ConnectCode, req, voiceLock)
+				err := bot.issueMutesAndRecord(ctx, dgs.GuildID, dgs.ConnectCode, req, voiceLock)
 				if err != nil {
-					log.Println(err)
+					l.Error("Error issuing mutes", err)
 				}
 			} else if voiceLock != nil {
 				voiceLock.Release(context.Background())
 			}
 		} else {
 			// no priority; issue all at once
-			log.Println("Issuing mutes/deafens with no particular priority")
+			l.Debug("Issuing mutes/deafens with no particular priority", "users", len(users))
 			req := task.UserModifyRequest{
 				Premium: premTier,
+				Phase:   dgs.phaseName(),
 				Users:   users,
 			}
-			err := bot.issueMutesAndRecord(dgs.GuildID, dgs.ConnectCode, req, voiceLock)
+			err := bot.issueMutesAndRecord(ctx, dgs.GuildID, dgs.ConnectCode, req, voiceLock)
 			if err != nil {
-				log.Println(err)
+				l.Error("Error issuing mutes", err)
 			}
 		}
 	}
 }
 
-func (bot *Bot) issueMutesAndRecord(guildID, connectCode string, req task.UserModifyRequest, lock *redislock.Lock) error {
-	return bot.TokenProvider.ModifyUsers(guildID, connectCode, req, lock)
+// userChange determines the change to apply to a user in the given voice channel, according to the guild's voice
+// strategy
+func (dgs *GameState) userChange(sett *settings.GuildSettings, userData UserData, channelID string) (task.UserModify, bool, bool) {
+	if sett.GetVoiceStrategy() == settings.MoveStrategy {
+		return dgs.moveChange(sett, userData, channelID)
+	}
+	return dgs.voiceChange(sett, userData, channelID != "" && channelID == dgs.VoiceChannel)
+}
+
+// moveChange determines the voice channel a user should be in for the current game state, when players are moved
+// instead of muted. Only linked players in one of the game's channels are moved; changed is also true when the user
+// still has a mute or deafen to clear
+func (dgs *GameState) moveChange(sett *settings.GuildSettings, userData UserData, channelID string) (task.UserModify, bool, bool) {
+	auData, found := dgs.GameData.GetByName(userData.InGameName)
+	isAlive := found && auData.IsAlive
+
+	uid, _ := strconv.ParseUint(userData.User.UserID, 10, 64)
+	userModify := task.UserModify{
+		UserID: uid,
+	}
+	if !found || userData.IsSpectator() || !dgs.isGameChannel(sett, channelID) {
+		return userModify, isAlive, false
+	}
+	if target := dgs.moveTarget(sett, isAlive); target != channelID {
+		userModify.ChannelID = target
+	}
+	return userModify, isAlive, userModify.ChannelID != "" || userData.ShouldBeMute || userData.ShouldBeDeaf
+}
+
+// moveTarget is the voice channel a player belongs in for the current phase: during tasks, alive players go to the
+// tasks channel and dead players to the ghost channel (if they're set), and everyone is back in the game's channel
+// otherwise
+func (dgs *GameState) moveTarget(sett *settings.GuildSettings, isAlive bool) string {
+	if dgs.GameData.GetPhase() == game.TASKS {
+		if isAlive && sett.GetTasksChannelID() != "" {
+			return sett.GetTasksChannelID()
+		}
+		if !isAlive && sett.GetGhostChannelID() != "" {
+			return sett.GetGhostChannelID()
+		}
+	}
+	return dgs.VoiceChannel
+}
+
+// isGameChannel reports whether the voice channel is the game's channel, or one of the channels its players are moved
+// to with the move strategy
+func (dgs *GameState) isGameChannel(sett *settings.GuildSettings, channelID string) bool {
+	if channelID == "" {
+		return false
+	}
+	if channelID == dgs.VoiceChannel {
+		return true
+	}
+	return sett.GetVoiceStrategy() == settings.MoveStrategy &&
+		(channelID == sett.GetGhostChannelID() || channelID == sett.GetTasksChannelID())
+}
+
+// voiceChange determines the mute/deafen state a user should be in for the current game state. changed is false if
+// the user is already in that state, or shouldn't be touched at all (unlinked users, when spectators aren't muted)
+func (dgs *GameState) voiceChange(sett *settings.GuildSettings, userData UserData, inTrackedChannel bool) (task.UserModify, bool, bool) {
+	shouldMute, shouldDeaf, isAlive, managed := dgs.voiceState(sett, userData, inTrackedChannel)
+
+	incorrectMuteDeafenState := shouldMute != userData.ShouldBeMute || shouldDeaf != userData.ShouldBeDeaf
+
+	uid, _ := strconv.ParseUint(userData.User.UserID, 10, 64)
+	userModify := task.UserModify{
+		UserID: uid,
+		Mute:   shouldMute,
+		Deaf:   shouldDeaf,
+	}
+	// only issue a change if the User isn't in the right state already
+	return userModify, isAlive, incorrectMuteDeafenState && managed
+}
+
+// voiceState determines the mute/deafen state a user should be in for the current game state, whether they're alive,
+// and whether the bot manages their voice state at all. Linked players and spectators are always managed, but other
+// unlinked users are only managed when spectators are muted like dead players (not to accidentally undeafen music
+// bots, for example)
+func (dgs *GameState) voiceState(sett *settings.GuildSettings, userData UserData, inTrackedChannel bool) (mute, deaf, isAlive, managed bool) {
+	mode, phase := dgs.GameData.GetMode(), dgs.GameData.GetPhase()
+	if sett.GetVoiceStrategy() == settings.MoveStrategy {
+		// players are moved instead; only linked players are managed, to clear any mutes left over from before
+		auData, found := dgs.GameData.GetByName(userData.InGameName)
+		return false, false, found && auData.IsAlive, found
+	}
+	if userData.IsSpectator() {
+		mute, deaf = sett.GetSpectatorVoiceState(mode, inTrackedChannel, phase)
+		return mute, deaf, false, true
+	}
+
+	tracked := inTrackedChannel
+	auData, found := dgs.GameData.GetByName(userData.InGameName)
+	// only actually tracked if we're in a tracked channel AND linked to a player
+	if !sett.GetMuteSpectator() {
+		tracked = tracked && found
+	}
+	// with MuteSpectator on, we just assume unlinked users are dead
+	isAlive = found && auData.IsAlive
+	mute, deaf = sett.GetVoiceState(mode, isAlive, tracked, phase)
+	return mute, deaf, isAlive, found || sett.GetMuteSpectator()
+}
+
+// applySpectatorRole makes unlinked users with the guild's spectator role spectators, and stops users spectating once
+// they lose it. Users who chose to spectate (or not) themselves are left alone. Returns whether the user changed
+func applySpectatorRole(sett *settings.GuildSettings, userData *UserData, member *discordgo.Member) bool {
+	if member == nil || userData.SpectatorChosen {
+		return false
+	}
+	spectator := sett.HasSpectatorRole(member) && userData.GetPlayerName() == amongus.UnlinkedPlayerName
+	if spectator == userData.IsSpectator() {
+		return false
+	}
+	userData.Spectator = spectator
+	return true
+}
+
+// applySpectatorRoles applies the spectator role to every known user in the game's channels. Role changes only reach
+// the bot with the member's next voice state update, so this catches up on any the game state missed
+func (dgs *GameState) applySpectatorRoles(sett *settings.GuildSettings, g *discordgo.Guild) {
+	for _, voiceState := range g.VoiceStates {
+		if !dgs.isGameChannel(sett, voiceState.ChannelID) {
+			continue
+		}
+		userData, err := dgs.GetUser(voiceState.UserID)
+		if err != nil {
+			continue
+		}
+		if applySpectatorRole(sett, &userData, guildMember(g, voiceState)) {
+			dgs.UpdateUserData(voiceState.UserID, userData)
+		}
+	}
+}
+
+// guildMember returns the member in the voice state, falling back to the guild's cached members (voice states from the
+// initial guild payload don't include them)
+func guildMember(g *discordgo.Guild, voiceState *discordgo.VoiceState) *discordgo.Member {
+	if voiceState.Member != nil {
+		return voiceState.Member
+	}
+	for _, v := range g.Members {
+		if v.User != nil && v.User.ID == voiceState.UserID {
+			return v
+		}
+	}
+	return nil
+}
+
+// phaseName is the name of the game's current phase, for the voice log
+func (dgs *GameState) phaseName() string {
+	return string(game.PhaseNames[dgs.GameData.GetPhase()])
+}
+
+// appliesTo reports whether a user's change should be sent ahead of the others
+func (priority HandlePriority) appliesTo(isAlive bool) bool {
+	return (priority == AlivePriority && isAlive) || (priority == DeadPriority && !isAlive)
+}
+
+func (bot *Bot) issueMutesAndRecord(ctx context.Context, guildID, connectCode string, req task.UserModifyRequest, lock *redislock.Lock) error {
+	return bot.sink.ModifyUsers(ctx, guildID, connectCode, req, lock)
 }
@@ -0,0 +1,154 @@
+package bot
+
+import (
+	"github.com/automuteus/automuteus/v8/pkg/amongus"
+	"github.com/automuteus/automuteus/v8/pkg/game"
+	"github.com/automuteus/automuteus/v8/pkg/settings"
+	"github.com/bwmarrin/discordgo"
+	"testing"
+)
+
+func TestVoiceChange_spectators(t *testing.T) {
+	sett := settings.MakeGuildSettings()
+	dgs := NewDiscordGameState("1")
+	dgs.GameData.UpdatePhase(game.TASKS)
+	dgs.GameData.UpdatePhase(game.DISCUSS)
+
+	unlinked := UserData{User: User{UserID: "2"}, InGameName: amongus.UnlinkedPlayerName}
+	if _, _, changed := dgs.voiceChange(sett, unlinked, true); changed {
+		t.Error("Expected unlinked users to be left alone when spectators aren't muted")
+	}
+
+	spectator := unlinked
+	spectator.SetSpectator(true)
+	modify, isAlive, changed := dgs.voiceChange(sett, spectator, true)
+	if !changed || !modify.Mute || modify.Deaf || isAlive {
+		t.Errorf("Expected spectators to be muted during discussion by default, got %+v (alive: %t)", modify, isAlive)
+	}
+
+	sett.SetVoiceRule(game.ClassicMode, true, game.DISCUSS, game.SpectatorRules, false)
+	if _, _, changed := dgs.voiceChange(sett, spectator, true); changed {
+		t.Error("Expected spectators to follow their own voice rules")
+	}
+
+	// linking a spectator makes them a player again
+	spectator.Link(amongus.PlayerData{Name: "Kuro"})
+	if spectator.IsSpectator() {
+		t.Error("Expected linked users not to be spectators")
+	}
+}
+
+func TestUserChange_move(t *testing.T) {
+	sett := settings.MakeGuildSettings()
+	sett.SetVoiceStrategy(settings.MoveStrategy)
+	sett.SetGhostChannelID("ghost")
+	sett.SetTasksChannelID("tasks")
+	dgs := NewDiscordGameState("1")
+	dgs.VoiceChannel = "game"
+	dgs.GameData.PlayerData = map[string]amongus.PlayerData{
+		"Kuro": {Name: "Kuro", IsAlive: true},
+		"Soup": {Name: "Soup", IsAlive: false},
+	}
+	dgs.GameData.UpdatePhase(game.TASKS)
+
+	alive := UserData{User: User{UserID: "2"}, InGameName: "Kuro"}
+	dead := UserData{User: User{UserID: "3"}, InGameName: "Soup"}
+	if modify, isAlive, changed := dgs.userChange(sett, alive, "game"); !changed || modify.ChannelID != "tasks" || !isAlive || modify.Mute {
+		t.Errorf("Expected alive players to be moved to the tasks channel during tasks, got %+v", modify)
+	}
+	if modify, _, changed := dgs.userChange(sett, dead, "game"); !changed || modify.ChannelID != "ghost" {
+		t.Errorf("Expected dead players to be moved to the ghost channel during tasks, got %+v", modify)
+	}
+	if _, _, changed := dgs.userChange(sett, dead, "ghost"); changed {
+		t.Error("Expected players already in the right channel to be left alone")
+	}
+	if _, _, changed := dgs.userChange(sett, dead, "afk"); changed {
+		t.Error("Expected players outside the game's channels to be left alone")
+	}
+	unlinked := UserData{User: User{UserID: "4"}, InGameName: amongus.UnlinkedPlayerName}
+	if _, _, changed := dgs.userChange(sett, unlinked, "game"); changed {
+		t.Error("Expected unlinked users not to be moved")
+	}
+
+	dgs.GameData.UpdatePhase(game.DISCUSS)
+	if modify, _, changed := dgs.userChange(sett, dead, "ghost"); !changed || modify.ChannelID != "game" {
+		t.Errorf("Expected dead players to be moved back for discussion, got %+v", modify)
+	}
+
+	// players left muted from the mute strategy get unmuted, without being moved
+	dead.SetShouldBeMuteDeaf(true, false)
+	if modify, _, changed := dgs.userChange(sett, dead, "game"); !changed || modify.ChannelID != "" || modify.Mute {
+		t.Errorf("Expected leftover mutes to be cleared, got %+v", modify)
+	}
+}
+
+func TestApplySpectatorRole(t *testing.T) {
+	sett := settings.MakeGuildSettings()
+	sett.SetSpectatorRoleID("spec")
+	withRole := &discordgo.Member{Roles: []string{"spec"}}
+	withoutRole := &discordgo.Member{}
+
+	userData := UserData{User: User{UserID: "2"}, InGameName: amongus.UnlinkedPlayerName}
+	if !applySpectatorRole(sett, &userData, withRole) || !userData.IsSpectator() {
+		t.Error("Expected unlinked users with the spectator role to spectate")
+	}
+	if applySpectatorRole(sett, &userData, withRole) {
+		t.Error("Expected users already spectating not to change")
+	}
+	if applySpectatorRole(sett, &userData, nil) || !userData.IsSpectator() {
+		t.Error("Expected users to be left alone without their member")
+	}
+	if !applySpectatorRole(sett, &userData, withoutRole) || userData.IsSpectator() {
+		t.Error("Expected users to stop spectating once they lose the role")
+	}
+
+	linked := UserData{User: User{UserID: "3"}, InGameName: "Kuro"}
+	if applySpectatorRole(sett, &linked, withRole) || linked.IsSpectator() {
+		t.Error("Expected linked users to keep playing")
+	}
+
+	// users who stop spectating themselves aren't made spectators again by their role
+	userData.SetSpectator(true)
+	userData.SetSpectator(false)
+	if applySpectatorRole(sett, &userData, withRole) || userData.IsSpectator() {
+		t.Error("Expected the user's own choice to win over their role")
+	}
+	// until they're linked, which resets their choice
+	userData.Link(amongus.PlayerData{Name: "Kuro"})
+	userData.InGameName = amongus.UnlinkedPlayerName
+	if !applySpectatorRole(sett, &userData, withRole) {
+		t.Error("Expected the role to apply again after being linked")
+	}
+}
+
+func TestApplySpectatorRoles(t *testing.T) {
+	sett := settings.MakeGuildSettings()
+	sett.SetSpectatorRoleID("spec")
+	dgs := NewDiscordGameState("1")
+	dgs.VoiceChannel = "game"
+	dgs.UpdateUserData("2", UserData{User: User{UserID: "2"}, InGameName: amongus.UnlinkedPlayerName})
+	dgs.UpdateUserData("3", UserData{User: User{UserID: "3"}, InGameName: amongus.UnlinkedPlayerName})
+	g := &discordgo.Guild{
+		Members: []*discordgo.Member{
+			{User: &discordgo.User{ID: "2"}, Roles: []string{"spec"}},
+			{User: &discordgo.User{ID: "3"}, Roles: []string{"spec"}},
+		},
+		VoiceStates: []*discordgo.VoiceState{
+			{UserID: "2", ChannelID: "game"},
+			{UserID: "3", ChannelID: "afk"},
+		},
+	}
+	dgs.applySpectatorRoles(sett, g)
+	if userData, _ := dgs.GetUser("2"); !userData.IsSpectator() {
+		t.Error("Expected users in the game's channel to get the role applied")
+	}
+	if userData, _ := dgs.GetUser("3"); userData.IsSpectator() {
+		t.Error("Expected users outside the game's channels to be left alone")
+	}
+
+	g.Members[0].Roles = nil
+	dgs.applySpectatorRoles(sett, g)
+	if userData, _ := dgs.GetUser("2"); userData.IsSpectator() {
+		t.Error("Expected users who lost the role to stop spectating")
+	}
+}
@@ -3,8 +3,8 @@ package common
 import (
 	"context"
 	"fmt"
+	"github.com/automuteus/automuteus/v8/internal/logging"
 	"github.com/go-redis/redis/v8"
-	"log"
 	"time"
 )
 
@@ -46,13 +46,13 @@ func GuildDownloadCategoryCooldownKey(guildID, category string) string {
 func MarkUserRateLimit(client *redis.Client, userID, cmdType string, ttl time.Duration) {
 	err := client.Set(context.Background(), UserRateLimitGeneralKey(userID), "", GlobalUserRateLimitDuration).Err()
 	if err != nil {
-		log.Println(err)
+		logger.Error("Error marking the user's rate limit", err, "user_id", userID)
 	}
 
 	if cmdType != "" && ttl > 0 {
 		err = client.Set(context.Background(), UserRateLimitSpecificKey(userID, cmdType), "", ttl).Err()
 		if err != nil {
-			log.Println(err)
+			logger.Error("Error marking the user's command rate limit", err, "user_id", userID, "command", cmdType)
 		}
 	}
 }
@@ -64,7 +64,7 @@ func IncrementRateLimitExceed(client *redis.Client, userID string) bool {
 		Member: float64(t),
 	}).Result()
 	if err != nil {
-		log.Println(err)
+		logger.Error("Error counting the user's rate limit violation", err, "user_id", userID)
 	}
 
 	beforeStr := fmt.Sprintf("%d", time.Now().Add(-SoftbanExpiration).Unix())
@@ -74,7 +74,7 @@ func IncrementRateLimitExceed(client *redis.Client, userID string) bool {
 		fmt.Sprintf("%d", t),
 	).Result()
 	if err != nil {
-		log.Println(err)
+		logger.Error("Error counting the user's rate limit violations", err, "user_id", userID)
 	}
 	if count > SoftbanThreshold {
 		softbanUser(client, userID)
@@ -89,14 +89,14 @@ func IncrementRateLimitExceed(client *redis.Client, userID string) bool {
 func softbanUser(client *redis.Client, userID string) {
 	err := client.Set(context.Background(), UserSoftbanKey(userID), "", SoftbanDuration).Err()
 	if err != nil {
-		log.Println(err)
+		logger.Error("Error softbanning the user", err, "user_id", userID)
 	}
 }
 
 func IsUserBanned(client *redis.Client, userID string) bool {
 	v, err := client.Exists(context.Background(), UserSoftbanKey(userID)).Result()
 	if err != nil {
-		log.Println(err)
+		logger.Error("Error checking if the user is softbanned", err, "user_id", userID)
 		return false
 	}
 	return v == 1 // =1 means the user is present, and thus rate-limited
@@ -105,7 +105,7 @@ func IsUserBanned(client *redis.Client, userID string) bool {
 func IsUserRateLimitedGeneral(client *redis.Client, userID string) bool {
 	v, err := client.Exists(context.Background(), UserRateLimitGeneralKey(userID)).Result()
 	if err != nil {
-		log.Println(err)
+		logger.Error("Error checking the user's rate limit", err, "user_id", userID)
 		return false
 	}
 	return v == 1 // =1 means the user is present, and thus rate-limited
@@ -114,7 +114,7 @@ func IsUserRateLimitedGeneral(client *redis.Client, userID string) bool {
 func IsUserRateLimitedSpecific(client *redis.Client, userID string, cmdType string) bool {
 	v, err := client.Exists(context.Background(), UserRateLimitSpecificKey(userID, cmdType)).Result()
 	if err != nil {
-		log.Println(err)
+		logger.Error("Error checking the user's command rate limit", err, "user_id", userID, "command", cmdType)
 		return false
 	}
 	return v == 1 // =1 means the user is present, and thus rate-limited
@@ -123,7 +123,7 @@ func IsUserRateLimitedSpecific(client *redis.Client, userID string, cmdType stri
 func MarkDownloadCategoryCooldown(client *redis.Client, guildID, category string) {
 	err := client.Set(context.Background(), GuildDownloadCategoryCooldownKey(guildID, category), "", GuildDownloadCooldown).Err()
 	if err != nil {
-		log.Println(err)
+		logger.Error("Error marking the download cooldown", err, logging.GuildIDKey, guildID, "category", category)
 	}
 }
 
@@ -132,7 +132,7 @@ func GetDownloadCategoryCooldown(client *redis.Client, guildID, category string)
 	if err == redis.Nil {
 		return 0, nil
 	} else if err != nil {
-		log.Println(err)
+		logger.Error("Error getting the download cooldown", err, logging.GuildIDKey, guildID, "category", category)
 		return -1, err
 	}
 	return v, nil
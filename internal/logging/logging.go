@@ -0,0 +1,162 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"golang.org/x/exp/slog"
+	"gopkg.in/natefinch/lumberjack.v2"
+	"io"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	TextFormat = "text"
+	JSONFormat = "json"
+)
+
+// keys for the attributes identifying where a line came from
+const (
+	PackageKey     = "pkg"
+	GuildIDKey     = "guild_id"
+	ConnectCodeKey = "connect_code"
+	ShardIDKey     = "shard_id"
+	MatchIDKey     = "match_id"
+)
+
+type Config struct {
+	// Format is either TextFormat or JSONFormat
+	Format string
+	// Level applies to every package without its own level in PackageLevels
+	Level         slog.Level
+	PackageLevels map[string]slog.Level
+}
+
+type state struct {
+	handler slog.Handler
+	config  Config
+}
+
+func (s *state) level(pkg string) slog.Level {
+	if level, ok := s.config.PackageLevels[pkg]; ok {
+		return level
+	}
+	return s.config.Level
+}
+
+var current atomic.Value
+
+func init() {
+	current.Store(&state{
+		handler: slog.Default().Handler(),
+		config:  Config{Level: slog.LevelInfo},
+	})
+}
+
+// Init sends every log line to w in the configured format, including the lines logged with the standard log package
+func Init(w io.Writer, config Config) {
+	var handler slog.Handler
+	opts := slog.HandlerOptions{Level: slog.LevelDebug}
+	if config.Format == JSONFormat {
+		handler = opts.NewJSONHandler(w)
+	} else {
+		handler = opts.NewTextHandler(w)
+	}
+	current.Store(&state{
+		handler: handler,
+		config:  config,
+	})
+	slog.SetDefault(For(""))
+}
+
+// For returns the logger for a package, which only logs at or above the package's level. It can be called before
+// Init (e.g. in package variables); it always logs with the latest configuration
+func For(pkg string) *slog.Logger {
+	return slog.New(&packageHandler{pkg: pkg})
+}
+
+// RotatingFile is a log file that's rotated once it reaches maxSizeMB, keeping rotated files for maxAgeDays
+func RotatingFile(filename string, maxSizeMB, maxAgeDays int) io.WriteCloser {
+	return &lumberjack.Logger{
+		Filename: filename,
+		MaxSize:  maxSizeMB,
+		MaxAge:   maxAgeDays,
+	}
+}
+
+// ParseLevel parses a level name (debug, info, warn or error)
+func ParseLevel(str string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(str)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info", "":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return slog.LevelInfo, fmt.Errorf("unknown log level \"%s\"", str)
+	}
+}
+
+// ParsePackageLevels parses per-package levels, like "bot=debug,tokenprovider=warn"
+func ParsePackageLevels(str string) (map[string]slog.Level, error) {
+	levels := make(map[string]slog.Level)
+	for _, entry := range strings.Split(str, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		pkg, levelStr, found := strings.Cut(entry, "=")
+		if !found {
+			return nil, fmt.Errorf("expected package=level, got \"%s\"", entry)
+		}
+		level, err := ParseLevel(levelStr)
+		if err != nil {
+			return nil, err
+		}
+		levels[strings.TrimSpace(pkg)] = level
+	}
+	return levels, nil
+}
+
+// packageHandler filters by its package's level, and passes records on to whichever handler is current
+type packageHandler struct {
+	pkg string
+	// applied (in order) to the current handler; attributes and groups added with With/WithGroup
+	with []func(slog.Handler) slog.Handler
+}
+
+func (h *packageHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= current.Load().(*state).level(h.pkg)
+}
+
+func (h *packageHandler) Handle(r slog.Record) error {
+	handler := current.Load().(*state).handler
+	if h.pkg != "" {
+		handler = handler.WithAttrs([]slog.Attr{slog.String(PackageKey, h.pkg)})
+	}
+	for _, with := range h.with {
+		handler = with(handler)
+	}
+	return handler.Handle(r)
+}
+
+func (h *packageHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.extend(func(handler slog.Handler) slog.Handler {
+		return handler.WithAttrs(attrs)
+	})
+}
+
+func (h *packageHandler) WithGroup(name string) slog.Handler {
+	return h.extend(func(handler slog.Handler) slog.Handler {
+		return handler.WithGroup(name)
+	})
+}
+
+func (h *packageHandler) extend(with func(slog.Handler) slog.Handler) *packageHandler {
+	return &packageHandler{
+		pkg:  h.pkg,
+		with: append(append([]func(slog.Handler) slog.Handler{}, h.with...), with),
+	}
+}
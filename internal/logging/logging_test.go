@@ -0,0 +1,66 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"golang.org/x/exp/slog"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestParsePackageLevels(t *testing.T) {
+	levels, err := ParsePackageLevels("bot=debug, tokenprovider=WARN,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if levels["bot"] != slog.LevelDebug || levels["tokenprovider"] != slog.LevelWarn || len(levels) != 2 {
+		t.Errorf("unexpected levels %v", levels)
+	}
+
+	_, err = ParsePackageLevels("bot")
+	if err == nil {
+		t.Error("expected an error without a level")
+	}
+	_, err = ParsePackageLevels("bot=loud")
+	if err == nil {
+		t.Error("expected an error for an unknown level")
+	}
+}
+
+func TestFor(t *testing.T) {
+	// created before Init, like a package variable
+	botLogger := For("bot")
+	otherLogger := For("other")
+
+	buf := bytes.Buffer{}
+	Init(&buf, Config{
+		Format:        JSONFormat,
+		Level:         slog.LevelWarn,
+		PackageLevels: map[string]slog.Level{"bot": slog.LevelDebug},
+	})
+
+	botLogger.With(GuildIDKey, "1", ConnectCodeKey, "ABCDEFGH").Debug("popped job")
+	otherLogger.Info("filtered out")
+	log.Println("from the standard logger")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected only the bot's debug line, got %v", lines)
+	}
+	var line map[string]interface{}
+	err := json.Unmarshal([]byte(lines[0]), &line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line["msg"] != "popped job" || line[PackageKey] != "bot" || line[GuildIDKey] != "1" || line[ConnectCodeKey] != "ABCDEFGH" {
+		t.Errorf("unexpected line %v", line)
+	}
+
+	buf.Reset()
+	Init(&buf, Config{Format: TextFormat, Level: slog.LevelInfo})
+	log.Println("from the standard logger")
+	if !strings.Contains(buf.String(), "msg=\"from the standard logger\"") {
+		t.Errorf("expected the standard logger to go through the text handler, got %s", buf.String())
+	}
+}
@@ -1,14 +1,132 @@
 package server
 
 import (
+	"context"
+	"encoding/json"
 	"github.com/gorilla/mux"
-	"log"
 	"net/http"
+	"sort"
+	"sync"
+	"time"
 )
 
 var GlobalReady = false
 
-func StartHealthCheckServer(port string) {
+// names of the checks /ready reports on
+const (
+	RedisCheck    = "redis"
+	PostgresCheck = "postgres"
+	DiscordCheck  = "discord"
+	WorkersCheck  = "workers"
+	JobsCheck     = "jobs"
+)
+
+// DefaultRequiredChecks are the checks that have to pass for the bot to be ready, unless configured otherwise. Worker
+// sessions aren't required, because mutes fall back to the capture client or the primary bot without them
+var DefaultRequiredChecks = []string{RedisCheck, PostgresCheck, DiscordCheck, JobsCheck}
+
+// ReadyCheckTimeout bounds how long /ready waits on any one check
+const ReadyCheckTimeout = time.Second * 2
+
+// Check reports whether a dependency is healthy (a nil error), with optional details about its state
+type Check func(ctx context.Context) (details interface{}, err error)
+
+type CheckResult struct {
+	Healthy  bool        `json:"healthy"`
+	Required bool        `json:"required"`
+	Error    string      `json:"error,omitempty"`
+	Details  interface{} `json:"details,omitempty"`
+}
+
+type ReadyResponse struct {
+	Ready  bool                   `json:"ready"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+type HealthChecker struct {
+	checks     map[string]Check
+	checksLock sync.RWMutex
+	required   map[string]bool
+}
+
+// NewHealthChecker creates a checker that's ready once every required check passes. Checks that aren't required are
+// still reported, but don't affect readiness
+func NewHealthChecker(required []string) *HealthChecker {
+	hc := &HealthChecker{
+		checks:   make(map[string]Check),
+		required: make(map[string]bool),
+	}
+	for _, name := range required {
+		hc.required[name] = true
+	}
+	return hc
+}
+
+func (hc *HealthChecker) AddCheck(name string, check Check) {
+	hc.checksLock.Lock()
+	hc.checks[name] = check
+	hc.checksLock.Unlock()
+}
+
+// Ready runs every check concurrently, and reports whether every required check passed. A required check that was
+// never added fails, so a typo in the configuration can't make the bot look healthier than it is
+func (hc *HealthChecker) Ready(ctx context.Context) ReadyResponse {
+	ctx, cancel := context.WithTimeout(ctx, ReadyCheckTimeout)
+	defer cancel()
+
+	resp := ReadyResponse{
+		Ready:  true,
+		Checks: make(map[string]CheckResult),
+	}
+	lock := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	hc.checksLock.RLock()
+	for name, check := range hc.checks {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+			details, err := check(ctx)
+			result := CheckResult{
+				Healthy:  err == nil,
+				Required: hc.required[name],
+				Details:  details,
+			}
+			if err != nil {
+				result.Error = err.Error()
+			}
+			lock.Lock()
+			resp.Checks[name] = result
+			lock.Unlock()
+		}(name, check)
+	}
+	hc.checksLock.RUnlock()
+	wg.Wait()
+
+	for _, name := range hc.requiredNames() {
+		result, ok := resp.Checks[name]
+		if !ok {
+			resp.Checks[name] = CheckResult{
+				Required: true,
+				Error:    "no such check",
+			}
+		}
+		if !result.Healthy {
+			resp.Ready = false
+		}
+	}
+	return resp
+}
+
+func (hc *HealthChecker) requiredNames() []string {
+	names := make([]string, 0, len(hc.required))
+	for name := range hc.required {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func StartHealthCheckServer(port string, checker *HealthChecker) {
 	r := mux.NewRouter()
 
 	r.HandleFunc("/live", func(w http.ResponseWriter, r *http.Request) {
@@ -17,31 +135,27 @@ func StartHealthCheckServer(port string) {
 	})
 
 	r.HandleFunc("/ready", func(w http.ResponseWriter, r *http.Request) {
-		if GlobalReady {
-			resp, err := http.Get("https://discordapp.com/api/v8/gateway")
-			if err != nil {
-				log.Println(err)
-				w.WriteHeader(http.StatusInternalServerError)
-				w.Write([]byte(err.Error()))
-				return
-			}
-			defer resp.Body.Close()
-			w.WriteHeader(resp.StatusCode)
-			if resp.StatusCode == http.StatusOK {
-				w.Write([]byte("ready"))
-			} else {
-				w.Write([]byte("unready"))
-			}
-
-			if resp.StatusCode == http.StatusTooManyRequests {
-				w.WriteHeader(http.StatusTooManyRequests)
-				log.Println("I'M BEING RATE-LIMITED BY DISCORD")
-				return
-			}
-		} else {
+		w.Header().Set("Content-Type", "application/json")
+		if !GlobalReady {
 			w.WriteHeader(http.StatusTooEarly)
+			writeReadyResponse(w, ReadyResponse{Checks: map[string]CheckResult{}})
+			return
 		}
+		resp := checker.Ready(r.Context())
+		if resp.Ready {
+			w.WriteHeader(http.StatusOK)
+		} else {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		writeReadyResponse(w, resp)
 	})
 
 	http.ListenAndServe(":"+port, r)
 }
+
+func writeReadyResponse(w http.ResponseWriter, resp ReadyResponse) {
+	err := json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		logger.Error("Error writing ready response", err)
+	}
+}
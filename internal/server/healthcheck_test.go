@@ -0,0 +1,51 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestHealthChecker_Ready(t *testing.T) {
+	hc := NewHealthChecker([]string{RedisCheck, PostgresCheck})
+	hc.AddCheck(RedisCheck, func(ctx context.Context) (interface{}, error) {
+		return map[string]int{"idle_conns": 1}, nil
+	})
+	hc.AddCheck(PostgresCheck, func(ctx context.Context) (interface{}, error) {
+		return nil, nil
+	})
+	hc.AddCheck(WorkersCheck, func(ctx context.Context) (interface{}, error) {
+		return nil, errors.New("1 of 1 worker session(s) disconnected")
+	})
+
+	resp := hc.Ready(context.Background())
+	if !resp.Ready {
+		t.Error("expected to be ready when only an optional check fails")
+	}
+	workers := resp.Checks[WorkersCheck]
+	if workers.Healthy || workers.Required || workers.Error == "" {
+		t.Errorf("expected the failing optional check to be reported, got %+v", workers)
+	}
+	if !resp.Checks[RedisCheck].Healthy || resp.Checks[RedisCheck].Details == nil {
+		t.Errorf("expected the redis check to pass with details, got %+v", resp.Checks[RedisCheck])
+	}
+
+	hc.AddCheck(PostgresCheck, func(ctx context.Context) (interface{}, error) {
+		return nil, errors.New("connection refused")
+	})
+	resp = hc.Ready(context.Background())
+	if resp.Ready {
+		t.Error("expected not to be ready when a required check fails")
+	}
+}
+
+func TestHealthChecker_ReadyMissingCheck(t *testing.T) {
+	hc := NewHealthChecker([]string{JobsCheck})
+	resp := hc.Ready(context.Background())
+	if resp.Ready {
+		t.Error("expected not to be ready when a required check was never added")
+	}
+	if !resp.Checks[JobsCheck].Required || resp.Checks[JobsCheck].Error == "" {
+		t.Errorf("expected the missing check to be reported, got %+v", resp.Checks[JobsCheck])
+	}
+}
@@ -7,9 +7,9 @@ import (
 	"github.com/go-redis/redis/v8"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
-	"log"
 	"net/http"
 	"strconv"
+	"time"
 )
 
 type EventType int
@@ -34,31 +34,183 @@ var MetricTypeStrings = []string{
 	"official_request", //must be the last request
 }
 
+// JobRejects counts capture jobs that were dropped because they failed to decode or validate
+var JobRejects = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "capture_job_rejects_total",
+	Help: "Number of capture jobs rejected, differentiated by reason",
+}, []string{"reason"})
+
+func RecordJobReject(reason string) {
+	JobRejects.WithLabelValues(reason).Inc()
+}
+
+// JobDuplicates counts sequenced capture jobs that were dropped because they had already been processed
+var JobDuplicates = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "capture_job_duplicates_total",
+	Help: "Number of duplicate capture jobs dropped",
+})
+
+// CaptureSourceChanges counts how often a game's primary capture client was elected, including failovers
+var CaptureSourceChanges = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "capture_source_changes_total",
+	Help: "Number of times a game elected a new primary capture client",
+})
+
+// JobsMissing counts sequenced capture jobs that never arrived, and were skipped
+var JobsMissing = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "capture_jobs_missing_total",
+	Help: "Number of capture jobs skipped over because they never arrived",
+})
+
+// JobLockTimeouts counts capture jobs that couldn't be processed because the game state stayed locked
+var JobLockTimeouts = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "capture_job_lock_timeouts_total",
+	Help: "Number of capture jobs put off because the game state stayed locked, differentiated by whether the job will be retried or was skipped",
+}, []string{"result"})
+
+// what happened to a job that timed out waiting for the game state lock
+const (
+	JobRetried = "retried"
+	JobSkipped = "skipped"
+)
+
+// VoiceStateDrift counts users the voice state reconciler found out of sync with the state they should be in
+var VoiceStateDrift = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "voice_state_drift_total",
+	Help: "Number of users found with the wrong voice state, differentiated by what was wrong (mute, deaf or channel)",
+}, []string{"kind"})
+
+func RecordVoiceStateDrift(kind string) {
+	VoiceStateDrift.WithLabelValues(kind).Inc()
+}
+
+// VoiceStateCorrections counts the users the voice state reconciler sent corrective requests for
+var VoiceStateCorrections = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "voice_state_corrections_total",
+	Help: "Number of users sent corrective voice state requests, differentiated by result",
+}, []string{"result"})
+
+func RecordVoiceStateCorrections(result string, num int) {
+	VoiceStateCorrections.WithLabelValues(result).Add(float64(num))
+}
+
+// TransitionLatency measures how long it takes from popping a phase transition job until the players' voice states
+// have been updated for the new phase
+var TransitionLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "capture_transition_latency_seconds",
+	Help:    "Seconds from popping a phase transition job to the players' voice states being updated, differentiated by transition",
+	Buckets: []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 20},
+}, []string{"transition"})
+
+func RecordTransitionLatency(from, to string, latency time.Duration) {
+	TransitionLatency.WithLabelValues(from + "_" + to).Observe(latency.Seconds())
+}
+
+// ModifyUsersLatency measures how long a single user's mute/deafen/move took, for each way of applying it
+var ModifyUsersLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "modify_users_latency_seconds",
+	Help:    "Seconds taken to modify a single user's voice state, differentiated by path (worker, capture or official)",
+	Buckets: []float64{.05, .1, .25, .5, 1, 2.5, 5, 10},
+}, []string{"path"})
+
+func RecordModifyUsersLatency(path string, latency time.Duration) {
+	ModifyUsersLatency.WithLabelValues(path).Observe(latency.Seconds())
+}
+
+// GameStateLockWait measures how long obtaining a game state lock took, whether it was obtained or not
+var GameStateLockWait = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "game_state_lock_wait_seconds",
+	Help:    "Seconds spent waiting for a game state lock, differentiated by result (obtained or not_obtained)",
+	Buckets: []float64{.001, .005, .01, .05, .1, .25, .5, 1, 2.5, 5},
+}, []string{"result"})
+
+// GameStateLockRetries counts the retries it took to obtain a game state lock, whether it was obtained or not
+var GameStateLockRetries = prometheus.NewHistogram(prometheus.HistogramOpts{
+	Name:    "game_state_lock_retries",
+	Help:    "Number of retries taken to obtain a game state lock",
+	Buckets: []float64{0, 1, 2, 5, 10, 25, 50},
+})
+
+// results of trying to obtain a game state lock
+const (
+	LockObtained  = "obtained"
+	LockContended = "contended"
+	LockTimedOut  = "timed_out"
+	LockFailed    = "error"
+)
+
+// GameStateLockAttempts counts game state locks by the function taking them, and by result: obtained straight away,
+// obtained after waiting on another holder (contended), still held by someone else at the deadline (timed_out), or
+// a Redis error
+var GameStateLockAttempts = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "game_state_lock_attempts_total",
+	Help: "Game state locks taken, differentiated by caller and result (obtained, contended, timed_out or error)",
+}, []string{"caller", "result"})
+
+func RecordGameStateLock(caller, result string, wait time.Duration, retries int) {
+	obtained := "obtained"
+	if result != LockObtained && result != LockContended {
+		obtained = "not_obtained"
+	}
+	GameStateLockWait.WithLabelValues(obtained).Observe(wait.Seconds())
+	GameStateLockRetries.Observe(float64(retries))
+	GameStateLockAttempts.WithLabelValues(caller, result).Inc()
+}
+
+// JobQueueDepth is how many jobs are waiting to be processed for each game the shard is subscribed to
+var JobQueueDepth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "capture_job_queue_depth",
+	Help: "Number of jobs waiting to be processed, differentiated by connect code",
+}, []string{"connect_code"})
+
+// SubscribedGames is the number of games each shard is subscribed to (processing the jobs of)
+var SubscribedGames = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "subscribed_games",
+	Help: "Number of games subscribed to, differentiated by shard",
+}, []string{"shard"})
+
+// WorkerSessions is the number of open worker bot sessions
+var WorkerSessions = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "worker_sessions",
+	Help: "Number of open worker bot sessions",
+})
+
 type Collector struct {
-	counterDesc *prometheus.Desc
-	client      *redis.Client
-	commit      string
-	nodeID      string
+	counterDesc     *prometheus.Desc
+	activeGamesDesc *prometheus.Desc
+	client          *redis.Client
+	commit          string
+	nodeID          string
+
+	// games that haven't had any activity for this long aren't counted as active
+	activeGameSecs int64
 }
 
 func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- c.counterDesc
+	ch <- c.activeGamesDesc
 }
 
 func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(
+		c.activeGamesDesc,
+		prometheus.GaugeValue,
+		float64(rediskey.GetActiveGames(context.Background(), c.client, c.activeGameSecs)),
+	)
+
 	official := int64(0)
 	for i, str := range MetricTypeStrings {
 		if i != int(OfficialRequest) {
 			v, err := c.client.Get(context.Background(), rediskey.RequestsByType(str)).Result()
 			if !errors.Is(err, redis.Nil) && err != nil {
-				log.Println(err)
+				logger.Error("Error fetching request count", err, "type", str)
 				continue
 			} else {
 				num := int64(0)
 				if v != "" {
 					num, err = strconv.ParseInt(v, 10, 64)
 					if err != nil {
-						log.Println(err)
+						logger.Error("Error parsing request count", err, "type", str)
 						num = 0
 					}
 				}
@@ -93,16 +245,33 @@ func RecordDiscordRequests(client *redis.Client, requestType EventType, num int6
 	}
 }
 
-func NewCollector(client *redis.Client, nodeID string) *Collector {
+func NewCollector(client *redis.Client, nodeID string, activeGameSecs int64) *Collector {
 	return &Collector{
-		counterDesc: prometheus.NewDesc("discord_requests_by_node_and_type", "Number of discord requests made, differentiated by node/type", []string{"nodeID", "type"}, nil),
-		client:      client,
-		nodeID:      nodeID,
+		counterDesc:     prometheus.NewDesc("discord_requests_by_node_and_type", "Number of discord requests made, differentiated by node/type", []string{"nodeID", "type"}, nil),
+		activeGamesDesc: prometheus.NewDesc("active_games", "Number of games with activity recently, across all nodes", nil, nil),
+		client:          client,
+		nodeID:          nodeID,
+		activeGameSecs:  activeGameSecs,
 	}
 }
 
-func PrometheusMetricsServer(client *redis.Client, nodeID, port string) error {
-	prometheus.MustRegister(NewCollector(client, nodeID))
+func PrometheusMetricsServer(client *redis.Client, nodeID, port string, activeGameSecs int64) error {
+	prometheus.MustRegister(NewCollector(client, nodeID, activeGameSecs))
+	prometheus.MustRegister(JobRejects)
+	prometheus.MustRegister(JobDuplicates)
+	prometheus.MustRegister(JobsMissing)
+	prometheus.MustRegister(JobLockTimeouts)
+	prometheus.MustRegister(CaptureSourceChanges)
+	prometheus.MustRegister(VoiceStateDrift)
+	prometheus.MustRegister(VoiceStateCorrections)
+	prometheus.MustRegister(TransitionLatency)
+	prometheus.MustRegister(ModifyUsersLatency)
+	prometheus.MustRegister(GameStateLockWait)
+	prometheus.MustRegister(GameStateLockRetries)
+	prometheus.MustRegister(GameStateLockAttempts)
+	prometheus.MustRegister(JobQueueDepth)
+	prometheus.MustRegister(SubscribedGames)
+	prometheus.MustRegister(WorkerSessions)
 
 	http.Handle("/metrics", promhttp.Handler())
 
@@ -0,0 +1,64 @@
+package tracing
+
+import (
+	"context"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const ServiceName = "automuteus"
+
+const (
+	GuildIDKey     = attribute.Key("automuteus.guild_id")
+	ConnectCodeKey = attribute.Key("automuteus.connect_code")
+	PhaseKey       = attribute.Key("automuteus.phase")
+	UserIDKey      = attribute.Key("automuteus.user_id")
+	PathKey        = attribute.Key("automuteus.path")
+)
+
+// Init exports spans over OTLP/HTTP to the endpoint (host:port), and returns a function that flushes any remaining
+// spans on shutdown. Until Init is called, every span is a no-op, so tracing is disabled by default
+func Init(ctx context.Context, endpoint string, insecure bool) (func(context.Context) error, error) {
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(endpoint)}
+	if insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(ServiceName))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Start starts a span as a child of the span in ctx, if there is one
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(ServiceName).Start(ctx, name, opts...)
+}
+
+// GameAttributes are the attributes identifying which game a span belongs to
+func GameAttributes(guildID, connectCode string) trace.SpanStartEventOption {
+	return trace.WithAttributes(
+		GuildIDKey.String(guildID),
+		ConnectCodeKey.String(connectCode),
+	)
+}
+
+// End records the error on the span (if there was one), and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
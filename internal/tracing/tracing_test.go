@@ -0,0 +1,42 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"testing"
+)
+
+func TestStartAndEnd(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	ctx, parent := Start(context.Background(), "parent", GameAttributes("1", "ABCDEFGH"))
+	_, child := Start(ctx, "child")
+	End(child, errors.New("failed"))
+	End(parent, nil)
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 ended spans, got %d", len(spans))
+	}
+	if spans[0].Parent().SpanID() != spans[1].SpanContext().SpanID() {
+		t.Error("expected the child span to be nested under the parent")
+	}
+	if spans[0].Status().Code != codes.Error {
+		t.Error("expected the child span to record its error")
+	}
+	if spans[1].Status().Code == codes.Error {
+		t.Error("expected the parent span not to have an error")
+	}
+	attrs := map[string]string{}
+	for _, attr := range spans[1].Attributes() {
+		attrs[string(attr.Key)] = attr.Value.Emit()
+	}
+	if attrs[string(GuildIDKey)] != "1" || attrs[string(ConnectCodeKey)] != "ABCDEFGH" {
+		t.Errorf("expected the game's attributes on the parent span, got %v", attrs)
+	}
+}
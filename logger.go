@@ -0,0 +1,7 @@
+package main
+
+import (
+	"github.com/automuteus/automuteus/v8/internal/logging"
+)
+
+var logger = logging.For("main")
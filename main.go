@@ -1,24 +1,28 @@
 package main
 
 import (
+	"context"
 	_ "embed"
 	"errors"
 	"fmt"
 	"github.com/automuteus/automuteus/v8/bot/command"
 	"github.com/automuteus/automuteus/v8/bot/tokenprovider"
+	"github.com/automuteus/automuteus/v8/internal/logging"
 	"github.com/automuteus/automuteus/v8/internal/server"
+	"github.com/automuteus/automuteus/v8/internal/tracing"
 	"github.com/automuteus/automuteus/v8/pkg/capture"
+	"github.com/automuteus/automuteus/v8/pkg/game"
 	"github.com/automuteus/automuteus/v8/pkg/locale"
 	storage2 "github.com/automuteus/automuteus/v8/pkg/storage"
 	"github.com/bwmarrin/discordgo"
 	"io"
-	"log"
 	"math/rand"
 	"os"
 	"os/signal"
 	"path"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -39,6 +43,17 @@ var postgresFileContents string
 const (
 	DefaultURL                   = "http://localhost:8123"
 	DefaultMaxRequests5Sec int64 = 7
+
+	// ShutdownUnmuteTimeout bounds how long shutdown waits on unmuting players
+	ShutdownUnmuteTimeout = time.Second * 10
+
+	// ShutdownTracingTimeout bounds how long shutdown waits on exporting the remaining spans
+	ShutdownTracingTimeout = time.Second * 5
+
+	// DefaultLogMaxSizeMB is how big logs.txt gets before it's rotated
+	DefaultLogMaxSizeMB = 100
+	// DefaultLogMaxAgeDays is how long rotated logs are kept
+	DefaultLogMaxAgeDays = 14
 )
 
 type registeredCommand struct {
@@ -49,10 +64,17 @@ type registeredCommand struct {
 func main() {
 	// seed the rand generator (used for making connection codes)
 	rand.Seed(time.Now().Unix())
-	err := discordMainWrapper()
+	var err error
+	switch {
+	case len(os.Args) > 1 && os.Args[1] == simulateCommand:
+		err = simulateMain(os.Args[2:])
+	case len(os.Args) > 1 && os.Args[1] == replayCommand:
+		err = replayMain(os.Args[2:])
+	default:
+		err = discordMainWrapper()
+	}
 	if err != nil {
-		log.Println("Program exited with the following error:")
-		log.Println(err)
+		logger.Error("Program exited with an error", err)
 		return
 	}
 }
@@ -69,24 +91,45 @@ func discordMainWrapper() error {
 		logPath = "./"
 	}
 
+	logLevel, err := logging.ParseLevel(os.Getenv("LOG_LEVEL"))
+	if err != nil {
+		return err
+	}
+	// like "bot=debug,tokenprovider=warn"
+	packageLevels, err := logging.ParsePackageLevels(os.Getenv("LOG_LEVELS"))
+	if err != nil {
+		return err
+	}
+	var logOutput io.Writer = os.Stdout
 	logEntry := os.Getenv("DISABLE_LOG_FILE")
 	if logEntry == "" {
-		file, err := os.Create(path.Join(logPath, "logs.txt"))
-		if err != nil {
-			return err
+		maxSizeMB := DefaultLogMaxSizeMB
+		if num, err := strconv.Atoi(os.Getenv("LOG_MAX_SIZE_MB")); err == nil && num > 0 {
+			maxSizeMB = num
 		}
-		mw := io.MultiWriter(os.Stdout, file)
-		log.SetOutput(mw)
+		maxAgeDays := DefaultLogMaxAgeDays
+		if num, err := strconv.Atoi(os.Getenv("LOG_MAX_AGE_DAYS")); err == nil && num > 0 {
+			maxAgeDays = num
+		}
+		// appended to across restarts, and rotated once it's too big
+		file := logging.RotatingFile(path.Join(logPath, "logs.txt"), maxSizeMB, maxAgeDays)
+		defer file.Close()
+		logOutput = io.MultiWriter(os.Stdout, file)
 	}
+	logging.Init(logOutput, logging.Config{
+		Format:        strings.ToLower(os.Getenv("LOG_FORMAT")),
+		Level:         logLevel,
+		PackageLevels: packageLevels,
+	})
 
 	emojiGuildID := os.Getenv("EMOJI_GUILD_ID")
 
-	log.Println(version + "-" + commit)
+	logger.Info("Starting AutoMuteUs", "version", version, "commit", commit)
 
 	numShardsStr := os.Getenv("NUM_SHARDS")
 	numShards, err := strconv.Atoi(numShardsStr)
 	if err != nil {
-		log.Println("No NUM_SHARDS specified; defaulting to 1")
+		logger.Info("No NUM_SHARDS specified; defaulting to 1")
 		numShards = 1
 	}
 
@@ -98,7 +141,7 @@ func discordMainWrapper() error {
 	var shards shards
 	shardsStr := os.Getenv("SHARDS")
 	if shardsStr == "" {
-		log.Println("No SHARDS specified, defaulting to 0")
+		logger.Info("No SHARDS specified; defaulting to 0")
 		shards = defaultShard()
 	} else {
 		shards, err = parseShards(shardsStr, numShards)
@@ -109,7 +152,7 @@ func discordMainWrapper() error {
 
 	url := os.Getenv("HOST")
 	if url == "" {
-		log.Printf("[Info] No valid HOST provided. Defaulting to %s\n", DefaultURL)
+		logger.Info("No valid HOST provided; using the default", "url", DefaultURL)
 		url = DefaultURL
 	}
 
@@ -125,7 +168,7 @@ func discordMainWrapper() error {
 			Password: redisPassword,
 		})
 		if err != nil {
-			log.Println(err)
+			logger.Error("Error initializing the Redis client", err)
 		}
 		err = storageInterface.Init(storage.RedisParameters{
 			Addr:     redisAddr,
@@ -133,7 +176,7 @@ func discordMainWrapper() error {
 			Password: redisPassword,
 		})
 		if err != nil {
-			log.Println(err)
+			logger.Error("Error initializing the Redis storage", err)
 		}
 	} else {
 		return errors.New("no REDIS_ADDR specified; exiting")
@@ -141,6 +184,14 @@ func discordMainWrapper() error {
 
 	locale.InitLang(os.Getenv("LOCALE_PATH"), os.Getenv("BOT_LANG"))
 
+	// modded lobbies can have roles the bot doesn't know about
+	if rolesPath := os.Getenv("CUSTOM_ROLES_PATH"); rolesPath != "" {
+		err := game.LoadRoles(rolesPath)
+		if err != nil {
+			return err
+		}
+	}
+
 	psql := storage2.PsqlInterface{}
 	pAddr := os.Getenv("POSTGRES_ADDR")
 	if pAddr == "" {
@@ -166,17 +217,24 @@ func discordMainWrapper() error {
 		go func() {
 			err := psql.ExecFromString(postgresFileContents)
 			if err != nil {
-				log.Println("Exiting with fatal error when attempting to execute postgres.sql:")
-				log.Fatal(err)
+				logger.Error("Exiting with a fatal error when executing postgres.sql", err)
+				os.Exit(1)
 			}
 		}()
 	}
 
-	log.Println("Bot is now running.  Press CTRL-C to exit.")
+	logger.Info("Bot is now running. Press CTRL-C to exit")
 	sc := make(chan os.Signal, 1)
 	signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM, os.Interrupt)
 
-	go server.StartHealthCheckServer("8080")
+	// the checks that have to pass for /ready to succeed; the rest are only reported
+	requiredChecks := server.DefaultRequiredChecks
+	requiredChecksStr := strings.ReplaceAll(os.Getenv("READY_REQUIRED_CHECKS"), " ", "")
+	if requiredChecksStr != "" {
+		requiredChecks = strings.Split(requiredChecksStr, ",")
+	}
+	healthChecker := server.NewHealthChecker(requiredChecks)
+	go server.StartHealthCheckServer("8080", healthChecker)
 
 	topGGToken := os.Getenv("TOP_GG_TOKEN")
 
@@ -185,7 +243,7 @@ func discordMainWrapper() error {
 	taskTimeoutmsStr := os.Getenv("ACK_TIMEOUT_MS")
 	num, err := strconv.ParseInt(taskTimeoutmsStr, 10, 64)
 	if err == nil {
-		log.Printf("Read from env; using ACK_TIMEOUT_MS=%d\n", num)
+		logger.Info("Read ACK_TIMEOUT_MS from env", "timeout_ms", num)
 		taskTimeoutms = time.Millisecond * time.Duration(num)
 	}
 
@@ -196,7 +254,28 @@ func discordMainWrapper() error {
 		maxReq = num
 	}
 
+	// tracing stays disabled (every span is a no-op) unless an OTLP endpoint is provided
+	if tracingEndpoint := os.Getenv("TRACING_OTLP_ENDPOINT"); tracingEndpoint != "" {
+		shutdownTracing, err := tracing.Init(context.Background(), tracingEndpoint, os.Getenv("TRACING_OTLP_INSECURE") != "")
+		if err != nil {
+			return err
+		}
+		logger.Info("Exporting traces", "endpoint", tracingEndpoint)
+		defer func() {
+			tracingCtx, cancel := context.WithTimeout(context.Background(), ShutdownTracingTimeout)
+			defer cancel()
+			err := shutdownTracing(tracingCtx)
+			if err != nil {
+				logger.Error("Error exporting the remaining spans", err)
+			}
+		}()
+	}
+
 	tokenProvider := tokenprovider.NewTokenProvider(nil, nil, taskTimeoutms, maxReq)
+	if os.Getenv("VOICE_LOG_POSTGRES") != "" {
+		logger.Info("Recording voice operations in Postgres as well as Redis")
+		tokenProvider.SetVoiceOpSink(psql.InsertVoiceOp)
+	}
 	var extraTokens []string
 	extraTokenStr := strings.ReplaceAll(os.Getenv("WORKER_BOT_TOKENS"), " ", "")
 	if extraTokenStr != "" {
@@ -207,16 +286,20 @@ func discordMainWrapper() error {
 	for i, shard := range shards {
 		bots[i] = bot.MakeAndStartBot(version, commit, discordToken, topGGToken, url, emojiGuildID, numShards, int(shard), &redisClient, &storageInterface, &psql, logPath)
 		if bots[i] == nil {
-			log.Fatalf("bot %d failed to initialize; did you provide a valid Discord Bot Token?", shard)
+			logger.Error("Bot failed to initialize; did you provide a valid Discord Bot Token?", nil, logging.ShardIDKey, shard)
+			os.Exit(1)
 		}
 	}
 
 	// initialize the token provider using the first shard's redis client and primary session
 	bots[0].InitTokenProvider(tokenProvider)
 	for i := 0; i < len(shards); i++ {
-		bots[i].TokenProvider = tokenProvider
+		bots[i].SetTokenProvider(tokenProvider)
 	}
 	tokenProvider.PopulateAndStartSessions(extraTokens)
+	for name, check := range bot.HealthChecks(bots, &psql) {
+		healthChecker.AddCheck(name, check)
+	}
 	// indicate to Kubernetes that we're ready to start receiving traffic
 	server.GlobalReady = true
 
@@ -237,14 +320,15 @@ func discordMainWrapper() error {
 		for _, guild := range slashCommandGuildIds {
 			for _, v := range command.All {
 				if guild == "" {
-					log.Printf("Registering command %s GLOBALLY\n", v.Name)
+					logger.Info("Registering command globally", "command", v.Name, logging.ShardIDKey, shards[0])
 				} else {
-					log.Printf("Registering command %s in guild %s\n", v.Name, guild)
+					logger.Info("Registering command", "command", v.Name, logging.GuildIDKey, guild, logging.ShardIDKey, shards[0])
 				}
 
 				id, err := bots[0].PrimarySession.ApplicationCommandCreate(bots[0].PrimarySession.State.User.ID, guild, v)
 				if err != nil {
-					log.Panicf("Cannot create command: %v", err)
+					logger.Error("Cannot create command", err, "command", v.Name, logging.GuildIDKey, guild, logging.ShardIDKey, shards[0])
+					panic(err)
 				} else {
 					registeredCommands = append(registeredCommands, registeredCommand{
 						GuildID:            guild,
@@ -253,28 +337,40 @@ func discordMainWrapper() error {
 				}
 			}
 		}
-		log.Println("Finishing registering all commands!")
+		logger.Info("Finished registering all commands", logging.ShardIDKey, shards[0])
 	}
 
 	<-sc
-	log.Printf("Received Sigterm or Kill signal. Bot will terminate in 1 second")
-	time.Sleep(time.Second)
+	logger.Info("Received Sigterm or Kill signal. Bot will terminate once running games are unmuted", "timeout", ShutdownUnmuteTimeout)
+
+	// nobody should be left muted just because the bot went away
+	unmuteCtx, cancel := context.WithTimeout(context.Background(), ShutdownUnmuteTimeout)
+	wg := sync.WaitGroup{}
+	for _, v := range bots {
+		wg.Add(1)
+		go func(b *bot.Bot) {
+			defer wg.Done()
+			b.UnmuteRunningGames(unmuteCtx)
+		}(v)
+	}
+	wg.Wait()
+	cancel()
 
 	// only delete the slash commands if we're not the official bot, AND we're the primary/"master" shard
 	if !isOfficial && shards.isPrimaryShard() {
-		log.Println("Deleting slash commands")
+		logger.Info("Deleting slash commands", logging.ShardIDKey, shards[0])
 		for _, v := range registeredCommands {
 			if v.GuildID == "" {
-				log.Printf("Deleting command %s GLOBALLY\n", v.ApplicationCommand.Name)
+				logger.Info("Deleting command globally", "command", v.ApplicationCommand.Name, logging.ShardIDKey, shards[0])
 			} else {
-				log.Printf("Deleting command %s on guild %s\n", v.ApplicationCommand.Name, v.GuildID)
+				logger.Info("Deleting command", "command", v.ApplicationCommand.Name, logging.GuildIDKey, v.GuildID, logging.ShardIDKey, shards[0])
 			}
 			err = bots[0].PrimarySession.ApplicationCommandDelete(v.ApplicationCommand.ApplicationID, v.GuildID, v.ApplicationCommand.ID)
 			if err != nil {
-				log.Println(err)
+				logger.Error("Error deleting command", err, "command", v.ApplicationCommand.Name, logging.GuildIDKey, v.GuildID, logging.ShardIDKey, shards[0])
 			}
 		}
-		log.Println("Finished deleting all commands")
+		logger.Info("Finished deleting all commands", logging.ShardIDKey, shards[0])
 	}
 
 	for _, v := range bots {
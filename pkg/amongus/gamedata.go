@@ -2,7 +2,6 @@ package amongus
 
 import (
 	"github.com/automuteus/automuteus/v8/pkg/game"
-	"log"
 	"strings"
 )
 
@@ -10,10 +9,15 @@ type GameData struct {
 	//indexed by amongusname
 	PlayerData map[string]PlayerData `json:"playerData"`
 
-	Phase  game.Phase   `json:"phase"`
-	Room   string       `json:"room"`
-	Region string       `json:"region"`
-	Map    game.PlayMap `json:"map"`
+	Phase  game.Phase    `json:"phase"`
+	Room   string        `json:"room"`
+	Region string        `json:"region"`
+	Map    game.PlayMap  `json:"map"`
+	Mode   game.GameMode `json:"mode"`
+	// Options is nil until the capture reports the lobby's options
+	Options *game.LobbyOptions `json:"options,omitempty"`
+	// TaskProgress is nil until the capture reports the crew's progress for the current game
+	TaskProgress *game.TaskProgress `json:"taskProgress,omitempty"`
 }
 
 func NewGameData() GameData {
@@ -23,6 +27,7 @@ func NewGameData() GameData {
 		Room:       "",
 		Region:     "",
 		Map:        game.EMPTYMAP,
+		Mode:       game.ClassicMode,
 	}
 }
 
@@ -32,6 +37,9 @@ func (auData *GameData) Reset() {
 	auData.Room = ""
 	auData.Region = ""
 	auData.Map = game.EMPTYMAP
+	auData.Mode = game.ClassicMode
+	auData.Options = nil
+	auData.TaskProgress = nil
 }
 
 func (auData *GameData) SetRoomRegionMap(room, region string, playMap game.PlayMap) {
@@ -40,6 +48,30 @@ func (auData *GameData) SetRoomRegionMap(room, region string, playMap game.PlayM
 	auData.Map = playMap
 }
 
+func (auData *GameData) SetMode(mode game.GameMode) {
+	auData.Mode = mode
+}
+
+func (auData *GameData) GetMode() game.GameMode {
+	return auData.Mode
+}
+
+func (auData *GameData) SetOptions(opts *game.LobbyOptions) {
+	auData.Options = opts
+}
+
+func (auData *GameData) GetOptions() *game.LobbyOptions {
+	return auData.Options
+}
+
+func (auData *GameData) SetTaskProgress(progress game.TaskProgress) {
+	auData.TaskProgress = &progress
+}
+
+func (auData *GameData) GetTaskProgress() *game.TaskProgress {
+	return auData.TaskProgress
+}
+
 func (auData *GameData) GetRoomRegionMap() (string, string, game.PlayMap) {
 	return auData.Room, auData.Region, auData.Map
 }
@@ -58,6 +90,10 @@ func (auData *GameData) UpdatePhase(phase game.Phase) (old game.Phase) {
 	if old != phase {
 		if phase == game.LOBBY || (phase == game.TASKS && old == game.LOBBY) {
 			auData.setAllAlive()
+			// the last game's progress is kept in the lobby, so it can still be shown after the game
+			if phase == game.TASKS {
+				auData.TaskProgress = nil
+			}
 		} else if phase == game.MENU {
 			auData.Reset()
 		}
@@ -101,7 +137,7 @@ func (auData *GameData) applyPlayerUpdate(update game.Player) (bool, bool, Playe
 			Name:    update.Name,
 			IsAlive: !update.IsDead,
 		}
-		log.Printf("Added new player instance for %s\n", update.Name)
+		logger.Debug("Added new player instance", "player", update.Name)
 		return true, false, auData.PlayerData[update.Name]
 	}
 	playerData := auData.PlayerData[update.Name]
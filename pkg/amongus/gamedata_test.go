@@ -36,3 +36,24 @@ func TestGameData_UpdatePhase(t *testing.T) {
 		t.Error("GameData was not reset properly when transitioning from TASKS->MENU")
 	}
 }
+
+func TestGameData_TaskProgress(t *testing.T) {
+	gd := NewGameData()
+	gd.UpdatePhase(game.LOBBY)
+	gd.UpdatePhase(game.TASKS)
+	gd.SetTaskProgress(game.TaskProgress{Completed: 5, Total: 20})
+	if p := gd.GetTaskProgress(); p == nil || p.Percent() != 25 {
+		t.Errorf("Expected 25%% task progress, got %+v", p)
+	}
+
+	gd.UpdatePhase(game.GAMEOVER)
+	gd.UpdatePhase(game.LOBBY)
+	if gd.GetTaskProgress() == nil {
+		t.Error("Task progress should be kept after the game is over")
+	}
+
+	gd.UpdatePhase(game.TASKS)
+	if gd.GetTaskProgress() != nil {
+		t.Error("Task progress was not cleared when a new game started")
+	}
+}
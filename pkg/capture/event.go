@@ -19,6 +19,10 @@ const (
 	State
 	Player
 	GameOver
+	Meeting
+	Vote
+	Kill
+	TaskProgress
 )
 
 type Event struct {
@@ -42,7 +46,7 @@ func PushEvent(ctx context.Context, redis *redis.Client, connCode string, jobTyp
 
 	// new list
 	if count < 2 {
-		// log.Printf("Set TTL for List")
+		// logger.Debug("Set TTL for List")
 		redis.Expire(ctx, rediskey.EventsNamespace+connCode, EventTTLSeconds*time.Second)
 	}
 
@@ -0,0 +1,25 @@
+package capture
+
+import (
+	"encoding/json"
+	"github.com/automuteus/automuteus/v8/pkg/task"
+)
+
+// ClientFrame is a message sent by a capture client connected directly to the bot over a WebSocket. Exactly one of
+// Job or TaskAck is set
+type ClientFrame struct {
+	// Job is an encoded task.Job; it's kept raw so a bad job can be rejected without dropping the whole frame
+	Job     json.RawMessage `json:"job,omitempty"`
+	TaskAck *TaskAck        `json:"taskAck,omitempty"`
+}
+
+// TaskAck tells the bot whether a capture client managed to complete a task.ModifyTask
+type TaskAck struct {
+	TaskID  string `json:"taskID"`
+	Success bool   `json:"success"`
+}
+
+// ServerFrame is a message sent by the bot to a capture client connected over a WebSocket
+type ServerFrame struct {
+	Task *task.ModifyTask `json:"task,omitempty"`
+}
@@ -28,6 +28,18 @@ func MakeDefaultDelays() GameDelays {
 	}
 }
 
+// MakeDefaultDelaysForMode returns the default delays for a game mode. Hide and Seek has a shorter intro than classic
+// games, and no meetings to transition to or from
+func MakeDefaultDelaysForMode(mode GameMode) GameDelays {
+	delays := MakeDefaultDelays()
+	if mode == HideAndSeekMode {
+		delays.Delays[PhaseNames[LOBBY]][PhaseNames[TASKS]] = 4
+		delays.Delays[PhaseNames[DISCUSS]][PhaseNames[LOBBY]] = 0
+		delays.Delays[PhaseNames[DISCUSS]][PhaseNames[TASKS]] = 0
+	}
+	return delays
+}
+
 func (gd *GameDelays) GetDelay(origin, dest Phase) int {
 	return gd.Delays[PhaseNames[origin]][PhaseNames[dest]]
 }
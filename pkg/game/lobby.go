@@ -1,7 +1,43 @@
 package game
 
+import "fmt"
+
+// MaxLobbySize is the most players a lobby can hold
+const MaxLobbySize = 15
+
 type Lobby struct {
 	LobbyCode string  `json:"LobbyCode"`
 	Region    Region  `json:"Region"`
 	PlayMap   PlayMap `json:"Map"`
+	// GameMode is omitted by capture clients that predate game modes, which only support classic lobbies
+	GameMode GameMode `json:"GameMode"`
+	// Options is omitted by capture clients that don't read the lobby's options
+	Options *LobbyOptions `json:"Options,omitempty"`
+}
+
+// LobbyOptions are the game options the lobby's host picked. Times are in seconds; a VotingTime of 0 means voting
+// isn't timed
+type LobbyOptions struct {
+	NumImpostors   int     `json:"NumImpostors"`
+	DiscussionTime int     `json:"DiscussionTime"`
+	VotingTime     int     `json:"VotingTime"`
+	KillCooldown   float64 `json:"KillCooldown"`
+	ConfirmEjects  bool    `json:"ConfirmEjects"`
+	PlayerSpeed    float64 `json:"PlayerSpeed"`
+}
+
+func (opts LobbyOptions) Validate() error {
+	switch {
+	case opts.NumImpostors < 0 || opts.NumImpostors > MaxLobbySize:
+		return fmt.Errorf("invalid impostor count %d", opts.NumImpostors)
+	case opts.DiscussionTime < 0:
+		return fmt.Errorf("invalid discussion time %d", opts.DiscussionTime)
+	case opts.VotingTime < 0:
+		return fmt.Errorf("invalid voting time %d", opts.VotingTime)
+	case opts.KillCooldown < 0:
+		return fmt.Errorf("invalid kill cooldown %v", opts.KillCooldown)
+	case opts.PlayerSpeed < 0:
+		return fmt.Errorf("invalid player speed %v", opts.PlayerSpeed)
+	}
+	return nil
 }
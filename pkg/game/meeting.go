@@ -0,0 +1,35 @@
+package game
+
+// Meeting is a meeting being called, either by a player pressing the emergency button or by reporting a body
+type Meeting struct {
+	Caller string `json:"Caller"`
+	// Reported is the name of the player whose body was reported, or empty if the emergency button was pressed
+	Reported string `json:"Reported,omitempty"`
+}
+
+func (m Meeting) IsReport() bool {
+	return m.Reported != ""
+}
+
+// Vote is a single vote cast in a meeting
+type Vote struct {
+	Voter string `json:"Voter"`
+	// Target is the name of the player voted for, or empty if the voter skipped (or didn't vote at all)
+	Target string `json:"Target,omitempty"`
+}
+
+func (v Vote) Skipped() bool {
+	return v.Target == ""
+}
+
+// VoteResult is how everyone voted when a meeting ended
+type VoteResult struct {
+	Votes []Vote `json:"Votes"`
+	// Exiled is the name of the player that was voted out, or empty if nobody was (a skip or a tie)
+	Exiled string `json:"Exiled,omitempty"`
+}
+
+// Exile is a player being voted out of a meeting. It's persisted on its own, apart from the votes that caused it
+type Exile struct {
+	Exiled string `json:"Exiled"`
+}
@@ -0,0 +1,39 @@
+package game
+
+import "strings"
+
+// GameMode is the mode a lobby is set to. Capture clients that predate game modes don't send one, so the zero value
+// is the classic mode
+type GameMode int
+
+const (
+	ClassicMode GameMode = iota
+	HideAndSeekMode
+)
+
+type GameModeNameString string
+
+var GameModeNames = map[GameMode]GameModeNameString{
+	ClassicMode:     "CLASSIC",
+	HideAndSeekMode: "HIDENSEEK",
+}
+
+func (mode GameMode) ToString() GameModeNameString {
+	return GameModeNames[mode]
+}
+
+// ValidGameMode returns whether the mode is one the bot knows about
+func ValidGameMode(mode GameMode) bool {
+	_, ok := GameModeNames[mode]
+	return ok
+}
+
+// GetGameModeFromString returns the game mode with the name, or false if there's none
+func GetGameModeFromString(input string) (GameMode, bool) {
+	for mode, name := range GameModeNames {
+		if strings.EqualFold(string(name), input) {
+			return mode, true
+		}
+	}
+	return ClassicMode, false
+}
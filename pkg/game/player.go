@@ -19,4 +19,13 @@ type Player struct {
 	Color        int          `json:"Color"`
 	IsDead       bool         `json:"IsDead"`
 	Disconnected bool         `json:"Disconnected"`
+	// Killer is the name of the impostor that killed the player. It's only set on DIED actions, by capture clients
+	// that can tell who the killer was
+	Killer string `json:"Killer,omitempty"`
+}
+
+// Kill attributes a death to the impostor that caused it
+type Kill struct {
+	Killer string `json:"Killer"`
+	Victim string `json:"Victim"`
 }
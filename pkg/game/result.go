@@ -27,11 +27,38 @@ type Gameover struct {
 type PlayerInfo struct {
 	Name       string `json:"Name"`
 	IsImpostor bool   `json:"IsImpostor"`
+	// Role is only sent by capture clients that know about roles
+	Role RoleIdentifier `json:"Role,omitempty"`
 }
 
-type GameRole int16
+// GameRole returns the player's role, falling back to a plain Crewmate or Impostor if the capture client didn't send
+// one, or sent one that isn't registered
+func (pi PlayerInfo) GameRole() GameRole {
+	if pi.Role != "" {
+		if r, ok := LookupRole(string(pi.Role)); ok {
+			return r.ID
+		}
+	}
+	if pi.IsImpostor {
+		return ImposterRole
+	}
+	return CrewmateRole
+}
 
-const (
-	CrewmateRole GameRole = iota
-	ImposterRole
-)
+// ImpostorWin returns whether the impostors won
+func (r GameResult) ImpostorWin() bool {
+	return r == ImpostorByKill || r == ImpostorByVote || r == ImpostorBySabotage || r == ImpostorDisconnect
+}
+
+// Won returns whether a player with the role won. Neutral roles have win conditions of their own, which capture
+// clients don't report, so they never count as having won
+func (r GameResult) Won(role GameRole) bool {
+	switch role.Team() {
+	case ImpostorTeam:
+		return r.ImpostorWin()
+	case CrewTeam:
+		return !r.ImpostorWin()
+	default:
+		return false
+	}
+}
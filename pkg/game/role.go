@@ -0,0 +1,216 @@
+package game
+
+import (
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// GameRole identifies a player's role. The built-in roles use the game's own role IDs, so capture clients can send
+// them as-is; modded roles are registered with IDs of their own (see LoadRoles)
+type GameRole int16
+
+const (
+	CrewmateRole GameRole = iota
+	ImposterRole
+	ScientistRole
+	EngineerRole
+	GuardianAngelRole
+	ShapeshifterRole
+	CrewmateGhostRole
+	ImpostorGhostRole
+	NoisemakerRole
+	PhantomRole
+	TrackerRole
+)
+
+// Team is the side a role plays for, which decides whether it won
+type Team int16
+
+const (
+	CrewTeam Team = iota
+	ImpostorTeam
+	NeutralTeam
+)
+
+var TeamNames = map[Team]string{
+	CrewTeam:     "crew",
+	ImpostorTeam: "impostor",
+	NeutralTeam:  "neutral",
+}
+
+type RoleInfo struct {
+	ID   GameRole `yaml:"id"`
+	Name string   `yaml:"name"`
+	Team Team     `yaml:"-"`
+}
+
+var (
+	rolesLock sync.RWMutex
+	roles     = map[GameRole]RoleInfo{}
+	// rolesByName is keyed by normalizeRoleName
+	rolesByName = map[string]GameRole{}
+)
+
+func init() {
+	for _, r := range []RoleInfo{
+		{CrewmateRole, "Crewmate", CrewTeam},
+		{ImposterRole, "Impostor", ImpostorTeam},
+		{ScientistRole, "Scientist", CrewTeam},
+		{EngineerRole, "Engineer", CrewTeam},
+		{GuardianAngelRole, "Guardian Angel", CrewTeam},
+		{ShapeshifterRole, "Shapeshifter", ImpostorTeam},
+		{CrewmateGhostRole, "Crewmate Ghost", CrewTeam},
+		{ImpostorGhostRole, "Impostor Ghost", ImpostorTeam},
+		{NoisemakerRole, "Noisemaker", CrewTeam},
+		{PhantomRole, "Phantom", ImpostorTeam},
+		{TrackerRole, "Tracker", CrewTeam},
+	} {
+		if err := RegisterRole(r); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func normalizeRoleName(name string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "", "_", "", "-", "").Replace(name))
+}
+
+// RegisterRole adds a role to the registry. Neither its ID nor its name can already be taken
+func RegisterRole(r RoleInfo) error {
+	if r.Name == "" {
+		return fmt.Errorf("role %d has no name", r.ID)
+	}
+	if _, ok := TeamNames[r.Team]; !ok {
+		return fmt.Errorf("role %s has an invalid team %d", r.Name, r.Team)
+	}
+	rolesLock.Lock()
+	defer rolesLock.Unlock()
+	if existing, ok := roles[r.ID]; ok {
+		return fmt.Errorf("role ID %d is already taken by %s", r.ID, existing.Name)
+	}
+	key := normalizeRoleName(r.Name)
+	if _, ok := rolesByName[key]; ok {
+		return fmt.Errorf("role name %s is already taken", r.Name)
+	}
+	roles[r.ID] = r
+	rolesByName[key] = r.ID
+	return nil
+}
+
+// LookupRole finds a role by the identifier a capture client sent for it; either its ID, or its name
+func LookupRole(identifier string) (RoleInfo, bool) {
+	rolesLock.RLock()
+	defer rolesLock.RUnlock()
+	if num, err := strconv.ParseInt(identifier, 10, 16); err == nil {
+		r, ok := roles[GameRole(num)]
+		return r, ok
+	}
+	id, ok := rolesByName[normalizeRoleName(identifier)]
+	if !ok {
+		return RoleInfo{}, false
+	}
+	return roles[id], true
+}
+
+// Info returns the registered role, or an unnamed crew role if it isn't registered (e.g. a modded role that was
+// since removed from the config)
+func (r GameRole) Info() RoleInfo {
+	rolesLock.RLock()
+	defer rolesLock.RUnlock()
+	if info, ok := roles[r]; ok {
+		return info
+	}
+	return RoleInfo{ID: r, Name: fmt.Sprintf("Role %d", r), Team: CrewTeam}
+}
+
+func (r GameRole) Team() Team {
+	return r.Info().Team
+}
+
+func (r GameRole) String() string {
+	return r.Info().Name
+}
+
+// RolesInTeam returns the IDs of every registered role that plays for the team, in order
+func RolesInTeam(team Team) []int16 {
+	rolesLock.RLock()
+	defer rolesLock.RUnlock()
+	var ids []int16
+	for id, r := range roles {
+		if r.Team == team {
+			ids = append(ids, int16(id))
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// RoleIdentifier is a role as sent by a capture client, either as the role's ID or its name
+type RoleIdentifier string
+
+func (id *RoleIdentifier) UnmarshalJSON(b []byte) error {
+	var num int64
+	if err := json.Unmarshal(b, &num); err == nil {
+		*id = RoleIdentifier(strconv.FormatInt(num, 10))
+		return nil
+	}
+	var str string
+	if err := json.Unmarshal(b, &str); err != nil {
+		return fmt.Errorf("role must be an ID or a name: %w", err)
+	}
+	*id = RoleIdentifier(str)
+	return nil
+}
+
+// roleConfig is the format of the file modded roles are registered with, e.g.
+//
+//	roles:
+//	  - id: 100
+//	    name: Jester
+//	    team: neutral
+type roleConfig struct {
+	Roles []struct {
+		RoleInfo `yaml:",inline"`
+		Team     string `yaml:"team"`
+	} `yaml:"roles"`
+}
+
+// LoadRoles registers the modded roles listed in a YAML (or JSON) file
+func LoadRoles(path string) error {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var config roleConfig
+	err = yaml.Unmarshal(contents, &config)
+	if err != nil {
+		return err
+	}
+	for _, r := range config.Roles {
+		team, ok := teamFromName(r.Team)
+		if !ok {
+			return fmt.Errorf("role %s has an unknown team %s", r.Name, r.Team)
+		}
+		r.RoleInfo.Team = team
+		err = RegisterRole(r.RoleInfo)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func teamFromName(name string) (Team, bool) {
+	for team, n := range TeamNames {
+		if strings.EqualFold(n, name) {
+			return team, true
+		}
+	}
+	return 0, false
+}
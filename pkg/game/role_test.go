@@ -0,0 +1,76 @@
+package game
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLookupRole(t *testing.T) {
+	tests := map[string]GameRole{
+		"0":              CrewmateRole,
+		"5":              ShapeshifterRole,
+		"Engineer":       EngineerRole,
+		"guardian angel": GuardianAngelRole,
+		"GUARDIAN_ANGEL": GuardianAngelRole,
+	}
+	for identifier, expected := range tests {
+		r, ok := LookupRole(identifier)
+		if !ok || r.ID != expected {
+			t.Errorf("Expected %s to be role %d, got %d (found %v)", identifier, expected, r.ID, ok)
+		}
+	}
+	if _, ok := LookupRole("Jester"); ok {
+		t.Error("Expected an unregistered role not to be found")
+	}
+}
+
+func TestPlayerInfo_GameRole(t *testing.T) {
+	var gameOver Gameover
+	err := json.Unmarshal([]byte(`{"GameOverReason":3,"PlayerInfos":[`+
+		`{"Name":"a","IsImpostor":true,"Role":"Phantom"},`+
+		`{"Name":"b","IsImpostor":false,"Role":3},`+
+		`{"Name":"c","IsImpostor":true},`+
+		`{"Name":"d","IsImpostor":false,"Role":"Unknown Mod Role"}]}`), &gameOver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []GameRole{PhantomRole, EngineerRole, ImposterRole, CrewmateRole}
+	for i, pi := range gameOver.PlayerInfos {
+		if pi.GameRole() != expected[i] {
+			t.Errorf("Expected %s to be %s, got %s", pi.Name, expected[i], pi.GameRole())
+		}
+	}
+	if !gameOver.GameOverReason.Won(PhantomRole) || gameOver.GameOverReason.Won(EngineerRole) {
+		t.Error("Expected the impostor team to win an ImpostorByKill game")
+	}
+}
+
+func TestLoadRoles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "roles.yaml")
+	err := os.WriteFile(path, []byte("roles:\n  - id: 100\n    name: Jester\n    team: neutral\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = LoadRoles(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, ok := LookupRole("jester")
+	if !ok || r.ID != 100 || r.Team != NeutralTeam {
+		t.Errorf("Expected Jester to be registered as a neutral role, got %+v", r)
+	}
+	if HumansByTask.Won(r.ID) || ImpostorByVote.Won(r.ID) {
+		t.Error("Expected a neutral role to never win")
+	}
+	neutral := RolesInTeam(NeutralTeam)
+	if len(neutral) != 1 || GameRole(neutral[0]) != r.ID {
+		t.Errorf("Expected Jester to be the only neutral role, got %v", neutral)
+	}
+
+	err = LoadRoles(path)
+	if err == nil {
+		t.Error("Expected registering the same role twice to fail")
+	}
+}
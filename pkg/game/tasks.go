@@ -0,0 +1,27 @@
+package game
+
+import "fmt"
+
+// TaskProgress is the crew's progress on the global task bar
+type TaskProgress struct {
+	Completed int `json:"Completed"`
+	Total     int `json:"Total"`
+}
+
+func (p TaskProgress) Validate() error {
+	if p.Total <= 0 {
+		return fmt.Errorf("invalid task total %d", p.Total)
+	}
+	if p.Completed < 0 || p.Completed > p.Total {
+		return fmt.Errorf("invalid completed tasks %d out of %d", p.Completed, p.Total)
+	}
+	return nil
+}
+
+// Percent is the percentage of the task bar that's filled, rounded down
+func (p TaskProgress) Percent() int {
+	if p.Total <= 0 {
+		return 0
+	}
+	return 100 * p.Completed / p.Total
+}
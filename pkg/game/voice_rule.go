@@ -1,5 +1,9 @@
 package game
 
+// SpectatorRules is the column of the voice rules for spectators. Rules saved before spectators had their own column
+// don't have it, so spectators follow the rules for dead players until it's set
+const SpectatorRules = "spectator"
+
 type VoiceRules struct {
 	MuteRules map[PhaseNameString]map[string]bool
 	DeafRules map[PhaseNameString]map[string]bool
@@ -18,34 +22,63 @@ func (rules *VoiceRules) GetVoiceState(isAlive, isTracked bool, phase Phase) (bo
 	return rules.MuteRules[phaseStr][aliveStr], rules.DeafRules[phaseStr][aliveStr]
 }
 
+// GetSpectatorVoiceState is GetVoiceState for spectators, who aren't alive or dead
+func (rules *VoiceRules) GetSpectatorVoiceState(isTracked bool, phase Phase) (bool, bool) {
+	if !isTracked {
+		return false, false
+	}
+	return rules.Get(true, phase, SpectatorRules), rules.Get(false, phase, SpectatorRules)
+}
+
+// Get returns whether players in the column ("alive", "dead" or SpectatorRules) are muted (or deafened) in the phase
+func (rules *VoiceRules) Get(isMute bool, phase Phase, column string) bool {
+	byColumn := rules.DeafRules[PhaseNames[phase]]
+	if isMute {
+		byColumn = rules.MuteRules[PhaseNames[phase]]
+	}
+	if v, ok := byColumn[column]; ok || column != SpectatorRules {
+		return v
+	}
+	return byColumn["dead"]
+}
+
+// MakeMuteAndDeafenRules returns the default rules, which are the same for every game mode. Hide and Seek games never
+// have a discussion, and otherwise play like the tasks phase of a classic game: hiders are muted and deafened while
+// they're hunted, and caught hiders can talk amongst themselves
 func MakeMuteAndDeafenRules() VoiceRules {
 	rules := VoiceRules{
 		MuteRules: map[PhaseNameString]map[string]bool{
 			PhaseNames[LOBBY]: {
-				"alive": false,
-				"dead":  false,
+				"alive":        false,
+				"dead":         false,
+				SpectatorRules: false,
 			},
 			PhaseNames[TASKS]: {
-				"alive": true,
-				"dead":  false,
+				"alive":        true,
+				"dead":         false,
+				SpectatorRules: false,
 			},
 			PhaseNames[DISCUSS]: {
-				"alive": false,
-				"dead":  true,
+				"alive":        false,
+				"dead":         true,
+				SpectatorRules: true,
 			},
 		},
 		DeafRules: map[PhaseNameString]map[string]bool{
 			PhaseNames[LOBBY]: {
-				"alive": false,
-				"dead":  false,
+				"alive":        false,
+				"dead":         false,
+				SpectatorRules: false,
 			},
 			PhaseNames[TASKS]: {
-				"alive": true,
-				"dead":  false,
+				"alive":        true,
+				"dead":         false,
+				SpectatorRules: false,
 			},
 			PhaseNames[DISCUSS]: {
-				"alive": false,
-				"dead":  false,
+				"alive":        false,
+				"dead":         false,
+				SpectatorRules: false,
 			},
 		},
 	}
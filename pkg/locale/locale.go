@@ -1,7 +1,6 @@
 package locale
 
 import (
-	"log"
 	"os"
 	"path"
 	"regexp"
@@ -57,7 +56,7 @@ func LoadTranslations(localePath, defaultLang string) *i18n.Bundle {
 				fileLang := match[re.SubexpIndex("lang")]
 
 				if _, err := bundle.LoadMessageFile(path.Join(localePath, file.Name())); err != nil {
-					log.Println(err)
+					logger.Error("Error loading locale file", err, "file", file.Name())
 				} else {
 					langName, _ := i18n.NewLocalizer(bundle, fileLang).Localize(&i18n.LocalizeConfig{
 						DefaultMessage: &i18n.Message{
@@ -67,7 +66,7 @@ func LoadTranslations(localePath, defaultLang string) *i18n.Bundle {
 					})
 					localeLanguages[fileLang /* msgFile.Tag.String() */] = langName
 
-					log.Printf("[Locale] Loaded language: %s - %s", fileLang, langName)
+					logger.Info("Loaded language", "lang", fileLang, "name", langName)
 				}
 			}
 		}
@@ -128,10 +127,10 @@ func LocalizeMessage(args ...interface{}) string {
 
 	// fix go-i18n extract
 	msg = strings.ReplaceAll(msg, "\\n", "\n")
-	// log.Printf("[Locale] (%s) %s", lang, msg)
+	// logger.Debug("Localized message", "lang", lang, "message", msg)
 
 	if err != nil {
-		log.Printf("[Locale] Warning: %s", err)
+		logger.Warn("Error localizing message", "error", err, "lang", lang, "message_id", message.ID)
 	}
 
 	return msg
@@ -0,0 +1,123 @@
+package namematch
+
+import (
+	"golang.org/x/text/unicode/norm"
+	"strings"
+	"unicode"
+)
+
+// DefaultThreshold is the lowest score a name can match a player with, for the player to be linked automatically
+const DefaultThreshold = 0.8
+
+// minPrefixLength is how long a name has to be before it's matched as a prefix of a player's name (or the other way
+// around); shorter names would match far too many players
+const minPrefixLength = 3
+
+// a prefix scores more the more of the longer name it covers. A prefix has to cover at least half of the longer name
+// to reach DefaultThreshold, so "kur" isn't enough to link "kurokobo"
+const (
+	minPrefixScore   = 0.65
+	prefixScoreRange = 0.3
+)
+
+// MinMargin is how much better the best candidate has to match a player than the runner-up, for the player to be
+// linked automatically; otherwise there's no telling which of them is playing
+const MinMargin = 0.1
+
+// Candidate is a user who could be linked to a player, along with every name they might be playing under
+type Candidate struct {
+	UserID string
+	Names  []string
+}
+
+// Normalize reduces a name to what's compared when matching: accents are stripped, compatibility characters (like
+// fullwidth letters) are folded, everything is lowercased, and anything that isn't a letter or digit (spaces,
+// punctuation, emoji) is dropped
+func Normalize(name string) string {
+	var b strings.Builder
+	for _, r := range norm.NFKD.String(name) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			// the combining marks left over from decomposing accented letters
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return b.String()
+}
+
+// Score is how confident we are that name and playerName refer to the same person, from 0 to 1. Identical names
+// (once normalized) score 1, a name that's a prefix of the other scores less the more of the other name it's missing,
+// and otherwise the score drops with the edit distance between them
+func Score(name, playerName string) float64 {
+	a, b := []rune(Normalize(name)), []rune(Normalize(playerName))
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	if string(a) == string(b) {
+		return 1
+	}
+	short, long := a, b
+	if len(short) > len(long) {
+		short, long = long, short
+	}
+	if len(short) >= minPrefixLength && strings.HasPrefix(string(long), string(short)) {
+		// "kuro" is likely "kurokobo", but the more of the name is missing, the less sure we are
+		return minPrefixScore + prefixScoreRange*float64(len(short))/float64(len(long))
+	}
+	return 1 - float64(levenshtein(a, b))/float64(len(long))
+}
+
+// Best returns the candidate whose names best match playerName, and the score they matched with. If no candidate
+// scores at least threshold, or the best candidate doesn't beat the runner-up by at least MinMargin, there's no match,
+// and the returned userID is empty
+func Best(playerName string, candidates []Candidate, threshold float64) (userID string, score float64) {
+	runnerUp := 0.0
+	for _, c := range candidates {
+		best := 0.0
+		for _, name := range c.Names {
+			if s := Score(name, playerName); s > best {
+				best = s
+			}
+		}
+		if best > score {
+			userID, score, runnerUp = c.UserID, best, score
+		} else if best > runnerUp {
+			runnerUp = best
+		}
+	}
+	if score < threshold || score-runnerUp < MinMargin {
+		return "", score
+	}
+	return userID, score
+}
+
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func min(vals ...int) int {
+	m := vals[0]
+	for _, v := range vals[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
@@ -0,0 +1,98 @@
+package namematch
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := map[string]string{
+		"Kuro Kobo": "kurokobo",
+		"🔥Kuro🔥":    "kuro",
+		"Ｋｕｒｏ":      "kuro",
+		"Zoë":       "zoe",
+		"x_X.x":     "xxx",
+		"🔥":         "",
+	}
+	for in, expected := range tests {
+		if out := Normalize(in); out != expected {
+			t.Errorf("Expected %q to normalize to %q, got %q", in, expected, out)
+		}
+	}
+}
+
+func TestScore(t *testing.T) {
+	if s := Score("🔥 Kuro", "kuro"); s != 1 {
+		t.Errorf("Expected names that normalize to the same thing to score 1, got %f", s)
+	}
+	if s := Score("Kuro", "kurokobo"); s < DefaultThreshold || s >= 1 {
+		t.Errorf("Expected a prefix to match, but not perfectly, got %f", s)
+	}
+	if s := Score("Ku", "kurokobo"); s >= DefaultThreshold {
+		t.Errorf("Expected a very short prefix not to match, got %f", s)
+	}
+	if s := Score("Kur", "kurokobo"); s >= DefaultThreshold {
+		t.Errorf("Expected a prefix covering less than half the name not to match, got %f", s)
+	}
+	if s := Score("Sam", "Samantha"); s >= DefaultThreshold {
+		t.Errorf("Expected a short name not to match every longer name starting with it, got %f", s)
+	}
+	if s, longer := Score("Kurok", "kurokobo"), Score("Kuroko", "kurokobo"); s >= longer {
+		t.Errorf("Expected a longer prefix to score more, got %f and %f", s, longer)
+	}
+	if s := Score("kurokobo", "kurokoba"); s < DefaultThreshold {
+		t.Errorf("Expected a single typo to match, got %f", s)
+	}
+	if s := Score("Bob", "Rob"); s >= DefaultThreshold {
+		t.Errorf("Expected short names a letter apart not to match, got %f", s)
+	}
+	if s := Score("🔥", "🔥"); s != 0 {
+		t.Errorf("Expected names with nothing to compare to score 0, got %f", s)
+	}
+}
+
+func TestBest(t *testing.T) {
+	candidates := []Candidate{
+		{UserID: "1", Names: []string{"soup", "Soupy"}},
+		{UserID: "2", Names: []string{"kurokobo"}},
+		{UserID: "3", Names: []string{"Denver"}},
+	}
+	if userID, _ := Best("Kuro", candidates, DefaultThreshold); userID != "2" {
+		t.Errorf("Expected Kuro to match user 2, got %q", userID)
+	}
+	if userID, _ := Best("Soupy", candidates, DefaultThreshold); userID != "1" {
+		t.Errorf("Expected Soupy to match user 1, got %q", userID)
+	}
+	if userID, _ := Best("Alice", candidates, DefaultThreshold); userID != "" {
+		t.Errorf("Expected Alice not to match anyone, got %q", userID)
+	}
+
+	candidates = append(candidates, Candidate{UserID: "4", Names: []string{"Soupy"}})
+	if userID, _ := Best("Soupy", candidates, DefaultThreshold); userID != "" {
+		t.Errorf("Expected an ambiguous match not to match anyone, got %q", userID)
+	}
+}
+
+func TestBest_Margin(t *testing.T) {
+	candidates := []Candidate{
+		{UserID: "1", Names: []string{"kurokoba"}},
+		{UserID: "2", Names: []string{"kurokobo2"}},
+	}
+	// both are close, and neither is close enough to beat the other by the margin
+	if userID, _ := Best("Kurokobo", candidates, DefaultThreshold); userID != "" {
+		t.Errorf("Expected two similar candidates not to match anyone, got %q", userID)
+	}
+
+	candidates = []Candidate{
+		{UserID: "1", Names: []string{"Sam"}},
+		{UserID: "2", Names: []string{"Samuel"}},
+	}
+	if userID, _ := Best("Samantha", candidates, DefaultThreshold); userID != "" {
+		t.Errorf("Expected Samantha not to match Sam or Samuel, got %q", userID)
+	}
+
+	candidates = []Candidate{
+		{UserID: "1", Names: []string{"Alicia"}},
+		{UserID: "2", Names: []string{"alice1"}},
+	}
+	if userID, _ := Best("Alice", candidates, DefaultThreshold); userID != "2" {
+		t.Errorf("Expected Alice to match user 2 well ahead of user 1, got %q", userID)
+	}
+}
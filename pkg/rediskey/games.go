@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"github.com/go-redis/redis/v8"
 	"github.com/jackc/pgx/v4/pgxpool"
-	"log"
 	"time"
 )
 
@@ -24,7 +23,7 @@ func GetActiveGames(ctx context.Context, client *redis.Client, secs int64) int64
 	before := now.Add(-(time.Second * time.Duration(secs)))
 	count, err := client.ZCount(ctx, ActiveGamesZSet, fmt.Sprintf("%d", before.Unix()), fmt.Sprintf("%d", now.Unix())).Result()
 	if err != nil {
-		log.Println(err)
+		logger.Error("Error counting active games", err)
 		return 0
 	}
 	return count
@@ -35,7 +34,7 @@ func RefreshTotalGames(ctx context.Context, client *redis.Client, pool *pgxpool.
 	if v != NotFound {
 		err := client.Set(ctx, TotalGames, v, TotalGameExpiration).Err()
 		if err != nil {
-			log.Println(err)
+			logger.Error("Error caching total games", err)
 		}
 	}
 	return v
@@ -8,6 +8,8 @@ const JobNamespace = "automuteus:jobs:"
 const TotalUsers = "automuteus:users:total"
 const TotalGames = "automuteus:games:total"
 
+const CaptureTokenSecret = "automuteus:capture:token:secret"
+
 func ActiveGamesForGuild(guildID string) string {
 	return "automuteus:discord:" + guildID + ":games:set"
 }
@@ -32,6 +34,10 @@ func GuildCacheHash(guildID string) string {
 	return "automuteus:discord:" + guildID + ":cache"
 }
 
+func VoiceLog(guildID string) string {
+	return "automuteus:discord:" + guildID + ":voice:log"
+}
+
 func SnowflakeLockID(snowflake string) string {
 	return "automuteus:snowflake:" + snowflake + ":lock"
 }
@@ -48,6 +54,31 @@ func CompleteTask(taskID string) string {
 	return "automuteus:tasks:complete:ack:" + taskID
 }
 
+func GameStateLockedNotice(connectCode string) string {
+	return "automuteus:game:" + connectCode + ":locked:notice"
+}
+
+func JobStream(connectCode string) string {
+	return JobNamespace + connectCode + ":stream"
+}
+
+// JobRecording is scoped to the guild, so a guild can only ever read back recordings of its own games
+func JobRecording(guildID, connectCode string) string {
+	return "automuteus:discord:" + guildID + ":recording:" + connectCode
+}
+
+func JobNextSequence(connectCode string) string {
+	return JobNamespace + connectCode + ":seq:next"
+}
+
+func JobLastSequence(connectCode string) string {
+	return JobNamespace + connectCode + ":seq:last"
+}
+
+func CapturePrimarySource(connectCode string) string {
+	return "automuteus:capture:" + connectCode + ":primary"
+}
+
 func TasksList(connectCode string) string {
 	return "automuteus:tasks:list:" + connectCode
 }
@@ -3,9 +3,9 @@ package rediskey
 import (
 	"context"
 	"errors"
+	"github.com/automuteus/automuteus/v8/internal/logging"
 	"github.com/go-redis/redis/v8"
 	"github.com/jackc/pgx/v4/pgxpool"
-	"log"
 	"time"
 )
 
@@ -26,7 +26,7 @@ func RefreshTotalUsers(ctx context.Context, client *redis.Client, pool *pgxpool.
 	if v != NotFound {
 		err := client.Set(ctx, TotalUsers, v, TotalUsersExpiration).Err()
 		if err != nil {
-			log.Println(err)
+			logger.Error("Error caching total users", err)
 		}
 	}
 	return v
@@ -38,7 +38,7 @@ func GetCachedUserInfo(ctx context.Context, client *redis.Client, userID, guildI
 		return ""
 	}
 	if err != nil {
-		log.Println(err)
+		logger.Error("Error fetching cached user info", err, "user_id", userID, logging.GuildIDKey, guildID)
 		return ""
 	}
 	return user
@@ -10,6 +10,12 @@ import (
 const DefaultLeaderboardSize = 3
 const DefaultLeaderboardMin = 3
 
+// the voice strategies: players are either muted/deafened according to the voice rules, or moved between channels
+const (
+	MuteStrategy = "mute"
+	MoveStrategy = "move"
+)
+
 type GuildSettings struct {
 	AdminUserIDs             []string        `json:"adminIDs"`
 	PermissionRoleIDs        []string        `json:"permissionRoleIDs"`
@@ -27,6 +33,16 @@ type GuildSettings struct {
 	LeaderboardMin           int    `json:"leaderboardMin"`
 	MuteSpectator            bool   `json:"muteSpectator"`
 	DisplayRoomCode          string `json:"displayRoomCode"`
+	ShowTaskProgress         bool   `json:"showTaskProgress"`
+	SpectatorRoleID          string `json:"spectatorRoleID"`
+	VoiceStrategy            string `json:"voiceStrategy"`
+	GhostChannelID           string `json:"ghostChannelID"`
+	TasksChannelID           string `json:"tasksChannelID"`
+
+	// ModeVoiceRules and ModeDelays are the rules and delays for game modes other than classic, which use VoiceRules
+	// and Delays. Modes that haven't been customized use the mode's defaults
+	ModeVoiceRules map[game.GameMode]game.VoiceRules `json:"modeVoiceRules,omitempty"`
+	ModeDelays     map[game.GameMode]game.GameDelays `json:"modeDelays,omitempty"`
 }
 
 func MakeGuildSettings() *GuildSettings {
@@ -46,6 +62,13 @@ func MakeGuildSettings() *GuildSettings {
 		LeaderboardMin:           DefaultLeaderboardMin,
 		MuteSpectator:            false,
 		DisplayRoomCode:          "always",
+		ShowTaskProgress:         false,
+		SpectatorRoleID:          "",
+		VoiceStrategy:            MuteStrategy,
+		GhostChannelID:           "",
+		TasksChannelID:           "",
+		ModeVoiceRules:           map[game.GameMode]game.VoiceRules{},
+		ModeDelays:               map[game.GameMode]game.GameDelays{},
 		lock:                     sync.RWMutex{},
 	}
 }
@@ -161,6 +184,62 @@ func (gs *GuildSettings) SetMuteSpectator(behavior bool) {
 	gs.MuteSpectator = behavior
 }
 
+func (gs *GuildSettings) GetShowTaskProgress() bool {
+	return gs.ShowTaskProgress
+}
+
+func (gs *GuildSettings) SetShowTaskProgress(v bool) {
+	gs.ShowTaskProgress = v
+}
+
+func (gs *GuildSettings) GetSpectatorRoleID() string {
+	return gs.SpectatorRoleID
+}
+
+func (gs *GuildSettings) SetSpectatorRoleID(id string) {
+	gs.SpectatorRoleID = id
+}
+
+// HasSpectatorRole reports whether the member has the guild's spectator role, if it has one
+func (gs *GuildSettings) HasSpectatorRole(mem *discordgo.Member) bool {
+	if mem == nil || gs.SpectatorRoleID == "" {
+		return false
+	}
+	for _, role := range mem.Roles {
+		if role == gs.SpectatorRoleID {
+			return true
+		}
+	}
+	return false
+}
+
+func (gs *GuildSettings) GetVoiceStrategy() string {
+	if gs.VoiceStrategy == "" {
+		return MuteStrategy
+	}
+	return gs.VoiceStrategy
+}
+
+func (gs *GuildSettings) SetVoiceStrategy(strategy string) {
+	gs.VoiceStrategy = strategy
+}
+
+func (gs *GuildSettings) GetGhostChannelID() string {
+	return gs.GhostChannelID
+}
+
+func (gs *GuildSettings) SetGhostChannelID(id string) {
+	gs.GhostChannelID = id
+}
+
+func (gs *GuildSettings) GetTasksChannelID() string {
+	return gs.TasksChannelID
+}
+
+func (gs *GuildSettings) SetTasksChannelID(id string) {
+	gs.TasksChannelID = id
+}
+
 func (gs *GuildSettings) GetMapDetailed() bool {
 	return gs.MapVersion == "detailed"
 }
@@ -185,31 +264,76 @@ func (gs *GuildSettings) SetLanguage(l string) {
 	gs.Language = l
 }
 
-func (gs *GuildSettings) GetDelay(oldPhase, newPhase game.Phase) int {
-	return gs.Delays.GetDelay(oldPhase, newPhase)
+// GetDelays returns the delays for a game mode. Modes other than classic use their defaults until one of their delays
+// is changed with SetDelay, so the defaults aren't saved with the settings
+func (gs *GuildSettings) GetDelays(mode game.GameMode) game.GameDelays {
+	if mode == game.ClassicMode {
+		return gs.Delays
+	}
+	if delays, ok := gs.ModeDelays[mode]; ok {
+		return delays
+	}
+	return game.MakeDefaultDelaysForMode(mode)
 }
 
-func (gs *GuildSettings) SetDelay(oldPhase, newPhase game.Phase, v int) {
-	gs.Delays.Delays[oldPhase.ToString()][newPhase.ToString()] = v
+func (gs *GuildSettings) GetDelay(mode game.GameMode, oldPhase, newPhase game.Phase) int {
+	delays := gs.GetDelays(mode)
+	return delays.GetDelay(oldPhase, newPhase)
 }
 
-func (gs *GuildSettings) GetVoiceRule(isMute bool, phase game.Phase, alive string) bool {
-	if isMute {
-		return gs.VoiceRules.MuteRules[phase.ToString()][alive]
+func (gs *GuildSettings) SetDelay(mode game.GameMode, oldPhase, newPhase game.Phase, v int) {
+	delays := gs.GetDelays(mode)
+	delays.Delays[oldPhase.ToString()][newPhase.ToString()] = v
+	if mode == game.ClassicMode {
+		return
 	}
-	return gs.VoiceRules.DeafRules[phase.ToString()][alive]
+	if gs.ModeDelays == nil {
+		gs.ModeDelays = map[game.GameMode]game.GameDelays{}
+	}
+	gs.ModeDelays[mode] = delays
+}
+
+// GetVoiceRules returns the voice rules for a game mode. Like GetDelays, modes other than classic use the default
+// rules until one of them is changed with SetVoiceRule
+func (gs *GuildSettings) GetVoiceRules(mode game.GameMode) game.VoiceRules {
+	if mode == game.ClassicMode {
+		return gs.VoiceRules
+	}
+	if rules, ok := gs.ModeVoiceRules[mode]; ok {
+		return rules
+	}
+	return game.MakeMuteAndDeafenRules()
 }
 
-func (gs *GuildSettings) SetVoiceRule(isMute bool, phase game.Phase, alive string, val bool) {
+func (gs *GuildSettings) GetVoiceRule(mode game.GameMode, isMute bool, phase game.Phase, alive string) bool {
+	rules := gs.GetVoiceRules(mode)
+	return rules.Get(isMute, phase, alive)
+}
+
+func (gs *GuildSettings) SetVoiceRule(mode game.GameMode, isMute bool, phase game.Phase, alive string, val bool) {
+	rules := gs.GetVoiceRules(mode)
 	if isMute {
-		gs.VoiceRules.MuteRules[phase.ToString()][alive] = val
+		rules.MuteRules[phase.ToString()][alive] = val
 	} else {
-		gs.VoiceRules.DeafRules[phase.ToString()][alive] = val
+		rules.DeafRules[phase.ToString()][alive] = val
+	}
+	if mode == game.ClassicMode {
+		return
+	}
+	if gs.ModeVoiceRules == nil {
+		gs.ModeVoiceRules = map[game.GameMode]game.VoiceRules{}
 	}
+	gs.ModeVoiceRules[mode] = rules
+}
+
+func (gs *GuildSettings) GetVoiceState(mode game.GameMode, alive bool, tracked bool, phase game.Phase) (bool, bool) {
+	rules := gs.GetVoiceRules(mode)
+	return rules.GetVoiceState(alive, tracked, phase)
 }
 
-func (gs *GuildSettings) GetVoiceState(alive bool, tracked bool, phase game.Phase) (bool, bool) {
-	return gs.VoiceRules.GetVoiceState(alive, tracked, phase)
+func (gs *GuildSettings) GetSpectatorVoiceState(mode game.GameMode, tracked bool, phase game.Phase) (bool, bool) {
+	rules := gs.GetVoiceRules(mode)
+	return rules.GetSpectatorVoiceState(tracked, phase)
 }
 
 func (gs *GuildSettings) GetDisplayRoomCode() string {
@@ -0,0 +1,39 @@
+package settings
+
+import (
+	"github.com/automuteus/automuteus/v8/pkg/game"
+	"testing"
+)
+
+func TestGuildSettings_ModeDefaultsNotSaved(t *testing.T) {
+	gs := MakeGuildSettings()
+	if gs.GetDelay(game.HideAndSeekMode, game.LOBBY, game.TASKS) != 4 {
+		t.Error("Expected the default Hide and Seek delay from the lobby to tasks")
+	}
+	if !gs.GetVoiceRule(game.HideAndSeekMode, true, game.TASKS, "alive") {
+		t.Error("Expected living hiders to be muted during tasks by default")
+	}
+	if len(gs.ModeDelays) != 0 || len(gs.ModeVoiceRules) != 0 {
+		t.Errorf("Expected reading the defaults not to save them, got %v and %v", gs.ModeDelays, gs.ModeVoiceRules)
+	}
+}
+
+func TestGuildSettings_SetModeRules(t *testing.T) {
+	gs := MakeGuildSettings()
+	gs.SetDelay(game.HideAndSeekMode, game.LOBBY, game.TASKS, 2)
+	gs.SetVoiceRule(game.HideAndSeekMode, false, game.TASKS, "alive", false)
+
+	if gs.GetDelay(game.HideAndSeekMode, game.LOBBY, game.TASKS) != 2 {
+		t.Error("Expected the Hide and Seek delay to be changed")
+	}
+	if gs.GetVoiceRule(game.HideAndSeekMode, false, game.TASKS, "alive") {
+		t.Error("Expected living hiders not to be deafened during tasks")
+	}
+	// the rest of the mode's rules are still the defaults
+	if gs.GetDelay(game.HideAndSeekMode, game.TASKS, game.LOBBY) != 1 || !gs.GetVoiceRule(game.HideAndSeekMode, true, game.TASKS, "alive") {
+		t.Error("Expected the other Hide and Seek rules to be unchanged")
+	}
+	if gs.GetDelay(game.ClassicMode, game.LOBBY, game.TASKS) != 7 || !gs.GetVoiceRule(game.ClassicMode, false, game.TASKS, "alive") {
+		t.Error("Expected the classic rules to be unchanged")
+	}
+}
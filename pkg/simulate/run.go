@@ -0,0 +1,48 @@
+package simulate
+
+import (
+	"context"
+	"github.com/automuteus/automuteus/v8/internal/logging"
+	"github.com/automuteus/automuteus/v8/pkg/task"
+	"github.com/go-redis/redis/v8"
+	"time"
+)
+
+// Run pushes every step of the script to the connect code's job queue, waiting out each step's delay first.
+// Delays are divided by speed, so a speed of 2 replays the script twice as fast. Jobs are sequenced like a capture
+// client's would be, so running a script again against the same code continues the sequence
+func Run(ctx context.Context, client *redis.Client, connectCode string, script Script, speed float64) error {
+	if speed <= 0 {
+		speed = 1
+	}
+	for i, step := range script.Steps {
+		jobType, payload, err := step.Job()
+		if err != nil {
+			return err
+		}
+
+		delay := time.Duration(float64(step.Delay) / speed)
+		if delay > 0 {
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		job, err := task.NewJob(jobType, payload)
+		if err != nil {
+			return err
+		}
+		job.Seq, err = task.NextSequence(ctx, client, connectCode)
+		if err != nil {
+			return err
+		}
+		err = task.Push(ctx, client, connectCode, job)
+		if err != nil {
+			return err
+		}
+		logger.Info("Pushed job", "step", i+1, "steps", len(script.Steps), "seq", job.Seq, "type", jobType, "payload", payload, logging.ConnectCodeKey, connectCode)
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package simulate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/automuteus/automuteus/v8/pkg/game"
+	"github.com/automuteus/automuteus/v8/pkg/task"
+	"gopkg.in/yaml.v3"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Script is a sequence of capture events to replay against a connect code, as if they came from a capture client
+type Script struct {
+	ConnectCode string `json:"connectCode"`
+	Steps       []Step `json:"steps"`
+}
+
+// Step is a single job in a Script. Exactly one of Connected, Lobby, Phase, Player, GameOver, Meeting, Votes, Kill or
+// Tasks must be set
+type Step struct {
+	// Delay is how long to wait (after the previous step) before pushing this step's job
+	Delay Delay `json:"delay"`
+
+	Connected *bool              `json:"connected,omitempty"`
+	Lobby     *game.Lobby        `json:"lobby,omitempty"`
+	Phase     *PhaseValue        `json:"phase,omitempty"`
+	Player    *game.Player       `json:"player,omitempty"`
+	GameOver  *game.Gameover     `json:"gameOver,omitempty"`
+	Meeting   *game.Meeting      `json:"meeting,omitempty"`
+	Votes     *game.VoteResult   `json:"votes,omitempty"`
+	Kill      *game.Kill         `json:"kill,omitempty"`
+	Tasks     *game.TaskProgress `json:"tasks,omitempty"`
+}
+
+// Delay accepts either a duration string ("1.5s", "300ms") or a number of milliseconds
+type Delay time.Duration
+
+func (d *Delay) UnmarshalJSON(b []byte) error {
+	var v interface{}
+	err := json.Unmarshal(b, &v)
+	if err != nil {
+		return err
+	}
+	switch value := v.(type) {
+	case float64:
+		*d = Delay(time.Duration(value) * time.Millisecond)
+	case string:
+		dur, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		*d = Delay(dur)
+	default:
+		return fmt.Errorf("invalid delay: %s", string(b))
+	}
+	return nil
+}
+
+// PhaseValue accepts either the numeric value of a phase or its name
+type PhaseValue string
+
+func (p *PhaseValue) UnmarshalJSON(b []byte) error {
+	var v interface{}
+	err := json.Unmarshal(b, &v)
+	if err != nil {
+		return err
+	}
+	switch value := v.(type) {
+	case float64:
+		*p = PhaseValue(strconv.Itoa(int(value)))
+	case string:
+		*p = PhaseValue(value)
+	default:
+		return fmt.Errorf("invalid phase: %s", string(b))
+	}
+	return nil
+}
+
+// ParseScript parses a YAML or JSON script; name is only used to pick the format from the file extension
+func ParseScript(name string, contents []byte) (Script, error) {
+	var script Script
+	ext := strings.ToLower(filepath.Ext(name))
+	if ext == ".yaml" || ext == ".yml" {
+		// round-trip through JSON, so both formats use the same field names as the capture payloads
+		var v interface{}
+		err := yaml.Unmarshal(contents, &v)
+		if err != nil {
+			return script, err
+		}
+		contents, err = json.Marshal(v)
+		if err != nil {
+			return script, err
+		}
+	}
+	err := json.Unmarshal(contents, &script)
+	if err != nil {
+		return script, err
+	}
+	for i, step := range script.Steps {
+		jobType, payload, err := step.Job()
+		if err == nil {
+			// make sure the bot won't reject the job
+			_, err = task.NewJob(jobType, payload)
+		}
+		if err != nil {
+			return script, fmt.Errorf("step %d: %w", i+1, err)
+		}
+	}
+	return script, nil
+}
+
+// Job returns the job type and payload that a capture client would push for this step
+func (step Step) Job() (task.JobType, string, error) {
+	var jobs []task.JobType
+	var payload string
+	if step.Connected != nil {
+		jobs = append(jobs, task.ConnectionJob)
+		payload = strconv.FormatBool(*step.Connected)
+	}
+	if step.Lobby != nil {
+		jobs = append(jobs, task.LobbyJob)
+		b, err := json.Marshal(step.Lobby)
+		if err != nil {
+			return 0, "", err
+		}
+		payload = string(b)
+	}
+	if step.Phase != nil {
+		jobs = append(jobs, task.StateJob)
+		phase, err := parsePhase(string(*step.Phase))
+		if err != nil {
+			return 0, "", err
+		}
+		payload = strconv.Itoa(int(phase))
+	}
+	if step.Player != nil {
+		jobs = append(jobs, task.PlayerJob)
+		b, err := json.Marshal(step.Player)
+		if err != nil {
+			return 0, "", err
+		}
+		payload = string(b)
+	}
+	if step.GameOver != nil {
+		jobs = append(jobs, task.GameOverJob)
+		b, err := json.Marshal(step.GameOver)
+		if err != nil {
+			return 0, "", err
+		}
+		payload = string(b)
+	}
+
+	if step.Meeting != nil {
+		jobs = append(jobs, task.MeetingJob)
+		b, err := json.Marshal(step.Meeting)
+		if err != nil {
+			return 0, "", err
+		}
+		payload = string(b)
+	}
+	if step.Votes != nil {
+		jobs = append(jobs, task.VoteJob)
+		b, err := json.Marshal(step.Votes)
+		if err != nil {
+			return 0, "", err
+		}
+		payload = string(b)
+	}
+
+	if step.Kill != nil {
+		jobs = append(jobs, task.KillJob)
+		b, err := json.Marshal(step.Kill)
+		if err != nil {
+			return 0, "", err
+		}
+		payload = string(b)
+	}
+
+	if step.Tasks != nil {
+		jobs = append(jobs, task.TaskProgressJob)
+		b, err := json.Marshal(step.Tasks)
+		if err != nil {
+			return 0, "", err
+		}
+		payload = string(b)
+	}
+
+	if len(jobs) != 1 {
+		return 0, "", errors.New("exactly one of connected, lobby, phase, player, gameOver, meeting, votes, kill or tasks must be set")
+	}
+	return jobs[0], payload, nil
+}
+
+// parsePhase accepts the numeric value of a phase, or any name game.GetPhaseFromString understands (plus "menu" and
+// "gameover", which players can't set manually but capture clients do send)
+func parsePhase(str string) (game.Phase, error) {
+	if num, err := strconv.Atoi(str); err == nil {
+		if num < int(game.LOBBY) || num > int(game.GAMEOVER) {
+			return game.UNINITIALIZED, fmt.Errorf("invalid phase: %d", num)
+		}
+		return game.Phase(num), nil
+	}
+	switch strings.ToLower(str) {
+	case "menu":
+		return game.MENU, nil
+	case "gameover":
+		return game.GAMEOVER, nil
+	}
+	phase := game.GetPhaseFromString(str)
+	if phase == game.UNINITIALIZED {
+		return phase, fmt.Errorf("invalid phase: %s", str)
+	}
+	return phase, nil
+}
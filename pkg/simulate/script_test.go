@@ -0,0 +1,110 @@
+package simulate
+
+import (
+	"github.com/automuteus/automuteus/v8/pkg/task"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestParseScript_YAML(t *testing.T) {
+	contents, err := os.ReadFile("testdata/game.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	script, err := ParseScript("game.yaml", contents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(script.Steps) != 9 {
+		t.Fatalf("Expected 9 steps, got %d", len(script.Steps))
+	}
+	if time.Duration(script.Steps[1].Delay) != 500*time.Millisecond {
+		t.Error("Expected the duration string 500ms to be parsed")
+	}
+
+	jobType, payload, err := script.Steps[5].Job()
+	if err != nil {
+		t.Error(err)
+	}
+	if jobType != task.StateJob || payload != "1" {
+		t.Errorf("Expected phase \"tasks\" to produce a state job with payload 1, got type %d w/ payload %s", jobType, payload)
+	}
+
+	jobType, payload, err = script.Steps[6].Job()
+	if err != nil {
+		t.Error(err)
+	}
+	if jobType != task.PlayerJob || payload != `{"Action":2,"Name":"Alice","Color":0,"IsDead":true,"Disconnected":false}` {
+		t.Errorf("Unexpected player job type %d w/ payload %s", jobType, payload)
+	}
+}
+
+func TestParseScript_JSON(t *testing.T) {
+	script, err := ParseScript("game.json", []byte(`{"connectCode":"ABCDEFGH","steps":[{"delay":250,"phase":2},{"connected":false}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if script.ConnectCode != "ABCDEFGH" {
+		t.Error("Expected the connect code to be parsed")
+	}
+	if time.Duration(script.Steps[0].Delay) != 250*time.Millisecond {
+		t.Error("Expected a numeric delay to be parsed as milliseconds")
+	}
+	jobType, payload, err := script.Steps[1].Job()
+	if err != nil {
+		t.Error(err)
+	}
+	if jobType != task.ConnectionJob || payload != "false" {
+		t.Errorf("Unexpected connection job type %d w/ payload %s", jobType, payload)
+	}
+}
+
+func TestParseScript_Meeting(t *testing.T) {
+	script, err := ParseScript("game.yaml", []byte("steps:\n"+
+		"  - meeting: {Caller: Alice, Reported: Bob}\n"+
+		"  - votes: {Votes: [{Voter: Alice, Target: Carol}, {Voter: Carol}], Exiled: Carol}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobType, payload, err := script.Steps[0].Job()
+	if err != nil {
+		t.Error(err)
+	}
+	if jobType != task.MeetingJob || payload != `{"Caller":"Alice","Reported":"Bob"}` {
+		t.Errorf("Unexpected meeting job type %d w/ payload %s", jobType, payload)
+	}
+	jobType, payload, err = script.Steps[1].Job()
+	if err != nil {
+		t.Error(err)
+	}
+	if jobType != task.VoteJob || payload != `{"Votes":[{"Voter":"Alice","Target":"Carol"},{"Voter":"Carol"}],"Exiled":"Carol"}` {
+		t.Errorf("Unexpected vote job type %d w/ payload %s", jobType, payload)
+	}
+}
+
+func TestParseScript_Tasks(t *testing.T) {
+	script, err := ParseScript("game.yaml", []byte("steps:\n  - tasks: {Completed: 4, Total: 32}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobType, payload, err := script.Steps[0].Job()
+	if err != nil {
+		t.Error(err)
+	}
+	if jobType != task.TaskProgressJob || payload != `{"Completed":4,"Total":32}` {
+		t.Errorf("Unexpected task progress job type %d w/ payload %s", jobType, payload)
+	}
+}
+
+func TestParseScript_Invalid(t *testing.T) {
+	if _, err := ParseScript("game.json", []byte(`{"steps":[{"delay":"1s"}]}`)); err == nil {
+		t.Error("Expected a step without a job to be rejected")
+	}
+	if _, err := ParseScript("game.json", []byte(`{"steps":[{"phase":"lobby","connected":true}]}`)); err == nil {
+		t.Error("Expected a step with multiple jobs to be rejected")
+	}
+	if _, err := ParseScript("game.json", []byte(`{"steps":[{"phase":"voting"}]}`)); err == nil {
+		t.Error("Expected an unknown phase to be rejected")
+	}
+}
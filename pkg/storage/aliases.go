@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"github.com/automuteus/automuteus/v8/pkg/namematch"
+	"github.com/georgysavva/scany/pgxscan"
+	"strconv"
+)
+
+// MaxAliasesPerUser is how many in-game aliases a user can register in a guild
+const MaxAliasesPerUser = 10
+
+var (
+	ErrInvalidAlias   = errors.New("alias has no letters or digits")
+	ErrAliasTaken     = errors.New("alias is already registered to another user")
+	ErrTooManyAliases = errors.New("user has too many aliases")
+)
+
+type PostgresUserAlias struct {
+	GuildID  uint64 `db:"guild_id"`
+	UserID   uint64 `db:"user_id"`
+	Alias    string `db:"alias"`
+	AliasKey string `db:"alias_key"`
+}
+
+// AddUserAlias registers an in-game name the user plays under in the guild. Aliases are unique per guild, once
+// normalized (see namematch.Normalize), so two users can't both claim the same name
+func (psqlInterface *PsqlInterface) AddUserAlias(guildID, userID, alias string) error {
+	gid, uid, err := parseGuildAndUserIDs(guildID, userID)
+	if err != nil {
+		return err
+	}
+	conn, err := psqlInterface.Pool.Acquire(context.Background())
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	_, err = ensureUserExists(conn.Conn(), uid)
+	if err != nil {
+		return err
+	}
+	return insertUserAlias(conn.Conn(), gid, uid, alias)
+}
+
+func insertUserAlias(conn PgxIface, guildID, userID uint64, alias string) error {
+	key := namematch.Normalize(alias)
+	if key == "" {
+		return ErrInvalidAlias
+	}
+	var count int64
+	err := conn.QueryRow(context.Background(), "SELECT COUNT(*) FROM user_aliases WHERE guild_id = $1 AND user_id = $2 AND alias_key != $3;",
+		guildID, userID, key).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count >= MaxAliasesPerUser {
+		return ErrTooManyAliases
+	}
+	// re-registering your own alias just updates how it's displayed
+	tag, err := conn.Exec(context.Background(), "INSERT INTO user_aliases (guild_id, user_id, alias, alias_key) VALUES ($1, $2, $3, $4) "+
+		"ON CONFLICT (guild_id, alias_key) DO UPDATE SET alias = EXCLUDED.alias WHERE user_aliases.user_id = EXCLUDED.user_id;",
+		guildID, userID, alias, key)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrAliasTaken
+	}
+	return nil
+}
+
+// DeleteUserAlias removes one of the user's aliases, and returns whether they had it registered
+func (psqlInterface *PsqlInterface) DeleteUserAlias(guildID, userID, alias string) (bool, error) {
+	gid, uid, err := parseGuildAndUserIDs(guildID, userID)
+	if err != nil {
+		return false, err
+	}
+	tag, err := psqlInterface.Pool.Exec(context.Background(), "DELETE FROM user_aliases WHERE guild_id = $1 AND user_id = $2 AND alias_key = $3;",
+		gid, uid, namematch.Normalize(alias))
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (psqlInterface *PsqlInterface) GetUserAliases(guildID, userID string) ([]string, error) {
+	gid, uid, err := parseGuildAndUserIDs(guildID, userID)
+	if err != nil {
+		return nil, err
+	}
+	var aliases []*PostgresUserAlias
+	err = pgxscan.Select(context.Background(), psqlInterface.Pool, &aliases, "SELECT * FROM user_aliases WHERE guild_id = $1 AND user_id = $2 ORDER BY alias;", gid, uid)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(aliases))
+	for i, v := range aliases {
+		names[i] = v.Alias
+	}
+	return names, nil
+}
+
+// GetUserIDByAlias returns the user who registered the player name as an alias in the guild, or an empty string
+func (psqlInterface *PsqlInterface) GetUserIDByAlias(guildID, playerName string) (string, error) {
+	gid, err := strconv.ParseUint(guildID, 10, 64)
+	if err != nil {
+		return "", err
+	}
+	conn, err := psqlInterface.Pool.Acquire(context.Background())
+	if err != nil {
+		return "", err
+	}
+	defer conn.Release()
+	return getUserIDByAlias(conn.Conn(), gid, playerName)
+}
+
+func getUserIDByAlias(conn PgxIface, guildID uint64, playerName string) (string, error) {
+	key := namematch.Normalize(playerName)
+	if key == "" {
+		return "", nil
+	}
+	var aliases []*PostgresUserAlias
+	err := pgxscan.Select(context.Background(), conn, &aliases, "SELECT * FROM user_aliases WHERE guild_id = $1 AND alias_key = $2;", guildID, key)
+	if err != nil || len(aliases) == 0 {
+		return "", err
+	}
+	return strconv.FormatUint(aliases[0].UserID, 10), nil
+}
+
+func parseGuildAndUserIDs(guildID, userID string) (uint64, uint64, error) {
+	gid, err := strconv.ParseUint(guildID, 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	uid, err := strconv.ParseUint(userID, 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	return gid, uid, nil
+}
@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"errors"
+	"github.com/jackc/pgconn"
+	"github.com/pashagolub/pgxmock"
+	"testing"
+)
+
+func TestInsertUserAlias(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	mock.ExpectQuery("^SELECT COUNT\\(\\*\\) FROM user_aliases (.+)$").
+		WithArgs(GuildIDInt, UserIDInt, "kurokobo").
+		WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(int64(0)))
+	mock.ExpectExec("^INSERT INTO user_aliases (.+)$").
+		WithArgs(GuildIDInt, UserIDInt, "🔥 Kuro Kobo", "kurokobo").
+		WillReturnResult(pgconn.CommandTag("INSERT 0 1"))
+
+	err = insertUserAlias(mock, GuildIDInt, UserIDInt, "🔥 Kuro Kobo")
+	if err != nil {
+		t.Error(err)
+	}
+
+	// the alias belongs to someone else, so the upsert doesn't touch it
+	mock.ExpectQuery("^SELECT COUNT\\(\\*\\) FROM user_aliases (.+)$").
+		WithArgs(GuildIDInt, UserIDInt, "soup").
+		WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(int64(1)))
+	mock.ExpectExec("^INSERT INTO user_aliases (.+)$").
+		WithArgs(GuildIDInt, UserIDInt, "Soup", "soup").
+		WillReturnResult(pgconn.CommandTag("INSERT 0 0"))
+
+	err = insertUserAlias(mock, GuildIDInt, UserIDInt, "Soup")
+	if !errors.Is(err, ErrAliasTaken) {
+		t.Errorf("Expected the alias to be taken, got %v", err)
+	}
+
+	mock.ExpectQuery("^SELECT COUNT\\(\\*\\) FROM user_aliases (.+)$").
+		WithArgs(GuildIDInt, UserIDInt, "denver").
+		WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(int64(MaxAliasesPerUser)))
+
+	err = insertUserAlias(mock, GuildIDInt, UserIDInt, "Denver")
+	if !errors.Is(err, ErrTooManyAliases) {
+		t.Errorf("Expected the user to have too many aliases, got %v", err)
+	}
+
+	if err := insertUserAlias(mock, GuildIDInt, UserIDInt, "🔥🔥"); !errors.Is(err, ErrInvalidAlias) {
+		t.Errorf("Expected an alias without letters or digits to be invalid, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestGetUserIDByAlias(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	mock.ExpectQuery("^SELECT (.+) FROM user_aliases WHERE guild_id = (.+) AND alias_key = (.+)$").
+		WithArgs(GuildIDInt, "kurokobo").
+		WillReturnRows(pgxmock.NewRows([]string{"guild_id", "user_id", "alias", "alias_key"}).
+			AddRow(GuildIDInt, UserIDInt, "Kuro Kobo", "kurokobo"))
+
+	userID, err := getUserIDByAlias(mock, GuildIDInt, "KuroKobo")
+	if err != nil {
+		t.Error(err)
+	}
+	if userID != UserID {
+		t.Errorf("Expected the alias to belong to %s, got %q", UserID, userID)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
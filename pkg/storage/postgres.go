@@ -4,13 +4,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"github.com/automuteus/automuteus/v8/internal/logging"
 	"github.com/automuteus/automuteus/v8/pkg/premium"
 	"github.com/georgysavva/scany/pgxscan"
 	"github.com/jackc/pgconn"
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/top-gg/go-dbl"
-	"log"
 	"strconv"
 	"time"
 )
@@ -55,7 +55,7 @@ func (psqlInterface *PsqlInterface) ExecFromString(postgresFileContents string)
 	if err != nil {
 		return err
 	}
-	log.Println(tag.String())
+	logger.Debug("Executed postgres file", "tag", tag.String())
 	return nil
 }
 
@@ -136,6 +136,11 @@ func optUser(conn PgxIface, uid uint64, opt bool) error {
 		if err != nil {
 			return err
 		}
+
+		_, err = conn.Exec(context.Background(), "DELETE FROM user_aliases WHERE user_id = $1;", uid)
+		if err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -209,14 +214,18 @@ func (psqlInterface *PsqlInterface) GetGameEvents(matchID string) ([]*PostgresGa
 }
 
 func insertGame(conn PgxIface, game *PostgresGame) (uint64, error) {
-	t, err := conn.Query(context.Background(), "INSERT INTO games VALUES (DEFAULT, $1, $2, $3, $4, $5) RETURNING game_id;", game.GuildID, game.ConnectCode, game.StartTime, game.WinType, game.EndTime)
+	t, err := conn.Query(context.Background(), "INSERT INTO games (guild_id, connect_code, start_time, win_type, end_time, game_mode, "+
+		"num_impostors, discussion_time, voting_time, kill_cooldown, confirm_ejects, player_speed) "+
+		"VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING game_id;",
+		game.GuildID, game.ConnectCode, game.StartTime, game.WinType, game.EndTime, game.GameMode,
+		game.NumImpostors, game.DiscussionTime, game.VotingTime, game.KillCooldown, game.ConfirmEjects, game.PlayerSpeed)
 	if t != nil {
 		for t.Next() {
 			g := uint64(0)
 			err := t.Scan(&g)
 
 			if err != nil {
-				log.Println(err)
+				logger.Error("Error scanning inserted game", err, logging.GuildIDKey, game.GuildID, logging.ConnectCodeKey, game.ConnectCode)
 				t.Close()
 				return 0, err
 			}
@@ -267,7 +276,7 @@ func isUserPremium(conn PgxIface, dbl *dbl.Client, userID string) (bool, error)
 		go func() {
 			err := setUserVoteTime(conn, userID, time.Now().Unix())
 			if err != nil {
-				log.Println(err)
+				logger.Error("Error setting user vote time", err, "user_id", userID)
 			}
 		}()
 		return true, nil
@@ -294,7 +303,7 @@ func guildOrUserPremium(conn PgxIface, dbl *dbl.Client, guildID, userID string)
 	if premium.IsExpired(tier, daysRem) && userID != "" {
 		prem, err := isUserPremium(conn, dbl, userID)
 		if err != nil {
-			log.Println(err)
+			logger.Error("Error checking user premium", err, logging.GuildIDKey, guildID, "user_id", userID)
 		}
 		if prem {
 			// no expiry because the expiry is handled per-user elsewhere
@@ -312,13 +321,13 @@ func getGuildPremiumStatus(conn PgxIface, guildID string, depth int) (premium.Ti
 
 	gid, err := strconv.ParseUint(guildID, 10, 64)
 	if err != nil {
-		log.Println(err)
+		logger.Error("Error parsing guild ID", err, logging.GuildIDKey, guildID)
 		return premium.FreeTier, 0
 	}
 
 	guild, err := getGuild(conn, gid)
 	if err != nil {
-		log.Println(err)
+		logger.Error("Error fetching guild", err, logging.GuildIDKey, guildID)
 		return premium.FreeTier, 0
 	}
 
@@ -386,7 +395,7 @@ func ensureUserExists(conn PgxIface, userID uint64) (*PostgresUser, error) {
 	if user == nil {
 		err := insertUser(conn, userID)
 		if err != nil {
-			log.Println(err)
+			logger.Error("Error inserting user", err, "user_id", userID)
 		}
 		return getUser(conn, userID)
 	}
@@ -512,7 +521,7 @@ func (psqlInterface *PsqlInterface) UpdateGameAndPlayers(gameID int64, winType i
 	for _, player := range players {
 		err := insertPlayer(conn.Conn(), player)
 		if err != nil {
-			log.Println(err)
+			logger.Error("Error inserting player", err, logging.MatchIDKey, gameID, "user_id", player.UserID)
 		}
 	}
 
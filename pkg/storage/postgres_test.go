@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"github.com/automuteus/automuteus/v8/pkg/game"
 	"github.com/automuteus/automuteus/v8/pkg/premium"
 	"github.com/jackc/pgconn"
 	"github.com/pashagolub/pgxmock"
@@ -211,6 +212,11 @@ func TestOptUser(t *testing.T) {
 		WithArgs(UserIDInt).
 		WillReturnResult(pgconn.CommandTag{})
 
+	// expect all the user's aliases to be deleted
+	mock.ExpectExec("^DELETE FROM user_aliases WHERE user_id = (.+)$").
+		WithArgs(UserIDInt).
+		WillReturnResult(pgconn.CommandTag{})
+
 	err = optUser(mock, UserIDInt, false)
 	if err != nil {
 		t.Error(err)
@@ -221,3 +227,56 @@ func TestOptUser(t *testing.T) {
 		t.Errorf("there were unfulfilled expectations: %s", err)
 	}
 }
+
+func TestInsertGame_lobbyOptions(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	pgame := &PostgresGame{
+		GuildID:     GuildIDInt,
+		ConnectCode: "ABCDEFGH",
+		StartTime:   1,
+		WinType:     -1,
+		EndTime:     -1,
+	}
+	pgame.SetLobbyOptions(&game.LobbyOptions{
+		NumImpostors:   2,
+		DiscussionTime: 15,
+		VotingTime:     120,
+		KillCooldown:   22.5,
+		ConfirmEjects:  true,
+		PlayerSpeed:    1.25,
+	})
+	mock.ExpectQuery("^INSERT INTO games (.+) RETURNING game_id;$").
+		WithArgs(GuildIDInt, "ABCDEFGH", int32(1), int16(-1), int32(-1), int16(0),
+			pgame.NumImpostors, pgame.DiscussionTime, pgame.VotingTime, pgame.KillCooldown, pgame.ConfirmEjects, pgame.PlayerSpeed).
+		WillReturnRows(pgxmock.NewRows([]string{"game_id"}).AddRow(uint64(5)))
+
+	gameID, err := insertGame(mock, pgame)
+	if err != nil {
+		t.Error(err)
+	}
+	if gameID != 5 {
+		t.Errorf("expected game ID 5, got %d", gameID)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRuleset_where(t *testing.T) {
+	confirmEjects := false
+	conditions, args := Ruleset{NumImpostors: 2, ConfirmEjects: &confirmEjects}.where([]interface{}{GuildIDInt})
+	if conditions != " AND num_impostors=$2 AND confirm_ejects=$3" {
+		t.Errorf("unexpected conditions: %s", conditions)
+	}
+	if len(args) != 3 || args[1] != int16(2) || args[2] != false {
+		t.Errorf("unexpected args: %v", args)
+	}
+
+	conditions, args = Ruleset{}.where([]interface{}{GuildIDInt})
+	if conditions != "" || len(args) != 1 {
+		t.Error("an empty ruleset should match every game")
+	}
+}
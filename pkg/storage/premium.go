@@ -3,9 +3,9 @@ package storage
 import (
 	"context"
 	"errors"
+	"github.com/automuteus/automuteus/v8/internal/logging"
 	"github.com/automuteus/automuteus/v8/pkg/premium"
 	"github.com/jackc/pgx/v4/pgxpool"
-	"log"
 	"strconv"
 	"time"
 )
@@ -204,7 +204,7 @@ func setGuildTransferredTo(conn *pgxpool.Conn, guildID, transferTo string) error
 	if err != nil {
 		return err
 	}
-	log.Printf("Marked guild %s as transferred to: %s\n", guildID, transferTo)
+	logger.Info("Marked guild as transferred", logging.GuildIDKey, guildID, "transfer_to", transferTo)
 	return nil
 }
 
@@ -213,7 +213,7 @@ func setGuildTransferredNull(conn PgxIface, guildID string) error {
 	if err != nil {
 		return err
 	}
-	log.Printf("Marked guild %s as NULL transfer\n", guildID)
+	logger.Info("Cleared guild transfer", logging.GuildIDKey, guildID)
 	return nil
 }
 
@@ -222,7 +222,7 @@ func setGuildInheritsFrom(conn *pgxpool.Conn, guildID, inheritsFrom string) erro
 	if err != nil {
 		return err
 	}
-	log.Printf("Marked guild %s as inheriting from %s\n", guildID, inheritsFrom)
+	logger.Info("Marked guild as inheriting", logging.GuildIDKey, guildID, "inherits_from", inheritsFrom)
 	return nil
 }
 
@@ -231,6 +231,6 @@ func setGuildInheritsNull(conn PgxIface, guildID string) error {
 	if err != nil {
 		return err
 	}
-	log.Printf("Marked guild %s as NULL inheriting\n", guildID)
+	logger.Info("Cleared guild inheritance", logging.GuildIDKey, guildID)
 	return nil
 }
@@ -5,13 +5,13 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"github.com/automuteus/automuteus/v8/internal/logging"
 	"github.com/automuteus/automuteus/v8/pkg/capture"
 	"github.com/automuteus/automuteus/v8/pkg/game"
 	"github.com/automuteus/automuteus/v8/pkg/settings"
 	"github.com/bwmarrin/discordgo"
 	"github.com/georgysavva/scany/pgxscan"
 	"github.com/nicksnyder/go-i18n/v2/i18n"
-	"log"
 	"strconv"
 	"time"
 )
@@ -26,6 +26,7 @@ const (
 	Discuss
 	PlayerDeath
 	PlayerDisconnect
+	MeetingCalled
 )
 
 type SimpleEvent struct {
@@ -34,15 +35,27 @@ type SimpleEvent struct {
 	Data            string
 }
 
+// TaskProgressPoint is a point on a game's task progress curve
+type TaskProgressPoint struct {
+	EventTimeOffset time.Duration
+	Percent         int
+}
+
 type GameStatistics struct {
 	GameDuration time.Duration
 	WinType      game.GameResult
+	GameMode     game.GameMode
 
 	NumMeetings    int
 	NumDeaths      int
 	NumVotedOff    int
 	NumDisconnects int
+	NumReports     int
+	NumVotes       int
+	NumSkips       int
 	Events         []SimpleEvent
+	// TaskProgress is empty if the capture didn't report the crew's task progress
+	TaskProgress []TaskProgressPoint
 }
 
 func (stats *GameStatistics) ToString() string {
@@ -55,13 +68,30 @@ func (stats *GameStatistics) ToString() string {
 			buf.WriteString(fmt.Sprintf("%s into the game, Tasks phase resumed", v.EventTimeOffset.String()))
 		case v.EventType == Discuss:
 			buf.WriteString(fmt.Sprintf("%s into the game, Discussion was called", v.EventTimeOffset.String()))
+			if v.Data != "" {
+				buf.WriteString(fmt.Sprintf(" with the crew's tasks %s%% complete", v.Data))
+			}
 		case v.EventType == PlayerDeath:
 			player := game.Player{}
 			err := json.Unmarshal([]byte(v.Data), &player)
 			if err != nil {
-				log.Println(err)
+				logger.Error("Error decoding game event", err, "event_type", v.EventType)
 			} else {
-				buf.WriteString(fmt.Sprintf("%s into the game, %s died", v.EventTimeOffset.String(), player.Name))
+				if player.Killer != "" {
+					buf.WriteString(fmt.Sprintf("%s into the game, %s was killed by %s", v.EventTimeOffset.String(), player.Name, player.Killer))
+				} else {
+					buf.WriteString(fmt.Sprintf("%s into the game, %s died", v.EventTimeOffset.String(), player.Name))
+				}
+			}
+		case v.EventType == MeetingCalled:
+			meeting := game.Meeting{}
+			err := json.Unmarshal([]byte(v.Data), &meeting)
+			if err != nil {
+				logger.Error("Error decoding game event", err, "event_type", v.EventType)
+			} else if meeting.IsReport() {
+				buf.WriteString(fmt.Sprintf("%s into the game, %s reported %s's body", v.EventTimeOffset.String(), meeting.Caller, meeting.Reported))
+			} else {
+				buf.WriteString(fmt.Sprintf("%s into the game, %s called an emergency meeting", v.EventTimeOffset.String(), meeting.Caller))
 			}
 		}
 		buf.WriteRune('\n')
@@ -92,9 +122,22 @@ func (stats *GameStatistics) FormatDurationAndWin() string {
 	}
 	buf.WriteString("This display is VERY UNFINISHED and will be refined as time goes on!\n\n")
 
-	buf.WriteString(fmt.Sprintf("Game lasted %s and %s\n", stats.GameDuration.String(), winner))
-	buf.WriteString(fmt.Sprintf("There were %d meetings, %d deaths, and of those deaths, %d were from being voted off\n",
-		stats.NumMeetings, stats.NumDeaths, stats.NumVotedOff))
+	if stats.GameMode == game.HideAndSeekMode {
+		// there are no meetings in Hide and Seek, so nobody gets voted off either
+		buf.WriteString(fmt.Sprintf("Hide and Seek game lasted %s and %s\n", stats.GameDuration.String(), winner))
+		buf.WriteString(fmt.Sprintf("There were %d deaths\n", stats.NumDeaths))
+	} else {
+		buf.WriteString(fmt.Sprintf("Game lasted %s and %s\n", stats.GameDuration.String(), winner))
+		buf.WriteString(fmt.Sprintf("There were %d meetings, %d deaths, and of those deaths, %d were from being voted off\n",
+			stats.NumMeetings, stats.NumDeaths, stats.NumVotedOff))
+		if stats.NumVotes > 0 {
+			buf.WriteString(fmt.Sprintf("%d bodies were reported, and %d of the %d votes cast were skips\n",
+				stats.NumReports, stats.NumSkips, stats.NumVotes))
+		}
+	}
+	if len(stats.TaskProgress) > 0 {
+		buf.WriteString(fmt.Sprintf("The crew completed %d%% of their tasks\n", stats.TaskProgress[len(stats.TaskProgress)-1].Percent))
+	}
 	buf.WriteString("Game Events:\n")
 	return buf.String()
 }
@@ -122,9 +165,13 @@ func (stats *GameStatistics) ToDiscordEmbed(combinedID string, sett *settings.Gu
 			})
 			fieldsOnLine++
 		case v.EventType == Discuss:
+			value := "💬 Discussion Begins"
+			if v.Data != "" {
+				value = fmt.Sprintf("💬 Discussion Begins (Tasks %s%%)", v.Data)
+			}
 			fields = append(fields, &discordgo.MessageEmbedField{
 				Name:   v.EventTimeOffset.String(),
-				Value:  "💬 Discussion Begins",
+				Value:  value,
 				Inline: true,
 			})
 			fieldsOnLine++
@@ -132,11 +179,32 @@ func (stats *GameStatistics) ToDiscordEmbed(combinedID string, sett *settings.Gu
 			player := game.Player{}
 			err := json.Unmarshal([]byte(v.Data), &player)
 			if err != nil {
-				log.Println(err)
+				logger.Error("Error decoding game event", err, "event_type", v.EventType)
 			} else {
+				value := fmt.Sprintf("☠️ \"%s\" Died", player.Name)
+				if player.Killer != "" {
+					value = fmt.Sprintf("🔪 \"%s\" Killed \"%s\"", player.Killer, player.Name)
+				}
 				fields = append(fields, &discordgo.MessageEmbedField{
 					Name:   v.EventTimeOffset.String(),
-					Value:  fmt.Sprintf("☠️ \"%s\" Died", player.Name),
+					Value:  value,
+					Inline: false,
+				})
+			}
+			fieldsOnLine = 0
+		case v.EventType == MeetingCalled:
+			meeting := game.Meeting{}
+			err := json.Unmarshal([]byte(v.Data), &meeting)
+			if err != nil {
+				logger.Error("Error decoding game event", err, "event_type", v.EventType)
+			} else {
+				value := fmt.Sprintf("📢 \"%s\" Called a Meeting", meeting.Caller)
+				if meeting.IsReport() {
+					value = fmt.Sprintf("🚨 \"%s\" Reported \"%s\"", meeting.Caller, meeting.Reported)
+				}
+				fields = append(fields, &discordgo.MessageEmbedField{
+					Name:   v.EventTimeOffset.String(),
+					Value:  value,
 					Inline: false,
 				})
 			}
@@ -181,12 +249,29 @@ func StatsFromGameAndEvents(pgame *PostgresGame, events []*PostgresGameEvent) Ga
 	if pgame != nil {
 		stats.GameDuration = time.Second * time.Duration(pgame.EndTime-pgame.StartTime)
 		stats.WinType = game.GameResult(pgame.WinType)
+		stats.GameMode = game.GameMode(pgame.GameMode)
 	}
 
 	if len(events) < 2 {
 		return stats
 	}
 
+	// kills reported as separate jobs are attributed to the matching deaths, like kills reported with the death
+	killers := make(map[string]string)
+	for _, v := range events {
+		if v.EventType == int16(capture.Kill) {
+			kill := game.Kill{}
+			err := json.Unmarshal([]byte(v.Payload), &kill)
+			if err != nil {
+				logger.Error("Error decoding game event", err, "event_id", v.EventID, logging.MatchIDKey, v.GameID)
+			} else {
+				killers[kill.Victim] = kill.Killer
+			}
+		}
+	}
+
+	// discussions are annotated with the task progress they were called at
+	taskPercent := ""
 	for _, v := range events {
 		if v.EventType == int16(capture.State) {
 			if v.Payload == DiscussCode {
@@ -194,7 +279,7 @@ func StatsFromGameAndEvents(pgame *PostgresGame, events []*PostgresGameEvent) Ga
 				stats.Events = append(stats.Events, SimpleEvent{
 					EventType:       Discuss,
 					EventTimeOffset: time.Second * time.Duration(v.EventTime-pgame.StartTime),
-					Data:            "",
+					Data:            taskPercent,
 				})
 			} else if v.Payload == TasksCode {
 				stats.Events = append(stats.Events, SimpleEvent{
@@ -207,15 +292,22 @@ func StatsFromGameAndEvents(pgame *PostgresGame, events []*PostgresGameEvent) Ga
 			player := game.Player{}
 			err := json.Unmarshal([]byte(v.Payload), &player)
 			if err != nil {
-				log.Println(err)
+				logger.Error("Error decoding game event", err, "event_id", v.EventID, logging.MatchIDKey, v.GameID)
 			} else {
 				switch {
 				case player.Action == game.DIED:
 					stats.NumDeaths++
+					data := v.Payload
+					if killer, ok := killers[player.Name]; ok && player.Killer == "" {
+						player.Killer = killer
+						if b, err := json.Marshal(player); err == nil {
+							data = string(b)
+						}
+					}
 					stats.Events = append(stats.Events, SimpleEvent{
 						EventType:       PlayerDeath,
 						EventTimeOffset: time.Second * time.Duration(v.EventTime-pgame.StartTime),
-						Data:            v.Payload,
+						Data:            data,
 					})
 				case player.Action == game.EXILED:
 					stats.NumVotedOff++
@@ -223,33 +315,106 @@ func StatsFromGameAndEvents(pgame *PostgresGame, events []*PostgresGameEvent) Ga
 					stats.NumDisconnects++
 				}
 			}
+		} else if v.EventType == int16(capture.Meeting) {
+			meeting := game.Meeting{}
+			err := json.Unmarshal([]byte(v.Payload), &meeting)
+			if err != nil {
+				logger.Error("Error decoding game event", err, "event_id", v.EventID, logging.MatchIDKey, v.GameID)
+			} else {
+				if meeting.IsReport() {
+					stats.NumReports++
+				}
+				stats.Events = append(stats.Events, SimpleEvent{
+					EventType:       MeetingCalled,
+					EventTimeOffset: time.Second * time.Duration(v.EventTime-pgame.StartTime),
+					Data:            v.Payload,
+				})
+			}
+		} else if v.EventType == int16(capture.Vote) {
+			vote := game.Vote{}
+			err := json.Unmarshal([]byte(v.Payload), &vote)
+			if err != nil {
+				logger.Error("Error decoding game event", err, "event_id", v.EventID, logging.MatchIDKey, v.GameID)
+			} else if vote.Voter != "" {
+				// exiles are persisted as vote events too, but they're already counted from the EXILED actions
+				stats.NumVotes++
+				if vote.Skipped() {
+					stats.NumSkips++
+				}
+			}
+		} else if v.EventType == int16(capture.TaskProgress) {
+			progress := game.TaskProgress{}
+			err := json.Unmarshal([]byte(v.Payload), &progress)
+			if err != nil {
+				logger.Error("Error decoding game event", err, "event_id", v.EventID, logging.MatchIDKey, v.GameID)
+			} else {
+				taskPercent = strconv.Itoa(progress.Percent())
+				stats.TaskProgress = append(stats.TaskProgress, TaskProgressPoint{
+					EventTimeOffset: time.Second * time.Duration(v.EventTime-pgame.StartTime),
+					Percent:         progress.Percent(),
+				})
+			}
 		}
 	}
 
 	return stats
 }
 
-func (psqlInterface *PsqlInterface) NumGamesPlayedOnGuild(guildID string) int64 {
+// Ruleset narrows guild stats down to games played with certain lobby options. Zero values match any game
+type Ruleset struct {
+	NumImpostors  int16
+	ConfirmEjects *bool
+}
+
+// where returns the conditions games must meet to match the ruleset, numbering its placeholders after the query's
+// other args
+func (r Ruleset) where(args []interface{}) (string, []interface{}) {
+	conditions := ""
+	if r.NumImpostors > 0 {
+		args = append(args, r.NumImpostors)
+		conditions += fmt.Sprintf(" AND num_impostors=$%d", len(args))
+	}
+	if r.ConfirmEjects != nil {
+		args = append(args, *r.ConfirmEjects)
+		conditions += fmt.Sprintf(" AND confirm_ejects=$%d", len(args))
+	}
+	return conditions, args
+}
+
+func (psqlInterface *PsqlInterface) NumGamesPlayedOnGuild(guildID string, ruleset Ruleset) int64 {
 	gid, _ := strconv.ParseInt(guildID, 10, 64)
 	var r int64
-	err := pgxscan.Get(context.Background(), psqlInterface.Pool, &r, "SELECT COUNT(*) FROM games WHERE guild_id=$1 AND end_time != -1;", gid)
+	conditions, args := ruleset.where([]interface{}{gid})
+	err := pgxscan.Get(context.Background(), psqlInterface.Pool, &r, "SELECT COUNT(*) FROM games WHERE guild_id=$1 AND end_time != -1"+conditions+";", args...)
 	if err != nil {
 		return -1
 	}
 	return r
 }
 
-func (psqlInterface *PsqlInterface) NumGamesWonAsRoleOnServer(guildID string, role game.GameRole) int64 {
+func (psqlInterface *PsqlInterface) NumGamesPlayedOnGuildInMode(guildID string, mode game.GameMode, ruleset Ruleset) int64 {
+	gid, _ := strconv.ParseInt(guildID, 10, 64)
+	var r int64
+	conditions, args := ruleset.where([]interface{}{gid, int16(mode)})
+	err := pgxscan.Get(context.Background(), psqlInterface.Pool, &r, "SELECT COUNT(*) FROM games WHERE guild_id=$1 AND end_time != -1 AND game_mode=$2"+conditions+";", args...)
+	if err != nil {
+		return -1
+	}
+	return r
+}
+
+func (psqlInterface *PsqlInterface) NumGamesWonAsRoleOnServer(guildID string, team game.Team, ruleset Ruleset) int64 {
 	gid, _ := strconv.ParseInt(guildID, 10, 64)
 	var r int64
 	var err error
-	if role == game.CrewmateRole {
-		err = pgxscan.Get(context.Background(), psqlInterface.Pool, &r, "SELECT COUNT(*) FROM games WHERE guild_id=$1 AND (win_type=0 OR win_type=1 OR win_type=6)", gid)
+	conditions, args := ruleset.where([]interface{}{gid})
+	if team == game.CrewTeam {
+		err = pgxscan.Get(context.Background(), psqlInterface.Pool, &r, "SELECT COUNT(*) FROM games WHERE guild_id=$1 AND (win_type=0 OR win_type=1 OR win_type=6)"+conditions, args...)
 	} else {
-		err = pgxscan.Get(context.Background(), psqlInterface.Pool, &r, "SELECT COUNT(*) FROM games WHERE guild_id=$1 AND (win_type=2 OR win_type=3 OR win_type=4 OR win_type=5)", gid)
+		err = pgxscan.Get(context.Background(), psqlInterface.Pool, &r, "SELECT COUNT(*) FROM games WHERE guild_id=$1 AND (win_type=2 OR win_type=3 OR win_type=4 OR win_type=5)"+conditions, args...)
 	}
 	if err != nil {
-		log.Println(err)
+		logger.Error("Error querying stats", err, "query", "NumGamesWonAsRoleOnServer", logging.GuildIDKey, guildID)
 		return -1
 	}
 	return r
@@ -283,36 +448,36 @@ func (psqlInterface *PsqlInterface) NumGamesPlayedByUserOnServer(userID, guildID
 	return r
 }
 
-func (psqlInterface *PsqlInterface) NumWinsAsRoleOnServer(userID, guildID string, role int16) int64 {
+func (psqlInterface *PsqlInterface) NumWinsAsRoleOnServer(userID, guildID string, team game.Team) int64 {
 	var r int64
-	err := pgxscan.Get(context.Background(), psqlInterface.Pool, &r, "SELECT COUNT(*) FROM users_games WHERE user_id=$1 AND guild_id=$2 AND player_role=$3 AND player_won=true;", userID, guildID, role)
+	err := pgxscan.Get(context.Background(), psqlInterface.Pool, &r, "SELECT COUNT(*) FROM users_games WHERE user_id=$1 AND guild_id=$2 AND player_role=ANY($3) AND player_won=true;", userID, guildID, game.RolesInTeam(team))
 	if err != nil {
 		return -1
 	}
 	return r
 }
 
-func (psqlInterface *PsqlInterface) NumWinsAsRole(userID string, role int16) int64 {
+func (psqlInterface *PsqlInterface) NumWinsAsRole(userID string, team game.Team) int64 {
 	var r int64
-	err := pgxscan.Get(context.Background(), psqlInterface.Pool, &r, "SELECT COUNT(*) FROM users_games WHERE user_id=$1 AND player_role=$2 AND player_won=true;", userID, role)
+	err := pgxscan.Get(context.Background(), psqlInterface.Pool, &r, "SELECT COUNT(*) FROM users_games WHERE user_id=$1 AND player_role=ANY($2) AND player_won=true;", userID, game.RolesInTeam(team))
 	if err != nil {
 		return -1
 	}
 	return r
 }
 
-func (psqlInterface *PsqlInterface) NumGamesAsRoleOnServer(userID, guildID string, role int16) int64 {
+func (psqlInterface *PsqlInterface) NumGamesAsRoleOnServer(userID, guildID string, team game.Team) int64 {
 	var r int64
-	err := pgxscan.Get(context.Background(), psqlInterface.Pool, &r, "SELECT COUNT(*) FROM users_games WHERE user_id=$1 AND guild_id=$2 AND player_role=$3;", userID, guildID, role)
+	err := pgxscan.Get(context.Background(), psqlInterface.Pool, &r, "SELECT COUNT(*) FROM users_games WHERE user_id=$1 AND guild_id=$2 AND player_role=ANY($3);", userID, guildID, game.RolesInTeam(team))
 	if err != nil {
 		return -1
 	}
 	return r
 }
 
-func (psqlInterface *PsqlInterface) NumGamesAsRole(userID string, role int16) int64 {
+func (psqlInterface *PsqlInterface) NumGamesAsRole(userID string, team game.Team) int64 {
 	var r int64
-	err := pgxscan.Get(context.Background(), psqlInterface.Pool, &r, "SELECT COUNT(*) FROM users_games WHERE user_id=$1 AND player_role=$2;", userID, role)
+	err := pgxscan.Get(context.Background(), psqlInterface.Pool, &r, "SELECT COUNT(*) FROM users_games WHERE user_id=$1 AND player_role=ANY($2);", userID, game.RolesInTeam(team))
 	if err != nil {
 		return -1
 	}
@@ -365,7 +530,17 @@ func (psqlInterface *PsqlInterface) ColorRankingForPlayerOnServer(userID, guildI
 	err := pgxscan.Select(context.Background(), psqlInterface.Pool, &r, "SELECT count(*),mode() within GROUP (ORDER BY player_color) AS mode FROM users_games WHERE user_id=$1 AND guild_id=$2 GROUP BY player_color ORDER BY count desc;", userID, guildID)
 
 	if err != nil {
-		log.Println(err)
+		logger.Error("Error querying stats", err, "query", "ColorRankingForPlayerOnServer", "user_id", userID, logging.GuildIDKey, guildID)
+	}
+	return r
+}
+
+func (psqlInterface *PsqlInterface) RoleRankingForPlayerOnServer(userID, guildID string) []*Int16ModeCount {
+	r := []*Int16ModeCount{}
+	err := pgxscan.Select(context.Background(), psqlInterface.Pool, &r, "SELECT count(*),mode() within GROUP (ORDER BY player_role) AS mode FROM users_games WHERE user_id=$1 AND guild_id=$2 GROUP BY player_role ORDER BY count desc;", userID, guildID)
+
+	if err != nil {
+		logger.Error("Error querying stats", err, "query", "RoleRankingForPlayerOnServer", "user_id", userID, logging.GuildIDKey, guildID)
 	}
 	return r
 }
@@ -385,7 +560,7 @@ func (psqlInterface *PsqlInterface) NamesRankingForPlayerOnServer(userID, guildI
 	err := pgxscan.Select(context.Background(), psqlInterface.Pool, &r, "SELECT count(*),mode() within GROUP (ORDER BY player_name) AS mode FROM users_games WHERE user_id=$1 AND guild_id=$2 GROUP BY player_name ORDER BY count desc;", userID, guildID)
 
 	if err != nil {
-		log.Println(err)
+		logger.Error("Error querying stats", err, "query", "NamesRankingForPlayerOnServer", "user_id", userID, logging.GuildIDKey, guildID)
 	}
 	return r
 }
@@ -395,7 +570,7 @@ func (psqlInterface *PsqlInterface) TotalGamesRankingForServer(guildID uint64) [
 	err := pgxscan.Select(context.Background(), psqlInterface.Pool, &r, "SELECT count(*),mode() within GROUP (ORDER BY user_id) AS mode FROM users_games WHERE guild_id=$1 GROUP BY user_id ORDER BY count desc;", guildID)
 
 	if err != nil {
-		log.Println(err)
+		logger.Error("Error querying stats", err, "query", "TotalGamesRankingForServer", logging.GuildIDKey, guildID)
 	}
 	return r
 }
@@ -410,12 +585,12 @@ func (psqlInterface *PsqlInterface) OtherPlayersRankingForPlayerOnServer(userID,
 		"ORDER BY percent desc", userID, guildID)
 
 	if err != nil {
-		log.Println(err)
+		logger.Error("Error querying stats", err, "query", "OtherPlayersRankingForPlayerOnServer", "user_id", userID, logging.GuildIDKey, guildID)
 	}
 	return r
 }
 
-func (psqlInterface *PsqlInterface) TotalWinRankingForServerByRole(guildID uint64, role int16) []*PostgresPlayerRanking {
+func (psqlInterface *PsqlInterface) TotalWinRankingForServerByRole(guildID uint64, team game.Team) []*PostgresPlayerRanking {
 	var r []*PostgresPlayerRanking
 	err := pgxscan.Select(context.Background(), psqlInterface.Pool, &r, "SELECT DISTINCT user_id,"+
 		"COUNT(user_id) FILTER ( WHERE player_won = TRUE ) AS win, "+
@@ -424,12 +599,12 @@ func (psqlInterface *PsqlInterface) TotalWinRankingForServerByRole(guildID uint6
 		"(COUNT(user_id) FILTER ( WHERE player_won = TRUE )::decimal / COUNT(*)) * 100 AS win_rate "+
 		// "(COUNT(user_id) FILTER ( WHERE player_won = FALSE )::decimal / COUNT(*)) * 100 AS loss_rate" +
 		"FROM users_games "+
-		"WHERE guild_id = $1 AND player_role = $2 "+
+		"WHERE guild_id = $1 AND player_role = ANY($2) "+
 		"GROUP BY user_id "+
-		"ORDER BY win_rate DESC", guildID, role)
+		"ORDER BY win_rate DESC", guildID, game.RolesInTeam(team))
 
 	if err != nil {
-		log.Println(err)
+		logger.Error("Error querying stats", err, "query", "TotalWinRankingForServerByRole", logging.GuildIDKey, guildID)
 	}
 	return r
 }
@@ -448,7 +623,7 @@ func (psqlInterface *PsqlInterface) TotalWinRankingForServer(guildID uint64) []*
 		"ORDER BY win_rate DESC", guildID)
 
 	if err != nil {
-		log.Println(err)
+		logger.Error("Error querying stats", err, "query", "TotalWinRankingForServer", logging.GuildIDKey, guildID)
 	}
 	return r
 }
@@ -463,7 +638,7 @@ func (psqlInterface *PsqlInterface) DeleteAllGamesForUser(userID string) error {
 	return err
 }
 
-func (psqlInterface *PsqlInterface) BestTeammateByRole(userID, guildID string, role int16, leaderboardMin int) []*PostgresBestTeammatePlayerRanking {
+func (psqlInterface *PsqlInterface) BestTeammateByRole(userID, guildID string, team game.Team, leaderboardMin int) []*PostgresBestTeammatePlayerRanking {
 	var r []*PostgresBestTeammatePlayerRanking
 	err := pgxscan.Select(context.Background(), psqlInterface.Pool, &r, "SELECT DISTINCT users_games.user_id, "+
 		"uG.user_id as teammate_id,"+
@@ -472,18 +647,18 @@ func (psqlInterface *PsqlInterface) BestTeammateByRole(userID, guildID string, r
 		"(COUNT(users_games.user_id) FILTER ( WHERE users_games.player_won = TRUE )::decimal / COUNT(*)) * 100 AS win_rate "+
 		"FROM users_games "+
 		"INNER JOIN users_games uG ON users_games.game_id = uG.game_id AND users_games.user_id <> uG.user_id "+
-		"WHERE users_games.guild_id = $1 AND users_games.player_role = $2 AND uG.player_role = $2 AND users_games.user_id = $3 "+
+		"WHERE users_games.guild_id = $1 AND users_games.player_role = ANY($2) AND uG.player_role = ANY($2) AND users_games.user_id = $3 "+
 		"GROUP BY users_games.user_id, uG.user_id "+
 		"HAVING COUNT(users_games.player_won) >= $4 "+
-		"ORDER BY win_rate DESC, win DESC, total DESC", guildID, role, userID, leaderboardMin)
+		"ORDER BY win_rate DESC, win DESC, total DESC", guildID, game.RolesInTeam(team), userID, leaderboardMin)
 
 	if err != nil {
-		log.Println(err)
+		logger.Error("Error querying stats", err, "query", "BestTeammateByRole", "user_id", userID, logging.GuildIDKey, guildID)
 	}
 	return r
 }
 
-func (psqlInterface *PsqlInterface) WorstTeammateByRole(userID, guildID string, role int16, leaderboardMin int) []*PostgresWorstTeammatePlayerRanking {
+func (psqlInterface *PsqlInterface) WorstTeammateByRole(userID, guildID string, team game.Team, leaderboardMin int) []*PostgresWorstTeammatePlayerRanking {
 	var r []*PostgresWorstTeammatePlayerRanking
 	err := pgxscan.Select(context.Background(), psqlInterface.Pool, &r, "SELECT DISTINCT users_games.user_id, "+
 		"uG.user_id as teammate_id,"+
@@ -492,18 +667,18 @@ func (psqlInterface *PsqlInterface) WorstTeammateByRole(userID, guildID string,
 		"(COUNT(users_games.user_id) FILTER ( WHERE users_games.player_won = FALSE )::decimal / COUNT(*)) * 100 AS loose_rate "+
 		"FROM users_games "+
 		"INNER JOIN users_games uG ON users_games.game_id = uG.game_id AND users_games.user_id <> uG.user_id "+
-		"WHERE users_games.guild_id = $1 AND users_games.player_role = $2 AND uG.player_role = $2 AND users_games.user_id = $3 "+
+		"WHERE users_games.guild_id = $1 AND users_games.player_role = ANY($2) AND uG.player_role = ANY($2) AND users_games.user_id = $3 "+
 		"GROUP BY users_games.user_id, uG.user_id "+
 		"HAVING COUNT(users_games.player_won) >= $4 "+
-		"ORDER BY loose_rate DESC, loose DESC, total DESC", guildID, role, userID, leaderboardMin)
+		"ORDER BY loose_rate DESC, loose DESC, total DESC", guildID, game.RolesInTeam(team), userID, leaderboardMin)
 
 	if err != nil {
-		log.Println(err)
+		logger.Error("Error querying stats", err, "query", "WorstTeammateByRole", "user_id", userID, logging.GuildIDKey, guildID)
 	}
 	return r
 }
 
-func (psqlInterface *PsqlInterface) BestTeammateForServerByRole(guildID string, role int16, leaderboardMin int) []*PostgresBestTeammatePlayerRanking {
+func (psqlInterface *PsqlInterface) BestTeammateForServerByRole(guildID string, team game.Team, leaderboardMin int) []*PostgresBestTeammatePlayerRanking {
 	var r []*PostgresBestTeammatePlayerRanking
 	err := pgxscan.Select(context.Background(), psqlInterface.Pool, &r, "SELECT DISTINCT "+
 		"CASE WHEN users_games.user_id > uG.user_id THEN users_games.user_id ELSE uG.user_id END, "+
@@ -513,18 +688,18 @@ func (psqlInterface *PsqlInterface) BestTeammateForServerByRole(guildID string,
 		"(COUNT(users_games.user_id) FILTER ( WHERE users_games.player_won = TRUE )::decimal / COUNT(*)) * 100 AS win_rate "+
 		"FROM users_games "+
 		"INNER JOIN users_games uG ON users_games.game_id = uG.game_id AND users_games.user_id <> uG.user_id "+
-		"WHERE users_games.guild_id = $1 AND users_games.player_role = $2 and uG.player_role = $2"+
+		"WHERE users_games.guild_id = $1 AND users_games.player_role = ANY($2) and uG.player_role = ANY($2) "+
 		"GROUP BY users_games.user_id, uG.user_id "+
 		"HAVING COUNT(users_games.player_won) >= $3 "+
-		"ORDER BY win_rate DESC, win DESC, total DESC", guildID, role, leaderboardMin)
+		"ORDER BY win_rate DESC, win DESC, total DESC", guildID, game.RolesInTeam(team), leaderboardMin)
 
 	if err != nil {
-		log.Println(err)
+		logger.Error("Error querying stats", err, "query", "BestTeammateForServerByRole", logging.GuildIDKey, guildID)
 	}
 	return r
 }
 
-func (psqlInterface *PsqlInterface) WorstTeammateForServerByRole(guildID string, role int16, leaderboardMin int) []*PostgresWorstTeammatePlayerRanking {
+func (psqlInterface *PsqlInterface) WorstTeammateForServerByRole(guildID string, team game.Team, leaderboardMin int) []*PostgresWorstTeammatePlayerRanking {
 	var r []*PostgresWorstTeammatePlayerRanking
 	err := pgxscan.Select(context.Background(), psqlInterface.Pool, &r, "SELECT DISTINCT "+
 		"CASE WHEN users_games.user_id > uG.user_id THEN users_games.user_id ELSE uG.user_id END, "+
@@ -534,38 +709,73 @@ func (psqlInterface *PsqlInterface) WorstTeammateForServerByRole(guildID string,
 		"(COUNT(users_games.user_id) FILTER ( WHERE users_games.player_won = FALSE )::decimal / COUNT(*)) * 100 AS loose_rate "+
 		"FROM users_games "+
 		"INNER JOIN users_games uG ON users_games.game_id = uG.game_id AND users_games.user_id <> uG.user_id "+
-		"WHERE users_games.guild_id = $1 AND users_games.player_role = $2 AND uG.player_role = $2"+
+		"WHERE users_games.guild_id = $1 AND users_games.player_role = ANY($2) AND uG.player_role = ANY($2) "+
 		"GROUP BY users_games.user_id, uG.user_id "+
 		"HAVING COUNT(users_games.player_won) >= $3 "+
-		"ORDER BY loose_rate DESC, loose DESC, total DESC", guildID, role, leaderboardMin)
+		"ORDER BY loose_rate DESC, loose DESC, total DESC", guildID, game.RolesInTeam(team), leaderboardMin)
 
 	if err != nil {
-		log.Println(err)
+		logger.Error("Error querying stats", err, "query", "WorstTeammateForServerByRole", logging.GuildIDKey, guildID)
 	}
 	return r
 }
 
-func (psqlInterface *PsqlInterface) UserWinByActionAndRole(userdID, guildID string, action string, role int16) []*PostgresUserActionRanking {
+// UserMeetingStatsOnServer summarizes the meetings the user called and the votes they cast, from the meeting and vote
+// events. Votes are matched to their target's role by the target's name in the same game
+func (psqlInterface *PsqlInterface) UserMeetingStatsOnServer(userID, guildID string) (PostgresUserMeetingStats, error) {
+	var r PostgresUserMeetingStats
+	err := pgxscan.Get(context.Background(), psqlInterface.Pool, &r, "SELECT "+
+		"COUNT(*) FILTER ( WHERE ge.event_type = $3 ) AS meetings_called, "+
+		"COUNT(*) FILTER ( WHERE ge.event_type = $3 AND ge.payload ? 'Reported' ) AS bodies_reported, "+
+		"COUNT(*) FILTER ( WHERE ge.event_type = $4 AND ge.payload ? 'Voter' ) AS votes, "+
+		"COUNT(*) FILTER ( WHERE ge.event_type = $4 AND ge.payload ? 'Voter' AND NOT ge.payload ? 'Target' ) AS skips, "+
+		"COUNT(*) FILTER ( WHERE ge.event_type = $4 AND ug.player_role = ANY($5) AND target.player_role IS NOT NULL ) AS crew_votes, "+
+		"COUNT(*) FILTER ( WHERE ge.event_type = $4 AND ug.player_role = ANY($5) AND target.player_role = ANY($6) ) AS correct_votes, "+
+		"COUNT(*) FILTER ( WHERE ge.event_type = $4 AND ge.payload ? 'Exiled' AND ug.player_role = ANY($5) ) AS voted_out_as_crew "+
+		"FROM game_events ge "+
+		"INNER JOIN users_games ug ON ug.game_id = ge.game_id AND ug.user_id = ge.user_id "+
+		"LEFT JOIN users_games target ON target.game_id = ge.game_id AND target.player_name = ge.payload ->> 'Target' "+
+		"WHERE ge.user_id = $1 AND ug.guild_id = $2;",
+		userID, guildID, int16(capture.Meeting), int16(capture.Vote), game.RolesInTeam(game.CrewTeam), game.RolesInTeam(game.ImpostorTeam))
+	return r, err
+}
+
+// UserPlayerHistoryOnServer counts the games each of the users played on the server, by the name and color they played as
+func (psqlInterface *PsqlInterface) UserPlayerHistoryOnServer(guildID string, userIDs []uint64) ([]*PostgresUserPlayerHistory, error) {
+	gid, err := strconv.ParseUint(guildID, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	var r []*PostgresUserPlayerHistory
+	err = pgxscan.Select(context.Background(), psqlInterface.Pool, &r, "SELECT user_id, player_name, player_color, COUNT(*) AS count "+
+		"FROM users_games "+
+		"WHERE guild_id = $1 AND user_id = ANY($2) "+
+		"GROUP BY user_id, player_name, player_color;", gid, userIDs)
+	return r, err
+}
+
+func (psqlInterface *PsqlInterface) UserWinByActionAndRole(userdID, guildID string, action string, team game.Team) []*PostgresUserActionRanking {
 	var r []*PostgresUserActionRanking
 	err := pgxscan.Select(context.Background(), psqlInterface.Pool, &r, "SELECT users_games.user_id, "+
 		"COUNT(ge.user_id) FILTER ( WHERE payload ->> 'Action' = $1 ) as total_action, "+
 		"total_user.total as total, "+
 		"total_user.win_rate as win_rate "+
 		"FROM users_games "+
-		"LEFT JOIN (SELECT user_id, guild_id, player_role, "+
+		"LEFT JOIN (SELECT user_id, guild_id, "+
 		"COUNT(users_games.player_won) as total, "+
 		"(COUNT(users_games.user_id) FILTER ( WHERE users_games.player_won = TRUE )::decimal / COUNT(*)) * 100 AS win_rate "+
 		"FROM users_games "+
-		"GROUP BY user_id, player_role, guild_id "+
-		") total_user on total_user.user_id = users_games.user_id and users_games.player_role = total_user.player_role and users_games.guild_id = total_user.guild_id "+
+		"WHERE player_role = ANY($4) "+
+		"GROUP BY user_id, guild_id "+
+		") total_user on total_user.user_id = users_games.user_id and users_games.guild_id = total_user.guild_id "+
 		"LEFT JOIN game_events ge ON users_games.game_id = ge.game_id AND ge.user_id = users_games.user_id "+
 		"WHERE users_games.user_id = $2 AND users_games.guild_id = $3 "+
-		"AND users_games.player_role = $4 "+
+		"AND users_games.player_role = ANY($4) "+
 		"GROUP BY users_games.user_id, total, win_rate "+
-		"ORDER BY win_rate DESC, total DESC;", action, userdID, guildID, role)
+		"ORDER BY win_rate DESC, total DESC;", action, userdID, guildID, game.RolesInTeam(team))
 
 	if err != nil {
-		log.Println(err)
+		logger.Error("Error querying stats", err, "query", "UserWinByActionAndRole", "user_id", userdID, logging.GuildIDKey, guildID)
 	}
 	return r
 }
@@ -580,14 +790,14 @@ func (psqlInterface *PsqlInterface) UserFrequentFirstTarget(userID, guildID stri
 		"FROM game_events WHERE game_events.game_id = users_games.game_id AND payload ->> 'Action' = $1 "+
 		"ORDER BY event_time FETCH FIRST 1 ROW ONLY ) AS ge ON TRUE "+
 		"LEFT JOIN LATERAL (SELECT count(*) AS total "+
-		"FROM users_games WHERE users_games.user_id = ge.user_id AND users_games.guild_id = $2 AND player_role = 0) AS TOTAL_GAME ON TRUE "+
+		"FROM users_games WHERE users_games.user_id = ge.user_id AND users_games.guild_id = $2 AND player_role = ANY($5)) AS TOTAL_GAME ON TRUE "+
 		"WHERE users_games.guild_id = $2 AND users_games.user_id = ge.user_id AND users_games.user_id = $3"+
 		"GROUP BY users_games.user_id, total  "+
 		"ORDER BY total_death DESC "+
-		"LIMIT $4;", action, guildID, userID, leaderboardSize)
+		"LIMIT $4;", action, guildID, userID, leaderboardSize, game.RolesInTeam(game.CrewTeam))
 
 	if err != nil {
-		log.Println(err)
+		logger.Error("Error querying stats", err, "query", "UserFrequentFirstTarget", "user_id", userID, logging.GuildIDKey, guildID)
 	}
 	return r
 }
@@ -602,35 +812,70 @@ func (psqlInterface *PsqlInterface) UserMostFrequentFirstTargetForServer(guildID
 		"FROM game_events WHERE game_events.game_id = users_games.game_id AND payload ->> 'Action' = $1 "+
 		"ORDER BY event_time FETCH FIRST 1 ROW ONLY ) AS ge ON TRUE "+
 		"LEFT JOIN LATERAL (SELECT COUNT(*) AS total "+
-		"FROM users_games WHERE users_games.user_id = ge.user_id AND users_games.guild_id = $2 AND player_role = 0) AS TOTAL_GAME ON TRUE "+
+		"FROM users_games WHERE users_games.user_id = ge.user_id AND users_games.guild_id = $2 AND player_role = ANY($4)) AS TOTAL_GAME ON TRUE "+
 		"WHERE users_games.guild_id = $2 AND users_games.user_id = ge.user_id AND total > 3"+
 		"GROUP BY users_games.user_id, total  "+
 		"ORDER BY death_rate DESC, total_death DESC "+
-		"LIMIT $3;", action, guildID, leaderboardSize)
+		"LIMIT $3;", action, guildID, leaderboardSize, game.RolesInTeam(game.CrewTeam))
 
 	if err != nil {
-		log.Println(err)
+		logger.Error("Error querying stats", err, "query", "UserMostFrequentFirstTargetForServer", logging.GuildIDKey, guildID)
 	}
 	return r
 }
 
+// DeadliestImpostorsForServer ranks the guild's impostors by the kills attributed to them per game they played as an
+// impostor. Only attributed kills count, since guessing would credit every impostor with each kill
+func (psqlInterface *PsqlInterface) DeadliestImpostorsForServer(guildID string, leaderboardSize int) []*PostgresUserKillRanking {
+	var r []*PostgresUserKillRanking
+	err := pgxscan.Select(context.Background(), psqlInterface.Pool, &r, "SELECT ke.user_id, "+
+		"COUNT(*) as total_kills, "+
+		"impostor_games.total as total, "+
+		"COUNT(*)::decimal / impostor_games.total as kill_rate "+
+		"FROM game_events ke "+
+		"INNER JOIN games ON games.game_id = ke.game_id AND games.guild_id = $1 "+
+		"INNER JOIN (SELECT user_id, COUNT(*) as total FROM users_games "+
+		"WHERE guild_id = $1 AND player_role = ANY($2) "+
+		"GROUP BY user_id) impostor_games ON impostor_games.user_id = ke.user_id "+
+		"WHERE ke.event_type = $3 "+
+		"GROUP BY ke.user_id, impostor_games.total "+
+		"ORDER BY kill_rate DESC, total_kills DESC "+
+		"LIMIT $4;", guildID, game.RolesInTeam(game.ImpostorTeam), int16(capture.Kill), leaderboardSize)
+	if err != nil {
+		logger.Error("Error querying stats", err, "query", "DeadliestImpostorsForServer", logging.GuildIDKey, guildID)
+	}
+	return r
+}
+
+// killedByCondition is true for deaths that can be blamed on the impostor usG: either the death was attributed to them
+// by a kill event, or it wasn't attributed at all, so every impostor in the game is blamed
+const killedByCondition = "ge.payload ->> 'Action' = $1 AND (ke.event_id IS NULL OR ke.user_id = usG.user_id)"
+
+// killJoin joins the kill event attributing the death ge (if there is one), with the kill event type passed as the
+// query's param'th arg
+func killJoin(param int) string {
+	return "LEFT JOIN game_events ke ON ke.game_id = ge.game_id AND ge.payload ->> 'Action' = $1 " +
+		fmt.Sprintf("AND ke.event_type = $%d AND ke.payload ->> 'Victim' = users_games.player_name ", param)
+}
+
 func (psqlInterface *PsqlInterface) UserMostFrequentKilledBy(userID, guildID string) []*PostgresUserMostFrequentKilledByanking {
 	var r []*PostgresUserMostFrequentKilledByanking
 	err := pgxscan.Select(context.Background(), psqlInterface.Pool, &r, "SELECT users_games.user_id, "+
 		"usG.user_id as teammate_id, "+
-		"COUNT(ge.user_id) FILTER ( WHERE payload ->> 'Action' = $1 ) as total_death, "+
-		"COUNT(usG.user_id) as encounter, (COUNT(ge.user_id) FILTER ( WHERE payload ->> 'Action' = $1 ))::decimal/count(usG.player_name) * 100 as death_rate "+
+		"COUNT(ge.user_id) FILTER ( WHERE "+killedByCondition+" ) as total_death, "+
+		"COUNT(usG.user_id) as encounter, (COUNT(ge.user_id) FILTER ( WHERE "+killedByCondition+" ))::decimal/count(usG.player_name) * 100 as death_rate "+
 		"FROM users_games "+
-		"LEFT JOIN users_games usG on users_games.game_id = usG.game_id and usG.player_role = $2 "+
-		"LEFT JOIN (SELECT user_id, guild_id, player_role, COUNT(users_games.player_won) as total "+
-		"FROM users_games "+
-		"GROUP BY user_id, player_role, guild_id) total_user on total_user.user_id = users_games.user_id and users_games.player_role = total_user.player_role and users_games.guild_id = total_user.guild_id "+
+		"LEFT JOIN users_games usG on users_games.game_id = usG.game_id and usG.player_role = ANY($2) "+
+		"LEFT JOIN (SELECT user_id, guild_id, COUNT(users_games.player_won) as total "+
+		"FROM users_games WHERE player_role = ANY($5) "+
+		"GROUP BY user_id, guild_id) total_user on total_user.user_id = users_games.user_id and users_games.guild_id = total_user.guild_id "+
 		"LEFT JOIN game_events ge ON users_games.game_id = ge.game_id AND ge.user_id = $3 "+
-		"WHERE users_games.guild_id = $4 AND users_games.user_id = $3 AND users_games.player_role = $5 "+
+		killJoin(6)+
+		"WHERE users_games.guild_id = $4 AND users_games.user_id = $3 AND users_games.player_role = ANY($5) "+
 		"GROUP BY users_games.user_id, usG.user_id, users_games.user_id, total "+
-		"ORDER BY death_rate DESC, total_death DESC, encounter DESC;", strconv.Itoa(int(game.DIED)), strconv.Itoa(int(game.ImposterRole)), userID, guildID, strconv.Itoa(int(game.CrewmateRole)))
+		"ORDER BY death_rate DESC, total_death DESC, encounter DESC;", strconv.Itoa(int(game.DIED)), game.RolesInTeam(game.ImpostorTeam), userID, guildID, game.RolesInTeam(game.CrewTeam), int16(capture.Kill))
 	if err != nil {
-		log.Println(err)
+		logger.Error("Error querying stats", err, "query", "UserMostFrequentKilledBy", "user_id", userID, logging.GuildIDKey, guildID)
 	}
 	return r
 }
@@ -639,19 +884,20 @@ func (psqlInterface *PsqlInterface) UserMostFrequentKilledByServer(guildID strin
 	var r []*PostgresUserMostFrequentKilledByanking
 	err := pgxscan.Select(context.Background(), psqlInterface.Pool, &r, "SELECT users_games.user_id, "+
 		"usG.user_id as teammate_id, "+
-		"COUNT(ge.user_id) FILTER ( WHERE payload ->> 'Action' = $1 ) as total_death, "+
-		"COUNT(usG.user_id) as encounter, (COUNT(ge.user_id) FILTER ( WHERE payload ->> 'Action' = $1 ))::decimal/count(usG.player_name) * 100 as death_rate "+
-		"FROM users_games "+
-		"INNER JOIN users_games usG on users_games.game_id = usG.game_id and usG.player_role = $2 "+
-		"INNER JOIN (SELECT user_id, guild_id, player_role, COUNT(users_games.player_won) as total "+
+		"COUNT(ge.user_id) FILTER ( WHERE "+killedByCondition+" ) as total_death, "+
+		"COUNT(usG.user_id) as encounter, (COUNT(ge.user_id) FILTER ( WHERE "+killedByCondition+" ))::decimal/count(usG.player_name) * 100 as death_rate "+
 		"FROM users_games "+
-		"GROUP BY user_id, player_role, guild_id) total_user on total_user.user_id = users_games.user_id and users_games.player_role = total_user.player_role and users_games.guild_id = total_user.guild_id "+
+		"INNER JOIN users_games usG on users_games.game_id = usG.game_id and usG.player_role = ANY($2) "+
+		"INNER JOIN (SELECT user_id, guild_id, COUNT(users_games.player_won) as total "+
+		"FROM users_games WHERE player_role = ANY($4) "+
+		"GROUP BY user_id, guild_id) total_user on total_user.user_id = users_games.user_id and users_games.guild_id = total_user.guild_id "+
 		"INNER JOIN game_events ge ON users_games.game_id = ge.game_id AND ge.user_id = users_games.user_id "+
-		"WHERE users_games.guild_id = $3 AND users_games.player_role = $4 "+
+		killJoin(5)+
+		"WHERE users_games.guild_id = $3 AND users_games.player_role = ANY($4) "+
 		"GROUP BY users_games.user_id, usG.user_id, users_games.user_id, total "+
-		"ORDER BY death_rate DESC, total_death DESC, encounter DESC;", strconv.Itoa(int(game.DIED)), strconv.Itoa(int(game.ImposterRole)), guildID, strconv.Itoa(int(game.CrewmateRole)))
+		"ORDER BY death_rate DESC, total_death DESC, encounter DESC;", strconv.Itoa(int(game.DIED)), game.RolesInTeam(game.ImpostorTeam), guildID, game.RolesInTeam(game.CrewTeam), int16(capture.Kill))
 	if err != nil {
-		log.Println(err)
+		logger.Error("Error querying stats", err, "query", "UserMostFrequentKilledByServer", logging.GuildIDKey, guildID)
 	}
 	return r
 }
@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"encoding/json"
+	"github.com/automuteus/automuteus/v8/pkg/capture"
+	"github.com/automuteus/automuteus/v8/pkg/game"
+	"testing"
+	"time"
+)
+
+func TestStatsFromGameAndEvents_meetings(t *testing.T) {
+	pgame := &PostgresGame{
+		StartTime: 100,
+		EndTime:   400,
+		WinType:   0,
+	}
+	events := []*PostgresGameEvent{
+		{EventTime: 110, EventType: int16(capture.State), Payload: TasksCode},
+		{EventTime: 150, EventType: int16(capture.Meeting), Payload: `{"Caller":"Alice","Reported":"Bob"}`},
+		{EventTime: 150, EventType: int16(capture.State), Payload: DiscussCode},
+		{EventTime: 200, EventType: int16(capture.Vote), Payload: `{"Voter":"Alice","Target":"Carol"}`},
+		{EventTime: 200, EventType: int16(capture.Vote), Payload: `{"Voter":"Carol"}`},
+		{EventTime: 200, EventType: int16(capture.Vote), Payload: `{"Exiled":"Carol"}`},
+		{EventTime: 250, EventType: int16(capture.Meeting), Payload: `{"Caller":"Alice"}`},
+	}
+	stats := StatsFromGameAndEvents(pgame, events)
+	if stats.NumMeetings != 1 {
+		t.Errorf("Expected 1 meeting from the discussion phases, got %d", stats.NumMeetings)
+	}
+	if stats.NumReports != 1 {
+		t.Errorf("Expected 1 reported body, got %d", stats.NumReports)
+	}
+	if stats.NumVotes != 2 || stats.NumSkips != 1 {
+		t.Errorf("Expected 2 votes with 1 skip, got %d votes with %d skips", stats.NumVotes, stats.NumSkips)
+	}
+	meetingEvents := 0
+	for _, v := range stats.Events {
+		if v.EventType == MeetingCalled {
+			meetingEvents++
+		}
+	}
+	if meetingEvents != 2 {
+		t.Errorf("Expected 2 meeting events, got %d", meetingEvents)
+	}
+}
+
+func TestPostgresUserMeetingStats_Rates(t *testing.T) {
+	var empty PostgresUserMeetingStats
+	if empty.SkipRate() != 0 || empty.CorrectVoteRate() != 0 {
+		t.Error("Expected a user without votes to have rates of 0")
+	}
+	s := PostgresUserMeetingStats{Votes: 4, Skips: 1, CrewVotes: 2, CorrectVotes: 1}
+	if s.SkipRate() != 25 || s.CorrectVoteRate() != 50 {
+		t.Errorf("Unexpected rates %v and %v", s.SkipRate(), s.CorrectVoteRate())
+	}
+}
+
+func TestStatsFromGameAndEvents_kills(t *testing.T) {
+	pgame := &PostgresGame{
+		StartTime: 100,
+		EndTime:   400,
+	}
+	events := []*PostgresGameEvent{
+		{EventTime: 120, EventType: int16(capture.Player), Payload: `{"Action":2,"Name":"Alice","IsDead":true,"Killer":"Dave"}`},
+		{EventTime: 130, EventType: int16(capture.Player), Payload: `{"Action":2,"Name":"Bob","IsDead":true}`},
+		{EventTime: 130, EventType: int16(capture.Kill), Payload: `{"Killer":"Erin","Victim":"Bob"}`},
+		{EventTime: 140, EventType: int16(capture.Player), Payload: `{"Action":2,"Name":"Carol","IsDead":true}`},
+	}
+	stats := StatsFromGameAndEvents(pgame, events)
+	if stats.NumDeaths != 3 {
+		t.Fatalf("Expected 3 deaths, got %d", stats.NumDeaths)
+	}
+	expected := []string{"Dave", "Erin", ""}
+	for i, v := range stats.Events {
+		player := game.Player{}
+		if err := json.Unmarshal([]byte(v.Data), &player); err != nil {
+			t.Fatal(err)
+		}
+		if player.Killer != expected[i] {
+			t.Errorf("Expected %s's killer to be %q, got %q", player.Name, expected[i], player.Killer)
+		}
+	}
+}
+
+func TestStatsFromGameAndEvents_taskProgress(t *testing.T) {
+	pgame := &PostgresGame{
+		StartTime: 100,
+		EndTime:   400,
+	}
+	events := []*PostgresGameEvent{
+		{EventTime: 110, EventType: int16(capture.State), Payload: TasksCode},
+		{EventTime: 130, EventType: int16(capture.State), Payload: DiscussCode},
+		{EventTime: 160, EventType: int16(capture.TaskProgress), Payload: `{"Completed":10,"Total":40}`},
+		{EventTime: 200, EventType: int16(capture.TaskProgress), Payload: `{"Completed":30,"Total":40}`},
+		{EventTime: 220, EventType: int16(capture.State), Payload: DiscussCode},
+	}
+	stats := StatsFromGameAndEvents(pgame, events)
+	if len(stats.TaskProgress) != 2 || stats.TaskProgress[0].Percent != 25 || stats.TaskProgress[1].Percent != 75 {
+		t.Fatalf("Unexpected task progress curve %+v", stats.TaskProgress)
+	}
+	if stats.TaskProgress[1].EventTimeOffset != 100*time.Second {
+		t.Errorf("Expected the progress to be 100s into the game, got %s", stats.TaskProgress[1].EventTimeOffset)
+	}
+	if stats.Events[1].Data != "" || stats.Events[2].Data != "75" {
+		t.Errorf("Expected only the second discussion to be annotated with the task progress, got %+v", stats.Events)
+	}
+}
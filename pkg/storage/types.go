@@ -3,6 +3,7 @@ package storage
 import (
 	"bytes"
 	"fmt"
+	"github.com/automuteus/automuteus/v8/pkg/game"
 )
 
 type PostgresGuild struct {
@@ -14,7 +15,7 @@ type PostgresGuild struct {
 	InheritsFrom  *uint64 `db:"inherits_from"`
 }
 
-func nilToEmpty[T int32 | uint64](s *T) string {
+func nilToEmpty[T int16 | int32 | uint64 | float32 | bool](s *T) string {
 	if s == nil {
 		return ""
 	} else {
@@ -35,14 +36,42 @@ type PostgresGame struct {
 	StartTime   int32  `db:"start_time"`
 	WinType     int16  `db:"win_type"`
 	EndTime     int32  `db:"end_time"`
+	GameMode    int16  `db:"game_mode"`
+
+	// the lobby's options, which are nil if the capture didn't report them
+	NumImpostors   *int16   `db:"num_impostors"`
+	DiscussionTime *int16   `db:"discussion_time"`
+	VotingTime     *int16   `db:"voting_time"`
+	KillCooldown   *float32 `db:"kill_cooldown"`
+	ConfirmEjects  *bool    `db:"confirm_ejects"`
+	PlayerSpeed    *float32 `db:"player_speed"`
+}
+
+// SetLobbyOptions records the options the game was played with
+func (g *PostgresGame) SetLobbyOptions(opts *game.LobbyOptions) {
+	if opts == nil {
+		return
+	}
+	numImpostors, discussionTime, votingTime := int16(opts.NumImpostors), int16(opts.DiscussionTime), int16(opts.VotingTime)
+	killCooldown, playerSpeed := float32(opts.KillCooldown), float32(opts.PlayerSpeed)
+	confirmEjects := opts.ConfirmEjects
+	g.NumImpostors = &numImpostors
+	g.DiscussionTime = &discussionTime
+	g.VotingTime = &votingTime
+	g.KillCooldown = &killCooldown
+	g.ConfirmEjects = &confirmEjects
+	g.PlayerSpeed = &playerSpeed
 }
 
 func GamesToCSV(g []*PostgresGame) string {
-	s := bytes.NewBufferString("game_id,guild_id,connect_code,start_time,win_type,end_time,\n")
+	s := bytes.NewBufferString("game_id,guild_id,connect_code,start_time,win_type,end_time,game_mode," +
+		"num_impostors,discussion_time,voting_time,kill_cooldown,confirm_ejects,player_speed,\n")
 	for _, v := range g {
 		if v != nil {
-			s.WriteString(fmt.Sprintf("%d,%d,%s,%d,%d,%d,\n",
-				v.GameID, v.GuildID, v.ConnectCode, v.StartTime, v.WinType, v.EndTime))
+			s.WriteString(fmt.Sprintf("%d,%d,%s,%d,%d,%d,%d,%s,%s,%s,%s,%s,%s,\n",
+				v.GameID, v.GuildID, v.ConnectCode, v.StartTime, v.WinType, v.EndTime, v.GameMode,
+				nilToEmpty(v.NumImpostors), nilToEmpty(v.DiscussionTime), nilToEmpty(v.VotingTime),
+				nilToEmpty(v.KillCooldown), nilToEmpty(v.ConfirmEjects), nilToEmpty(v.PlayerSpeed)))
 		}
 	}
 	return s.String()
@@ -85,6 +114,14 @@ func UsersGamesToCSV(ug []*PostgresUserGame) string {
 	return s.String()
 }
 
+// PostgresUserPlayerHistory is how many games a user played under a name and color
+type PostgresUserPlayerHistory struct {
+	UserID      uint64 `db:"user_id"`
+	PlayerName  string `db:"player_name"`
+	PlayerColor int16  `db:"player_color"`
+	Count       int64  `db:"count"`
+}
+
 type PostgresGameEvent struct {
 	EventID   uint64  `db:"event_id"`
 	UserID    *uint64 `db:"user_id"`
@@ -141,6 +178,34 @@ type PostgresUserActionRanking struct {
 	WinRate     float64 `db:"win_rate"`
 }
 
+// PostgresUserMeetingStats summarizes a user's meetings and votes. CrewVotes only counts votes for linked players,
+// whose roles are known, so it's the number CorrectVotes is out of
+type PostgresUserMeetingStats struct {
+	MeetingsCalled int64 `db:"meetings_called"`
+	BodiesReported int64 `db:"bodies_reported"`
+	Votes          int64 `db:"votes"`
+	Skips          int64 `db:"skips"`
+	CrewVotes      int64 `db:"crew_votes"`
+	CorrectVotes   int64 `db:"correct_votes"`
+	VotedOutAsCrew int64 `db:"voted_out_as_crew"`
+}
+
+// SkipRate is the percentage of the user's votes that were skips
+func (s PostgresUserMeetingStats) SkipRate() float64 {
+	if s.Votes == 0 {
+		return 0
+	}
+	return 100.0 * float64(s.Skips) / float64(s.Votes)
+}
+
+// CorrectVoteRate is the percentage of the user's votes as a crewmate that were for an impostor
+func (s PostgresUserMeetingStats) CorrectVoteRate() float64 {
+	if s.CrewVotes == 0 {
+		return 0
+	}
+	return 100.0 * float64(s.CorrectVotes) / float64(s.CrewVotes)
+}
+
 type PostgresUserMostFrequentFirstTargetRanking struct {
 	UserID     uint64  `db:"user_id"`
 	TotalDeath int64   `db:"total_death"`
@@ -148,6 +213,13 @@ type PostgresUserMostFrequentFirstTargetRanking struct {
 	DeathRate  float64 `db:"death_rate"`
 }
 
+type PostgresUserKillRanking struct {
+	UserID     uint64  `db:"user_id"`
+	TotalKills int64   `db:"total_kills"`
+	Count      int64   `db:"total"`
+	KillRate   float64 `db:"kill_rate"`
+}
+
 type PostgresUserMostFrequentKilledByanking struct {
 	UserID     uint64  `db:"user_id"`
 	TeammateID uint64  `db:"teammate_id"`
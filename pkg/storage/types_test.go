@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"github.com/automuteus/automuteus/v8/pkg/game"
 	"github.com/automuteus/automuteus/v8/pkg/premium"
 	"strings"
 	"testing"
@@ -43,10 +44,23 @@ func TestGamesToCSV(t *testing.T) {
 		StartTime:   2,
 		WinType:     3,
 		EndTime:     4,
+		GameMode:    1,
 	}
-	if strings.Split(GamesToCSV(games), "\n")[1] != "0,1,a,2,3,4," {
+	if strings.Split(GamesToCSV(games), "\n")[1] != "0,1,a,2,3,4,1,,,,,,," {
 		t.Error("Games to CSV didn't match expected value")
 	}
+
+	games[0].SetLobbyOptions(&game.LobbyOptions{
+		NumImpostors:   2,
+		DiscussionTime: 15,
+		VotingTime:     120,
+		KillCooldown:   22.5,
+		ConfirmEjects:  true,
+		PlayerSpeed:    1.25,
+	})
+	if strings.Split(GamesToCSV(games), "\n")[1] != "0,1,a,2,3,4,1,2,15,120,22.5,true,1.25," {
+		t.Error("Games to CSV didn't include the lobby options")
+	}
 }
 
 func TestEventsToCSV(t *testing.T) {
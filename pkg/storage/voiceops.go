@@ -0,0 +1,36 @@
+package storage
+
+import (
+	"context"
+	"github.com/automuteus/automuteus/v8/pkg/task"
+	"strconv"
+)
+
+// InsertVoiceOp records a voice operation the bot sent in the guild. Operations are only recorded in Postgres when
+// VOICE_LOG_POSTGRES is set; otherwise they're just kept in the guild's voice log in Redis
+func (psqlInterface *PsqlInterface) InsertVoiceOp(guildID string, op task.VoiceOp) error {
+	gid, err := strconv.ParseUint(guildID, 10, 64)
+	if err != nil {
+		return err
+	}
+	conn, err := psqlInterface.Pool.Acquire(context.Background())
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+	return insertVoiceOp(conn.Conn(), gid, op)
+}
+
+func insertVoiceOp(conn PgxIface, guildID uint64, op task.VoiceOp) error {
+	_, err := conn.Exec(context.Background(), "INSERT INTO voice_ops VALUES (DEFAULT, $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12);",
+		guildID, op.ConnectCode, op.UserID, op.Mute, op.Deaf, nilIfEmpty(op.ChannelID), op.Path, nilIfEmpty(op.TokenHash),
+		op.LatencyMs, nilIfEmpty(op.Error), nilIfEmpty(op.Phase), op.Time.UnixMilli())
+	return err
+}
+
+func nilIfEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"github.com/automuteus/automuteus/v8/pkg/task"
+	"github.com/jackc/pgconn"
+	"github.com/pashagolub/pgxmock"
+	"testing"
+	"time"
+)
+
+func TestInsertVoiceOp(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	op := task.VoiceOp{
+		Time:        time.UnixMilli(1650000000000),
+		ConnectCode: "ABCDEFGH",
+		UserID:      UserIDInt,
+		Mute:        true,
+		Path:        task.VoicePathOfficial,
+		LatencyMs:   120,
+		Phase:       "TASKS",
+	}
+	phase := "TASKS"
+	// empty optional fields are stored as NULL
+	mock.ExpectExec("^INSERT INTO voice_ops VALUES (.+)$").
+		WithArgs(GuildIDInt, "ABCDEFGH", UserIDInt, true, false, (*string)(nil), task.VoicePathOfficial, (*string)(nil),
+			int64(120), (*string)(nil), &phase, int64(1650000000000)).
+		WillReturnResult(pgconn.CommandTag("INSERT 0 1"))
+
+	err = insertVoiceOp(mock, GuildIDInt, op)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
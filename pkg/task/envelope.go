@@ -0,0 +1,209 @@
+package task
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/automuteus/automuteus/v8/pkg/game"
+	"strconv"
+)
+
+// JobVersion is the newest job envelope version the bot understands. Producers that predate versioning don't send a
+// version at all, and their jobs are treated as version 1 (the format is identical)
+const JobVersion = 1
+
+type RejectReason string
+
+const (
+	RejectMalformed RejectReason = "malformed"
+	RejectVersion   RejectReason = "version"
+	RejectType      RejectReason = "type"
+	RejectPayload   RejectReason = "payload"
+)
+
+// RejectError is returned when a job can't be decoded, or doesn't pass validation
+type RejectError struct {
+	Reason RejectReason
+	Err    error
+}
+
+func (e *RejectError) Error() string {
+	return fmt.Sprintf("rejected job (%s): %s", e.Reason, e.Err)
+}
+
+func (e *RejectError) Unwrap() error {
+	return e.Err
+}
+
+func reject(reason RejectReason, format string, args ...interface{}) *RejectError {
+	return &RejectError{
+		Reason: reason,
+		Err:    fmt.Errorf(format, args...),
+	}
+}
+
+// envelope is how a Job is encoded on the wire; the payload is always a string, and is parsed according to the type
+type envelope struct {
+	Version int             `json:"version,omitempty"`
+	Seq     uint64          `json:"seq,omitempty"`
+	Source  string          `json:"source,omitempty"`
+	JobType JobType         `json:"type"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// NewJob validates a payload for the job type, and returns the Job a producer would push for it
+func NewJob(jobType JobType, payload string) (Job, error) {
+	job := Job{
+		Version: JobVersion,
+		JobType: jobType,
+		Payload: payload,
+	}
+	return job, job.decodePayload()
+}
+
+// DecodeJob decodes and validates an encoded job. Any error returned is a *RejectError
+func DecodeJob(b []byte) (Job, error) {
+	var job Job
+	err := json.Unmarshal(b, &job)
+	var rejectErr *RejectError
+	if err != nil && !errors.As(err, &rejectErr) {
+		// not even valid JSON, so UnmarshalJSON was never called
+		return job, &RejectError{Reason: RejectMalformed, Err: err}
+	}
+	return job, err
+}
+
+func (job Job) MarshalJSON() ([]byte, error) {
+	payload, err := json.Marshal(job.Payload)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(envelope{
+		Version: job.Version,
+		Seq:     job.Seq,
+		Source:  job.Source,
+		JobType: job.JobType,
+		Payload: payload,
+	})
+}
+
+// UnmarshalJSON decodes and validates a job envelope. Any error returned is a *RejectError
+func (job *Job) UnmarshalJSON(b []byte) error {
+	var env envelope
+	err := json.Unmarshal(b, &env)
+	if err != nil {
+		return &RejectError{Reason: RejectMalformed, Err: err}
+	}
+	if env.Version == 0 {
+		env.Version = 1
+	}
+	if env.Version < 0 || env.Version > JobVersion {
+		return reject(RejectVersion, "unsupported version %d", env.Version)
+	}
+	var payload string
+	err = json.Unmarshal(env.Payload, &payload)
+	if err != nil {
+		return reject(RejectPayload, "payload for job type %d is not a string", env.JobType)
+	}
+	*job = Job{
+		Version: env.Version,
+		Seq:     env.Seq,
+		Source:  env.Source,
+		JobType: env.JobType,
+		Payload: payload,
+	}
+	return job.decodePayload()
+}
+
+// decodePayload parses the payload into the typed field for the job's type, and validates it
+func (job *Job) decodePayload() error {
+	switch job.JobType {
+	case ConnectionJob:
+		connected, err := strconv.ParseBool(job.Payload)
+		if err != nil {
+			return reject(RejectPayload, "invalid connection payload %s", job.Payload)
+		}
+		job.Connected = connected
+	case LobbyJob:
+		err := json.Unmarshal([]byte(job.Payload), &job.Lobby)
+		if err != nil {
+			return &RejectError{Reason: RejectPayload, Err: err}
+		}
+		if !game.ValidGameMode(job.Lobby.GameMode) {
+			return reject(RejectPayload, "invalid game mode %d", job.Lobby.GameMode)
+		}
+		if job.Lobby.Options != nil {
+			if err := job.Lobby.Options.Validate(); err != nil {
+				return &RejectError{Reason: RejectPayload, Err: err}
+			}
+		}
+	case StateJob:
+		num, err := strconv.ParseInt(job.Payload, 10, 64)
+		if err != nil {
+			return &RejectError{Reason: RejectPayload, Err: err}
+		}
+		job.Phase = game.Phase(num)
+		if job.Phase < game.LOBBY || job.Phase > game.GAMEOVER {
+			return reject(RejectPayload, "invalid phase %d", num)
+		}
+	case PlayerJob:
+		err := json.Unmarshal([]byte(job.Payload), &job.Player)
+		if err != nil {
+			return &RejectError{Reason: RejectPayload, Err: err}
+		}
+		if job.Player.Color < game.Red || job.Player.Color > game.Coral {
+			return reject(RejectPayload, "invalid color %d for player %s", job.Player.Color, job.Player.Name)
+		}
+		if job.Player.Action < game.JOINED || job.Player.Action > game.EXILED {
+			return reject(RejectPayload, "invalid action %d for player %s", job.Player.Action, job.Player.Name)
+		}
+		if job.Player.Killer != "" && job.Player.Action != game.DIED {
+			return reject(RejectPayload, "killer set on action %d for player %s", job.Player.Action, job.Player.Name)
+		}
+	case GameOverJob:
+		err := json.Unmarshal([]byte(job.Payload), &job.GameOver)
+		if err != nil {
+			return &RejectError{Reason: RejectPayload, Err: err}
+		}
+		if job.GameOver.GameOverReason < game.HumansByVote || job.GameOver.GameOverReason > game.Unknown {
+			return reject(RejectPayload, "invalid game over reason %d", job.GameOver.GameOverReason)
+		}
+	case MeetingJob:
+		err := json.Unmarshal([]byte(job.Payload), &job.Meeting)
+		if err != nil {
+			return &RejectError{Reason: RejectPayload, Err: err}
+		}
+		if job.Meeting.Caller == "" {
+			return reject(RejectPayload, "meeting has no caller")
+		}
+	case VoteJob:
+		err := json.Unmarshal([]byte(job.Payload), &job.Votes)
+		if err != nil {
+			return &RejectError{Reason: RejectPayload, Err: err}
+		}
+		for _, vote := range job.Votes.Votes {
+			if vote.Voter == "" {
+				return reject(RejectPayload, "vote has no voter")
+			}
+		}
+	case KillJob:
+		err := json.Unmarshal([]byte(job.Payload), &job.Kill)
+		if err != nil {
+			return &RejectError{Reason: RejectPayload, Err: err}
+		}
+		if job.Kill.Killer == "" || job.Kill.Victim == "" {
+			return reject(RejectPayload, "kill needs both a killer and a victim")
+		}
+	case TaskProgressJob:
+		err := json.Unmarshal([]byte(job.Payload), &job.Tasks)
+		if err != nil {
+			return &RejectError{Reason: RejectPayload, Err: err}
+		}
+		if err := job.Tasks.Validate(); err != nil {
+			return &RejectError{Reason: RejectPayload, Err: err}
+		}
+	default:
+		return reject(RejectType, "unknown job type %d", job.JobType)
+	}
+	return nil
+}
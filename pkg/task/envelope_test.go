@@ -0,0 +1,117 @@
+package task
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/automuteus/automuteus/v8/pkg/game"
+	"testing"
+)
+
+func TestJob_UnmarshalJSON(t *testing.T) {
+	var job Job
+	// jobs from producers that predate versioning have no version
+	err := json.Unmarshal([]byte(`{"type":3,"payload":"{\"Action\":2,\"Name\":\"Alice\",\"Color\":4,\"IsDead\":true,\"Disconnected\":false}"}`), &job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Version != 1 || job.JobType != PlayerJob {
+		t.Errorf("Expected a version 1 player job, got version %d type %d", job.Version, job.JobType)
+	}
+	if job.Player.Name != "Alice" || job.Player.Color != game.Orange || job.Player.Action != game.DIED || !job.Player.IsDead {
+		t.Errorf("Player payload was not decoded properly: %+v", job.Player)
+	}
+
+	err = json.Unmarshal([]byte(`{"version":1,"type":2,"payload":"2"}`), &job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Phase != game.DISCUSS {
+		t.Errorf("Expected the DISCUSS phase, got %d", job.Phase)
+	}
+
+	err = json.Unmarshal([]byte(`{"type":1,"payload":"{\"LobbyCode\":\"ABCDEF\",\"Options\":{\"NumImpostors\":2,\"DiscussionTime\":15,\"VotingTime\":120,\"ConfirmEjects\":true}}"}`), &job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Lobby.Options == nil || job.Lobby.Options.NumImpostors != 2 || job.Lobby.Options.VotingTime != 120 || !job.Lobby.Options.ConfirmEjects {
+		t.Errorf("Lobby options were not decoded properly: %+v", job.Lobby.Options)
+	}
+
+	err = json.Unmarshal([]byte(`{"type":6,"payload":"{\"Votes\":[{\"Voter\":\"Alice\",\"Target\":\"Bob\"},{\"Voter\":\"Bob\"}],\"Exiled\":\"Bob\"}"}`), &job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(job.Votes.Votes) != 2 || job.Votes.Votes[0].Skipped() || !job.Votes.Votes[1].Skipped() || job.Votes.Exiled != "Bob" {
+		t.Errorf("Votes were not decoded properly: %+v", job.Votes)
+	}
+
+	err = json.Unmarshal([]byte(`{"type":3,"payload":"{\"Action\":2,\"Name\":\"Alice\",\"IsDead\":true,\"Killer\":\"Bob\"}"}`), &job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Player.Killer != "Bob" {
+		t.Errorf("Expected the killer to be decoded, got %+v", job.Player)
+	}
+
+	err = json.Unmarshal([]byte(`{"type":8,"payload":"{\"Completed\":13,\"Total\":40}"}`), &job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Tasks.Completed != 13 || job.Tasks.Total != 40 || job.Tasks.Percent() != 32 {
+		t.Errorf("Task progress was not decoded properly: %+v", job.Tasks)
+	}
+}
+
+func TestJob_UnmarshalJSON_Rejects(t *testing.T) {
+	tests := map[string]RejectReason{
+		`not json`: RejectMalformed,
+		`{"version":2,"type":0,"payload":"true"}`:                                     RejectVersion,
+		`{"type":9,"payload":""}`:                                                     RejectType,
+		`{"type":0,"payload":true}`:                                                   RejectPayload,
+		`{"type":0,"payload":"yes please"}`:                                           RejectPayload,
+		`{"type":1,"payload":"{"}`:                                                    RejectPayload,
+		`{"type":1,"payload":"{\"GameMode\":5}"}`:                                     RejectPayload,
+		`{"type":1,"payload":"{\"Options\":{\"NumImpostors\":-1}}"}`:                  RejectPayload,
+		`{"type":2,"payload":"5"}`:                                                    RejectPayload,
+		`{"type":3,"payload":"{\"Name\":\"Alice\",\"Color\":18}"}`:                    RejectPayload,
+		`{"type":3,"payload":"{\"Name\":\"Alice\",\"Action\":7}"}`:                    RejectPayload,
+		`{"type":4,"payload":"{\"GameOverReason\":8,\"PlayerInfos\":[]}"}`:            RejectPayload,
+		`{"type":5,"payload":"{\"Reported\":\"Bob\"}"}`:                               RejectPayload,
+		`{"type":6,"payload":"{\"Votes\":[{\"Target\":\"Bob\"}]}"}`:                   RejectPayload,
+		`{"type":7,"payload":"{\"Killer\":\"Bob\"}"}`:                                 RejectPayload,
+		`{"type":3,"payload":"{\"Name\":\"Alice\",\"Action\":0,\"Killer\":\"Bob\"}"}`: RejectPayload,
+		`{"type":8,"payload":"{\"Completed\":3,\"Total\":0}"}`:                        RejectPayload,
+		`{"type":8,"payload":"{\"Completed\":12,\"Total\":10}"}`:                      RejectPayload,
+	}
+	for str, reason := range tests {
+		_, err := DecodeJob([]byte(str))
+		var rejectErr *RejectError
+		if !errors.As(err, &rejectErr) {
+			t.Errorf("Expected %s to be rejected, got error %v", str, err)
+			continue
+		}
+		if rejectErr.Reason != reason {
+			t.Errorf("Expected %s to be rejected for %s, got %s", str, reason, rejectErr.Reason)
+		}
+	}
+}
+
+func TestJob_MarshalJSON(t *testing.T) {
+	job, err := NewJob(LobbyJob, `{"LobbyCode":"ABCDEF","Region":2,"Map":4}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job.Seq = 12
+	b, err := json.Marshal(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Job
+	err = json.Unmarshal(b, &decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Version != JobVersion || decoded.Seq != 12 || decoded.Lobby.LobbyCode != "ABCDEF" || decoded.Lobby.Region != game.EU || decoded.Lobby.PlayMap != game.AIRSHIP {
+		t.Errorf("Job did not survive a round trip: %+v", decoded)
+	}
+}
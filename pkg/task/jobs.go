@@ -3,8 +3,12 @@ package task
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/automuteus/automuteus/v8/pkg/game"
 	"github.com/automuteus/automuteus/v8/pkg/rediskey"
 	"github.com/go-redis/redis/v8"
+	"strings"
 	"time"
 )
 
@@ -16,37 +20,92 @@ const (
 	StateJob
 	PlayerJob
 	GameOverJob
+	MeetingJob
+	VoteJob
+	KillJob
+	TaskProgressJob
 )
 
+// Job is a single event from a capture client, encoded as a versioned envelope (see envelope.go). Payload is the
+// payload as it was received; decoding a job parses it into the typed field for the job's type, e.g. Lobby for a
+// LobbyJob, so consumers never have to parse it themselves
 type Job struct {
-	JobType JobType     `json:"type"`
-	Payload interface{} `json:"payload"`
+	Version int
+	// Seq is the job's position in its source's sequence of jobs, counting from 1 each time the source connects.
+	// It's 0 if the producer doesn't sequence its jobs, in which case they're processed in the order they arrive
+	Seq uint64
+	// Source identifies the capture client that produced the job, when several are attached to the connect code.
+	// Sequence numbers are per source
+	Source  string
+	JobType JobType
+	Payload string
+
+	Connected bool
+	Lobby     game.Lobby
+	Phase     game.Phase
+	Player    game.Player
+	GameOver  game.Gameover
+	Meeting   game.Meeting
+	Votes     game.VoteResult
+	Kill      game.Kill
+	Tasks     game.TaskProgress
+}
+
+// StreamJob is a Job read from a connect code's stream. The ID must be passed to AckJob once the job has been
+// processed, otherwise the entry stays pending and is eventually reclaimed by another consumer. Err is set (to a
+// *RejectError) if the entry couldn't be decoded, in which case it should just be acknowledged
+type StreamJob struct {
+	ID  string
+	Job Job
+	Err error
+
+	// when the consumer popped the job, for measuring how long it takes to process
+	Popped time.Time
+	// how many times processing the job was put off, because the game state stayed locked
+	Attempts int
 }
 
 const JobTTLSeconds = 3600
 
-func PushJob(ctx context.Context, redis *redis.Client, connCode string, jobType JobType, payload string) error {
-	job := Job{
-		JobType: jobType,
-		Payload: payload,
-	}
-	jBytes, err := json.Marshal(job)
+// JobConsumerGroup is the single consumer group every bot process reads job streams through, so each job is only
+// delivered to one consumer
+const JobConsumerGroup = "automuteus"
+
+// JobStreamMaxLen bounds each stream; a game never produces anywhere near this many jobs between reads
+const JobStreamMaxLen = 1000
+
+// JobClaimMinIdle is how long a job can sit unacknowledged before another consumer is allowed to claim it
+const JobClaimMinIdle = time.Second * 30
+
+const jobField = "job"
+
+func PushJob(ctx context.Context, client *redis.Client, connCode string, jobType JobType, payload string) error {
+	job, err := NewJob(jobType, payload)
 	if err != nil {
 		return err
 	}
+	return Push(ctx, client, connCode, job)
+}
 
-	count, err := redis.RPush(ctx, rediskey.JobNamespace+connCode, string(jBytes)).Result()
-	if err == nil {
-		notify(ctx, redis, connCode)
+// Push adds an already validated job to the connect code's stream
+func Push(ctx context.Context, client *redis.Client, connCode string, job Job) error {
+	jBytes, err := json.Marshal(job)
+	if err != nil {
+		return err
 	}
 
-	// new list
-	if count < 2 {
-		// log.Printf("Set TTL for List")
-		redis.Expire(ctx, rediskey.JobNamespace+connCode, JobTTLSeconds*time.Second)
+	err = client.XAdd(ctx, &redis.XAddArgs{
+		Stream:       rediskey.JobStream(connCode),
+		MaxLenApprox: JobStreamMaxLen,
+		Values:       map[string]interface{}{jobField: string(jBytes)},
+	}).Err()
+	if err != nil {
+		return err
 	}
+	client.Expire(ctx, rediskey.JobStream(connCode), JobTTLSeconds*time.Second)
+	notify(ctx, client, connCode)
 
-	return err
+	return nil
 }
 
 func notify(ctx context.Context, redis *redis.Client, connCode string) {
@@ -57,15 +116,186 @@ func Subscribe(ctx context.Context, redis *redis.Client, connCode string) *redis
 	return redis.Subscribe(ctx, rediskey.JobNamespace+connCode+":notify")
 }
 
+// PopJob pops a job off the list-based queue that producers used before job streams. It's only kept so jobs from
+// producers that haven't been upgraded yet are still consumed. A job that was popped but fails to decode returns a
+// *RejectError
 func PopJob(ctx context.Context, redis *redis.Client, connCode string) (Job, error) {
 	str, err := redis.LPop(ctx, rediskey.JobNamespace+connCode).Result()
+	if err != nil {
+		return Job{}, err
+	}
+	return DecodeJob([]byte(str))
+}
+
+// EnsureJobGroup creates the consumer group (and the stream, if nothing has been pushed yet) for a connect code.
+// The group starts at the beginning of the stream, so jobs pushed before the group existed are still delivered
+func EnsureJobGroup(ctx context.Context, client *redis.Client, connCode string) error {
+	err := client.XGroupCreateMkStream(ctx, rediskey.JobStream(connCode), JobConsumerGroup, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return err
+	}
+	client.Expire(ctx, rediskey.JobStream(connCode), JobTTLSeconds*time.Second)
+	return nil
+}
+
+// ReadJobs reads up to count jobs that haven't been delivered to any consumer yet. It doesn't block, and returns
+// redis.Nil when there's nothing to read
+func ReadJobs(ctx context.Context, client *redis.Client, connCode, consumer string, count int64) ([]StreamJob, error) {
+	streams, err := client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    JobConsumerGroup,
+		Consumer: consumer,
+		Streams:  []string{rediskey.JobStream(connCode), ">"},
+		Count:    count,
+		Block:    -1,
+	}).Result()
+	if isNoGroup(err) {
+		// the stream expired (or was never created); recreate it so the next read succeeds
+		err = EnsureJobGroup(ctx, client, connCode)
+		if err != nil {
+			return nil, err
+		}
+		return nil, redis.Nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var jobs []StreamJob
+	for _, stream := range streams {
+		jobs = append(jobs, decodeJobs(stream.Messages)...)
+	}
+	return jobs, nil
+}
 
-	j := Job{}
+// ClaimStaleJobs claims every job that has been pending for at least minIdle, which means the consumer it was
+// delivered to died before acknowledging it
+func ClaimStaleJobs(ctx context.Context, client *redis.Client, connCode, consumer string, minIdle time.Duration) ([]StreamJob, error) {
+	pending, err := client.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: rediskey.JobStream(connCode),
+		Group:  JobConsumerGroup,
+		Start:  "-",
+		End:    "+",
+		Count:  JobStreamMaxLen,
+	}).Result()
+	if isNoGroup(err) || errors.Is(err, redis.Nil) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, v := range pending {
+		if v.Idle >= minIdle {
+			ids = append(ids, v.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	msgs, err := client.XClaim(ctx, &redis.XClaimArgs{
+		Stream:   rediskey.JobStream(connCode),
+		Group:    JobConsumerGroup,
+		Consumer: consumer,
+		MinIdle:  minIdle,
+		Messages: ids,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	return decodeJobs(msgs), nil
+}
+
+func AckJob(ctx context.Context, client *redis.Client, connCode, id string) error {
+	return client.XAck(ctx, rediskey.JobStream(connCode), JobConsumerGroup, id).Err()
+}
+
+// QueueDepth is how many of the connect code's jobs are still waiting to be processed: jobs left on the legacy list,
+// stream jobs no consumer has read yet, and stream jobs read but not acknowledged yet. Processed jobs stay in the
+// stream until it's trimmed, so its length isn't the depth
+func QueueDepth(ctx context.Context, client *redis.Client, connCode string) (int64, error) {
+	depth, err := client.LLen(ctx, rediskey.JobNamespace+connCode).Result()
 	if err != nil {
-		return j, err
+		return 0, err
 	}
-	err = json.Unmarshal([]byte(str), &j)
-	return j, err
+	groups, err := xInfoGroups(ctx, client, rediskey.JobStream(connCode))
+	if isNoStream(err) {
+		return depth, nil
+	} else if err != nil {
+		return 0, err
+	}
+	for _, group := range groups {
+		if group.Name != JobConsumerGroup {
+			continue
+		}
+		unread, err := client.XRangeN(ctx, rediskey.JobStream(connCode), "("+group.LastDeliveredID, "+", JobStreamMaxLen).Result()
+		if err != nil {
+			return 0, err
+		}
+		return depth + group.Pending + int64(len(unread)), nil
+	}
+	// nothing has been read through the group yet
+	unread, err := client.XLen(ctx, rediskey.JobStream(connCode)).Result()
+	if err != nil {
+		return 0, err
+	}
+	return depth + unread, nil
+}
+
+// xInfoGroups is XINFO GROUPS, read field by field. The client's XInfoGroups expects exactly the 4 fields Redis 6
+// replies with, and fails on Redis 7, which adds entries-read and lag
+func xInfoGroups(ctx context.Context, client *redis.Client, stream string) ([]redis.XInfoGroup, error) {
+	reply, err := client.Do(ctx, "XINFO", "GROUPS", stream).Slice()
+	if err != nil {
+		return nil, err
+	}
+	groups := make([]redis.XInfoGroup, 0, len(reply))
+	for _, v := range reply {
+		fields, ok := v.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unexpected XINFO GROUPS reply %v", v)
+		}
+		var group redis.XInfoGroup
+		for i := 0; i+1 < len(fields); i += 2 {
+			key, _ := fields[i].(string)
+			switch key {
+			case "name":
+				group.Name, _ = fields[i+1].(string)
+			case "consumers":
+				group.Consumers, _ = fields[i+1].(int64)
+			case "pending":
+				group.Pending, _ = fields[i+1].(int64)
+			case "last-delivered-id":
+				group.LastDeliveredID, _ = fields[i+1].(string)
+			}
+		}
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+// decodeJobs converts stream entries to jobs. Entries that fail to decode are still returned (with Err set), so the
+// consumer can acknowledge them; they'd otherwise be reclaimed (and fail to decode) forever
+func decodeJobs(msgs []redis.XMessage) []StreamJob {
+	jobs := make([]StreamJob, 0, len(msgs))
+	for _, msg := range msgs {
+		sj := StreamJob{ID: msg.ID}
+		str, ok := msg.Values[jobField].(string)
+		if !ok {
+			sj.Err = reject(RejectMalformed, "entry %s has no %s field", msg.ID, jobField)
+		} else {
+			sj.Job, sj.Err = DecodeJob([]byte(str))
+		}
+		jobs = append(jobs, sj)
+	}
+	return jobs
+}
+
+func isNoGroup(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "NOGROUP")
+}
+
+func isNoStream(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "no such key")
 }
 
 func Ack(ctx context.Context, redis *redis.Client, connCode string) {
@@ -0,0 +1,248 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/automuteus/automuteus/v8/pkg/rediskey"
+	"github.com/go-redis/redis/v8"
+	"testing"
+	"time"
+)
+
+const testConnCode = "ABCDEFGH"
+
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		client.Close()
+	})
+	return mr, client
+}
+
+func pushStates(t *testing.T, client *redis.Client, phases ...string) {
+	t.Helper()
+	for _, phase := range phases {
+		err := PushJob(context.Background(), client, testConnCode, StateJob, phase)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func payloads(jobs []StreamJob) []string {
+	var p []string
+	for _, sj := range jobs {
+		p = append(p, sj.Job.Payload)
+	}
+	return p
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestEnsureJobGroup(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestRedis(t)
+
+	// jobs pushed before the group exists are still delivered
+	pushStates(t, client, "1")
+	if err := EnsureJobGroup(ctx, client, testConnCode); err != nil {
+		t.Fatal(err)
+	}
+	// the group already exists, so this gets BUSYGROUP
+	if err := EnsureJobGroup(ctx, client, testConnCode); err != nil {
+		t.Errorf("Expected ensuring an existing group to succeed, got %v", err)
+	}
+
+	jobs, err := ReadJobs(ctx, client, testConnCode, "a", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalStrings(payloads(jobs), []string{"1"}) {
+		t.Errorf("Expected the job pushed before the group to be read, got %v", payloads(jobs))
+	}
+}
+
+func TestReadJobs(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestRedis(t)
+
+	// no stream or group yet; the group is created and the read comes back empty
+	_, err := ReadJobs(ctx, client, testConnCode, "a", 10)
+	if !errors.Is(err, redis.Nil) {
+		t.Fatalf("Expected redis.Nil without a group, got %v", err)
+	}
+
+	pushStates(t, client, "1", "2", "3")
+	jobs, err := ReadJobs(ctx, client, testConnCode, "a", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalStrings(payloads(jobs), []string{"1", "2"}) {
+		t.Errorf("Expected the first 2 jobs, got %v", payloads(jobs))
+	}
+
+	// another consumer only gets what hasn't been delivered yet
+	jobs, err = ReadJobs(ctx, client, testConnCode, "b", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalStrings(payloads(jobs), []string{"3"}) {
+		t.Errorf("Expected only the undelivered job, got %v", payloads(jobs))
+	}
+
+	_, err = ReadJobs(ctx, client, testConnCode, "a", 10)
+	if !errors.Is(err, redis.Nil) {
+		t.Errorf("Expected redis.Nil once everything was delivered, got %v", err)
+	}
+}
+
+func TestReadJobs_malformed(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestRedis(t)
+	if err := EnsureJobGroup(ctx, client, testConnCode); err != nil {
+		t.Fatal(err)
+	}
+	client.XAdd(ctx, &redis.XAddArgs{
+		Stream: rediskey.JobStream(testConnCode),
+		Values: map[string]interface{}{"other": "value"},
+	})
+
+	jobs, err := ReadJobs(ctx, client, testConnCode, "a", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rejectErr *RejectError
+	if len(jobs) != 1 || !errors.As(jobs[0].Err, &rejectErr) || jobs[0].ID == "" {
+		t.Errorf("Expected the malformed entry to be returned with its ID and a *RejectError, got %+v", jobs)
+	}
+}
+
+func TestClaimStaleJobs(t *testing.T) {
+	ctx := context.Background()
+	mr, client := newTestRedis(t)
+	now := time.Now()
+	mr.SetTime(now)
+
+	// nothing to claim without a group
+	jobs, err := ClaimStaleJobs(ctx, client, testConnCode, "b", JobClaimMinIdle)
+	if err != nil || len(jobs) != 0 {
+		t.Fatalf("Expected nothing to claim without a group, got %v, %v", payloads(jobs), err)
+	}
+
+	pushStates(t, client, "1", "2")
+	if err := EnsureJobGroup(ctx, client, testConnCode); err != nil {
+		t.Fatal(err)
+	}
+	// "a" reads both jobs, acknowledges the first and dies before acknowledging the second
+	read, err := ReadJobs(ctx, client, testConnCode, "a", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AckJob(ctx, client, testConnCode, read[0].ID); err != nil {
+		t.Fatal(err)
+	}
+
+	mr.SetTime(now.Add(JobClaimMinIdle - time.Second))
+	jobs, err = ClaimStaleJobs(ctx, client, testConnCode, "b", JobClaimMinIdle)
+	if err != nil || len(jobs) != 0 {
+		t.Errorf("Expected nothing to claim before the idle time, got %v, %v", payloads(jobs), err)
+	}
+
+	mr.SetTime(now.Add(JobClaimMinIdle))
+	jobs, err = ClaimStaleJobs(ctx, client, testConnCode, "b", JobClaimMinIdle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalStrings(payloads(jobs), []string{"2"}) || jobs[0].ID != read[1].ID {
+		t.Errorf("Expected the unacknowledged job to be claimed, got %v", payloads(jobs))
+	}
+
+	// claiming resets the idle time, so it isn't claimed again straight away
+	jobs, err = ClaimStaleJobs(ctx, client, testConnCode, "c", JobClaimMinIdle)
+	if err != nil || len(jobs) != 0 {
+		t.Errorf("Expected a just-claimed job not to be claimed again, got %v, %v", payloads(jobs), err)
+	}
+}
+
+func TestAckJob(t *testing.T) {
+	ctx := context.Background()
+	mr, client := newTestRedis(t)
+	now := time.Now()
+	mr.SetTime(now)
+
+	pushStates(t, client, "1")
+	if err := EnsureJobGroup(ctx, client, testConnCode); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadJobs(ctx, client, testConnCode, "a", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AckJob(ctx, client, testConnCode, read[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	pending, err := client.XPending(ctx, rediskey.JobStream(testConnCode), JobConsumerGroup).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pending.Count != 0 {
+		t.Errorf("Expected no pending jobs after acknowledging, got %d", pending.Count)
+	}
+
+	mr.SetTime(now.Add(JobClaimMinIdle))
+	jobs, err := ClaimStaleJobs(ctx, client, testConnCode, "b", JobClaimMinIdle)
+	if err != nil || len(jobs) != 0 {
+		t.Errorf("Expected an acknowledged job never to be claimed, got %v, %v", payloads(jobs), err)
+	}
+}
+
+func TestQueueDepth(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestRedis(t)
+
+	depth, err := QueueDepth(ctx, client, testConnCode)
+	if err != nil || depth != 0 {
+		t.Errorf("Expected a depth of 0 without a stream, got %d, %v", depth, err)
+	}
+
+	pushStates(t, client, "1", "2", "3")
+	client.RPush(ctx, rediskey.JobNamespace+testConnCode, "legacy")
+	depth, err = QueueDepth(ctx, client, testConnCode)
+	if err != nil || depth != 4 {
+		t.Errorf("Expected a depth of 4 before the group exists, got %d, %v", depth, err)
+	}
+
+	if err := EnsureJobGroup(ctx, client, testConnCode); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadJobs(ctx, client, testConnCode, "a", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// read jobs still count until they're acknowledged
+	depth, err = QueueDepth(ctx, client, testConnCode)
+	if err != nil || depth != 4 {
+		t.Errorf("Expected a depth of 4 after reading, got %d, %v", depth, err)
+	}
+
+	if err := AckJob(ctx, client, testConnCode, read[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	depth, err = QueueDepth(ctx, client, testConnCode)
+	if err != nil || depth != 3 {
+		t.Errorf("Expected a depth of 3 after acknowledging a job, got %d, %v", depth, err)
+	}
+}
@@ -13,11 +13,15 @@ type UserModify struct {
 	UserID uint64 `json:"userID"`
 	Mute   bool   `json:"mute"`
 	Deaf   bool   `json:"deaf"`
+	// ChannelID is the voice channel to move the user to, if they should be moved
+	ChannelID string `json:"channelID,omitempty"`
 }
 
 type UserModifyRequest struct {
 	Premium premium.Tier `json:"premium"`
 	Users   []UserModify `json:"users"`
+	// Phase is the name of the game phase that triggered the request, for the voice log
+	Phase string `json:"phase,omitempty"`
 }
 
 type ModifyTask struct {
@@ -43,14 +47,22 @@ func NewModifyTask(guildID, userID uint64, params PatchParams) ModifyTask {
 }
 
 type PatchParams struct {
-	Deaf bool `json:"deaf"`
-	Mute bool `json:"mute"`
+	Deaf      bool   `json:"deaf"`
+	Mute      bool   `json:"mute"`
+	ChannelID string `json:"channel_id,omitempty"`
 }
 
 func ApplyMuteDeaf(sess *discordgo.Session, guildID, userID string, mute, deaf bool) error {
+	return ApplyUserModify(sess, guildID, userID, UserModify{Mute: mute, Deaf: deaf})
+}
+
+// ApplyUserModify mutes/deafens the user, and moves them to another voice channel if the request has one, in a single
+// request to the member endpoint
+func ApplyUserModify(sess *discordgo.Session, guildID, userID string, request UserModify) error {
 	p := PatchParams{
-		Deaf: deaf,
-		Mute: mute,
+		Deaf:      request.Deaf,
+		Mute:      request.Mute,
+		ChannelID: request.ChannelID,
 	}
 
 	_, err := sess.RequestWithBucketID("PATCH", discordgo.EndpointGuildMember(guildID, userID), p, discordgo.EndpointGuildMember(guildID, ""))
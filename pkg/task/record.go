@@ -0,0 +1,69 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/automuteus/automuteus/v8/pkg/rediskey"
+	"github.com/go-redis/redis/v8"
+	"strconv"
+	"time"
+)
+
+// RecordingMaxLen bounds how many jobs are kept in a game's recording
+const RecordingMaxLen = 5000
+
+// RecordingTTL is how long a recording is kept after the last job was recorded
+const RecordingTTL = time.Hour * 24
+
+// RecordedJob is a job as it was received by the bot, so the sequence of jobs for a game can be replayed exactly
+type RecordedJob struct {
+	Time time.Time `json:"time"`
+	Job  Job       `json:"job"`
+}
+
+// RecordJob appends a job to the guild's recording for a connect code. The oldest jobs are trimmed once the recording
+// exceeds RecordingMaxLen
+func RecordJob(ctx context.Context, client *redis.Client, guildID, connCode string, job Job, t time.Time) error {
+	jBytes, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	err = client.XAdd(ctx, &redis.XAddArgs{
+		Stream:       rediskey.JobRecording(guildID, connCode),
+		MaxLenApprox: RecordingMaxLen,
+		Values: map[string]interface{}{
+			jobField: string(jBytes),
+			"time":   t.UnixMilli(),
+		},
+	}).Err()
+	if err != nil {
+		return err
+	}
+	return client.Expire(ctx, rediskey.JobRecording(guildID, connCode), RecordingTTL).Err()
+}
+
+// GetRecording returns every job the guild recorded for a connect code, oldest first. It's empty for another guild's
+// games
+func GetRecording(ctx context.Context, client *redis.Client, guildID, connCode string) ([]RecordedJob, error) {
+	msgs, err := client.XRange(ctx, rediskey.JobRecording(guildID, connCode), "-", "+").Result()
+	if err != nil {
+		return nil, err
+	}
+	jobs := make([]RecordedJob, 0, len(msgs))
+	for _, msg := range msgs {
+		var rj RecordedJob
+		str, _ := msg.Values[jobField].(string)
+		err = json.Unmarshal([]byte(str), &rj.Job)
+		if err != nil {
+			return nil, err
+		}
+		tStr, _ := msg.Values["time"].(string)
+		ms, err := strconv.ParseInt(tStr, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		rj.Time = time.UnixMilli(ms)
+		jobs = append(jobs, rj)
+	}
+	return jobs, nil
+}
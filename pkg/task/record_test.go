@@ -0,0 +1,38 @@
+package task
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestGetRecording(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestRedis(t)
+	now := time.UnixMilli(1650000000000)
+
+	job, err := NewJob(StateJob, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := RecordJob(ctx, client, "1", testConnCode, job, now); err != nil {
+		t.Fatal(err)
+	}
+
+	recording, err := GetRecording(ctx, client, "1", testConnCode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recording) != 1 || recording[0].Job.Payload != "1" || !recording[0].Time.Equal(now) {
+		t.Errorf("Expected the recorded job back, got %+v", recording)
+	}
+
+	// another guild asking for the same connect code mustn't get this guild's recording
+	recording, err = GetRecording(ctx, client, "2", testConnCode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recording) != 0 {
+		t.Errorf("Expected no recording for another guild, got %+v", recording)
+	}
+}
@@ -0,0 +1,192 @@
+package task
+
+import (
+	"context"
+	"github.com/automuteus/automuteus/v8/pkg/rediskey"
+	"github.com/go-redis/redis/v8"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// JobReorderTimeout is how long a job that arrived ahead of its sequence is held back, waiting for the jobs before
+// it. After that, the missing jobs are assumed lost, and are skipped
+const JobReorderTimeout = 2 * time.Second
+
+// Gap is a range of sequence numbers [From, To] from a source that were never received
+type Gap struct {
+	Source string
+	From   uint64
+	To     uint64
+}
+
+func (g Gap) Len() uint64 {
+	return g.To - g.From + 1
+}
+
+type heldJob struct {
+	job   StreamJob
+	until time.Time
+}
+
+// Sequencer puts sequenced jobs from a single source back in order, and drops duplicates. Jobs without a sequence
+// number (from producers that don't send one) are passed straight through. A source's count starts over every time it
+// connects, so a client that restarts isn't mistaken for one resending old jobs
+type Sequencer struct {
+	source string
+	// last is the highest sequence number that has been released
+	last uint64
+	// started is whether last is known. A sequencer that wasn't resumed doesn't know where its source's count is,
+	// so the jobs in front of the first one it sees aren't reported missing
+	started bool
+	held    map[uint64]heldJob
+}
+
+func NewSequencer(source string, last uint64) *Sequencer {
+	return &Sequencer{
+		source:  source,
+		last:    last,
+		started: last > 0,
+		held:    make(map[uint64]heldJob),
+	}
+}
+
+func (s *Sequencer) Last() uint64 {
+	return s.last
+}
+
+// Offer adds a job to the sequencer, and returns the jobs that are ready to be processed (in order). duplicate is
+// true if the job was already released or is already being held, in which case it should just be discarded. Jobs
+// that aren't next in the sequence are held for up to JobReorderTimeout, including the first one a new sequencer sees
+// (unless it's the first of its session), in case the ones before it arrive late
+func (s *Sequencer) Offer(sj StreamJob, now time.Time) (ready []StreamJob, duplicate bool) {
+	if sj.Job.JobType == ConnectionJob && sj.Job.Connected {
+		return s.restart(sj), false
+	}
+	seq := sj.Job.Seq
+	if seq == 0 {
+		return []StreamJob{sj}, false
+	}
+	if h, held := s.held[seq]; held && h.job.ID != "" && h.job.ID == sj.ID {
+		// the same entry, reclaimed while it was being held
+		return nil, false
+	} else if held || seq <= s.last {
+		return nil, true
+	}
+	if seq != s.last+1 {
+		s.held[seq] = heldJob{
+			job:   sj,
+			until: now.Add(JobReorderTimeout),
+		}
+		return nil, false
+	}
+	s.last = seq
+	s.started = true
+	return append([]StreamJob{sj}, s.releaseContiguous()...), false
+}
+
+// restart starts a new session for the source, which counts from the connection job. Jobs still held from the
+// previous session arrived before the connection job, so they're released ahead of it
+func (s *Sequencer) restart(sj StreamJob) []StreamJob {
+	var ready []StreamJob
+	for {
+		first, ok := s.firstHeld()
+		if !ok {
+			break
+		}
+		ready = append(ready, s.held[first].job)
+		delete(s.held, first)
+	}
+	s.last = sj.Job.Seq
+	s.started = true
+	return append(ready, sj)
+}
+
+// Expire skips over the gaps in front of held jobs that have waited at least JobReorderTimeout, and returns the jobs
+// that are ready to be processed as a result, along with the gaps that were skipped
+func (s *Sequencer) Expire(now time.Time) (ready []StreamJob, gaps []Gap) {
+	for {
+		first, ok := s.firstHeld()
+		if !ok || now.Before(s.held[first].until) {
+			return ready, gaps
+		}
+		if s.started {
+			gaps = append(gaps, Gap{Source: s.source, From: s.last + 1, To: first - 1})
+		}
+		s.last = first - 1
+		s.started = true
+		ready = append(ready, s.releaseContiguous()...)
+	}
+}
+
+// NextExpiry returns when the earliest held job expires, or false if no jobs are being held
+func (s *Sequencer) NextExpiry() (time.Time, bool) {
+	var next time.Time
+	for _, h := range s.held {
+		if next.IsZero() || h.until.Before(next) {
+			next = h.until
+		}
+	}
+	return next, !next.IsZero()
+}
+
+func (s *Sequencer) firstHeld() (uint64, bool) {
+	if len(s.held) == 0 {
+		return 0, false
+	}
+	seqs := make([]uint64, 0, len(s.held))
+	for seq := range s.held {
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	return seqs[0], true
+}
+
+func (s *Sequencer) releaseContiguous() []StreamJob {
+	var ready []StreamJob
+	for {
+		h, ok := s.held[s.last+1]
+		if !ok {
+			return ready
+		}
+		delete(s.held, s.last+1)
+		s.last++
+		ready = append(ready, h.job)
+	}
+}
+
+// NextSequence returns the next sequence number for a connect code, for producers that don't keep their own count
+func NextSequence(ctx context.Context, client *redis.Client, connCode string) (uint64, error) {
+	seq, err := client.Incr(ctx, rediskey.JobNextSequence(connCode)).Result()
+	if err != nil {
+		return 0, err
+	}
+	client.Expire(ctx, rediskey.JobNextSequence(connCode), JobTTLSeconds*time.Second)
+	return uint64(seq), nil
+}
+
+// GetLastSequences returns the highest sequence number processed from each source for a connect code, so a new
+// subscription can pick up where another consumer left off
+func GetLastSequences(ctx context.Context, client *redis.Client, connCode string) (map[string]uint64, error) {
+	all, err := client.HGetAll(ctx, rediskey.JobLastSequence(connCode)).Result()
+	if err != nil {
+		return nil, err
+	}
+	lastSeqs := make(map[string]uint64, len(all))
+	for source, str := range all {
+		seq, err := strconv.ParseUint(str, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		lastSeqs[source] = seq
+	}
+	return lastSeqs, nil
+}
+
+func SetLastSequence(ctx context.Context, client *redis.Client, connCode, source string, seq uint64) error {
+	err := client.HSet(ctx, rediskey.JobLastSequence(connCode), source, seq).Err()
+	if err != nil {
+		return err
+	}
+	return client.Expire(ctx, rediskey.JobLastSequence(connCode), JobTTLSeconds*time.Second).Err()
+}
@@ -0,0 +1,170 @@
+package task
+
+import (
+	"testing"
+	"time"
+)
+
+func seqJob(seq uint64) StreamJob {
+	return StreamJob{Job: Job{Seq: seq}}
+}
+
+func seqs(jobs []StreamJob) []uint64 {
+	var s []uint64
+	for _, sj := range jobs {
+		s = append(s, sj.Job.Seq)
+	}
+	return s
+}
+
+func equalSeqs(a, b []uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSequencer_Offer(t *testing.T) {
+	now := time.Now()
+	s := NewSequencer("", 0)
+
+	ready, dup := s.Offer(seqJob(1), now)
+	if dup || !equalSeqs(seqs(ready), []uint64{1}) {
+		t.Errorf("Expected job 1 to be ready, got %v (duplicate %v)", seqs(ready), dup)
+	}
+	ready, dup = s.Offer(seqJob(3), now)
+	if dup || len(ready) != 0 {
+		t.Errorf("Expected job 3 to be held, got %v (duplicate %v)", seqs(ready), dup)
+	}
+	_, dup = s.Offer(seqJob(3), now)
+	if !dup {
+		t.Error("Expected a second job 3 to be a duplicate")
+	}
+	ready, dup = s.Offer(seqJob(2), now)
+	if dup || !equalSeqs(seqs(ready), []uint64{2, 3}) {
+		t.Errorf("Expected jobs 2 and 3 to be ready, got %v (duplicate %v)", seqs(ready), dup)
+	}
+	_, dup = s.Offer(seqJob(1), now)
+	if !dup {
+		t.Error("Expected a resent job 1 to be a duplicate")
+	}
+	ready, dup = s.Offer(seqJob(0), now)
+	if dup || len(ready) != 1 {
+		t.Error("Expected an unsequenced job to be passed straight through")
+	}
+	if s.Last() != 3 {
+		t.Errorf("Expected the last sequence to be 3, got %d", s.Last())
+	}
+}
+
+func TestSequencer_Resume(t *testing.T) {
+	s := NewSequencer("", 5)
+	_, dup := s.Offer(seqJob(5), time.Now())
+	if !dup {
+		t.Error("Expected job 5 to be a duplicate after resuming from 5")
+	}
+	ready, _ := s.Offer(seqJob(7), time.Now())
+	if len(ready) != 0 {
+		t.Errorf("Expected job 7 to be held after resuming from 5, got %v", seqs(ready))
+	}
+}
+
+func TestSequencer_Expire(t *testing.T) {
+	now := time.Now()
+	s := NewSequencer("", 1)
+	s.Offer(seqJob(4), now)
+	s.Offer(seqJob(5), now.Add(time.Second))
+	s.Offer(seqJob(8), now.Add(time.Second))
+
+	next, ok := s.NextExpiry()
+	if !ok || !next.Equal(now.Add(JobReorderTimeout)) {
+		t.Errorf("Expected the next expiry to be %v, got %v", now.Add(JobReorderTimeout), next)
+	}
+	ready, gaps := s.Expire(now)
+	if len(ready) != 0 || len(gaps) != 0 {
+		t.Errorf("Expected nothing to expire yet, got %v and %v", seqs(ready), gaps)
+	}
+
+	ready, gaps = s.Expire(now.Add(JobReorderTimeout))
+	if !equalSeqs(seqs(ready), []uint64{4, 5}) {
+		t.Errorf("Expected jobs 4 and 5 to be ready, got %v", seqs(ready))
+	}
+	if len(gaps) != 1 || gaps[0] != (Gap{From: 2, To: 3}) || gaps[0].Len() != 2 {
+		t.Errorf("Expected a gap of 2-3, got %v", gaps)
+	}
+
+	ready, gaps = s.Expire(now.Add(time.Second + JobReorderTimeout))
+	if !equalSeqs(seqs(ready), []uint64{8}) || len(gaps) != 1 || gaps[0] != (Gap{From: 6, To: 7}) {
+		t.Errorf("Expected job 8 to be ready after a gap of 6-7, got %v and %v", seqs(ready), gaps)
+	}
+	if _, ok := s.NextExpiry(); ok {
+		t.Error("Expected no jobs to be held")
+	}
+}
+
+func connectJob(seq uint64) StreamJob {
+	return StreamJob{Job: Job{Seq: seq, JobType: ConnectionJob, Connected: true}}
+}
+
+func TestSequencer_Restart(t *testing.T) {
+	now := time.Now()
+	s := NewSequencer("", 40)
+	s.Offer(seqJob(42), now)
+
+	// the client restarted, so its count starts over after the connection job
+	ready, dup := s.Offer(connectJob(0), now)
+	if dup || len(ready) != 2 || ready[0].Job.Seq != 42 || ready[1].Job.JobType != ConnectionJob {
+		t.Errorf("Expected the held job 42 to be released ahead of the connection job, got %v (duplicate %v)", seqs(ready), dup)
+	}
+	if s.Last() != 0 {
+		t.Errorf("Expected the last sequence to be reset, got %d", s.Last())
+	}
+	ready, dup = s.Offer(seqJob(1), now)
+	if dup || !equalSeqs(seqs(ready), []uint64{1}) {
+		t.Errorf("Expected job 1 of the new session to be ready, got %v (duplicate %v)", seqs(ready), dup)
+	}
+	ready, _ = s.Offer(seqJob(3), now)
+	if len(ready) != 0 {
+		t.Errorf("Expected job 3 of the new session to be held, got %v", seqs(ready))
+	}
+	_, gaps := s.Expire(now.Add(JobReorderTimeout))
+	if len(gaps) != 1 || gaps[0] != (Gap{From: 2, To: 2}) {
+		t.Errorf("Expected a gap of 2-2 in the new session, got %v", gaps)
+	}
+}
+
+func TestSequencer_FirstJobsSwapped(t *testing.T) {
+	now := time.Now()
+	s := NewSequencer("", 0)
+
+	ready, dup := s.Offer(seqJob(2), now)
+	if dup || len(ready) != 0 {
+		t.Errorf("Expected the first job seen to be held, got %v (duplicate %v)", seqs(ready), dup)
+	}
+	ready, dup = s.Offer(seqJob(1), now)
+	if dup || !equalSeqs(seqs(ready), []uint64{1, 2}) {
+		t.Errorf("Expected jobs 1 and 2 to be ready, got %v (duplicate %v)", seqs(ready), dup)
+	}
+}
+
+func TestSequencer_FirstJobMidSession(t *testing.T) {
+	now := time.Now()
+	s := NewSequencer("", 0)
+	s.Offer(seqJob(7), now)
+	s.Offer(seqJob(8), now)
+
+	// nothing says where the source's count was, so jobs 1-6 aren't reported missing
+	ready, gaps := s.Expire(now.Add(JobReorderTimeout))
+	if !equalSeqs(seqs(ready), []uint64{7, 8}) || len(gaps) != 0 {
+		t.Errorf("Expected jobs 7 and 8 to be ready without any gaps, got %v and %v", seqs(ready), gaps)
+	}
+	_, dup := s.Offer(seqJob(5), now)
+	if !dup {
+		t.Error("Expected a job from before the first one processed to be a duplicate")
+	}
+}
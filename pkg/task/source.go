@@ -0,0 +1,260 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"github.com/automuteus/automuteus/v8/pkg/rediskey"
+	"github.com/go-redis/redis/v8"
+	"strconv"
+	"time"
+)
+
+// SourceFailoverTimeout is how long an event reported by a secondary capture client can go unreported by the primary
+// before the secondary takes over. The primary being quiet isn't enough, since nothing happens for long stretches of
+// a game, and a secondary's copy of an event can always beat the primary's
+const SourceFailoverTimeout = 5 * time.Second
+
+// SourceDedupWindow is how long an event from the primary is remembered, so the same event reported by a secondary
+// can be recognized as a duplicate
+const SourceDedupWindow = 10 * time.Second
+
+// Admission is what Sources decided about a job
+type Admission struct {
+	// Process is true if the job should be processed; otherwise it duplicates (or is superseded by) the primary's
+	Process bool
+	// Primary is the primary source after the job, and Changed is true if the job changed it
+	Primary string
+	Changed bool
+}
+
+type sourceState struct {
+	connected bool
+	lastSeen  time.Time
+}
+
+type recentEvent struct {
+	key string
+	at  time.Time
+}
+
+// Sources arbitrates between several capture clients attached to the same connect code. Each source's jobs are put
+// in order by its own Sequencer, and then only the primary source's jobs are processed. Secondaries take over when
+// the primary disconnects, or stops reporting the events they do
+type Sources struct {
+	sequencers map[string]*Sequencer
+	sources    map[string]*sourceState
+	primary    string
+	elected    bool
+	recent     []recentEvent
+	// events reported by secondaries that the primary hasn't reported yet, oldest first
+	unreported []recentEvent
+	// jobs that couldn't be processed yet, which go ahead of any newer jobs once they're retried
+	requeued []StreamJob
+	retryAt  time.Time
+}
+
+// NewSources resumes from the last sequence number processed for each source
+func NewSources(lastSeqs map[string]uint64) *Sources {
+	s := &Sources{
+		sequencers: make(map[string]*Sequencer),
+		sources:    make(map[string]*sourceState),
+	}
+	for source, last := range lastSeqs {
+		s.sequencers[source] = NewSequencer(source, last)
+	}
+	return s
+}
+
+// Sequencer returns the sequencer for a source's jobs
+func (s *Sources) Sequencer(source string) *Sequencer {
+	seq, ok := s.sequencers[source]
+	if !ok {
+		seq = NewSequencer(source, 0)
+		s.sequencers[source] = seq
+	}
+	return seq
+}
+
+// Expire expires held jobs for every source; see Sequencer.Expire
+func (s *Sources) Expire(now time.Time) (ready []StreamJob, gaps []Gap) {
+	for _, seq := range s.sequencers {
+		r, g := seq.Expire(now)
+		ready = append(ready, r...)
+		gaps = append(gaps, g...)
+	}
+	return ready, gaps
+}
+
+// NextExpiry returns when the earliest held job for any source expires (or requeued jobs should be retried), or false
+// if no jobs are waiting
+func (s *Sources) NextExpiry() (time.Time, bool) {
+	var next time.Time
+	if len(s.requeued) > 0 {
+		next = s.retryAt
+	}
+	for _, seq := range s.sequencers {
+		if t, ok := seq.NextExpiry(); ok && (next.IsZero() || t.Before(next)) {
+			next = t
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// Requeue puts off jobs that were released in order but couldn't be processed yet, to be retried at retryAt. They
+// aren't offered to the sequencers again, since those have already released them
+func (s *Sources) Requeue(jobs []StreamJob, retryAt time.Time) {
+	s.requeued = append([]StreamJob(nil), jobs...)
+	s.retryAt = retryAt
+}
+
+// Requeued removes and returns the jobs that were put off, which should be processed ahead of any jobs released since
+func (s *Sources) Requeued() []StreamJob {
+	jobs := s.requeued
+	s.requeued = nil
+	return jobs
+}
+
+// Admit decides whether a job (already put in order by its source's sequencer) should be processed
+func (s *Sources) Admit(job Job, now time.Time) Admission {
+	state, ok := s.sources[job.Source]
+	if !ok {
+		state = &sourceState{connected: true}
+		s.sources[job.Source] = state
+	}
+	state.lastSeen = now
+	if job.JobType == ConnectionJob {
+		state.connected = job.Connected
+	}
+
+	changed := false
+	if !s.elected {
+		s.elect(job.Source)
+		changed = true
+	}
+
+	if job.Source == s.primary {
+		if job.JobType == ConnectionJob && !job.Connected {
+			if next, ok := s.nextPrimary(); ok {
+				// the game stays linked through the secondary
+				s.elect(next)
+				return s.admission(false, true)
+			}
+			// nothing to fail over to, so the next source to report anything is elected
+			s.elected = false
+			return s.admission(true, changed)
+		}
+		s.remember(job, now)
+		return s.admission(true, changed)
+	}
+
+	// secondaries connecting and disconnecting don't affect the game
+	if job.JobType == ConnectionJob || s.seen(job, now) {
+		return s.admission(false, false)
+	}
+	s.unreport(job, now)
+	if now.Sub(s.unreported[0].at) >= SourceFailoverTimeout {
+		// the primary still hasn't reported something a secondary did long ago, so it's stuck or gone
+		s.elect(job.Source)
+		s.remember(job, now)
+		return s.admission(true, true)
+	}
+	// the primary is probably just lagging behind; it'll report this too
+	return s.admission(false, false)
+}
+
+func (s *Sources) admission(process, changed bool) Admission {
+	return Admission{
+		Process: process,
+		Primary: s.primary,
+		Changed: changed,
+	}
+}
+
+func (s *Sources) elect(source string) {
+	s.primary = source
+	s.elected = true
+	s.unreported = nil
+}
+
+// nextPrimary returns the connected secondary that was heard from most recently
+func (s *Sources) nextPrimary() (string, bool) {
+	next, found := "", false
+	for source, state := range s.sources {
+		if source == s.primary || !state.connected {
+			continue
+		}
+		if !found || state.lastSeen.After(s.sources[next].lastSeen) {
+			next, found = source, true
+		}
+	}
+	return next, found
+}
+
+func eventKey(job Job) string {
+	return strconv.Itoa(int(job.JobType)) + ":" + job.Payload
+}
+
+// remember records an event the primary reported, so secondaries reporting it aren't waiting on the primary for it
+func (s *Sources) remember(job Job, now time.Time) {
+	s.prune(now)
+	key := eventKey(job)
+	s.recent = append(s.recent, recentEvent{
+		key: key,
+		at:  now,
+	})
+	i := 0
+	for _, e := range s.unreported {
+		if e.key != key {
+			s.unreported[i] = e
+			i++
+		}
+	}
+	s.unreported = s.unreported[:i]
+}
+
+// unreport records an event a secondary reported that the primary hasn't, keeping when it was first reported
+func (s *Sources) unreport(job Job, now time.Time) {
+	key := eventKey(job)
+	for _, e := range s.unreported {
+		if e.key == key {
+			return
+		}
+	}
+	s.unreported = append(s.unreported, recentEvent{
+		key: key,
+		at:  now,
+	})
+}
+
+func (s *Sources) seen(job Job, now time.Time) bool {
+	s.prune(now)
+	key := eventKey(job)
+	for _, e := range s.recent {
+		if e.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Sources) prune(now time.Time) {
+	i := 0
+	for i < len(s.recent) && now.Sub(s.recent[i].at) > SourceDedupWindow {
+		i++
+	}
+	s.recent = s.recent[i:]
+}
+
+// GetPrimarySource returns the capture client currently elected as the game's primary source, or "" if there's none
+// (or the game only has a single client, through the broker)
+func GetPrimarySource(ctx context.Context, client *redis.Client, connCode string) (string, error) {
+	source, err := client.Get(ctx, rediskey.CapturePrimarySource(connCode)).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	return source, err
+}
+
+func SetPrimarySource(ctx context.Context, client *redis.Client, connCode, source string) error {
+	return client.Set(ctx, rediskey.CapturePrimarySource(connCode), source, JobTTLSeconds*time.Second).Err()
+}
@@ -0,0 +1,139 @@
+package task
+
+import (
+	"testing"
+	"time"
+)
+
+func sourceJob(t *testing.T, source string, jobType JobType, payload string) Job {
+	job, err := NewJob(jobType, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job.Source = source
+	return job
+}
+
+func TestSources_Admit(t *testing.T) {
+	now := time.Now()
+	s := NewSources(nil)
+
+	a := s.Admit(sourceJob(t, "a", ConnectionJob, "true"), now)
+	if !a.Process || !a.Changed || a.Primary != "a" {
+		t.Errorf("Expected the first source to be elected primary, got %+v", a)
+	}
+	a = s.Admit(sourceJob(t, "b", ConnectionJob, "true"), now)
+	if a.Process || a.Changed {
+		t.Errorf("Expected a secondary connecting to be ignored, got %+v", a)
+	}
+
+	a = s.Admit(sourceJob(t, "a", StateJob, "1"), now.Add(time.Second))
+	if !a.Process {
+		t.Errorf("Expected a job from the primary to be processed, got %+v", a)
+	}
+	a = s.Admit(sourceJob(t, "b", StateJob, "1"), now.Add(2*time.Second))
+	if a.Process || a.Changed {
+		t.Errorf("Expected the same event from a secondary to be dropped, got %+v", a)
+	}
+	a = s.Admit(sourceJob(t, "b", StateJob, "2"), now.Add(2*time.Second))
+	if a.Process || a.Changed {
+		t.Errorf("Expected a new event from a secondary to be dropped while the primary is active, got %+v", a)
+	}
+
+	a = s.Admit(sourceJob(t, "b", StateJob, "3"), now.Add(time.Second+SourceFailoverTimeout))
+	if a.Process || a.Changed {
+		t.Errorf("Expected the secondary not to take over before its event went unreported for the timeout, got %+v", a)
+	}
+
+	// the primary never reported 2, so the secondary takes over
+	a = s.Admit(sourceJob(t, "b", StateJob, "4"), now.Add(2*time.Second+SourceFailoverTimeout))
+	if !a.Process || !a.Changed || a.Primary != "b" {
+		t.Errorf("Expected the secondary to take over once the primary missed its event, got %+v", a)
+	}
+	a = s.Admit(sourceJob(t, "a", StateJob, "4"), now.Add(3*time.Second+SourceFailoverTimeout))
+	if a.Process || a.Changed {
+		t.Errorf("Expected the old primary's late event to be dropped, got %+v", a)
+	}
+}
+
+func TestSources_AdmitIdlePrimary(t *testing.T) {
+	now := time.Now()
+	s := NewSources(nil)
+	s.Admit(sourceJob(t, "a", ConnectionJob, "true"), now)
+	s.Admit(sourceJob(t, "b", ConnectionJob, "true"), now)
+
+	// nothing happened for a while, and the secondary's copy of the next event beats the primary's
+	later := now.Add(2 * SourceFailoverTimeout)
+	a := s.Admit(sourceJob(t, "b", StateJob, "1"), later)
+	if a.Process || a.Changed {
+		t.Errorf("Expected the secondary's event to wait for the idle primary, got %+v", a)
+	}
+	a = s.Admit(sourceJob(t, "a", StateJob, "1"), later.Add(100*time.Millisecond))
+	if !a.Process || a.Changed || a.Primary != "a" {
+		t.Errorf("Expected the idle primary to stay primary, got %+v", a)
+	}
+
+	// the primary reported it, so the secondary has nothing to take over with later on
+	a = s.Admit(sourceJob(t, "b", StateJob, "2"), later.Add(SourceFailoverTimeout))
+	if a.Process || a.Changed {
+		t.Errorf("Expected the secondary not to take over with an event the primary just hasn't reported yet, got %+v", a)
+	}
+}
+
+func TestSources_AdmitDisconnect(t *testing.T) {
+	now := time.Now()
+	s := NewSources(nil)
+	s.Admit(sourceJob(t, "a", ConnectionJob, "true"), now)
+	s.Admit(sourceJob(t, "b", ConnectionJob, "true"), now)
+
+	a := s.Admit(sourceJob(t, "a", ConnectionJob, "false"), now)
+	if a.Process || !a.Changed || a.Primary != "b" {
+		t.Errorf("Expected a failover to the secondary without unlinking the game, got %+v", a)
+	}
+	a = s.Admit(sourceJob(t, "b", ConnectionJob, "false"), now)
+	if !a.Process || a.Changed {
+		t.Errorf("Expected the last source disconnecting to unlink the game, got %+v", a)
+	}
+	a = s.Admit(sourceJob(t, "a", ConnectionJob, "true"), now)
+	if !a.Process || !a.Changed || a.Primary != "a" {
+		t.Errorf("Expected the next source to connect to be elected, got %+v", a)
+	}
+}
+
+func TestSources_Sequencer(t *testing.T) {
+	s := NewSources(map[string]uint64{"a": 4})
+	if s.Sequencer("a").Last() != 4 || s.Sequencer("b").Last() != 0 {
+		t.Error("Expected each source's sequencer to resume from its own last sequence")
+	}
+	s.Sequencer("a").Offer(StreamJob{Job: Job{Seq: 6}}, time.Now())
+	if _, ok := s.NextExpiry(); !ok {
+		t.Error("Expected a held job")
+	}
+	ready, gaps := s.Expire(time.Now().Add(JobReorderTimeout))
+	if len(ready) != 1 || len(gaps) != 1 || gaps[0].Source != "a" {
+		t.Errorf("Expected job 6 from a to be released after a gap, got %v and %v", ready, gaps)
+	}
+}
+
+func TestSources_Requeue(t *testing.T) {
+	s := NewSources(nil)
+	now := time.Now()
+	s.Sequencer("a").Offer(StreamJob{Job: Job{Seq: 3}}, now)
+	jobs := []StreamJob{{Job: Job{Seq: 1}}, {Job: Job{Seq: 2}}}
+	s.Requeue(jobs[:1], now.Add(time.Second))
+	jobs[0].Job.Seq = 5
+	if next, ok := s.NextExpiry(); !ok || !next.Equal(now.Add(time.Second)) {
+		t.Errorf("Expected the requeued jobs to be retried before the held job expires, got %v", next)
+	}
+
+	requeued := s.Requeued()
+	if len(requeued) != 1 || requeued[0].Job.Seq != 1 {
+		t.Errorf("Expected the requeued job, got %v", requeued)
+	}
+	if len(s.Requeued()) != 0 {
+		t.Error("Expected the requeued jobs to be removed once they're taken")
+	}
+	if next, ok := s.NextExpiry(); !ok || !next.Equal(now.Add(JobReorderTimeout)) {
+		t.Errorf("Expected only the held job to be waiting, got %v", next)
+	}
+}
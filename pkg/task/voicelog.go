@@ -0,0 +1,113 @@
+package task
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/automuteus/automuteus/v8/pkg/rediskey"
+	"github.com/go-redis/redis/v8"
+	"strings"
+	"time"
+)
+
+// VoiceLogMaxLen bounds how many voice operations are kept in a guild's voice log
+const VoiceLogMaxLen = 1000
+
+// VoiceLogTTL is how long a guild's voice log is kept after the last operation was recorded
+const VoiceLogTTL = time.Hour * 24 * 7
+
+// the ways a voice operation can be applied
+const (
+	VoicePathWorker   = "worker"
+	VoicePathCapture  = "capture"
+	VoicePathOfficial = "official"
+)
+
+const voiceOpField = "op"
+
+// VoiceOp is a single mute/deafen (or move) request the bot sent for a user, and how it went
+type VoiceOp struct {
+	Time        time.Time `json:"time"`
+	ConnectCode string    `json:"connectCode"`
+	UserID      uint64    `json:"userID"`
+	Mute        bool      `json:"mute"`
+	Deaf        bool      `json:"deaf"`
+	ChannelID   string    `json:"channelID,omitempty"`
+	// Path is how the request was ultimately sent (VoicePathWorker, VoicePathCapture or VoicePathOfficial)
+	Path string `json:"path"`
+	// TokenHash identifies the worker bot the request was sent with, if any
+	TokenHash string `json:"tokenHash,omitempty"`
+	LatencyMs int64  `json:"latencyMs"`
+	Error     string `json:"error,omitempty"`
+	// Phase is the game phase that triggered the request
+	Phase string `json:"phase,omitempty"`
+}
+
+// RecordVoiceOp appends an operation to the guild's voice log. The oldest operations are trimmed once the log exceeds
+// VoiceLogMaxLen
+func RecordVoiceOp(ctx context.Context, client *redis.Client, guildID string, op VoiceOp) error {
+	jBytes, err := json.Marshal(op)
+	if err != nil {
+		return err
+	}
+	err = client.XAdd(ctx, &redis.XAddArgs{
+		Stream:       rediskey.VoiceLog(guildID),
+		MaxLenApprox: VoiceLogMaxLen,
+		Values: map[string]interface{}{
+			voiceOpField: string(jBytes),
+		},
+	}).Err()
+	if err != nil {
+		return err
+	}
+	return client.Expire(ctx, rediskey.VoiceLog(guildID), VoiceLogTTL).Err()
+}
+
+// GetVoiceLog returns the guild's most recent voice operations, newest first. A count of 0 returns all of them
+func GetVoiceLog(ctx context.Context, client *redis.Client, guildID string, count int64) ([]VoiceOp, error) {
+	var msgs []redis.XMessage
+	var err error
+	if count > 0 {
+		msgs, err = client.XRevRangeN(ctx, rediskey.VoiceLog(guildID), "+", "-", count).Result()
+	} else {
+		msgs, err = client.XRevRange(ctx, rediskey.VoiceLog(guildID), "+", "-").Result()
+	}
+	if err != nil {
+		return nil, err
+	}
+	ops := make([]VoiceOp, 0, len(msgs))
+	for _, msg := range msgs {
+		var op VoiceOp
+		str, _ := msg.Values[voiceOpField].(string)
+		err = json.Unmarshal([]byte(str), &op)
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
+
+// FilterVoiceOpsByUser returns just the operations for the user
+func FilterVoiceOpsByUser(ops []VoiceOp, userID uint64) []VoiceOp {
+	var filtered []VoiceOp
+	for _, op := range ops {
+		if op.UserID == userID {
+			filtered = append(filtered, op)
+		}
+	}
+	return filtered
+}
+
+// VoiceOpsToCSV formats the operations for /download, in the same shape as the other downloadable data
+func VoiceOpsToCSV(ops []VoiceOp) string {
+	s := bytes.NewBufferString("time_unix_ms,connect_code,user_id,mute,deaf,channel_id,path,token_hash,latency_ms,error,phase,\n")
+	for _, v := range ops {
+		// errors can contain commas and quotes, so quote them the CSV way
+		s.WriteString(fmt.Sprintf("%d,%s,%d,%t,%t,%s,%s,%s,%d,\"%s\",%s,\n",
+			v.Time.UnixMilli(), v.ConnectCode, v.UserID, v.Mute, v.Deaf, v.ChannelID, v.Path, v.TokenHash, v.LatencyMs,
+			strings.ReplaceAll(v.Error, `"`, `""`), v.Phase))
+	}
+	return s.String()
+}
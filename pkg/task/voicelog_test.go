@@ -0,0 +1,36 @@
+package task
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVoiceOpsToCSV(t *testing.T) {
+	ops := []VoiceOp{
+		{
+			Time:        time.UnixMilli(1650000000000),
+			ConnectCode: "ABCDEFGH",
+			UserID:      1,
+			Mute:        true,
+			Path:        VoicePathWorker,
+			TokenHash:   "abc",
+			LatencyMs:   80,
+			Phase:       "TASKS",
+		},
+		{
+			Time:        time.UnixMilli(1650000001000),
+			ConnectCode: "ABCDEFGH",
+			UserID:      2,
+			Path:        VoicePathOfficial,
+			LatencyMs:   300,
+			Error:       `HTTP 403 Forbidden, {"message": "Missing Permissions"}`,
+			Phase:       "LOBBY",
+		},
+	}
+	expected := "time_unix_ms,connect_code,user_id,mute,deaf,channel_id,path,token_hash,latency_ms,error,phase,\n" +
+		"1650000000000,ABCDEFGH,1,true,false,,worker,abc,80,\"\",TASKS,\n" +
+		"1650000001000,ABCDEFGH,2,false,false,,official,,300,\"HTTP 403 Forbidden, {\"\"message\"\": \"\"Missing Permissions\"\"}\",LOBBY,\n"
+	if csv := VoiceOpsToCSV(ops); csv != expected {
+		t.Errorf("Expected CSV:\n%s\ngot:\n%s", expected, csv)
+	}
+}
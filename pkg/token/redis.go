@@ -4,21 +4,20 @@ import (
 	"context"
 	"github.com/automuteus/automuteus/v8/pkg/rediskey"
 	"github.com/go-redis/redis/v8"
-	"log"
 	"time"
 )
 
 func LockForToken(client *redis.Client, token string) {
-	log.Println("Locking token for 5 seconds")
+	logger.Info("Locking token for 5 seconds")
 	err := client.Set(context.Background(), rediskey.BotTokenIdentifyLock(token), "", time.Second*5).Err()
 	if err != nil {
-		log.Println(err)
+		logger.Error("Error locking token", err)
 	}
 }
 
 func WaitForToken(client *redis.Client, token string) {
 	for IsTokenLocked(client, token) {
-		log.Println("Sleeping for 5 seconds while waiting for token to become available")
+		logger.Info("Sleeping for 5 seconds while waiting for token to become available")
 		time.Sleep(time.Second * 5)
 	}
 }
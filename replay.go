@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/automuteus/automuteus/v8/bot"
+	"github.com/automuteus/automuteus/v8/pkg/game"
+	"github.com/automuteus/automuteus/v8/pkg/locale"
+	"github.com/automuteus/automuteus/v8/pkg/settings"
+	"github.com/automuteus/automuteus/v8/pkg/task"
+	"os"
+)
+
+const replayCommand = "replay"
+
+// replayMain replays a recording downloaded with /debug view recording, and prints the mutes/deafens and game
+// message edits the bot would make for it. Nothing is sent to Discord, Redis or Postgres
+func replayMain(args []string) error {
+	fs := flag.NewFlagSet(replayCommand, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s %s [flags] <recording.json>\n", os.Args[0], replayCommand)
+		fs.PrintDefaults()
+	}
+	settingsPath := fs.String("settings", "", "guild settings to replay with, as JSON (defaults to the default settings)")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("exactly one recording file must be provided")
+	}
+
+	contents, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	var recording []task.RecordedJob
+	err = json.Unmarshal(contents, &recording)
+	if err != nil {
+		return err
+	}
+
+	sett := settings.MakeGuildSettings()
+	if *settingsPath != "" {
+		contents, err = os.ReadFile(*settingsPath)
+		if err != nil {
+			return err
+		}
+		err = json.Unmarshal(contents, sett)
+		if err != nil {
+			return err
+		}
+	}
+
+	locale.InitLang(os.Getenv("LOCALE_PATH"), os.Getenv("BOT_LANG"))
+
+	// modded lobbies can have roles the bot doesn't know about
+	if rolesPath := os.Getenv("CUSTOM_ROLES_PATH"); rolesPath != "" {
+		err := game.LoadRoles(rolesPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	bot.Replay(recording, sett, bot.TextReplaySink{Writer: os.Stdout})
+	return nil
+}
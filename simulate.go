@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/automuteus/automuteus/v8/internal/logging"
+	"github.com/automuteus/automuteus/v8/pkg/simulate"
+	"github.com/go-redis/redis/v8"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+const simulateCommand = "simulate"
+
+// simulateMain pushes a scripted sequence of capture jobs for a game, so the bot's job processing (voice rules,
+// stats, etc.) can be exercised against a local Redis and Postgres without running Among Us or a capture client.
+// Start a game with /new first, and pass its connect code with -code (or set connectCode in the script)
+func simulateMain(args []string) error {
+	fs := flag.NewFlagSet(simulateCommand, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s %s [flags] <script.yaml|script.json>\n", os.Args[0], simulateCommand)
+		fs.PrintDefaults()
+	}
+	connectCode := fs.String("code", "", "connect code of the game to push jobs to (overrides the script's connectCode)")
+	redisAddr := fs.String("redis", os.Getenv("REDIS_ADDR"), "redis address (defaults to REDIS_ADDR)")
+	redisPassword := fs.String("redis-pass", os.Getenv("REDIS_PASS"), "redis password (defaults to REDIS_PASS)")
+	speed := fs.Float64("speed", 1, "playback speed multiplier for the delays between steps")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("exactly one script file must be provided")
+	}
+	if *redisAddr == "" {
+		return errors.New("no REDIS_ADDR specified; exiting")
+	}
+
+	scriptPath := fs.Arg(0)
+	contents, err := os.ReadFile(scriptPath)
+	if err != nil {
+		return err
+	}
+	script, err := simulate.ParseScript(scriptPath, contents)
+	if err != nil {
+		return err
+	}
+	if *connectCode != "" {
+		script.ConnectCode = *connectCode
+	}
+	if script.ConnectCode == "" {
+		return errors.New("no connect code provided; use -code or set connectCode in the script")
+	}
+
+	client := redis.NewClient(&redis.Options{
+		Addr:     *redisAddr,
+		Password: *redisPassword,
+		DB:       0, // use default DB
+	})
+	defer client.Close()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM, os.Interrupt)
+	defer cancel()
+
+	logger.Info("Simulating jobs", "jobs", len(script.Steps), logging.ConnectCodeKey, script.ConnectCode)
+	err = simulate.Run(ctx, client, script.ConnectCode, script, *speed)
+	if err != nil {
+		return err
+	}
+	logger.Info("Finished simulating all jobs", logging.ConnectCodeKey, script.ConnectCode)
+	return nil
+}
@@ -0,0 +1,7 @@
+package storage
+
+import (
+	"github.com/automuteus/automuteus/v8/internal/logging"
+)
+
+var logger = logging.For("storage")
@@ -15,9 +15,25 @@ create table if not exists games
     connect_code CHAR(8) NOT NULL,
     start_time   integer NOT NULL,                            --2038 problem, but I do not care
     win_type     smallint,                                    --imposter win, crewmate win, etc
-    end_time     integer                                      --2038 problem, but I do not care
+    end_time     integer,                                     --2038 problem, but I do not care
+    game_mode    smallint NOT NULL default 0,                 --classic, hide and seek
+    num_impostors   smallint,                                 --lobby options; null if the capture didn't report them
+    discussion_time smallint,
+    voting_time     smallint,
+    kill_cooldown   real,
+    confirm_ejects  boolean,
+    player_speed    real
 );
 
+-- add game_mode and lobby option columns if not exists due to upgrade from 8.x or earlier
+alter table games add column if not exists game_mode smallint NOT NULL default 0;
+alter table games add column if not exists num_impostors smallint;
+alter table games add column if not exists discussion_time smallint;
+alter table games add column if not exists voting_time smallint;
+alter table games add column if not exists kill_cooldown real;
+alter table games add column if not exists confirm_ejects boolean;
+alter table games add column if not exists player_speed real;
+
 -- links userIDs to their hashed variants. Allows for deletion of users without deleting underlying game_event data
 create table if not exists users
 (
@@ -46,11 +62,39 @@ create table if not exists users_games
     game_id bigint REFERENCES games ON DELETE CASCADE, --if a game is deleted, delete all linked users_games
     player_name VARCHAR(10) NOT NULL,
     player_color smallint NOT NULL,
-    player_role smallint NOT NULL,
+    player_role smallint NOT NULL, --a game.GameRole; teams are resolved from the role registry
     player_won bool NOT NULL,
     PRIMARY KEY (user_id, game_id)
 );
 
+-- in-game names users have registered as theirs with /link alias. Matched on the normalized alias_key
+create table if not exists user_aliases
+(
+    guild_id numeric REFERENCES guilds ON DELETE CASCADE,
+    user_id numeric REFERENCES users ON DELETE CASCADE,
+    alias VARCHAR(32) NOT NULL,
+    alias_key VARCHAR(32) NOT NULL,
+    PRIMARY KEY (guild_id, alias_key)
+);
+
+-- mute/deafen/move requests sent for players; only recorded when VOICE_LOG_POSTGRES is set
+create table if not exists voice_ops
+(
+    op_id bigserial PRIMARY KEY,
+    guild_id numeric REFERENCES guilds ON DELETE CASCADE, --if a guild is deleted, delete its voice ops
+    connect_code VARCHAR(32) NOT NULL, --not always a game's code; /debug unmute uses its own
+    user_id numeric NOT NULL,
+    mute bool NOT NULL,
+    deaf bool NOT NULL,
+    channel_id VARCHAR(20),
+    path VARCHAR(10) NOT NULL, --worker, capture or official
+    token_hash CHAR(64),
+    latency_ms integer NOT NULL,
+    error TEXT,
+    phase VARCHAR(16),
+    op_time_ms bigint NOT NULL
+);
+
 create index if not exists guilds_id_index ON guilds (guild_id); --query guilds by ID
 create index if not exists guilds_premium_index ON guilds (premium); --query guilds by prem status
 
@@ -68,4 +112,8 @@ create index if not exists users_games_role_index ON users_games (player_role);
 create index if not exists users_games_won_index ON users_games (player_won); --query games by win status
 
 create index if not exists game_events_game_id_index on game_events (game_id); --query for game events by the game ID
-create index if not exists game_events_user_id_index on game_events (user_id); --query for game events by the user ID
\ No newline at end of file
+create index if not exists game_events_user_id_index on game_events (user_id); --query for game events by the user ID
+
+create index if not exists user_aliases_user_id_index on user_aliases (guild_id, user_id); --query aliases by the user
+
+create index if not exists voice_ops_guild_id_index on voice_ops (guild_id); --query voice ops by guild ID
@@ -4,10 +4,10 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"github.com/automuteus/automuteus/v8/internal/logging"
 	"github.com/automuteus/automuteus/v8/pkg/rediskey"
 	"github.com/automuteus/automuteus/v8/pkg/settings"
 	"github.com/go-redis/redis/v8"
-	"log"
 )
 
 var ctx = context.Background()
@@ -50,22 +50,22 @@ func (storageInterface *StorageInterface) GetGuildSettings(guildID string) *sett
 		s := settings.MakeGuildSettings()
 		jBytes, err := json.MarshalIndent(s, "", "  ")
 		if err != nil {
-			log.Println(err)
+			logger.Error("Error marshalling the default guild settings", err, logging.GuildIDKey, guildID)
 			return settings.MakeGuildSettings()
 		}
 		err = storageInterface.client.Set(ctx, key, jBytes, 0).Err()
 		if err != nil {
-			log.Println(err)
+			logger.Error("Error saving the default guild settings", err, logging.GuildIDKey, guildID)
 		}
 		return s
 	case err != nil:
-		log.Println(err)
+		logger.Error("Error getting the guild settings", err, logging.GuildIDKey, guildID)
 		return settings.MakeGuildSettings()
 	default:
 		s := settings.GuildSettings{}
 		err := json.Unmarshal([]byte(j), &s)
 		if err != nil {
-			log.Println(err)
+			logger.Error("Error unmarshalling the guild settings", err, logging.GuildIDKey, guildID)
 			return settings.MakeGuildSettings()
 		}
 		return &s